@@ -0,0 +1,146 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/report"
+)
+
+var compareOpts struct {
+	format string
+	output string
+}
+
+func init() {
+	compareCmd.Flags().StringVar(&compareOpts.format, "format", report.FormatText,
+		"format of the report: text, markdown, or html")
+	compareCmd.Flags().StringVarP(&compareOpts.output, "output", "o", "",
+		"file to which the report is written (default stdout)")
+	rootCmd.AddCommand(compareCmd)
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare [VARIANT=]FILE... ",
+	Short: "Compare the KPIs of runs of two or more variants (e.g., schedulers)",
+	Long: `Compare the KPIs of runs of two or more variants (e.g., schedulers), each of which may have
+runs with different seeds.
+
+Each argument is a file written by a metrics logger with the json formatter, prefixed by the name
+of its variant and "=". Files may be given by glob patterns (e.g., fifo='runs/fifo-*.log'), and
+default to variants named after the files. The first variant is the baseline.
+
+Markdown reports link the plots written to SVG files next to the report (or in the current
+directory if the report is written to stdout).`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		variants, err := loadVariants(args)
+		if err != nil {
+			return err
+		}
+
+		comparison, err := report.Compare(variants)
+		if err != nil {
+			return err
+		}
+
+		switch compareOpts.format {
+		case report.FormatText:
+			return writeOutput(compareOpts.output, comparison.WriteText)
+		case report.FormatMarkdown:
+			plotPath, err := writePlots(compareOpts.output, comparison.Plots())
+			if err != nil {
+				return err
+			}
+			return writeOutput(compareOpts.output, func(w io.Writer) error {
+				return comparison.WriteMarkdown(w, plotPath)
+			})
+		case report.FormatHTML:
+			return writeOutput(compareOpts.output, comparison.WriteHTML)
+		default:
+			return fmt.Errorf("Unknown format %q", compareOpts.format)
+		}
+	},
+}
+
+// loadVariants loads the runs of the variants from the arguments, in the order of their first
+// appearances.
+func loadVariants(args []string) ([]report.Variant, error) {
+	variants := []report.Variant{}
+	indices := map[string]int{}
+
+	for _, arg := range args {
+		name, pattern := "", arg
+		if i := strings.Index(arg, "="); i >= 0 {
+			name, pattern = arg[:i], arg[i+1:]
+		}
+
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("No file matches %q", pattern)
+		}
+
+		for _, path := range paths {
+			run, err := report.LoadRun(path)
+			if err != nil {
+				return nil, err
+			}
+
+			variantName := name
+			if variantName == "" {
+				variantName = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			}
+			i, ok := indices[variantName]
+			if !ok {
+				i = len(variants)
+				indices[variantName] = i
+				variants = append(variants, report.Variant{Name: variantName})
+			}
+			variants[i].Runs = append(variants[i].Runs, run)
+		}
+	}
+
+	return variants, nil
+}
+
+// writePlots writes the plots to SVG files named after the output (e.g., report-pending-pods.svg
+// for report.md), and returns the function that returns the path of the file of each plot relative
+// to the output.
+func writePlots(output string, plots []report.Plot) (func(report.Plot) string, error) {
+	dir, prefix := ".", "report"
+	if output != "" {
+		dir = filepath.Dir(output)
+		prefix = strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	}
+
+	fileName := func(plot report.Plot) string { return prefix + "-" + plot.Name + ".svg" }
+	for _, plot := range plots {
+		if err := ioutil.WriteFile(filepath.Join(dir, fileName(plot)), []byte(plot.SVG), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return fileName, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kubesim-report generates reports from the outputs of simulation runs.
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/containerd/containerd/log"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		log.L.WithError(err).Fatal("Error executing root command")
+	}
+}
+
+var rootCmd = &cobra.Command{
+	Use:           "kubesim-report",
+	Short:         "kubesim-report generates reports from the outputs of k8s-cluster-simulator runs.",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+// writeOutput writes a report by the write function to the file at the path, or to stdout if the
+// path is empty.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
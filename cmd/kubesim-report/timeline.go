@@ -0,0 +1,90 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/report"
+)
+
+var timelineOpts struct {
+	lanes   string
+	color   string
+	metrics string
+	output  string
+}
+
+func init() {
+	timelineCmd.Flags().StringVar(&timelineOpts.lanes, "lanes", report.LanesNode,
+		"lanes of the timeline: node, or gpu for a lane per GPU device")
+	timelineCmd.Flags().StringVar(&timelineOpts.color, "color", report.ColorByNamespace,
+		"color of the pods: namespace or priority")
+	timelineCmd.Flags().StringVar(&timelineOpts.metrics, "metrics", "",
+		"file written by a metrics logger with the json formatter in the same run, from which the "+
+			"allocatable resources of the nodes are read (default utilization relative to its peak)")
+	timelineCmd.Flags().StringVarP(&timelineOpts.output, "output", "o", "",
+		"file to which the HTML report is written (default stdout)")
+	rootCmd.AddCommand(timelineCmd)
+}
+
+var timelineCmd = &cobra.Command{
+	Use:   "timeline FILE",
+	Short: "Render the placement of pods on nodes over time as an HTML Gantt chart",
+	Long: `Render the placement of pods on nodes over time as a self-contained HTML Gantt chart, with
+a lane per node (or per GPU device) and a bar per bound pod, over the curves of the number of pending
+pods and the utilization of the resources.
+
+FILE is the pod event log written to podEventLog.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		events, err := report.LoadPodEvents(args[0])
+		if err != nil {
+			return err
+		}
+
+		opts := report.TimelineOptions{Lanes: timelineOpts.lanes, ColorBy: timelineOpts.color}
+		if timelineOpts.metrics != "" {
+			run, err := report.LoadRun(timelineOpts.metrics)
+			if err != nil {
+				return err
+			}
+			opts.Capacity = totalAllocatable(run)
+		}
+
+		timeline, err := report.BuildTimeline(events, opts)
+		if err != nil {
+			return err
+		}
+
+		return writeOutput(timelineOpts.output, timeline.WriteHTML)
+	},
+}
+
+// totalAllocatable returns the total allocatable resources of the nodes at the first metrics of the
+// run.
+func totalAllocatable(run *report.Run) v1.ResourceList {
+	total := v1.ResourceList{}
+	for _, nodeMet := range run.Metrics[0].Nodes {
+		for rsrc, q := range nodeMet.Allocatable {
+			sum := total[rsrc]
+			sum.Add(q)
+			total[rsrc] = sum
+		}
+	}
+
+	return total
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+// gpuIdleCollector is a metrics.Collector that computes the ratio of the GPUs requested but not used
+// by the pods, over the cluster and on each node.
+type gpuIdleCollector struct{}
+
+type gpuIdleRatio struct {
+	Cluster float64
+	Nodes   map[string]float64
+}
+
+func (c *gpuIdleCollector) Collect(
+	_ clock.Clock,
+	nodes map[string]node.Metrics,
+	_ []queue.PodQueue,
+	_ map[string]*pod.Pod) (string, interface{}) {
+
+	const gpu = v1.ResourceName("nvidia.com/gpu")
+
+	ratio := gpuIdleRatio{Nodes: map[string]float64{}}
+	totalReq, totalUsage := int64(0), int64(0)
+	for name, met := range nodes {
+		req := met.TotalResourceRequest[gpu]
+		usage := met.TotalResourceUsage[gpu]
+		if req.Value() == 0 {
+			continue
+		}
+
+		ratio.Nodes[name] = 1 - float64(usage.Value())/float64(req.Value())
+		totalReq += req.Value()
+		totalUsage += usage.Value()
+	}
+	if totalReq > 0 {
+		ratio.Cluster = 1 - float64(totalUsage)/float64(totalReq)
+	}
+
+	return "GPUIdleRatio", ratio
+}
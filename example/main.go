@@ -58,10 +58,16 @@ var rootCmd = &cobra.Command{
 		sched := buildScheduler() // see below
 		kubesim := kubesim.NewKubeSimFromConfigPathOrDie(configPath, queue, sched)
 
+		// Volume predicates need the persistent volumes and claims of the simulated cluster.
+		addVolumePredicates(sched, kubesim)
+
 		// 2. Register one or more pod submitters to KubeSim.
 		numOfSubmittingPods := 8
 		kubesim.AddSubmitter("MySubmitter", newMySubmitter(numOfSubmittingPods))
 
+		// Optionally, register collectors of custom metrics, which are written with the others.
+		kubesim.AddCollector(&gpuIdleCollector{})
+
 		// 3. Run the main loop of KubeSim.
 		//    In each execution of the loop, KubeSim
 		//      1) stores pods submitted from the registered submitters to its queue,
@@ -74,7 +80,7 @@ var rootCmd = &cobra.Command{
 	},
 }
 
-func buildScheduler() scheduler.Scheduler {
+func buildScheduler() *scheduler.GenericScheduler {
 	// 1. Create a generic scheduler that mimics a kube-scheduler.
 	sched := scheduler.NewGenericScheduler( /* preemption enabled */ true)
 
@@ -105,10 +111,26 @@ func buildScheduler() scheduler.Scheduler {
 		Reduce: nil,
 		Weight: 1,
 	})
+	sched.AddPrioritizer(priorities.PriorityConfig{
+		Name:   "ImageLocality",
+		Map:    priorities.ImageLocalityPriorityMap,
+		Reduce: nil,
+		Weight: 1,
+	})
 
 	return &sched
 }
 
+func addVolumePredicates(sched *scheduler.GenericScheduler, kubesim *kubesim.KubeSim) {
+	volumes := kubesim.Volumes()
+	sched.AddPredicate(predicates.CheckVolumeBindingPred,
+		predicates.NewVolumeBindingPredicate(volumes.VolumeBinder()))
+	sched.AddPredicate(predicates.MaxGCEPDVolumeCountPred,
+		predicates.NewMaxPDVolumeCountPredicate(predicates.GCEPDVolumeFilterType, volumes, volumes))
+	sched.AddPredicate(predicates.NoVolumeZoneConflictPred,
+		predicates.NewVolumeZonePredicate(volumes, volumes, volumes))
+}
+
 func newInterruptableContext() context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -26,7 +26,6 @@ import (
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
-	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/submitter"
 )
 
@@ -49,8 +48,7 @@ func (s *mySubmitter) Submit(
 	_ algorithm.NodeLister,
 	met metrics.Metrics) ([]submitter.Event, error) {
 
-	queueMetrics := met[metrics.QueueMetricsKey].(queue.Metrics)
-	submissionNum := s.targetPodsNum - queueMetrics.PendingPodsNum
+	submissionNum := s.targetPodsNum - met.Queue.PendingPodsNum
 	if submissionNum <= 0 {
 		return []submitter.Event{}, nil
 	}
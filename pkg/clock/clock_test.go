@@ -15,6 +15,7 @@
 package clock_test
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -83,3 +84,28 @@ func TestClockBefore(t *testing.T) {
 		t.Errorf("got: false\nwant: true")
 	}
 }
+
+func TestClockJSON(t *testing.T) {
+	time0, _ := time.Parse(time.RFC3339, "2018-01-01T12:30:15+09:00")
+	clock0 := clock.NewClock(time0)
+
+	data, err := json.Marshal(clock0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"2018-01-01T12:30:15+09:00"` {
+		t.Errorf("got: %s\nwant: %s", data, `"2018-01-01T12:30:15+09:00"`)
+	}
+
+	var actual clock.Clock
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.Sub(clock0) != 0 {
+		t.Errorf("got: %v\nwant: %v", actual, clock0)
+	}
+
+	if err := json.Unmarshal([]byte(`"2018-01-01"`), &actual); err == nil {
+		t.Errorf("got: nil\nwant: error")
+	}
+}
@@ -15,27 +15,101 @@
 package config
 
 import (
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cpuguy83/strongerrors"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
 )
 
 // Config represents a user-specified simulator config.
 type Config struct {
-	LogLevel      string
-	Tick          int
-	StartClock    string
-	MetricsTick   int
-	MetricsLogger []MetricsLoggerConfig
-	Cluster       []NodeConfig
+	LogLevel           string
+	Tick               int
+	StartClock         string
+	MetricsTick        int
+	MetricsLogger      []MetricsLoggerConfig
+	BindConflictPolicy string
+	Cluster            []NodeConfig
+	Failures           []FailureConfig
+	GPUAllocator       string
+	Maintenance        []MaintenanceConfig
+	// PodDisruptionBudgets limit the voluntary evictions of pods by drains.
+	PodDisruptionBudgets []PodDisruptionBudgetConfig
+	// NodeMonitorGracePeriod is the time in seconds for which a node condition must hold before the
+	// node is tainted by it (default: 40).
+	NodeMonitorGracePeriod int
+	// DefaultTolerationSeconds is the tolerationSeconds of the toleration to the not-ready NoExecute
+	// taint added to submitted pods (default: 300).
+	DefaultTolerationSeconds int
+	// PodStartup is the model of the startup latency of pods.
+	PodStartup PodStartupConfig
+	// StorageClasses, PersistentVolumes, and PersistentVolumeClaims are the storage of the cluster,
+	// which are used by pods via persistentVolumeClaim volumes.
+	StorageClasses         []StorageClassConfig
+	PersistentVolumes      []PersistentVolumeConfig
+	PersistentVolumeClaims []PersistentVolumeClaimConfig
+	// PodEventLog is an output device or file path in which the lifecycle events of pods are written
+	// in JSON Lines format (default: not written).
+	PodEventLog string
 }
 
+const (
+	// BindConflictOverCapacity is a bind conflict policy with which a pod bound to a node that
+	// cannot accommodate it fails to start with OverCapacity status.
+	BindConflictOverCapacity = "overCapacity"
+	// BindConflictRetry is a bind conflict policy with which a pod bound to a node that cannot
+	// accommodate it is returned to the queue of its scheduler.
+	BindConflictRetry = "retry"
+)
+
+const (
+	// MetricsFormatterOpenMetrics is a formatter of metrics loggers that writes metrics in the
+	// OpenMetrics text format, to a file or an HTTP endpoint.
+	MetricsFormatterOpenMetrics = "openMetrics"
+	// MetricsFormatterCSV is a formatter of metrics loggers that writes metrics as tables in the long
+	// format to CSV files in a directory.
+	MetricsFormatterCSV = "csv"
+	// MetricsFormatterParquet is a formatter of metrics loggers that writes metrics as tables in the
+	// long format to Parquet files in a directory.
+	MetricsFormatterParquet = "parquet"
+)
+
+const (
+	// PersistentVolumeLocal is a type of persistent volumes local to a node.
+	PersistentVolumeLocal = "local"
+	// PersistentVolumeGCEPersistentDisk is a type of persistent volumes of GCE persistent disks.
+	PersistentVolumeGCEPersistentDisk = "gcePersistentDisk"
+	// PersistentVolumeAWSElasticBlockStore is a type of persistent volumes of AWS EBS volumes.
+	PersistentVolumeAWSElasticBlockStore = "awsElasticBlockStore"
+	// PersistentVolumeAzureDisk is a type of persistent volumes of Azure data disks.
+	PersistentVolumeAzureDisk = "azureDisk"
+	// PersistentVolumeCinder is a type of persistent volumes of OpenStack Cinder volumes.
+	PersistentVolumeCinder = "cinder"
+)
+
+const (
+	// GPUAllocatorFirstFit selects node.FirstFitAllocator.
+	GPUAllocatorFirstFit = "firstFit"
+	// GPUAllocatorBestFit selects node.BestFitAllocator.
+	GPUAllocatorBestFit = "bestFit"
+	// GPUAllocatorTopologyAware selects node.TopologyAwareAllocator.
+	GPUAllocatorTopologyAware = "topologyAware"
+)
+
 // Made public to be parsed from YAML.
 
 type MetricsLoggerConfig struct {
@@ -49,22 +123,235 @@ type NodeConfig struct {
 	Metadata metav1.ObjectMeta
 	Spec     v1.NodeSpec
 	Status   NodeStatus
+	Topology NodeTopology
+	Devices  DevicesConfig
+	Eviction EvictionConfig
+	// OOMKill enables the OOM killer of the node, which kills pods exceeding their memory limits,
+	// and pods in the order of their QoS classes while the node runs out of memory.
+	OOMKill bool
+	// ImagePullBandwidth is the bandwidth in bytes per second with which the node pulls images
+	// (e.g., 100Mi).
+	ImagePullBandwidth string
+	// Images is the images cached on the node at the start of the simulation.
+	Images []string
 }
 
 type NodeStatus struct {
 	Allocatable map[v1.ResourceName]string
 }
 
-// BuildMetricsLogger builds metrics.FileWriter with the given MetricsLoggerConfig.
-// Returns error if the config is invalid or failed to create a FileWriter.
-func BuildMetricsLogger(conf []MetricsLoggerConfig) ([]*metrics.FileWriter, error) {
-	writers := make([]*metrics.FileWriter, 0, len(conf))
+// NodeTopology is the failure domains that a node belongs to.
+// The node is labeled with the domains of the non-empty levels.
+type NodeTopology struct {
+	Region string
+	Zone   string
+	Rack   string
+	// Host is the value of kubernetes.io/hostname label, which defaults to the name of the node.
+	Host string
+}
+
+// DevicesConfig is the device model of a node.
+type DevicesConfig struct {
+	GPUs []GPUConfig
+	// CrossIslandSlowdown is the factor by which the execution of a pod is stretched when the GPUs
+	// assigned to it span multiple islands.
+	CrossIslandSlowdown float64
+	// TimeSlicingSlowdown is the factor by which the execution of a pod is stretched when a GPU
+	// assigned to it is shared by time-slicing.
+	TimeSlicingSlowdown float64
+	// RepartitionSeconds is the time for which a GPU is unavailable when its MIG partition layout is
+	// changed.
+	RepartitionSeconds int
+}
+
+// GPUConfig is a GPU device of a node.
+type GPUConfig struct {
+	Memory string
+	// Island is the interconnect island (e.g., NVLink domain) of the GPU.
+	Island int
+	// MIG is the MIG profiles that partition the GPU (e.g., [3g.20gb, 2g.10gb, 1g.5gb]).
+	MIG []string
+	// Replicas is the number of pods that can share the GPU by time-slicing.
+	Replicas int
+}
+
+// PodStartupConfig is the model of the startup latency of pods.
+type PodStartupConfig struct {
+	// ContainerStartSeconds is the time to create and start the containers of a pod after its init
+	// containers.
+	ContainerStartSeconds int
+	// Images is the sizes of the images whose sizes are not annotated in pods.
+	Images []ImageConfig
+}
+
+// ImageConfig is the size of an image.
+type ImageConfig struct {
+	Name string
+	Size string
+}
+
+// EvictionConfig is the kubelet eviction of a node.
+type EvictionConfig struct {
+	Thresholds []EvictionThresholdConfig
+	// PressureTransitionPeriod is the time in seconds for which the node keeps its pressure condition
+	// after the pressure is relieved (default: 300).
+	PressureTransitionPeriod int
+}
+
+// EvictionThresholdConfig is an eviction threshold of the kubelet.
+type EvictionThresholdConfig struct {
+	// Signal is an eviction signal: memory.available or nodefs.available.
+	Signal string
+	// Value is the threshold as a quantity (e.g., 100Mi) or a percentage of the allocatable resource
+	// (e.g., 10%).
+	Value string
+	// GracePeriod is the time in seconds for which the signal must stay below the threshold before
+	// pods are evicted.
+	// The threshold is hard if it is zero, or soft otherwise.
+	GracePeriod int
+}
+
+// FailureConfig is a correlated failure of all nodes in a topology domain.
+type FailureConfig struct {
+	// Level is a topology level: region, zone, rack, or host.
+	Level string
+	// Domain is the name of the failed domain at the level.
+	Domain string
+	// At is the time at which the nodes fail, in seconds after the start of the simulation.
+	At int
+	// Duration is the time until the nodes recover, in seconds.
+	// The nodes never recover if it is zero.
+	Duration int
+}
+
+// MaintenanceConfig is an operation on a node at runtime.
+type MaintenanceConfig struct {
+	// At is the time at which the operation is done, in seconds after the start of the simulation.
+	At int
+	// Node is the name of the node.
+	Node string
+	// Operation is the type of the operation: cordon, uncordon, drain, taint, untaint, or condition.
+	Operation string
+	// Taint is the taint to add or remove, required by taint and untaint operations.
+	Taint TaintConfig
+	// Condition is the node condition to set, required by condition operations.
+	Condition ConditionConfig
+}
+
+// ConditionConfig is a condition of a node.
+type ConditionConfig struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// TaintConfig is a taint of a node.
+type TaintConfig struct {
+	Key    string
+	Value  string
+	Effect string
+}
+
+// PodDisruptionBudgetConfig is a pod disruption budget, which limits the number of the pods
+// matching Selector in Namespace that drains evict at a time.
+// Exactly one of MinAvailable and MaxUnavailable must be given, as an integer or a percentage
+// (e.g., "50%").
+type PodDisruptionBudgetConfig struct {
+	Namespace      string
+	Name           string
+	Selector       map[string]string
+	MinAvailable   string
+	MaxUnavailable string
+}
+
+// StorageClassConfig is a storage class.
+type StorageClassConfig struct {
+	Name string
+	// VolumeBindingMode is Immediate (default) or WaitForFirstConsumer.
+	VolumeBindingMode string
+	// AttachSeconds is the time to attach a volume of the class to a node before the pod using it
+	// starts.
+	AttachSeconds int
+}
+
+// PersistentVolumeConfig is a pre-provisioned persistent volume.
+type PersistentVolumeConfig struct {
+	Name             string
+	StorageClassName string
+	Capacity         string
+	// AccessModes is the access modes of the volume (default: [ReadWriteOnce]).
+	AccessModes []string
+	// Type is the type of the volume: local (default), gcePersistentDisk, awsElasticBlockStore,
+	// azureDisk, or cinder.
+	Type string
+	// Zone and Region are the failure domains of the volume, which is accessible only from the
+	// nodes in them.
+	Zone   string
+	Region string
+	// Node is the name of the node from which the volume is accessible only.
+	Node string
+}
+
+// PersistentVolumeClaimConfig is a persistent volume claim.
+type PersistentVolumeClaimConfig struct {
+	Namespace        string
+	Name             string
+	StorageClassName string
+	// Request is the storage size requested by the claim.
+	Request string
+	// AccessModes is the access modes requested by the claim (default: [ReadWriteOnce]).
+	AccessModes []string
+}
+
+// BuildPodEventLogger builds metrics.PodEventFileWriter with the given destination.
+// Returns nil if dest is empty, or error if failed to create a PodEventFileWriter.
+func BuildPodEventLogger(dest string) (*metrics.PodEventFileWriter, error) {
+	if dest == "" {
+		return nil, nil
+	}
+
+	return metrics.NewPodEventFileWriter(dest)
+}
+
+// BuildMetricsLogger builds metrics writers with the given MetricsLoggerConfig: a
+// metrics.FileWriter with the formatter, or with the openMetrics formatter, a
+// metrics.OpenMetricsServer if the destination is an http:// URL, or a metrics.OpenMetricsFileWriter
+// otherwise, or with the csv or parquet formatter, a metrics.ColumnarWriter writing to the
+// destination directory.
+// Returns error if the config is invalid or failed to create a writer.
+func BuildMetricsLogger(conf []MetricsLoggerConfig) ([]metrics.Writer, error) {
+	writers := make([]metrics.Writer, 0, len(conf))
 
 	for _, conf := range conf {
 		if conf.Dest == "" {
 			return nil, strongerrors.InvalidArgument(errors.New("destination must not be empty"))
 		}
 
+		switch conf.Formatter {
+		case MetricsFormatterOpenMetrics:
+			writer, err := buildOpenMetricsWriter(conf.Dest)
+			if err != nil {
+				return nil, err
+			}
+			writers = append(writers, writer)
+			continue
+		case MetricsFormatterCSV:
+			writer, err := metrics.NewCSVWriter(conf.Dest)
+			if err != nil {
+				return nil, err
+			}
+			writers = append(writers, writer)
+			continue
+		case MetricsFormatterParquet:
+			writer, err := metrics.NewParquetWriter(conf.Dest)
+			if err != nil {
+				return nil, err
+			}
+			writers = append(writers, writer)
+			continue
+		}
+
 		formatter, err := buildFormatter(conf.Formatter)
 		if err != nil {
 			return nil, err
@@ -81,6 +368,26 @@ func BuildMetricsLogger(conf []MetricsLoggerConfig) ([]*metrics.FileWriter, erro
 	return writers, nil
 }
 
+// buildOpenMetricsWriter builds a metrics.OpenMetricsServer serving at the http:// URL (at /metrics
+// if the URL has no path), or a metrics.OpenMetricsFileWriter writing to the destination otherwise.
+func buildOpenMetricsWriter(dest string) (metrics.Writer, error) {
+	if !strings.HasPrefix(dest, "http://") {
+		return metrics.NewOpenMetricsFileWriter(dest)
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" {
+		return nil, strongerrors.InvalidArgument(errors.Errorf("invalid OpenMetrics endpoint %q", dest))
+	}
+
+	path := u.Path
+	if path == "" || path == "/" {
+		path = "/metrics"
+	}
+
+	return metrics.NewOpenMetricsServer(u.Host, path)
+}
+
 func buildFormatter(conf string) (metrics.Formatter, error) {
 	switch conf {
 	case "JSON":
@@ -95,6 +402,10 @@ func buildFormatter(conf string) (metrics.Formatter, error) {
 }
 
 // BuildNode builds a *v1.Node with the given NodeConfig.
+// If the node has GPU devices, its allocatable nvidia.com/gpu and MIG resources default to the ones
+// that the devices provide.
+// If the topology of the node is given, the node is labeled with its domains, unless the labels are
+// given explicitly.
 // Returns error if failed to parse.
 func BuildNode(conf NodeConfig, startClock string) (*v1.Node, error) {
 	allocatable, err := util.BuildResourceList(conf.Status.Allocatable)
@@ -102,6 +413,22 @@ func BuildNode(conf NodeConfig, startClock string) (*v1.Node, error) {
 		return nil, err
 	}
 
+	devices, err := BuildGPUDevices(conf)
+	if err != nil {
+		return nil, err
+	}
+	if devices != nil {
+		for name, provided := range node.GPUResources(devices.GPUs, nil) {
+			if given, ok := allocatable[name]; !ok {
+				allocatable[name] = provided
+			} else if given.Cmp(provided) != 0 {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("allocatable %s %s of node %q does not match %s provided by devices",
+						name, given.String(), conf.Metadata.Name, provided.String()))
+			}
+		}
+	}
+
 	clock := time.Now()
 	if startClock != "" {
 		clock, err = time.Parse(time.RFC3339, startClock)
@@ -115,7 +442,7 @@ func BuildNode(conf NodeConfig, startClock string) (*v1.Node, error) {
 			Kind:       "Node",
 			APIVersion: "v1",
 		},
-		ObjectMeta: conf.Metadata,
+		ObjectMeta: buildNodeMetadata(conf),
 		Spec:       conf.Spec,
 		Status: v1.NodeStatus{
 			Capacity:    allocatable,
@@ -127,6 +454,521 @@ func BuildNode(conf NodeConfig, startClock string) (*v1.Node, error) {
 	return &node, nil
 }
 
+// BuildGPUDevices builds the GPU device model of the node with the given NodeConfig.
+// Returns nil if the node has no GPU devices, or error if failed to parse.
+func BuildGPUDevices(conf NodeConfig) (*node.GPUDevices, error) {
+	if len(conf.Devices.GPUs) == 0 {
+		return nil, nil
+	}
+
+	if conf.Devices.CrossIslandSlowdown != 0 && conf.Devices.CrossIslandSlowdown < 1 {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("crossIslandSlowdown of node %q must not be less than 1", conf.Metadata.Name))
+	}
+	if conf.Devices.TimeSlicingSlowdown != 0 && conf.Devices.TimeSlicingSlowdown < 1 {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("timeSlicingSlowdown of node %q must not be less than 1", conf.Metadata.Name))
+	}
+	if conf.Devices.RepartitionSeconds < 0 {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("repartitionSeconds of node %q must not be negative", conf.Metadata.Name))
+	}
+
+	gpus := make([]node.GPU, 0, len(conf.Devices.GPUs))
+	for _, gpuConf := range conf.Devices.GPUs {
+		gpu := node.GPU{Island: gpuConf.Island}
+		if gpuConf.Memory != "" {
+			memory, err := resource.ParseQuantity(gpuConf.Memory)
+			if err != nil {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("invalid GPU memory %q of node %q: %s", gpuConf.Memory, conf.Metadata.Name, err.Error()))
+			}
+			gpu.Memory = memory
+		}
+
+		if len(gpuConf.MIG) > 0 {
+			if gpuConf.Replicas > 1 {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("GPU of node %q cannot enable both MIG and time-slicing", conf.Metadata.Name))
+			}
+			if err := node.ValidateMIGLayout(gpu, gpuConf.MIG); err != nil {
+				return nil, err
+			}
+			gpu.MIGProfiles = gpuConf.MIG
+		}
+		if gpuConf.Replicas < 0 {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("GPU replicas of node %q must not be negative", conf.Metadata.Name))
+		}
+		gpu.Replicas = gpuConf.Replicas
+
+		gpus = append(gpus, gpu)
+	}
+
+	return &node.GPUDevices{
+		GPUs:                gpus,
+		CrossIslandSlowdown: conf.Devices.CrossIslandSlowdown,
+		TimeSlicingSlowdown: conf.Devices.TimeSlicingSlowdown,
+		RepartitionDuration: time.Duration(conf.Devices.RepartitionSeconds) * time.Second,
+	}, nil
+}
+
+// BuildEvictionPolicy builds the kubelet eviction policy of the node with the given NodeConfig.
+// Returns nil if the node has no eviction thresholds, or error if failed to parse.
+func BuildEvictionPolicy(conf NodeConfig) (*node.EvictionPolicy, error) {
+	if len(conf.Eviction.Thresholds) == 0 {
+		return nil, nil
+	}
+
+	if conf.Eviction.PressureTransitionPeriod < 0 {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("pressureTransitionPeriod of node %q must not be negative", conf.Metadata.Name))
+	}
+	policy := &node.EvictionPolicy{PressureTransitionPeriod: node.DefaultPressureTransitionPeriod}
+	if conf.Eviction.PressureTransitionPeriod > 0 {
+		policy.PressureTransitionPeriod = time.Duration(conf.Eviction.PressureTransitionPeriod) * time.Second
+	}
+
+	for _, thConf := range conf.Eviction.Thresholds {
+		th := node.EvictionThreshold{
+			Signal:      node.EvictionSignal(thConf.Signal),
+			GracePeriod: time.Duration(thConf.GracePeriod) * time.Second,
+		}
+		if !th.Signal.IsValid() {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("eviction signal %q is not supported", thConf.Signal))
+		}
+		if thConf.GracePeriod < 0 {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("grace period of eviction threshold %s of node %q must not be negative",
+					thConf.Signal, conf.Metadata.Name))
+		}
+
+		if strings.HasSuffix(thConf.Value, "%") {
+			percentage, err := strconv.ParseFloat(strings.TrimSuffix(thConf.Value, "%"), 64)
+			if err != nil || percentage < 0 || percentage > 100 {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("invalid eviction threshold %q of node %q", thConf.Value, conf.Metadata.Name))
+			}
+			th.Percentage = percentage / 100
+		} else {
+			quantity, err := resource.ParseQuantity(thConf.Value)
+			if err != nil || quantity.Sign() < 0 {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("invalid eviction threshold %q of node %q", thConf.Value, conf.Metadata.Name))
+			}
+			th.Quantity = &quantity
+		}
+
+		policy.Thresholds = append(policy.Thresholds, th)
+	}
+
+	return policy, nil
+}
+
+// BuildStartupModel builds the model of the startup latency of pods on the node with the given
+// NodeConfig.
+// Returns nil if neither the node nor the config models the startup latency, or error if failed to
+// parse.
+func BuildStartupModel(conf *Config, nodeConf NodeConfig) (*node.StartupModel, error) {
+	startupConf := conf.PodStartup
+	if nodeConf.ImagePullBandwidth == "" && len(nodeConf.Images) == 0 &&
+		startupConf.ContainerStartSeconds == 0 && len(startupConf.Images) == 0 {
+		return nil, nil
+	}
+
+	if startupConf.ContainerStartSeconds < 0 {
+		return nil, strongerrors.InvalidArgument(errors.New("containerStartSeconds must not be negative"))
+	}
+	model := &node.StartupModel{
+		ImageSizes:             make(map[string]int64, len(startupConf.Images)),
+		ContainerStartDuration: time.Duration(startupConf.ContainerStartSeconds) * time.Second,
+	}
+
+	if nodeConf.ImagePullBandwidth != "" {
+		bandwidth, err := resource.ParseQuantity(nodeConf.ImagePullBandwidth)
+		if err != nil || bandwidth.Sign() <= 0 {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("invalid imagePullBandwidth %q of node %q",
+					nodeConf.ImagePullBandwidth, nodeConf.Metadata.Name))
+		}
+		model.ImagePullBandwidth = bandwidth.Value()
+	}
+
+	for _, imageConf := range startupConf.Images {
+		if imageConf.Name == "" {
+			return nil, strongerrors.InvalidArgument(errors.New("image name must not be empty"))
+		}
+		size, err := resource.ParseQuantity(imageConf.Size)
+		if err != nil || size.Sign() < 0 {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("invalid size %q of image %q", imageConf.Size, imageConf.Name))
+		}
+		model.ImageSizes[imageConf.Name] = size.Value()
+	}
+
+	return model, nil
+}
+
+// BuildGPUAllocator builds the node.GPUAllocator with the given name.
+// Returns error if the name is not supported.
+func BuildGPUAllocator(name string) (node.GPUAllocator, error) {
+	switch name {
+	case "", GPUAllocatorFirstFit:
+		return node.FirstFitAllocator{}, nil
+	case GPUAllocatorBestFit:
+		return node.BestFitAllocator{}, nil
+	case GPUAllocatorTopologyAware:
+		return node.TopologyAwareAllocator{}, nil
+	default:
+		return nil, strongerrors.InvalidArgument(errors.Errorf("GPU allocator %q is not supported", name))
+	}
+}
+
+// BuildFailure validates the given FailureConfig and returns its topology level.
+func BuildFailure(conf FailureConfig) (node.TopologyLevel, error) {
+	level := node.TopologyLevel(conf.Level)
+	if !level.IsValid() {
+		return "", strongerrors.InvalidArgument(errors.Errorf("topology level %q is not supported", conf.Level))
+	}
+	if conf.Domain == "" {
+		return "", strongerrors.InvalidArgument(errors.New("failure domain must not be empty"))
+	}
+	if conf.At < 0 || conf.Duration < 0 {
+		return "", strongerrors.InvalidArgument(
+			errors.Errorf("failure of %s %q must not have negative time", conf.Level, conf.Domain))
+	}
+
+	return level, nil
+}
+
+// BuildNodeOperation validates the given MaintenanceConfig and builds its node.Operation.
+func BuildNodeOperation(conf MaintenanceConfig) (node.Operation, error) {
+	if conf.Node == "" {
+		return node.Operation{}, strongerrors.InvalidArgument(errors.New("maintenance node must not be empty"))
+	}
+	if conf.At < 0 {
+		return node.Operation{}, strongerrors.InvalidArgument(
+			errors.Errorf("maintenance of node %q must not have negative time", conf.Node))
+	}
+
+	op := node.Operation{Type: node.OperationType(conf.Operation)}
+	switch op.Type {
+	case node.Cordon, node.Uncordon, node.Drain:
+		return op, nil
+	case node.AddTaint, node.RemoveTaint:
+	case node.SetCondition:
+		return buildConditionOperation(conf)
+	default:
+		return node.Operation{}, strongerrors.InvalidArgument(
+			errors.Errorf("node operation %q is not supported", conf.Operation))
+	}
+
+	effect := v1.TaintEffect(conf.Taint.Effect)
+	if conf.Taint.Key == "" {
+		return node.Operation{}, strongerrors.InvalidArgument(
+			errors.Errorf("taint key of node %q must not be empty", conf.Node))
+	}
+	if effect != v1.TaintEffectNoSchedule && effect != v1.TaintEffectPreferNoSchedule &&
+		effect != v1.TaintEffectNoExecute {
+		return node.Operation{}, strongerrors.InvalidArgument(
+			errors.Errorf("taint effect %q is not supported", conf.Taint.Effect))
+	}
+	op.Taint = &v1.Taint{Key: conf.Taint.Key, Value: conf.Taint.Value, Effect: effect}
+
+	return op, nil
+}
+
+// buildConditionOperation builds the node.Operation of a condition operation.
+func buildConditionOperation(conf MaintenanceConfig) (node.Operation, error) {
+	if conf.Condition.Type == "" {
+		return node.Operation{}, strongerrors.InvalidArgument(
+			errors.Errorf("condition type of node %q must not be empty", conf.Node))
+	}
+
+	status := v1.ConditionStatus(conf.Condition.Status)
+	if status != v1.ConditionTrue && status != v1.ConditionFalse && status != v1.ConditionUnknown {
+		return node.Operation{}, strongerrors.InvalidArgument(
+			errors.Errorf("condition status %q is not supported", conf.Condition.Status))
+	}
+
+	return node.Operation{
+		Type: node.SetCondition,
+		Condition: &v1.NodeCondition{
+			Type:    v1.NodeConditionType(conf.Condition.Type),
+			Status:  status,
+			Reason:  conf.Condition.Reason,
+			Message: conf.Condition.Message,
+		},
+	}, nil
+}
+
+// BuildPodDisruptionBudget builds a policy.PodDisruptionBudget with the given
+// PodDisruptionBudgetConfig.
+// Returns error if the config is invalid.
+func BuildPodDisruptionBudget(conf PodDisruptionBudgetConfig) (*policy.PodDisruptionBudget, error) {
+	if conf.Name == "" {
+		return nil, strongerrors.InvalidArgument(errors.New("pod disruption budget name must not be empty"))
+	}
+	if (conf.MinAvailable == "") == (conf.MaxUnavailable == "") {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("pod disruption budget %q must have exactly one of minAvailable and maxUnavailable",
+				conf.Name))
+	}
+
+	namespace := conf.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	pdb := &policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: conf.Name},
+		Spec: policy.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: conf.Selector},
+		},
+	}
+
+	var ok bool
+	if conf.MinAvailable != "" {
+		if pdb.Spec.MinAvailable, ok = parseIntOrPercent(conf.MinAvailable); !ok {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("invalid minAvailable %q of pod disruption budget %q", conf.MinAvailable, conf.Name))
+		}
+	} else {
+		if pdb.Spec.MaxUnavailable, ok = parseIntOrPercent(conf.MaxUnavailable); !ok {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("invalid maxUnavailable %q of pod disruption budget %q", conf.MaxUnavailable, conf.Name))
+		}
+	}
+
+	return pdb, nil
+}
+
+// BuildStorageClass builds a storagev1.StorageClass and the time to attach its volumes with the
+// given StorageClassConfig.
+// Returns error if the config is invalid.
+func BuildStorageClass(conf StorageClassConfig) (*storagev1.StorageClass, time.Duration, error) {
+	if conf.Name == "" {
+		return nil, 0, strongerrors.InvalidArgument(errors.New("storage class name must not be empty"))
+	}
+	if conf.AttachSeconds < 0 {
+		return nil, 0, strongerrors.InvalidArgument(
+			errors.Errorf("attachSeconds of storage class %q must not be negative", conf.Name))
+	}
+
+	mode := storagev1.VolumeBindingMode(conf.VolumeBindingMode)
+	switch mode {
+	case "":
+		mode = storagev1.VolumeBindingImmediate
+	case storagev1.VolumeBindingImmediate, storagev1.VolumeBindingWaitForFirstConsumer:
+	default:
+		return nil, 0, strongerrors.InvalidArgument(
+			errors.Errorf("volume binding mode %q is not supported", conf.VolumeBindingMode))
+	}
+
+	class := &storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: conf.Name},
+		Provisioner:       "kubernetes.io/no-provisioner",
+		VolumeBindingMode: &mode,
+	}
+
+	return class, time.Duration(conf.AttachSeconds) * time.Second, nil
+}
+
+// BuildPersistentVolume builds a *v1.PersistentVolume with the given PersistentVolumeConfig.
+// If the volume has a zone, a region, or a node, it is labeled with the zone and the region, and has
+// the node affinity to them.
+// Returns error if the config is invalid.
+func BuildPersistentVolume(conf PersistentVolumeConfig) (*v1.PersistentVolume, error) {
+	if conf.Name == "" {
+		return nil, strongerrors.InvalidArgument(errors.New("persistent volume name must not be empty"))
+	}
+
+	capacity, err := resource.ParseQuantity(conf.Capacity)
+	if err != nil || capacity.Sign() <= 0 {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("invalid capacity %q of persistent volume %q", conf.Capacity, conf.Name))
+	}
+
+	accessModes, err := buildAccessModes(conf.AccessModes)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := buildPersistentVolumeSource(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	pv := &v1.PersistentVolume{
+		TypeMeta:   metav1.TypeMeta{Kind: "PersistentVolume", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: conf.Name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:                      v1.ResourceList{v1.ResourceStorage: capacity},
+			PersistentVolumeSource:        source,
+			AccessModes:                   accessModes,
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+			StorageClassName:              conf.StorageClassName,
+		},
+	}
+
+	requirements := []v1.NodeSelectorRequirement{}
+	addRequirement := func(label, value string) {
+		if value == "" {
+			return
+		}
+		if pv.Labels == nil {
+			pv.Labels = map[string]string{}
+		}
+		if label != v1.LabelHostname {
+			pv.Labels[label] = value
+		}
+		requirements = append(requirements, v1.NodeSelectorRequirement{
+			Key:      label,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{value},
+		})
+	}
+	addRequirement(v1.LabelZoneRegion, conf.Region)
+	addRequirement(v1.LabelZoneFailureDomain, conf.Zone)
+	addRequirement(v1.LabelHostname, conf.Node)
+
+	if len(requirements) > 0 {
+		pv.Spec.NodeAffinity = &v1.VolumeNodeAffinity{
+			Required: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: requirements}},
+			},
+		}
+	}
+
+	return pv, nil
+}
+
+// BuildPersistentVolumeClaim builds a *v1.PersistentVolumeClaim with the given
+// PersistentVolumeClaimConfig.
+// Returns error if the config is invalid.
+func BuildPersistentVolumeClaim(conf PersistentVolumeClaimConfig) (*v1.PersistentVolumeClaim, error) {
+	if conf.Name == "" {
+		return nil, strongerrors.InvalidArgument(errors.New("persistent volume claim name must not be empty"))
+	}
+
+	request, err := resource.ParseQuantity(conf.Request)
+	if err != nil || request.Sign() <= 0 {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("invalid request %q of persistent volume claim %q", conf.Request, conf.Name))
+	}
+
+	accessModes, err := buildAccessModes(conf.AccessModes)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := conf.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	className := conf.StorageClassName
+
+	return &v1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      conf.Name,
+			UID:       types.UID(namespace + "/" + conf.Name),
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			Resources:        v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: request}},
+			StorageClassName: &className,
+		},
+	}, nil
+}
+
+// buildAccessModes builds the access modes of a volume or a claim, defaulting to ReadWriteOnce.
+// Returns error if a mode is not supported.
+func buildAccessModes(modes []string) ([]v1.PersistentVolumeAccessMode, error) {
+	if len(modes) == 0 {
+		return []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}, nil
+	}
+
+	accessModes := make([]v1.PersistentVolumeAccessMode, 0, len(modes))
+	for _, mode := range modes {
+		m := v1.PersistentVolumeAccessMode(mode)
+		if m != v1.ReadWriteOnce && m != v1.ReadOnlyMany && m != v1.ReadWriteMany {
+			return nil, strongerrors.InvalidArgument(errors.Errorf("access mode %q is not supported", mode))
+		}
+		accessModes = append(accessModes, m)
+	}
+
+	return accessModes, nil
+}
+
+// buildPersistentVolumeSource builds the source of the persistent volume by its type, identified by
+// its name.
+// Returns error if the type is not supported.
+func buildPersistentVolumeSource(conf PersistentVolumeConfig) (v1.PersistentVolumeSource, error) {
+	switch conf.Type {
+	case "", PersistentVolumeLocal:
+		return v1.PersistentVolumeSource{Local: &v1.LocalVolumeSource{Path: "/mnt/" + conf.Name}}, nil
+	case PersistentVolumeGCEPersistentDisk:
+		return v1.PersistentVolumeSource{
+			GCEPersistentDisk: &v1.GCEPersistentDiskVolumeSource{PDName: conf.Name}}, nil
+	case PersistentVolumeAWSElasticBlockStore:
+		return v1.PersistentVolumeSource{
+			AWSElasticBlockStore: &v1.AWSElasticBlockStoreVolumeSource{VolumeID: conf.Name}}, nil
+	case PersistentVolumeAzureDisk:
+		return v1.PersistentVolumeSource{
+			AzureDisk: &v1.AzureDiskVolumeSource{DiskName: conf.Name, DataDiskURI: conf.Name}}, nil
+	case PersistentVolumeCinder:
+		return v1.PersistentVolumeSource{Cinder: &v1.CinderPersistentVolumeSource{VolumeID: conf.Name}}, nil
+	default:
+		return v1.PersistentVolumeSource{}, strongerrors.InvalidArgument(
+			errors.Errorf("persistent volume type %q is not supported", conf.Type))
+	}
+}
+
+// parseIntOrPercent parses a non-negative integer or percentage.
+// Returns false if the value is invalid.
+func parseIntOrPercent(value string) (*intstr.IntOrString, bool) {
+	ios := intstr.Parse(value)
+	v, err := intstr.GetValueFromIntOrPercent(&ios, 100, true)
+	return &ios, err == nil && v >= 0
+}
+
+// buildNodeMetadata returns the metadata in the NodeConfig, with the topology labels added.
+func buildNodeMetadata(conf NodeConfig) metav1.ObjectMeta {
+	topo := conf.Topology
+	if topo == (NodeTopology{}) {
+		return conf.Metadata
+	}
+
+	metadata := conf.Metadata
+	metadata.Labels = make(map[string]string, len(conf.Metadata.Labels)+6)
+	for k, v := range conf.Metadata.Labels {
+		metadata.Labels[k] = v
+	}
+
+	setDefault := func(label, value string) {
+		if _, ok := metadata.Labels[label]; !ok && value != "" {
+			metadata.Labels[label] = value
+		}
+	}
+
+	host := topo.Host
+	if host == "" {
+		host = conf.Metadata.Name
+	}
+
+	setDefault(node.Region.Label(), topo.Region)
+	setDefault(v1.LabelZoneRegion, topo.Region)
+	setDefault(node.Zone.Label(), topo.Zone)
+	setDefault(v1.LabelZoneFailureDomain, topo.Zone)
+	setDefault(node.Rack.Label(), topo.Rack)
+	setDefault(node.Host.Label(), host)
+
+	return metadata
+}
+
 func buildNodeCondition(clock metav1.Time) []v1.NodeCondition {
 	return []v1.NodeCondition{
 		{
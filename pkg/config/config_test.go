@@ -15,16 +15,22 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
 )
 
 func TestBuildMetricsLogger(t *testing.T) {
@@ -40,6 +46,31 @@ func TestBuildMetricsLogger(t *testing.T) {
 	}})
 	assert.EqualError(t, err, "formatter \"invalid\" is not supported")
 
+	_, err = BuildMetricsLogger([]MetricsLoggerConfig{{
+		Dest:      "http://",
+		Formatter: "openMetrics",
+	}})
+	assert.EqualError(t, err, "invalid OpenMetrics endpoint \"http://\"")
+
+	writers, err := BuildMetricsLogger([]MetricsLoggerConfig{{
+		Dest:      "http://127.0.0.1:0",
+		Formatter: "openMetrics",
+	}})
+	assert.NoError(t, err)
+	assert.IsType(t, &metrics.OpenMetricsServer{}, writers[0])
+
+	dir, err := ioutil.TempDir("", "kubesim-config-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writers, err = BuildMetricsLogger([]MetricsLoggerConfig{{
+		Dest:      filepath.Join(dir, "metrics"),
+		Formatter: "csv",
+	}})
+	assert.NoError(t, err)
+	assert.IsType(t, &metrics.ColumnarWriter{}, writers[0])
+	assert.FileExists(t, filepath.Join(dir, "metrics", "nodes.csv"))
+
 	// TODO: Test correct cases
 }
 
@@ -122,6 +153,350 @@ func TestBuildNode(t *testing.T) {
 	}
 }
 
+func TestBuildNodeTopologyLabels(t *testing.T) {
+	metadata := metav1.ObjectMeta{
+		Name:   "node-0",
+		Labels: map[string]string{"topology.kubernetes.io/rack": "custom"},
+	}
+
+	actual, err := BuildNode(NodeConfig{
+		Metadata: metadata,
+		Topology: NodeTopology{Region: "region-0", Zone: "zone-0", Rack: "rack-0"},
+	}, "")
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+
+	expected := map[string]string{
+		"topology.kubernetes.io/region":            "region-0",
+		"failure-domain.beta.kubernetes.io/region": "region-0",
+		"topology.kubernetes.io/zone":              "zone-0",
+		"failure-domain.beta.kubernetes.io/zone":   "zone-0",
+		"topology.kubernetes.io/rack":              "custom",
+		"kubernetes.io/hostname":                   "node-0",
+	}
+	assert.Equal(t, expected, actual.Labels)
+
+	// The labels in the config are not modified.
+	assert.Equal(t, 1, len(metadata.Labels))
+}
+
+func TestBuildNodeGPUDevices(t *testing.T) {
+	conf := NodeConfig{
+		Metadata: metav1.ObjectMeta{Name: "node-0"},
+		Status:   NodeStatus{Allocatable: map[v1.ResourceName]string{"cpu": "8"}},
+		Devices: DevicesConfig{
+			GPUs:                []GPUConfig{{Memory: "40Gi", Island: 0}, {Memory: "40Gi", Island: 1}},
+			CrossIslandSlowdown: 1.5,
+		},
+	}
+
+	n, err := BuildNode(conf, "")
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+	gpus := n.Status.Allocatable[node.GPUResourceName]
+	assert.Equal(t, int64(2), gpus.Value())
+
+	devices, err := BuildGPUDevices(conf)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+	assert.Equal(t, 2, len(devices.GPUs))
+	assert.Equal(t, 1, devices.GPUs[1].Island)
+	assert.Equal(t, resource.MustParse("40Gi"), devices.GPUs[0].Memory)
+
+	conf.Status.Allocatable[node.GPUResourceName] = "4"
+	_, err = BuildNode(conf, "")
+	assert.EqualError(t, err, "allocatable nvidia.com/gpu 4 of node \"node-0\" does not match 2 provided by devices")
+
+	conf.Devices.CrossIslandSlowdown = 0.5
+	_, err = BuildGPUDevices(conf)
+	assert.EqualError(t, err, "crossIslandSlowdown of node \"node-0\" must not be less than 1")
+
+	devices, _ = BuildGPUDevices(NodeConfig{})
+	assert.Nil(t, devices)
+}
+
+func TestBuildNodeSharedGPUs(t *testing.T) {
+	conf := NodeConfig{
+		Metadata: metav1.ObjectMeta{Name: "node-0"},
+		Status:   NodeStatus{Allocatable: map[v1.ResourceName]string{"cpu": "8"}},
+		Devices: DevicesConfig{
+			GPUs: []GPUConfig{
+				{Memory: "40Gi", MIG: []string{"3g.20gb", "1g.5gb", "1g.5gb"}},
+				{Memory: "40Gi", Replicas: 4},
+			},
+		},
+	}
+
+	n, err := BuildNode(conf, "")
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+	gpus := n.Status.Allocatable[node.GPUResourceName]
+	assert.Equal(t, int64(4), gpus.Value())
+	mig := n.Status.Allocatable["nvidia.com/mig-1g.5gb"]
+	assert.Equal(t, int64(2), mig.Value())
+	mig = n.Status.Allocatable["nvidia.com/mig-3g.20gb"]
+	assert.Equal(t, int64(1), mig.Value())
+
+	conf.Devices.GPUs[0].MIG = []string{"4g.20gb", "4g.20gb"}
+	_, err = BuildGPUDevices(conf)
+	assert.EqualError(t, err, "MIG profiles [4g.20gb 4g.20gb] need 8 compute slices, more than 7")
+
+	conf.Devices.GPUs[0].MIG = []string{"3g.20gb", "3g.20gb", "1g.5gb"}
+	_, err = BuildGPUDevices(conf)
+	assert.EqualError(t, err, "MIG profiles [3g.20gb 3g.20gb 1g.5gb] need 45Gi memory, more than 40Gi")
+
+	conf.Devices.GPUs[0].MIG = []string{"1g"}
+	_, err = BuildGPUDevices(conf)
+	assert.EqualError(t, err, "invalid MIG profile \"1g\"")
+
+	conf.Devices.GPUs[1].MIG = []string{"1g.5gb"}
+	conf.Devices.GPUs[0].MIG = nil
+	_, err = BuildGPUDevices(conf)
+	assert.EqualError(t, err, "GPU of node \"node-0\" cannot enable both MIG and time-slicing")
+}
+
+func TestBuildGPUAllocator(t *testing.T) {
+	allocator, _ := BuildGPUAllocator("")
+	assert.Equal(t, node.FirstFitAllocator{}, allocator)
+
+	allocator, _ = BuildGPUAllocator("topologyAware")
+	assert.Equal(t, node.TopologyAwareAllocator{}, allocator)
+
+	_, err := BuildGPUAllocator("invalid")
+	assert.EqualError(t, err, "GPU allocator \"invalid\" is not supported")
+}
+
+func TestBuildEvictionPolicy(t *testing.T) {
+	policy, err := BuildEvictionPolicy(NodeConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	policy, err = BuildEvictionPolicy(NodeConfig{Eviction: EvictionConfig{
+		Thresholds: []EvictionThresholdConfig{
+			{Signal: "memory.available", Value: "100Mi"},
+			{Signal: "nodefs.available", Value: "10%", GracePeriod: 60},
+		},
+	}})
+	assert.NoError(t, err)
+	quantity := resource.MustParse("100Mi")
+	assert.Equal(t, &node.EvictionPolicy{
+		Thresholds: []node.EvictionThreshold{
+			{Signal: node.SignalMemoryAvailable, Quantity: &quantity},
+			{Signal: node.SignalNodeFsAvailable, Percentage: 0.1, GracePeriod: 60 * time.Second},
+		},
+		PressureTransitionPeriod: node.DefaultPressureTransitionPeriod,
+	}, policy)
+
+	_, err = BuildEvictionPolicy(NodeConfig{Eviction: EvictionConfig{
+		Thresholds: []EvictionThresholdConfig{{Signal: "pid.available", Value: "10"}},
+	}})
+	assert.EqualError(t, err, "eviction signal \"pid.available\" is not supported")
+
+	conf := NodeConfig{Eviction: EvictionConfig{
+		Thresholds: []EvictionThresholdConfig{{Signal: "memory.available", Value: "110%"}},
+	}}
+	conf.Metadata.Name = "node-0"
+	_, err = BuildEvictionPolicy(conf)
+	assert.EqualError(t, err, "invalid eviction threshold \"110%\" of node \"node-0\"")
+}
+
+func TestBuildStartupModel(t *testing.T) {
+	model, err := BuildStartupModel(&Config{}, NodeConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, model)
+
+	conf := &Config{PodStartup: PodStartupConfig{
+		ContainerStartSeconds: 2,
+		Images:                []ImageConfig{{Name: "nginx:1.15", Size: "100Mi"}},
+	}}
+	model, err = BuildStartupModel(conf, NodeConfig{ImagePullBandwidth: "10Mi"})
+	assert.NoError(t, err)
+	assert.Equal(t, &node.StartupModel{
+		ImagePullBandwidth:     10 << 20,
+		ImageSizes:             map[string]int64{"nginx:1.15": 100 << 20},
+		ContainerStartDuration: 2 * time.Second,
+	}, model)
+
+	nodeConf := NodeConfig{ImagePullBandwidth: "0"}
+	nodeConf.Metadata.Name = "node-0"
+	_, err = BuildStartupModel(&Config{}, nodeConf)
+	assert.EqualError(t, err, "invalid imagePullBandwidth \"0\" of node \"node-0\"")
+
+	conf.PodStartup.Images = []ImageConfig{{Name: "nginx", Size: "large"}}
+	_, err = BuildStartupModel(conf, NodeConfig{})
+	assert.EqualError(t, err, "invalid size \"large\" of image \"nginx\"")
+}
+
+func TestBuildFailure(t *testing.T) {
+	level, err := BuildFailure(FailureConfig{Level: "rack", Domain: "rack-0", At: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, node.Rack, level)
+
+	_, err = BuildFailure(FailureConfig{Level: "row", Domain: "row-0"})
+	assert.EqualError(t, err, "topology level \"row\" is not supported")
+
+	_, err = BuildFailure(FailureConfig{Level: "zone"})
+	assert.EqualError(t, err, "failure domain must not be empty")
+
+	_, err = BuildFailure(FailureConfig{Level: "zone", Domain: "zone-0", Duration: -1})
+	assert.EqualError(t, err, "failure of zone \"zone-0\" must not have negative time")
+}
+
+func TestBuildNodeOperation(t *testing.T) {
+	op, err := BuildNodeOperation(MaintenanceConfig{At: 60, Node: "node-0", Operation: "drain"})
+	assert.NoError(t, err)
+	assert.Equal(t, node.Operation{Type: node.Drain}, op)
+
+	op, err = BuildNodeOperation(MaintenanceConfig{
+		Node:      "node-0",
+		Operation: "taint",
+		Taint:     TaintConfig{Key: "upgrade", Value: "true", Effect: "NoExecute"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, node.Operation{
+		Type:  node.AddTaint,
+		Taint: &v1.Taint{Key: "upgrade", Value: "true", Effect: v1.TaintEffectNoExecute},
+	}, op)
+
+	op, err = BuildNodeOperation(MaintenanceConfig{
+		Node:      "node-0",
+		Operation: "condition",
+		Condition: ConditionConfig{Type: "Ready", Status: "False", Reason: "KubeletNotReady"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, node.Operation{
+		Type:      node.SetCondition,
+		Condition: &v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionFalse, Reason: "KubeletNotReady"},
+	}, op)
+
+	_, err = BuildNodeOperation(MaintenanceConfig{
+		Node: "node-0", Operation: "condition", Condition: ConditionConfig{Type: "Ready", Status: "Maybe"}})
+	assert.EqualError(t, err, "condition status \"Maybe\" is not supported")
+
+	_, err = BuildNodeOperation(MaintenanceConfig{Operation: "cordon"})
+	assert.EqualError(t, err, "maintenance node must not be empty")
+
+	_, err = BuildNodeOperation(MaintenanceConfig{Node: "node-0", Operation: "reboot"})
+	assert.EqualError(t, err, "node operation \"reboot\" is not supported")
+
+	_, err = BuildNodeOperation(MaintenanceConfig{Node: "node-0", Operation: "untaint"})
+	assert.EqualError(t, err, "taint key of node \"node-0\" must not be empty")
+
+	_, err = BuildNodeOperation(MaintenanceConfig{
+		Node: "node-0", Operation: "taint", Taint: TaintConfig{Key: "upgrade", Effect: "NoRun"}})
+	assert.EqualError(t, err, "taint effect \"NoRun\" is not supported")
+}
+
+func TestBuildPodDisruptionBudget(t *testing.T) {
+	pdb, err := BuildPodDisruptionBudget(PodDisruptionBudgetConfig{
+		Name:         "web",
+		Selector:     map[string]string{"app": "web"},
+		MinAvailable: "50%",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.NamespaceDefault, pdb.Namespace)
+	assert.Equal(t, map[string]string{"app": "web"}, pdb.Spec.Selector.MatchLabels)
+	assert.Equal(t, intstr.FromString("50%"), *pdb.Spec.MinAvailable)
+	assert.Nil(t, pdb.Spec.MaxUnavailable)
+
+	pdb, err = BuildPodDisruptionBudget(PodDisruptionBudgetConfig{Name: "db", MaxUnavailable: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, intstr.FromInt(1), *pdb.Spec.MaxUnavailable)
+
+	_, err = BuildPodDisruptionBudget(PodDisruptionBudgetConfig{Name: "db"})
+	assert.EqualError(t, err, "pod disruption budget \"db\" must have exactly one of minAvailable and maxUnavailable")
+
+	_, err = BuildPodDisruptionBudget(PodDisruptionBudgetConfig{Name: "db", MinAvailable: "1", MaxUnavailable: "1"})
+	assert.EqualError(t, err, "pod disruption budget \"db\" must have exactly one of minAvailable and maxUnavailable")
+
+	_, err = BuildPodDisruptionBudget(PodDisruptionBudgetConfig{Name: "db", MaxUnavailable: "-1"})
+	assert.EqualError(t, err, "invalid maxUnavailable \"-1\" of pod disruption budget \"db\"")
+
+	_, err = BuildPodDisruptionBudget(PodDisruptionBudgetConfig{Name: "db", MinAvailable: "half"})
+	assert.EqualError(t, err, "invalid minAvailable \"half\" of pod disruption budget \"db\"")
+}
+
+func TestBuildStorageClass(t *testing.T) {
+	class, attach, err := BuildStorageClass(StorageClassConfig{Name: "standard", AttachSeconds: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, "standard", class.Name)
+	assert.Equal(t, storagev1.VolumeBindingImmediate, *class.VolumeBindingMode)
+	assert.Equal(t, 5*time.Second, attach)
+
+	class, _, err = BuildStorageClass(StorageClassConfig{Name: "local", VolumeBindingMode: "WaitForFirstConsumer"})
+	assert.NoError(t, err)
+	assert.Equal(t, storagev1.VolumeBindingWaitForFirstConsumer, *class.VolumeBindingMode)
+
+	_, _, err = BuildStorageClass(StorageClassConfig{})
+	assert.EqualError(t, err, "storage class name must not be empty")
+
+	_, _, err = BuildStorageClass(StorageClassConfig{Name: "local", VolumeBindingMode: "Lazy"})
+	assert.EqualError(t, err, "volume binding mode \"Lazy\" is not supported")
+
+	_, _, err = BuildStorageClass(StorageClassConfig{Name: "local", AttachSeconds: -1})
+	assert.EqualError(t, err, "attachSeconds of storage class \"local\" must not be negative")
+}
+
+func TestBuildPersistentVolume(t *testing.T) {
+	pv, err := BuildPersistentVolume(PersistentVolumeConfig{
+		Name:             "pd-0",
+		StorageClassName: "standard",
+		Capacity:         "100Gi",
+		Type:             "gcePersistentDisk",
+		Zone:             "zone-0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, resource.MustParse("100Gi"), pv.Spec.Capacity[v1.ResourceStorage])
+	assert.Equal(t, []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}, pv.Spec.AccessModes)
+	assert.Equal(t, "standard", pv.Spec.StorageClassName)
+	assert.Equal(t, "pd-0", pv.Spec.GCEPersistentDisk.PDName)
+	assert.Equal(t, map[string]string{v1.LabelZoneFailureDomain: "zone-0"}, pv.Labels)
+	assert.Equal(t, []v1.NodeSelectorRequirement{
+		{Key: v1.LabelZoneFailureDomain, Operator: v1.NodeSelectorOpIn, Values: []string{"zone-0"}},
+	}, pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions)
+
+	pv, err = BuildPersistentVolume(PersistentVolumeConfig{
+		Name: "local-0", Capacity: "1Ti", AccessModes: []string{"ReadWriteOnce", "ReadOnlyMany"}, Node: "node-0"})
+	assert.NoError(t, err)
+	assert.NotNil(t, pv.Spec.Local)
+	assert.Empty(t, pv.Labels)
+	assert.Equal(t, []v1.NodeSelectorRequirement{
+		{Key: v1.LabelHostname, Operator: v1.NodeSelectorOpIn, Values: []string{"node-0"}},
+	}, pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions)
+
+	_, err = BuildPersistentVolume(PersistentVolumeConfig{Capacity: "1Gi"})
+	assert.EqualError(t, err, "persistent volume name must not be empty")
+
+	_, err = BuildPersistentVolume(PersistentVolumeConfig{Name: "pv-0", Capacity: "large"})
+	assert.EqualError(t, err, "invalid capacity \"large\" of persistent volume \"pv-0\"")
+
+	_, err = BuildPersistentVolume(PersistentVolumeConfig{Name: "pv-0", Capacity: "1Gi", AccessModes: []string{"RWO"}})
+	assert.EqualError(t, err, "access mode \"RWO\" is not supported")
+
+	_, err = BuildPersistentVolume(PersistentVolumeConfig{Name: "pv-0", Capacity: "1Gi", Type: "nfs"})
+	assert.EqualError(t, err, "persistent volume type \"nfs\" is not supported")
+}
+
+func TestBuildPersistentVolumeClaim(t *testing.T) {
+	pvc, err := BuildPersistentVolumeClaim(PersistentVolumeClaimConfig{
+		Name: "data", StorageClassName: "standard", Request: "10Gi"})
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.NamespaceDefault, pvc.Namespace)
+	assert.Equal(t, "standard", *pvc.Spec.StorageClassName)
+	assert.Equal(t, resource.MustParse("10Gi"), pvc.Spec.Resources.Requests[v1.ResourceStorage])
+	assert.Equal(t, []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}, pvc.Spec.AccessModes)
+
+	_, err = BuildPersistentVolumeClaim(PersistentVolumeClaimConfig{Request: "10Gi"})
+	assert.EqualError(t, err, "persistent volume claim name must not be empty")
+
+	_, err = BuildPersistentVolumeClaim(PersistentVolumeClaimConfig{Name: "data"})
+	assert.EqualError(t, err, "invalid request \"\" of persistent volume claim \"data\"")
+}
+
 func TestBuildNodeConfig(t *testing.T) {
 	now := metav1.NewTime(time.Now())
 
@@ -17,6 +17,8 @@ package kubesim
 import (
 	"context"
 	"fmt"
+	"io"
+	"sort"
 	"time"
 
 	"github.com/containerd/containerd/log"
@@ -25,7 +27,9 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
 	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
@@ -38,6 +42,7 @@ import (
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/scheduler"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/submitter"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/volume"
 )
 
 // KubeSim represents a simulated kubernetes cluster.
@@ -45,18 +50,64 @@ type KubeSim struct {
 	tick  time.Duration
 	clock clock.Clock
 
-	nodes       map[string]*node.Node
-	pendingPods queue.PodQueue
-	boundPods   map[string]*pod.Pod
-
-	submitters map[string]submitter.Submitter
-	scheduler  scheduler.Scheduler
+	nodes        map[string]*node.Node
+	pendingBinds []pendingBind
+	boundPods    map[string]*pod.Pod
+	failures     []*failure
+	maintenance  []*maintenance
+	pdbs         []*policy.PodDisruptionBudget
+	volumes      *volume.Store
+	// nodeMonitorGracePeriod is the time for which a node condition must hold before the node is
+	// tainted by it.
+	nodeMonitorGracePeriod   time.Duration
+	defaultTolerationSeconds int64
+	// nodesChanged is whether any node may have become schedulable by node operations from
+	// submitters since the last schedule.
+	nodesChanged bool
+
+	submitters          map[string]submitter.Submitter
+	schedulers          []*namedScheduler
+	retryOnBindConflict bool
 
 	metricsWriters []metrics.Writer
 	metricsTick    time.Duration
+	collectors     []metrics.Collector
+	// summary builds the summary of the run, written by the metrics writers when Run returns.
+	summary *metrics.SummaryBuilder
+	// podEvents records the lifecycle events of pods, or is nil if they are not written.
+	podEvents *podEventRecorder
+}
+
+// namedScheduler is a scheduler registered to KubeSim with its name and the queue of pods
+// dispatched to it.
+type namedScheduler struct {
+	name      string
+	scheduler scheduler.Scheduler
+	queue     queue.PodQueue
+	metrics   metrics.SchedulerMetrics
+}
+
+// pendingBind is a binding decided by the scheduler, which takes effect at the clock.
+type pendingBind struct {
+	clock clock.Clock
+	sched *namedScheduler
+	event *scheduler.BindEvent
+}
+
+// failure is a correlated failure of all nodes in a topology domain.
+type failure struct {
+	level  node.TopologyLevel
+	domain string
+	failAt clock.Clock
+	// recoverAt is the clock at which the nodes recover, or nil if they never recover.
+	recoverAt *clock.Clock
+
+	failed    bool
+	recovered bool
 }
 
 // NewKubeSim creates a new KubeSim with the given config, queue, and scheduler.
+// The scheduler is registered as the default scheduler (i.e., v1.DefaultSchedulerName).
 // Returns error if the configuration failed.
 func NewKubeSim(
 	conf *config.Config, queue queue.PodQueue, sched scheduler.Scheduler,
@@ -78,6 +129,26 @@ func NewKubeSim(
 		return nil, err
 	}
 
+	failures, err := buildFailures(conf, clk, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	maintenance, err := buildMaintenance(conf, clk, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	pdbs, err := buildPodDisruptionBudgets(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	gracePeriod, tolerationSeconds, err := buildNodeLifecycle(conf)
+	if err != nil {
+		return nil, err
+	}
+
 	metricsTick := conf.Tick
 	if conf.MetricsTick != 0 {
 		metricsTick = conf.MetricsTick
@@ -88,20 +159,50 @@ func NewKubeSim(
 		return nil, err
 	}
 
-	return &KubeSim{
+	retryOnBindConflict, err := buildBindConflictPolicy(conf.BindConflictPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	podEvents, err := buildPodEventRecorder(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	kubesim := &KubeSim{
 		tick:  time.Duration(conf.Tick) * time.Second,
 		clock: clk,
 
 		nodes:       nodes,
-		pendingPods: queue,
 		boundPods:   map[string]*pod.Pod{},
+		failures:    failures,
+		maintenance: maintenance,
+		pdbs:        pdbs,
+
+		nodeMonitorGracePeriod:   gracePeriod,
+		defaultTolerationSeconds: tolerationSeconds,
 
 		submitters: map[string]submitter.Submitter{},
-		scheduler:  sched,
+		schedulers: []*namedScheduler{{
+			name:      v1.DefaultSchedulerName,
+			scheduler: sched,
+			queue:     queue,
+		}},
+		retryOnBindConflict: retryOnBindConflict,
 
 		metricsTick:    time.Duration(metricsTick) * time.Second,
 		metricsWriters: metricsWriters,
-	}, nil
+		collectors:     []metrics.Collector{&metrics.FragmentationCollector{}},
+		podEvents:      podEvents,
+	}
+
+	volumes, err := buildVolumes(conf, kubesim)
+	if err != nil {
+		return nil, err
+	}
+	kubesim.volumes = volumes
+
+	return kubesim, nil
 }
 
 // NewKubeSimFromConfigPath creates a new KubeSim with config from confPath (excluding file
@@ -139,15 +240,69 @@ func (k *KubeSim) AddSubmitter(name string, submitter submitter.Submitter) {
 	k.submitters[name] = submitter
 }
 
+// AddScheduler adds the new scheduler with its own queue to this KubeSim.
+// Pods whose spec.schedulerName equals to the name are pushed to the queue and scheduled by the
+// scheduler.
+// Schedulers make decisions in the same order that they are registered, on the same cluster state
+// at each clock, so bindings from different schedulers may conflict with each other.
+// If a scheduler with the same name has been registered, it is replaced with the new one.
+func (k *KubeSim) AddScheduler(name string, queue queue.PodQueue, sched scheduler.Scheduler) {
+	for _, s := range k.schedulers {
+		if s.name == name {
+			s.scheduler = sched
+			s.queue = queue
+			return
+		}
+	}
+
+	k.schedulers = append(k.schedulers, &namedScheduler{name: name, scheduler: sched, queue: queue})
+}
+
+// Volumes returns the persistent volumes, persistent volume claims, and storage classes of this
+// KubeSim, which can be given to the volume predicates of kube-scheduler.
+func (k *KubeSim) Volumes() *volume.Store {
+	return k.volumes
+}
+
+// SetGPUAllocator sets the allocator of GPUs on all nodes that have the GPU device model, replacing
+// the one given by the config.
+func (k *KubeSim) SetGPUAllocator(allocator node.GPUAllocator) {
+	for _, node := range k.nodes {
+		node.SetGPUAllocator(allocator)
+	}
+}
+
+// AddCollector adds the collector of custom metrics to this KubeSim.
+// The metrics are collected each time the metrics of the cluster are built (i.e., every tick), and
+// passed to submitters and metrics writers in Metrics.Extensions.
+func (k *KubeSim) AddCollector(collector metrics.Collector) {
+	k.collectors = append(k.collectors, collector)
+}
+
+// SetPodEventWriter sets the writer of the lifecycle events of pods, replacing the one given by the
+// config.
+func (k *KubeSim) SetPodEventWriter(writer metrics.PodEventWriter) {
+	k.podEvents = newPodEventRecorder(writer)
+}
+
 // Run executes the main loop, which invokes submitters and the scheduler, and binds pods to the
 // selected nodes.
 // This method blocks until ctx is done or this KubeSim finishes processing all pods.
-func (k *KubeSim) Run(ctx context.Context) error {
+// The metrics writers that implement io.Closer (e.g., to complete their files) are closed when this
+// method returns, even with an error.
+func (k *KubeSim) Run(ctx context.Context) (err error) {
+	defer func() {
+		if closeErr := k.closeMetricsWriters(); err == nil {
+			err = closeErr
+		}
+	}()
+
 	preMetricsClock := k.clock
-	met, err := metrics.BuildMetrics(k.clock, k.nodes, k.pendingPods)
+	met, err := k.buildMetrics()
 	if err != nil {
 		return err
 	}
+	k.summary = metrics.NewSummaryBuilder(k.clock)
 
 	submitterAddedEver := len(k.submitters) > 0
 
@@ -160,23 +315,39 @@ func (k *KubeSim) Run(ctx context.Context) error {
 
 		select {
 		case <-ctx.Done():
+			if err := k.podEvents.flush(); err != nil {
+				return err
+			}
+			if err := k.writeSummary(); err != nil {
+				return err
+			}
 			return ctx.Err()
 		default:
 			log.L.Debugf("Clock %s", k.clock.ToRFC3339())
 
-			if k.submit(met) != nil {
+			// Record pods that have started or finished by the clock, before submitters and
+			// schedulers delete them.
+			k.podEvents.observe(k.clock)
+
+			if err := k.submit(met); err != nil {
 				return err
 			}
 
-			if k.schedule() != nil {
+			if err := k.schedule(); err != nil {
+				return err
+			}
+
+			k.podEvents.observe(k.clock)
+			if err := k.podEvents.flush(); err != nil {
 				return err
 			}
 
 			// Rebuild metrics every tick for submitters to use.
-			met, err = metrics.BuildMetrics(k.clock, k.nodes, k.pendingPods)
+			met, err = k.buildMetrics()
 			if err != nil {
 				return err
 			}
+			k.summary.ObserveNodes(k.clock, met.Nodes)
 
 			if k.clock.Sub(preMetricsClock) > k.metricsTick {
 				preMetricsClock = k.clock
@@ -191,19 +362,71 @@ func (k *KubeSim) Run(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	k.podEvents.observe(k.clock)
+	if err := k.podEvents.flush(); err != nil {
+		return err
+	}
+
+	return k.writeSummary()
+}
+
+// Summary returns the summary of the run until the current clock.
+// It is also written by the metrics writers when Run returns.
+func (k *KubeSim) Summary() metrics.Summary {
+	if k.summary == nil {
+		k.summary = metrics.NewSummaryBuilder(k.clock)
+	}
+
+	pods := make([]*pod.Pod, 0, len(k.boundPods))
+	for _, p := range k.boundPods {
+		pods = append(pods, p)
+	}
+
+	unboundPods := []*v1.Pod{}
+	// The pending pods of the queues that cannot list them are counted, but without their delays.
+	unlistedPodsNum := 0
+	for _, q := range k.queues() {
+		if lister, ok := q.(queue.PendingPodLister); ok {
+			unboundPods = append(unboundPods, lister.PendingPods()...)
+		} else {
+			unlistedPodsNum += q.Metrics().PendingPodsNum
+		}
+	}
+	for _, b := range k.pendingBinds {
+		unboundPods = append(unboundPods, b.event.Pod)
+	}
+
+	summary := k.summary.Build(k.clock, pods, unboundPods)
+	summary.UnboundPodsNum += unlistedPodsNum
+
+	return summary
 }
 
 // List implements "k8s.io/pkg/scheduler/algorithm".NodeLister interface.
+// Nodes that are not ready (e.g., have failed) or have been cordoned are not listed, as
+// kube-scheduler does not list them.
 // Never returns an error.
 func (k *KubeSim) List() ([]*v1.Node, error) {
 	nodes := make([]*v1.Node, 0, len(k.nodes))
 	for _, node := range k.nodes {
+		if !node.IsReady() || node.ToV1().Spec.Unschedulable {
+			continue
+		}
 		nodes = append(nodes, node.ToV1())
 	}
 	return nodes, nil
 }
 
+// GetNodeInfo implements "k8s.io/pkg/scheduler/algorithm/predicates".NodeInfo interface.
+// Returns error if no node has the name.
+func (k *KubeSim) GetNodeInfo(name string) (*v1.Node, error) {
+	node, ok := k.nodes[name]
+	if !ok {
+		return nil, fmt.Errorf("No node named %q", name)
+	}
+	return node.ToV1(), nil
+}
+
 // readConfig reads and parses a config from the path (excluding file extension).
 func readConfig(path string) (*config.Config, error) {
 	viper.SetConfigName(path)
@@ -255,6 +478,11 @@ func buildClock(startClock string) (clock.Clock, error) {
 }
 
 func buildCluster(conf *config.Config) (map[string]*node.Node, error) {
+	gpuAllocator, err := config.BuildGPUAllocator(conf.GPUAllocator)
+	if err != nil {
+		return nil, err
+	}
+
 	nodes := map[string]*node.Node{}
 	for _, nodeConf := range conf.Cluster {
 		nodeV1, err := config.BuildNode(nodeConf, conf.StartClock)
@@ -263,6 +491,37 @@ func buildCluster(conf *config.Config) (map[string]*node.Node, error) {
 		}
 
 		nodeSim := node.NewNode(nodeV1)
+		gpus, err := config.BuildGPUDevices(nodeConf)
+		if err != nil {
+			return nil, err
+		}
+		if gpus != nil {
+			nodeSim.SetGPUDevices(*gpus)
+		}
+		nodeSim.SetGPUAllocator(gpuAllocator)
+
+		evictionPolicy, err := config.BuildEvictionPolicy(nodeConf)
+		if err != nil {
+			return nil, err
+		}
+		if evictionPolicy != nil {
+			nodeSim.SetEvictionPolicy(*evictionPolicy)
+		}
+		if nodeConf.OOMKill {
+			nodeSim.EnableOOMKill()
+		}
+
+		startupModel, err := config.BuildStartupModel(conf, nodeConf)
+		if err != nil {
+			return nil, err
+		}
+		if startupModel != nil {
+			nodeSim.SetStartupModel(*startupModel)
+		}
+		for _, image := range nodeConf.Images {
+			nodeSim.AddImage(image)
+		}
+
 		nodes[nodeV1.Name] = &nodeSim
 
 		log.L.Debugf("Node %s created: %v", nodeV1.Name, nodeV1)
@@ -271,17 +530,132 @@ func buildCluster(conf *config.Config) (map[string]*node.Node, error) {
 	return nodes, nil
 }
 
-func buildMetricsWriters(conf *config.Config) ([]metrics.Writer, error) {
-	writers := []metrics.Writer{}
+// buildVolumes builds the storage classes, persistent volumes, and persistent volume claims in the
+// config, whose volumes are attached to the nodes given by nodes.
+// Claims that specify volumes are bound to them.
+// Returns error if any of them is invalid.
+func buildVolumes(conf *config.Config, nodes predicates.NodeInfo) (*volume.Store, error) {
+	store := volume.NewStore(nodes)
+
+	for _, classConf := range conf.StorageClasses {
+		class, attachDuration, err := config.BuildStorageClass(classConf)
+		if err != nil {
+			return nil, err
+		}
+		store.AddStorageClass(class, attachDuration)
+	}
+
+	for _, pvConf := range conf.PersistentVolumes {
+		pv, err := config.BuildPersistentVolume(pvConf)
+		if err != nil {
+			return nil, err
+		}
+		store.AddPersistentVolume(pv)
+	}
+
+	for _, pvcConf := range conf.PersistentVolumeClaims {
+		pvc, err := config.BuildPersistentVolumeClaim(pvcConf)
+		if err != nil {
+			return nil, err
+		}
+		store.AddPersistentVolumeClaim(pvc)
+	}
+
+	return store, nil
+}
+
+// buildFailures builds the correlated failures in the config.
+// Returns error if a failure is invalid or its domain has no nodes.
+func buildFailures(conf *config.Config, startClock clock.Clock, nodes map[string]*node.Node) ([]*failure, error) {
+	failures := make([]*failure, 0, len(conf.Failures))
+	for _, failureConf := range conf.Failures {
+		level, err := config.BuildFailure(failureConf)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, n := range nodes {
+			if domain, ok := node.DomainOf(n.ToV1(), level); ok && domain == failureConf.Domain {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("No nodes in %s %q", level, failureConf.Domain))
+		}
+
+		f := &failure{
+			level:  level,
+			domain: failureConf.Domain,
+			failAt: startClock.Add(time.Duration(failureConf.At) * time.Second),
+		}
+		if failureConf.Duration > 0 {
+			recoverAt := f.failAt.Add(time.Duration(failureConf.Duration) * time.Second)
+			f.recoverAt = &recoverAt
+		}
+		failures = append(failures, f)
+	}
 
-	fileWriters, err := config.BuildMetricsLogger(conf.MetricsLogger)
+	return failures, nil
+}
+
+// buildNodeLifecycle returns the node monitor grace period and the default toleration seconds in the
+// config, or their defaults if not given.
+func buildNodeLifecycle(conf *config.Config) (time.Duration, int64, error) {
+	if conf.NodeMonitorGracePeriod < 0 || conf.DefaultTolerationSeconds < 0 {
+		return 0, 0, strongerrors.InvalidArgument(
+			errors.New("Node monitor grace period and default toleration seconds must not be negative"))
+	}
+
+	gracePeriod := node.DefaultNodeMonitorGracePeriod
+	if conf.NodeMonitorGracePeriod > 0 {
+		gracePeriod = time.Duration(conf.NodeMonitorGracePeriod) * time.Second
+	}
+
+	tolerationSeconds := node.DefaultTolerationSeconds
+	if conf.DefaultTolerationSeconds > 0 {
+		tolerationSeconds = int64(conf.DefaultTolerationSeconds)
+	}
+
+	return gracePeriod, tolerationSeconds, nil
+}
+
+// buildBindConflictPolicy returns whether pods should be returned to the queue when they conflict
+// on binding, according to the given policy.
+func buildBindConflictPolicy(policy string) (bool, error) {
+	switch policy {
+	case "", config.BindConflictOverCapacity:
+		return false, nil
+	case config.BindConflictRetry:
+		return true, nil
+	default:
+		return false, strongerrors.InvalidArgument(
+			errors.Errorf("Bind conflict policy %q not supported", policy))
+	}
+}
+
+// buildPodEventRecorder builds the recorder of the lifecycle events of pods written to the pod event
+// log in the config, or returns nil if it is not given.
+func buildPodEventRecorder(conf *config.Config) (*podEventRecorder, error) {
+	writer, err := config.BuildPodEventLogger(conf.PodEventLog)
+	if err != nil || writer == nil {
+		return nil, err
+	}
+	log.L.Infof("Pod events written to %s", writer.FileName())
+
+	return newPodEventRecorder(writer), nil
+}
+
+func buildMetricsWriters(conf *config.Config) ([]metrics.Writer, error) {
+	writers, err := config.BuildMetricsLogger(conf.MetricsLogger)
 	if err != nil {
 		return []metrics.Writer{}, err
 	}
 
-	for _, writer := range fileWriters {
-		log.L.Infof("Metrics and log written to %s", writer.FileName())
-		writers = append(writers, writer)
+	for _, loggerConf := range conf.MetricsLogger {
+		log.L.Infof("Metrics and log written to %s", loggerConf.Dest)
 	}
 
 	return writers, nil
@@ -289,9 +663,13 @@ func buildMetricsWriters(conf *config.Config) ([]metrics.Writer, error) {
 
 // toTerminate determines whether the main loop of this KubeSim can be terminated,
 // because all submitters are terminated, no pods are running on the cluster, and there are no
-// pending pods in the queue.
+// pending pods in the queue nor pods waiting to be bound.
 func (k *KubeSim) toTerminate(submitterAddedEver bool) bool {
-	if _, err := k.pendingPods.Front(); err == queue.ErrEmptyQueue { // queue is empty
+	if len(k.pendingBinds) > 0 {
+		return false
+	}
+
+	if k.queuesEmpty() {
 		for _, node := range k.nodes { // cluster is empty
 			if node.PodsNum(k.clock) > 0 {
 				return false
@@ -319,6 +697,7 @@ func (k *KubeSim) submit(metrics metrics.Metrics) error {
 				pod.UID = types.UID(pod.Name) // FIXME
 				pod.CreationTimestamp = k.clock.ToMetaV1()
 				pod.Status.Phase = v1.PodPending
+				node.AddDefaultTolerations(pod, k.defaultTolerationSeconds)
 
 				log.L.Tracef("Submitter %s: Submit %v", name, pod)
 
@@ -330,30 +709,47 @@ func (k *KubeSim) submit(metrics metrics.Metrics) error {
 					log.L.Debugf("Submitter %s: Submit %s", name, key)
 				}
 
-				err := k.pendingPods.Push(pod)
+				sched, err := k.schedulerFor(pod)
 				if err != nil {
 					return err
 				}
+
+				if err := sched.queue.Push(pod); err != nil {
+					return err
+				}
+				k.podEvents.submitted(k.clock, pod, sched.name)
 			} else if del, ok := e.(*submitter.DeleteEvent); ok {
 				log.L.Debugf("Submitter %s: Delete %s",
 					name, util.PodKeyFromNames(del.PodNamespace, del.PodName))
 
-				if delFromQ := k.pendingPods.Delete(del.PodNamespace, del.PodName); !delFromQ {
-					k.deletePodFromNode(del.PodNamespace, del.PodName)
+				if delFromQ := k.deletePodFromQueues(del.PodNamespace, del.PodName); !delFromQ {
+					if !k.cancelPendingBind(del.PodNamespace, del.PodName) {
+						k.deletePodFromNode(del.PodNamespace, del.PodName)
+						continue
+					}
 				}
+				k.podEvents.deleted(k.clock, del.PodNamespace, del.PodName)
 			} else if up, ok := e.(*submitter.UpdateEvent); ok {
 				log.L.Tracef("Submitter %s: Update %s to %v",
 					name, util.PodKeyFromNames(up.PodNamespace, up.PodName), up.NewPod)
 				log.L.Debugf("Submitter %s: Update %s",
 					name, util.PodKeyFromNames(up.PodNamespace, up.PodName))
 
-				if err := k.pendingPods.Update(up.PodNamespace, up.PodName, up.NewPod); err != nil {
+				if err := k.updatePodInQueues(up.PodNamespace, up.PodName, up.NewPod); err != nil {
 					if e, ok := err.(*queue.ErrNoMatchingPod); ok {
 						log.L.Warnf("Error updating pod: %s", e.Error())
 					} else {
 						return err
 					}
 				}
+			} else if op, ok := e.(*submitter.NodeOperationEvent); ok {
+				log.L.Debugf("Submitter %s: Node operation %s on %s", name, op.Operation.Type, op.NodeName)
+
+				changed, err := k.operateNode(op.NodeName, op.Operation)
+				if err != nil {
+					return err
+				}
+				k.nodesChanged = k.nodesChanged || changed
 			} else if _, ok := e.(*submitter.TerminateSubmitterEvent); ok {
 				log.L.Debugf("Submitter %s: Terminate", name)
 				delete(k.submitters, name)
@@ -367,52 +763,456 @@ func (k *KubeSim) submit(metrics metrics.Metrics) error {
 }
 
 func (k *KubeSim) schedule() error {
-	// Build up-to-date NodeInfo.
-	nodeInfoMap := make(map[string]*nodeinfo.NodeInfo, len(k.nodes))
+	// Fail or recover nodes, operate them as scheduled, evict pods under resource pressure, and make
+	// repartitioned GPUs available, before binding pods to them.
+	nodesChanged := k.injectFailures() || k.nodesChanged
+	k.nodesChanged = false
+
+	maintained, err := k.runMaintenance()
+	if err != nil {
+		return err
+	}
+	nodesChanged = nodesChanged || maintained
+
+	// Bind the claims of storage classes with the Immediate binding mode, as the persistent volume
+	// controller does.
+	nodesChanged = k.volumes.BindImmediateClaims() || nodesChanged
+
 	for name, node := range k.nodes {
-		info, err := node.ToNodeInfo(k.clock)
-		if err != nil {
-			return err
+		for _, killed := range node.KillOOMPods(k.clock) {
+			log.L.Debugf("Node %s: OOM killer kills %s",
+				name, util.PodKeyFromNames(killed.ToV1().Namespace, killed.ToV1().Name))
+			k.podEvents.killed(k.clock, killed)
+			nodesChanged = true
+		}
+		for _, evicted := range node.EvictPodsUnderPressure(k.clock) {
+			log.L.Debugf("Node %s: Kubelet evicts %s",
+				name, util.PodKeyFromNames(evicted.ToV1().Namespace, evicted.ToV1().Name))
+			k.podEvents.killed(k.clock, evicted)
+			nodesChanged = true
+		}
+		if node.UpdateConditionTaints(k.clock, k.nodeMonitorGracePeriod) {
+			nodesChanged = true
+		}
+		if node.UpdateGPUs(k.clock) {
+			nodesChanged = true
 		}
-		nodeInfoMap[name] = info
 	}
 
-	// The scheduler makes scheduling decision.
-	events, err := k.scheduler.Schedule(k.clock, k.pendingPods, k, nodeInfoMap)
-	if err != nil {
+	// Evict pods by NoExecute taints and drains.
+	k.evictPods()
+
+	// Bind pods whose scheduling latency has elapsed.
+	if err := k.bindPendingPods(); err != nil {
 		return err
 	}
 
-	// Do the actual scheduling process for each event.
-	for _, e := range events {
-		if bind, ok := e.(*scheduler.BindEvent); ok {
-			nodeName := bind.ScheduleResult.SuggestedHost
-			node, ok := k.nodes[nodeName]
-			if !ok {
-				return fmt.Errorf("No node named %q", nodeName)
+	// Let unschedulable pods be retried if they may have become schedulable.
+	podsReleased := k.podsReleasedSince(k.clock.Add(-k.tick))
+	for _, sched := range k.schedulers {
+		if unschedulableQueue, ok := sched.queue.(queue.UnschedulablePodQueue); ok {
+			if podsReleased || nodesChanged {
+				unschedulableQueue.MoveAllToActive(k.clock)
 			}
-			bind.Pod.Spec.NodeName = nodeName
+			unschedulableQueue.Flush(k.clock)
+		}
+	}
 
-			pod, err := node.BindPod(k.clock, bind.Pod)
+	// Let queues observe the cluster state, and evict pods as they request (e.g., to reclaim quota).
+	for _, sched := range k.schedulers {
+		sched.metrics.StartTick()
+		if clusterAwareQueue, ok := sched.queue.(queue.ClusterAwarePodQueue); ok {
+			nodeInfoMap, err := k.buildNodeInfoMap()
 			if err != nil {
 				return err
 			}
 
-			key, err := util.PodKey(bind.Pod)
-			if err != nil {
-				return err
+			for _, victim := range clusterAwareQueue.UpdateCluster(k.clock, nodeInfoMap) {
+				log.L.Debugf("Scheduler %s: Queue evicts %s",
+					sched.name, util.PodKeyFromNames(victim.Namespace, victim.Name))
+				k.podEvents.preempted(k.clock, util.PodKeyFromNames(victim.Namespace, victim.Name), "QueueEviction")
+				k.deletePodFromNode(victim.Namespace, victim.Name)
+				k.summary.AddPreemptions(1)
+				sched.metrics.Add(metrics.SchedulerCounts{PreemptionVictimsNum: 1})
+			}
+		}
+	}
+
+	// Each scheduler makes scheduling decision on the same cluster state.
+	eventsList := make([][]scheduler.Event, 0, len(k.schedulers))
+	for _, sched := range k.schedulers {
+		nodeInfoMap, err := k.buildNodeInfoMap()
+		if err != nil {
+			return err
+		}
+
+		events, err := sched.scheduler.Schedule(k.clock, sched.queue, k, nodeInfoMap)
+		if err != nil {
+			return err
+		}
+		eventsList = append(eventsList, events)
+	}
+
+	// Do the actual scheduling process for each event.
+	for i, events := range eventsList {
+		sched := k.schedulers[i]
+
+		for _, e := range events {
+			if bind, ok := e.(*scheduler.BindEvent); ok {
+				k.podEvents.scheduled(k.clock, bind)
+				sched.metrics.Add(metrics.SchedulerCounts{
+					AttemptsNum:          1,
+					ScheduledAttemptsNum: 1,
+					EvaluatedNodesNum:    int64(bind.ScheduleResult.EvaluatedNodes),
+					FeasibleNodesNum:     int64(bind.ScheduleResult.FeasibleNodes),
+					PluginSeconds:        bind.PluginTime.Seconds(),
+				})
+
+				if bind.Latency > 0 {
+					k.pendingBinds = append(k.pendingBinds, pendingBind{
+						clock: k.clock.Add(bind.Latency),
+						sched: sched,
+						event: bind,
+					})
+					continue
+				}
+
+				if err := k.bindPod(k.clock, sched, bind); err != nil {
+					return err
+				}
+			} else if del, ok := e.(*scheduler.DeleteEvent); ok {
+				k.deletePodFromNode(del.PodNamespace, del.PodName)
+				k.summary.AddPreemptions(1)
+				sched.metrics.Add(metrics.SchedulerCounts{PreemptionVictimsNum: 1})
+			} else if rep, ok := e.(*scheduler.RepartitionGPUEvent); ok {
+				if err := k.repartitionGPU(sched, rep); err != nil {
+					return err
+				}
+			} else if failed, ok := e.(*scheduler.FailedSchedulingEvent); ok {
+				k.podEvents.failedScheduling(k.clock, failed)
+				sched.metrics.Add(failedSchedulingCounts(failed))
+			} else if nom, ok := e.(*scheduler.NominateEvent); ok {
+				k.podEvents.nominated(k.clock, nom)
+				sched.metrics.Add(metrics.SchedulerCounts{PreemptionAttemptsNum: 1})
+			} else {
+				log.L.Panic("Unknown scheduler event")
 			}
-			k.boundPods[key] = pod
-		} else if del, ok := e.(*scheduler.DeleteEvent); ok {
-			k.deletePodFromNode(del.PodNamespace, del.PodName)
-		} else {
-			log.L.Panic("Unknown scheduler event")
 		}
 	}
 
 	return nil
 }
 
+// failedSchedulingCounts returns the counts of the scheduling attempt that failed with the event.
+func failedSchedulingCounts(failed *scheduler.FailedSchedulingEvent) metrics.SchedulerCounts {
+	counts := metrics.SchedulerCounts{AttemptsNum: 1, PluginSeconds: failed.PluginTime.Seconds()}
+	if failed.Reasons == nil {
+		counts.ErrorAttemptsNum = 1
+		return counts
+	}
+
+	counts.UnschedulableAttemptsNum = 1
+	counts.EvaluatedNodesNum = int64(failed.EvaluatedNodes)
+	counts.FailedPredicates = make(map[string]int64, len(failed.Reasons))
+	for reason, num := range failed.Reasons {
+		counts.FailedPredicates[reason] = int64(num)
+	}
+
+	return counts
+}
+
+// injectFailures fails and recovers the nodes in the domains of the failures due at the current
+// clock.
+// Returns true if any node has failed or recovered.
+func (k *KubeSim) injectFailures() bool {
+	changed := false
+
+	for _, f := range k.failures {
+		if !f.failed && !k.clock.Before(f.failAt) {
+			f.failed, changed = true, true
+			for _, name := range k.nodesInDomain(f.level, f.domain) {
+				for _, lost := range k.nodes[name].Fail(k.clock) {
+					log.L.Debugf("Pod %s lost on node %s",
+						util.PodKeyFromNames(lost.ToV1().Namespace, lost.ToV1().Name), name)
+					k.podEvents.killed(k.clock, lost)
+				}
+			}
+			log.L.Debugf("Failure of %s %s", f.level, f.domain)
+		}
+
+		if f.failed && !f.recovered && f.recoverAt != nil && !k.clock.Before(*f.recoverAt) {
+			f.recovered, changed = true, true
+			for _, name := range k.nodesInDomain(f.level, f.domain) {
+				k.nodes[name].Recover(k.clock)
+			}
+			log.L.Debugf("Recovery of %s %s", f.level, f.domain)
+		}
+	}
+
+	return changed
+}
+
+// nodesInDomain returns the sorted names of the nodes in the domain at the topology level.
+func (k *KubeSim) nodesInDomain(level node.TopologyLevel, domain string) []string {
+	names := []string{}
+	for name, n := range k.nodes {
+		if d, ok := node.DomainOf(n.ToV1(), level); ok && d == domain {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// buildNodeInfoMap builds up-to-date NodeInfo of all nodes.
+func (k *KubeSim) buildNodeInfoMap() (map[string]*nodeinfo.NodeInfo, error) {
+	nodeInfoMap := make(map[string]*nodeinfo.NodeInfo, len(k.nodes))
+	for name, node := range k.nodes {
+		info, err := node.ToNodeInfo(k.clock)
+		if err != nil {
+			return nil, err
+		}
+		nodeInfoMap[name] = info
+	}
+
+	// As the scheduler cache of kubernetes does, summarize the images over the nodes for
+	// ImageLocalityPriority.
+	imageStates := map[string]*nodeinfo.ImageStateSummary{}
+	nodeImageSizes := make(map[string]map[string]int64, len(k.nodes))
+	for name, node := range k.nodes {
+		nodeImageSizes[name] = node.ImageSizes(k.clock)
+		for image, size := range nodeImageSizes[name] {
+			state, ok := imageStates[image]
+			if !ok {
+				state = &nodeinfo.ImageStateSummary{Size: size}
+				imageStates[image] = state
+			}
+			state.NumNodes++
+		}
+	}
+	for name, info := range nodeInfoMap {
+		states := make(map[string]*nodeinfo.ImageStateSummary, len(nodeImageSizes[name]))
+		for image := range nodeImageSizes[name] {
+			states[image] = imageStates[image]
+		}
+		info.SetImageStates(states)
+	}
+
+	return nodeInfoMap, nil
+}
+
+// schedulerFor returns the scheduler registered with the pod's spec.schedulerName.
+// If the pod does not specify the name, v1.DefaultSchedulerName is populated.
+// Returns error if no such scheduler is registered.
+func (k *KubeSim) schedulerFor(pod *v1.Pod) (*namedScheduler, error) {
+	if pod.Spec.SchedulerName == "" {
+		pod.Spec.SchedulerName = v1.DefaultSchedulerName
+	}
+
+	for _, sched := range k.schedulers {
+		if sched.name == pod.Spec.SchedulerName {
+			return sched, nil
+		}
+	}
+
+	return nil, strongerrors.NotFound(errors.Errorf("No scheduler named %q", pod.Spec.SchedulerName))
+}
+
+// queues returns the queues of all registered schedulers.
+func (k *KubeSim) queues() []queue.PodQueue {
+	queues := make([]queue.PodQueue, 0, len(k.schedulers))
+	for _, sched := range k.schedulers {
+		queues = append(queues, sched.queue)
+	}
+
+	return queues
+}
+
+// queuesEmpty returns whether there are no pending pods in any queue.
+func (k *KubeSim) queuesEmpty() bool {
+	for _, sched := range k.schedulers {
+		// Pods that failed to be scheduled may be kept aside from the front of the queue.
+		if _, err := sched.queue.Front(); err != queue.ErrEmptyQueue ||
+			sched.queue.Metrics().PendingPodsNum > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deletePodFromQueues deletes the pod from the queue that holds it.
+// Returns true if the pod is found, or false otherwise.
+func (k *KubeSim) deletePodFromQueues(podNamespace, podName string) bool {
+	for _, sched := range k.schedulers {
+		if sched.queue.Delete(podNamespace, podName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updatePodInQueues updates the pod in the queue that holds it to the newPod.
+// Returns queue.ErrNoMatchingPod if the pod is not found in any queue.
+func (k *KubeSim) updatePodInQueues(podNamespace, podName string, newPod *v1.Pod) error {
+	var err error
+	for _, sched := range k.schedulers {
+		err = sched.queue.Update(podNamespace, podName, newPod)
+		if _, ok := err.(*queue.ErrNoMatchingPod); !ok {
+			return err
+		}
+	}
+
+	return err
+}
+
+// bindPod binds the pod in the event to the selected node at the given clock.
+// If the node cannot start the pod anymore (i.e., it lacks the resources, or the GPUs or the MIG
+// instances of the pod) and the bind conflict policy is "retry", the pod is returned to the queue of
+// the scheduler.
+func (k *KubeSim) bindPod(clock clock.Clock, sched *namedScheduler, bind *scheduler.BindEvent) error {
+	nodeName := bind.ScheduleResult.SuggestedHost
+	node, ok := k.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("No node named %q", nodeName)
+	}
+
+	if k.retryOnBindConflict && !node.CanStartPod(clock, bind.Pod) {
+		log.L.Debugf("Scheduler %s: Pod %s conflicted on node %s; retrying",
+			sched.name, util.PodKeyFromNames(bind.Pod.Namespace, bind.Pod.Name), nodeName)
+		k.podEvents.bindRetried(clock, bind.Pod, nodeName, "BindConflict", "")
+		return sched.queue.Push(bind.Pod)
+	}
+
+	// Bind the unbound claims of the pod to volumes accessible from the node, as the volume binder of
+	// kube-scheduler does.
+	if _, err := k.volumes.AssumePodVolumes(bind.Pod, nodeName); err != nil {
+		log.L.Debugf("Scheduler %s: Pod %s failed to bind volumes on node %s: %s; retrying",
+			sched.name, util.PodKeyFromNames(bind.Pod.Namespace, bind.Pod.Name), nodeName, err.Error())
+		k.podEvents.bindRetried(clock, bind.Pod, nodeName, "VolumeBindingFailed", err.Error())
+		return sched.queue.Push(bind.Pod)
+	}
+	if err := k.volumes.BindPodVolumes(bind.Pod); err != nil {
+		return err
+	}
+
+	bind.Pod.Spec.NodeName = nodeName
+
+	pod, err := node.BindPodWithAttachDuration(clock, bind.Pod, k.volumes.AttachDuration(bind.Pod))
+	if err != nil {
+		return err
+	}
+
+	key, err := util.PodKey(bind.Pod)
+	if err != nil {
+		return err
+	}
+	k.boundPods[key] = pod
+	k.podEvents.bound(clock, pod)
+
+	return nil
+}
+
+// repartitionGPU changes the MIG partition layout of the GPU in the event, and evicts pods using it.
+func (k *KubeSim) repartitionGPU(sched *namedScheduler, rep *scheduler.RepartitionGPUEvent) error {
+	node, ok := k.nodes[rep.NodeName]
+	if !ok {
+		return fmt.Errorf("No node named %q", rep.NodeName)
+	}
+
+	victims, err := node.RepartitionGPU(k.clock, rep.GPU, rep.MIGProfiles)
+	if err != nil {
+		return err
+	}
+	log.L.Debugf("Scheduler %s: Repartition GPU %d of node %s into %v",
+		sched.name, rep.GPU, rep.NodeName, rep.MIGProfiles)
+
+	for _, victim := range victims {
+		log.L.Debugf("Scheduler %s: Repartitioning evicts %s",
+			sched.name, util.PodKeyFromNames(victim.ToV1().Namespace, victim.ToV1().Name))
+		k.deletePodFromNode(victim.ToV1().Namespace, victim.ToV1().Name)
+	}
+
+	return nil
+}
+
+// bindPendingPods binds pods whose binding clock is not after the current clock, in the order of
+// their binding clocks.
+func (k *KubeSim) bindPendingPods() error {
+	sort.SliceStable(k.pendingBinds, func(i, j int) bool {
+		return k.pendingBinds[i].clock.Before(k.pendingBinds[j].clock)
+	})
+
+	bound := 0
+	for _, b := range k.pendingBinds {
+		if k.clock.Before(b.clock) {
+			break
+		}
+		if err := k.bindPod(b.clock, b.sched, b.event); err != nil {
+			return err
+		}
+		bound++
+	}
+	k.pendingBinds = k.pendingBinds[bound:]
+
+	return nil
+}
+
+// cancelPendingBind cancels the binding of the pod that has not taken effect yet.
+// Returns true if the pod is found, or false otherwise.
+func (k *KubeSim) cancelPendingBind(podNamespace, podName string) bool {
+	for i, b := range k.pendingBinds {
+		if b.event.Pod.Namespace == podNamespace && b.event.Pod.Name == podName {
+			k.pendingBinds = append(k.pendingBinds[:i], k.pendingBinds[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// podsReleasedSince returns whether any pod on the cluster has released its resources (i.e.,
+// terminated or been deleted) between the given clock and the current clock.
+func (k *KubeSim) podsReleasedSince(since clock.Clock) bool {
+	for _, node := range k.nodes {
+		for _, pod := range node.PodList() {
+			wasAlive := pod.IsRunning(since) || pod.IsTerminating(since)
+			isAlive := pod.IsRunning(k.clock) || pod.IsTerminating(k.clock)
+			if wasAlive && !isAlive {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// buildMetrics builds the metrics at the current clock, with the metrics of the schedulers and the
+// collectors.
+func (k *KubeSim) buildMetrics() (metrics.Metrics, error) {
+	met, err := metrics.BuildMetrics(k.clock, k.nodes, k.queues()...)
+	if err != nil {
+		return metrics.Metrics{}, err
+	}
+
+	met.Schedulers = make(metrics.SchedulersMetrics, len(k.schedulers))
+	for _, sched := range k.schedulers {
+		met.Schedulers[sched.name] = sched.metrics.DeepCopy()
+	}
+
+	queues := k.queues()
+	for _, collector := range k.collectors {
+		name, value := collector.Collect(k.clock, met.Nodes, queues, k.boundPods)
+		if err := met.AddExtension(name, value); err != nil {
+			return metrics.Metrics{}, err
+		}
+	}
+
+	return met, nil
+}
+
 func (k *KubeSim) writeMetrics(met *metrics.Metrics) error {
 	for _, writer := range k.metricsWriters {
 		if err := writer.Write(met); err != nil {
@@ -423,6 +1223,35 @@ func (k *KubeSim) writeMetrics(met *metrics.Metrics) error {
 	return nil
 }
 
+// closeMetricsWriters closes the metrics writers that can be closed, and returns the first error if
+// any.
+func (k *KubeSim) closeMetricsWriters() error {
+	var err error
+	for _, writer := range k.metricsWriters {
+		if closer, ok := writer.(io.Closer); ok {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	return err
+}
+
+// writeSummary writes the summary of the run by the metrics writers that can write it.
+func (k *KubeSim) writeSummary() error {
+	summary := k.Summary()
+	for _, writer := range k.metricsWriters {
+		if summaryWriter, ok := writer.(metrics.SummaryWriter); ok {
+			if err := summaryWriter.WriteSummary(&summary); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (k *KubeSim) gcTerminatedPodsInNodes() {
 	for _, node := range k.nodes {
 		node.GCTerminatedPods(k.clock)
@@ -432,6 +1261,7 @@ func (k *KubeSim) gcTerminatedPodsInNodes() {
 func (k *KubeSim) deletePodFromNode(podNamespace, podName string) {
 	key := util.PodKeyFromNames(podNamespace, podName)
 	k.boundPods[key].Delete(k.clock)
+	k.podEvents.deleted(k.clock, podNamespace, podName)
 
 	nodeName := k.boundPods[key].ToV1().Spec.NodeName
 	deletedFromNode := k.nodes[nodeName].DeletePod(k.clock, podNamespace, podName) // nolint
@@ -0,0 +1,422 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubesim
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/core"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/config"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/scheduler"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/submitter"
+)
+
+const testStartClock = "2019-01-01T00:00:00Z"
+
+// testScheduler is a scheduler that binds all pods in its queue to the nodes in order of their
+// attempts, i.e., a pod is bound to nodeNames[i] at its i-th attempt, or to the last node after
+// that.
+type testScheduler struct {
+	nodeNames []string
+	attempts  map[string]int
+}
+
+// Schedule implements scheduler.Scheduler interface.
+func (s *testScheduler) Schedule(
+	clock clock.Clock,
+	podQueue queue.PodQueue,
+	nodeLister algorithm.NodeLister,
+	nodeInfoMap map[string]*nodeinfo.NodeInfo) ([]scheduler.Event, error) {
+
+	events := []scheduler.Event{}
+	for {
+		pod, err := podQueue.Pop()
+		if err == queue.ErrEmptyQueue {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if s.attempts == nil {
+			s.attempts = map[string]int{}
+		}
+		i := s.attempts[pod.Name]
+		if i >= len(s.nodeNames) {
+			i = len(s.nodeNames) - 1
+		}
+		s.attempts[pod.Name]++
+
+		events = append(events, &scheduler.BindEvent{
+			Pod: pod,
+			ScheduleResult: core.ScheduleResult{
+				SuggestedHost:  s.nodeNames[i],
+				EvaluatedNodes: 1,
+				FeasibleNodes:  1,
+			},
+		})
+	}
+
+	return events, nil
+}
+
+var _ = scheduler.Scheduler(&testScheduler{})
+
+// testSubmitter is a submitter that submits the events at the seconds from the start clock, and
+// terminates after submitting all of them.
+type testSubmitter struct {
+	events map[int][]submitter.Event
+}
+
+// Submit implements submitter.Submitter interface.
+func (s *testSubmitter) Submit(
+	clock clock.Clock,
+	nodeLister algorithm.NodeLister,
+	metrics metrics.Metrics) ([]submitter.Event, error) {
+
+	start, err := time.Parse(time.RFC3339, testStartClock)
+	if err != nil {
+		return nil, err
+	}
+
+	sec := int(clock.ToMetaV1().Sub(start) / time.Second)
+	events := s.events[sec]
+	delete(s.events, sec)
+	if len(s.events) == 0 {
+		events = append(events, &submitter.TerminateSubmitterEvent{})
+	}
+
+	return events, nil
+}
+
+var _ = submitter.Submitter(&testSubmitter{})
+
+// testPodEventWriter is a metrics.PodEventWriter that keeps the events written.
+type testPodEventWriter struct {
+	events []metrics.PodEvent
+}
+
+// WritePodEvents implements metrics.PodEventWriter interface.
+func (w *testPodEventWriter) WritePodEvents(events []metrics.PodEvent) error {
+	w.events = append(w.events, events...)
+	return nil
+}
+
+var _ = metrics.PodEventWriter(&testPodEventWriter{})
+
+// summaries returns the events of the types in the form of "<seconds from the start clock> <type>
+// <pod name> [<reason>]".
+func (w *testPodEventWriter) summaries(types ...metrics.PodEventType) []string {
+	start, _ := time.Parse(time.RFC3339, testStartClock)
+
+	summaries := []string{}
+	for _, e := range w.events {
+		for _, typ := range types {
+			if e.Type != typ {
+				continue
+			}
+
+			at, _ := time.Parse(time.RFC3339, e.Clock)
+			summary := fmt.Sprintf("%d %s %s", int(at.Sub(start)/time.Second), e.Type, e.Name)
+			if e.Reason != "" {
+				summary += " " + e.Reason
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries
+}
+
+// newTestConfig creates a config of a cluster of the nodes, with a tick of a second.
+func newTestConfig(nodes ...config.NodeConfig) *config.Config {
+	return &config.Config{
+		LogLevel:   "error",
+		Tick:       1,
+		StartClock: testStartClock,
+		Cluster:    nodes,
+	}
+}
+
+// newTestNodeConfig creates a config of a node with the allocatable resources.
+func newTestNodeConfig(name string, allocatable map[v1.ResourceName]string) config.NodeConfig {
+	return config.NodeConfig{
+		Metadata: metav1.ObjectMeta{Name: name},
+		Status:   config.NodeStatus{Allocatable: allocatable},
+	}
+}
+
+// newTestKubeSim creates a new KubeSim with the config, whose default scheduler binds pods to the
+// nodes as testScheduler does, and whose pod events are written to the returned writer.
+func newTestKubeSim(t *testing.T, conf *config.Config, nodeNames ...string) (*KubeSim, *testPodEventWriter) {
+	k, err := NewKubeSim(conf, queue.NewFIFOQueue(), &testScheduler{nodeNames: nodeNames})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &testPodEventWriter{}
+	k.SetPodEventWriter(writer)
+
+	return k, writer
+}
+
+// newTestPod creates a pod scheduled by the scheduler, which requests the resources and runs for
+// the seconds using them.
+func newTestPod(name, schedulerName string, requests v1.ResourceList, seconds int) *v1.Pod {
+	spec := fmt.Sprintf("- seconds: %d\n  resourceUsage:\n", seconds)
+	for rsrc, q := range requests {
+		spec += fmt.Sprintf("    %s: %s\n", rsrc, q.String())
+	}
+
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: map[string]string{"simSpec": spec},
+		},
+		Spec: v1.PodSpec{
+			SchedulerName: schedulerName,
+			Containers: []v1.Container{{
+				Name:      "container",
+				Resources: v1.ResourceRequirements{Requests: requests, Limits: requests},
+			}},
+		},
+	}
+}
+
+// islandAllocator is a node.GPUAllocator that selects the free GPUs with the smallest indices in a
+// single island, and fails if no island can accommodate all of them.
+type islandAllocator struct{}
+
+// Allocate implements node.GPUAllocator interface.
+func (islandAllocator) Allocate(gpus []node.GPU, free []int, n int) ([]int, bool) {
+	byIsland := map[int][]int{}
+	for _, i := range free {
+		island := gpus[i].Island
+		byIsland[island] = append(byIsland[island], i)
+		if len(byIsland[island]) == n {
+			return byIsland[island], true
+		}
+	}
+
+	return nil, false
+}
+
+var _ = node.GPUAllocator(islandAllocator{})
+
+func TestKubeSimBindConflictOnGPUs(t *testing.T) {
+	// node-0 has GPUs 0 and 1 in island 0, and GPU 2 in island 1.
+	// Both schedulers bind their pods to node-0 at clock 0; pod-0 takes GPU 0, so that pod-1 cannot
+	// be allocated two GPUs in a single island until pod-0 finishes, although node-0 has two free GPUs.
+	nodeConf := newTestNodeConfig("node-0", map[v1.ResourceName]string{"cpu": "4", "memory": "8Gi", "pods": "4"})
+	nodeConf.Devices.GPUs = []config.GPUConfig{{Island: 0}, {Island: 0}, {Island: 1}}
+
+	oneGPU := v1.ResourceList{node.GPUResourceName: resource.MustParse("1")}
+	twoGPUs := v1.ResourceList{node.GPUResourceName: resource.MustParse("2")}
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{
+			policy: config.BindConflictOverCapacity,
+			want: []string{
+				"0 Bound pod-0",
+				"0 Bound pod-1",
+				"0 Failed pod-1 OverCapacity",
+				"0 Started pod-0",
+				"10 Finished pod-0",
+			},
+		},
+		{
+			policy: config.BindConflictRetry,
+			want: []string{
+				"0 Bound pod-0",
+				"0 BindRetried pod-1 BindConflict",
+				"0 Started pod-0",
+				"1 BindRetried pod-1 BindConflict",
+				"2 BindRetried pod-1 BindConflict",
+				"3 BindRetried pod-1 BindConflict",
+				"4 BindRetried pod-1 BindConflict",
+				"5 BindRetried pod-1 BindConflict",
+				"6 BindRetried pod-1 BindConflict",
+				"7 BindRetried pod-1 BindConflict",
+				"8 BindRetried pod-1 BindConflict",
+				"9 BindRetried pod-1 BindConflict",
+				"10 Finished pod-0",
+				"10 Bound pod-1",
+				"10 Started pod-1",
+				"20 Finished pod-1",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		conf := newTestConfig(nodeConf)
+		conf.BindConflictPolicy = test.policy
+
+		k, writer := newTestKubeSim(t, conf, "node-0")
+		k.SetGPUAllocator(islandAllocator{})
+		k.AddScheduler("scheduler-1", queue.NewFIFOQueue(), &testScheduler{nodeNames: []string{"node-0"}})
+		k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+			0: {
+				&submitter.SubmitEvent{Pod: newTestPod("pod-0", "", oneGPU, 10)},
+				&submitter.SubmitEvent{Pod: newTestPod("pod-1", "scheduler-1", twoGPUs, 10)},
+			},
+		}})
+
+		if err := k.Run(context.Background()); err != nil {
+			t.Fatalf("%s: %v", test.policy, err)
+		}
+
+		got := writer.summaries(
+			metrics.PodBound, metrics.PodBindRetried, metrics.PodFailed, metrics.PodStarted, metrics.PodFinished)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got: %v\nwant: %v", test.policy, got, test.want)
+		}
+	}
+}
+
+func TestKubeSimVolumeBinding(t *testing.T) {
+	// The volume is accessible only from zone-b, and attached to a node in 5 seconds.
+	// The scheduler binds pod-0 to node-a in zone-a at its first attempt, and to node-b after that.
+	conf := newTestConfig(
+		newTestNodeConfig("node-a", map[v1.ResourceName]string{"cpu": "4", "memory": "8Gi", "pods": "4"}),
+		newTestNodeConfig("node-b", map[v1.ResourceName]string{"cpu": "4", "memory": "8Gi", "pods": "4"}))
+	conf.Cluster[0].Topology.Zone = "zone-a"
+	conf.Cluster[1].Topology.Zone = "zone-b"
+	conf.StorageClasses = []config.StorageClassConfig{
+		{Name: "standard", VolumeBindingMode: "WaitForFirstConsumer", AttachSeconds: 5},
+	}
+	conf.PersistentVolumes = []config.PersistentVolumeConfig{
+		{Name: "pv-0", StorageClassName: "standard", Capacity: "10Gi", Zone: "zone-b"},
+	}
+	conf.PersistentVolumeClaims = []config.PersistentVolumeClaimConfig{
+		{Name: "pvc-0", StorageClassName: "standard", Request: "5Gi"},
+	}
+
+	k, writer := newTestKubeSim(t, conf, "node-a", "node-b")
+
+	pod := newTestPod("pod-0", "", v1.ResourceList{"cpu": resource.MustParse("1")}, 10)
+	pod.Spec.Volumes = []v1.Volume{{
+		Name: "data",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-0"},
+		},
+	}}
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+		0: {&submitter.SubmitEvent{Pod: pod}},
+	}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The binding to node-a is rejected and retried, and pod-0 starts on node-b after the volume is
+	// attached.
+	want := []string{
+		"0 BindRetried pod-0 VolumeBindingFailed",
+		"1 Bound pod-0",
+		"6 Started pod-0",
+		"16 Finished pod-0",
+	}
+	got := writer.summaries(metrics.PodBound, metrics.PodBindRetried, metrics.PodStarted, metrics.PodFinished)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v\nwant: %v", got, want)
+	}
+
+	pvc, err := k.Volumes().GetPersistentVolumeClaimInfo("default", "pvc-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Spec.VolumeName != "pv-0" {
+		t.Errorf("got: %q\nwant: %q", pvc.Spec.VolumeName, "pv-0")
+	}
+}
+
+func TestKubeSimSchedulerMetrics(t *testing.T) {
+	// The default scheduler checks the resources of node-0, while the other scheduler binds pods to
+	// it.
+	conf := newTestConfig(newTestNodeConfig("node-0", map[v1.ResourceName]string{"cpu": "1", "memory": "8Gi", "pods": "4"}))
+	k, _ := newTestKubeSim(t, conf, "node-0")
+	sched := scheduler.NewGenericScheduler(false)
+	sched.AddPredicate("PodFitsResources", predicates.PodFitsResources)
+	k.AddScheduler(v1.DefaultSchedulerName, queue.NewFIFOQueue(), &sched)
+	k.AddScheduler("other", queue.NewFIFOQueue(), &testScheduler{nodeNames: []string{"node-0"}})
+
+	requests := v1.ResourceList{"cpu": resource.MustParse("1")}
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+		0: {
+			&submitter.SubmitEvent{Pod: newTestPod("pod-0", "", requests, 3)},
+			&submitter.SubmitEvent{Pod: newTestPod("pod-1", "", requests, 3)},
+			&submitter.SubmitEvent{Pod: newTestPod("pod-2", "other", v1.ResourceList{"memory": resource.MustParse("1Gi")}, 3)},
+		},
+	}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	met, err := k.buildMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each scheduler counts its own attempts. pod-1 does not fit node-0 until pod-0 finishes at 3
+	// seconds.
+	want := map[string]metrics.SchedulerCounts{
+		v1.DefaultSchedulerName: {
+			AttemptsNum:              5,
+			ScheduledAttemptsNum:     2,
+			UnschedulableAttemptsNum: 3,
+			FailedPredicates:         map[string]int64{"Insufficient cpu": 3},
+			EvaluatedNodesNum:        5,
+			FeasibleNodesNum:         2,
+			AvgFeasibleNodesNum:      0.4,
+		},
+		"other": {
+			AttemptsNum:          1,
+			ScheduledAttemptsNum: 1,
+			EvaluatedNodesNum:    1,
+			FeasibleNodesNum:     1,
+			AvgFeasibleNodesNum:  1,
+		},
+	}
+	got := map[string]metrics.SchedulerCounts{}
+	for name, met := range met.Schedulers {
+		if met.LastTick.AttemptsNum != 0 {
+			t.Errorf("%s: got: %d attempts at the last tick\nwant: 0", name, met.LastTick.AttemptsNum)
+		}
+		// The wall-clock time of the plugins varies.
+		met.PluginSeconds = 0
+		got[name] = met.SchedulerCounts
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %+v\nwant: %+v", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubesim
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	"github.com/cpuguy83/strongerrors"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/config"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// maintenance is an operation on a node scheduled by the config.
+type maintenance struct {
+	at        clock.Clock
+	nodeName  string
+	operation node.Operation
+	done      bool
+}
+
+// AddPodDisruptionBudget adds the pod disruption budget to this KubeSim, which limits the number of
+// pods that drains evict at a time.
+// If a budget with the same namespace and name has been added, it is replaced with the new one.
+func (k *KubeSim) AddPodDisruptionBudget(pdb *policy.PodDisruptionBudget) {
+	for i, p := range k.pdbs {
+		if p.Namespace == pdb.Namespace && p.Name == pdb.Name {
+			k.pdbs[i] = pdb
+			return
+		}
+	}
+
+	k.pdbs = append(k.pdbs, pdb)
+}
+
+// buildMaintenance builds the node operations scheduled by the config, in the order of their
+// clocks.
+// Returns error if an operation is invalid or its node does not exist.
+func buildMaintenance(
+	conf *config.Config, startClock clock.Clock, nodes map[string]*node.Node,
+) ([]*maintenance, error) {
+
+	schedule := make([]*maintenance, 0, len(conf.Maintenance))
+	for _, maintConf := range conf.Maintenance {
+		op, err := config.BuildNodeOperation(maintConf)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := nodes[maintConf.Node]; !ok {
+			return nil, strongerrors.InvalidArgument(errors.Errorf("No node named %q", maintConf.Node))
+		}
+
+		schedule = append(schedule, &maintenance{
+			at:        startClock.Add(time.Duration(maintConf.At) * time.Second),
+			nodeName:  maintConf.Node,
+			operation: op,
+		})
+	}
+
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].at.Before(schedule[j].at) })
+	return schedule, nil
+}
+
+// buildPodDisruptionBudgets builds the pod disruption budgets in the config.
+func buildPodDisruptionBudgets(conf *config.Config) ([]*policy.PodDisruptionBudget, error) {
+	pdbs := make([]*policy.PodDisruptionBudget, 0, len(conf.PodDisruptionBudgets))
+	for _, pdbConf := range conf.PodDisruptionBudgets {
+		pdb, err := config.BuildPodDisruptionBudget(pdbConf)
+		if err != nil {
+			return nil, err
+		}
+		pdbs = append(pdbs, pdb)
+	}
+
+	return pdbs, nil
+}
+
+// runMaintenance does the node operations scheduled at or before the current clock.
+// Returns true if any node may have become schedulable.
+func (k *KubeSim) runMaintenance() (bool, error) {
+	changed := false
+	for _, m := range k.maintenance {
+		if m.done || k.clock.Before(m.at) {
+			continue
+		}
+
+		m.done = true
+		c, err := k.operateNode(m.nodeName, m.operation)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || c
+	}
+
+	return changed, nil
+}
+
+// operateNode does the operation on the node.
+// Returns true if the node may have become schedulable.
+func (k *KubeSim) operateNode(nodeName string, op node.Operation) (bool, error) {
+	n, ok := k.nodes[nodeName]
+	if !ok {
+		return false, fmt.Errorf("No node named %q", nodeName)
+	}
+	if (op.Type == node.AddTaint || op.Type == node.RemoveTaint) && op.Taint == nil {
+		return false, strongerrors.InvalidArgument(
+			errors.Errorf("Node operation %s on node %q has no taint", op.Type, nodeName))
+	}
+	if op.Type == node.SetCondition && op.Condition == nil {
+		return false, strongerrors.InvalidArgument(
+			errors.Errorf("Node operation %s on node %q has no condition", op.Type, nodeName))
+	}
+
+	log.L.Debugf("Node %s: %s", nodeName, op.Type)
+
+	switch op.Type {
+	case node.Cordon:
+		n.Cordon()
+	case node.Uncordon:
+		n.Uncordon()
+		return true, nil
+	case node.Drain:
+		n.StartDrain()
+	case node.AddTaint:
+		n.AddTaint(k.clock, *op.Taint)
+	case node.RemoveTaint:
+		return n.RemoveTaint(*op.Taint), nil
+	case node.SetCondition:
+		c := op.Condition
+		return n.SetCondition(k.clock, c.Type, c.Status, c.Reason, c.Message), nil
+	default:
+		return false, strongerrors.InvalidArgument(errors.Errorf("Node operation %q not supported", op.Type))
+	}
+
+	return false, nil
+}
+
+// evictPods evicts the pods that do not tolerate the NoExecute taints of their nodes, and the pods
+// on draining nodes as far as the pod disruption budgets allow.
+// Evicted pods are deleted with their termination grace periods.
+func (k *KubeSim) evictPods() {
+	names := make([]string, 0, len(k.nodes))
+	for name := range k.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		n := k.nodes[name]
+
+		for _, victim := range n.PodsToEvictByTaints(k.clock) {
+			log.L.Debugf("Node %s: Taint evicts %s",
+				name, util.PodKeyFromNames(victim.ToV1().Namespace, victim.ToV1().Name))
+			k.deletePodFromNode(victim.ToV1().Namespace, victim.ToV1().Name)
+		}
+
+		if !n.IsDraining() {
+			continue
+		}
+
+		remaining := 0
+		for _, p := range sortedPods(n.PodList()) {
+			if p.IsTerminating(k.clock) {
+				remaining++
+			}
+			if !p.IsRunning(k.clock) {
+				continue
+			}
+
+			remaining++
+			if !k.disruptionAllowed(p.ToV1()) {
+				continue
+			}
+			log.L.Debugf("Node %s: Drain evicts %s",
+				name, util.PodKeyFromNames(p.ToV1().Namespace, p.ToV1().Name))
+			k.deletePodFromNode(p.ToV1().Namespace, p.ToV1().Name)
+		}
+
+		if remaining == 0 {
+			n.FinishDrain()
+			log.L.Debugf("Node %s: Drained", name)
+		}
+	}
+}
+
+// disruptionAllowed returns whether all pod disruption budgets matching the pod allow it to be
+// evicted now, as the eviction API of kubernetes does.
+func (k *KubeSim) disruptionAllowed(v1Pod *v1.Pod) bool {
+	for _, pdb := range k.pdbs {
+		matches, err := pdbMatches(pdb, v1Pod)
+		if err != nil {
+			log.L.Warnf("Error matching pod disruption budget %s: %s",
+				util.PodKeyFromNames(pdb.Namespace, pdb.Name), err.Error())
+			return false
+		}
+		if !matches {
+			continue
+		}
+
+		var healthy, expected int
+		for _, p := range k.boundPods {
+			if m, _ := pdbMatches(pdb, p.ToV1()); !m {
+				continue
+			}
+			if p.IsRunning(k.clock) {
+				healthy++
+				expected++
+			} else if p.IsTerminating(k.clock) {
+				expected++
+			}
+		}
+
+		desiredHealthy, err := desiredHealthyPods(pdb, expected)
+		if err != nil {
+			log.L.Warnf("Error computing pod disruption budget %s: %s",
+				util.PodKeyFromNames(pdb.Namespace, pdb.Name), err.Error())
+			return false
+		}
+		if healthy-desiredHealthy <= 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pdbMatches returns whether the pod is in the namespace and matches the selector of the pod
+// disruption budget.
+func pdbMatches(pdb *policy.PodDisruptionBudget, v1Pod *v1.Pod) (bool, error) {
+	if pdb.Namespace != v1Pod.Namespace || pdb.Spec.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return false, err
+	}
+	return !selector.Empty() && selector.Matches(labels.Set(v1Pod.Labels)), nil
+}
+
+// desiredHealthyPods returns the minimum number of healthy pods that the pod disruption budget
+// requires, given the expected number of pods.
+// Percentages are rounded up.
+func desiredHealthyPods(pdb *policy.PodDisruptionBudget, expected int) (int, error) {
+	if pdb.Spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetValueFromIntOrPercent(pdb.Spec.MaxUnavailable, expected, true)
+		if err != nil {
+			return 0, err
+		}
+		if desired := expected - maxUnavailable; desired > 0 {
+			return desired, nil
+		}
+		return 0, nil
+	}
+
+	if pdb.Spec.MinAvailable != nil {
+		return intstr.GetValueFromIntOrPercent(pdb.Spec.MinAvailable, expected, true)
+	}
+
+	return 0, nil
+}
+
+// sortedPods sorts the pods by their keys.
+func sortedPods(pods []*pod.Pod) []*pod.Pod {
+	sort.Slice(pods, func(i, j int) bool {
+		return util.PodKeyFromNames(pods[i].ToV1().Namespace, pods[i].ToV1().Name) <
+			util.PodKeyFromNames(pods[j].ToV1().Namespace, pods[j].ToV1().Name)
+	})
+	return pods
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubesim
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/config"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/scheduler"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/submitter"
+)
+
+var testAllocatable = map[v1.ResourceName]string{"cpu": "4", "memory": "8Gi", "pods": "4"}
+
+func TestDrainRespectsPodDisruptionBudget(t *testing.T) {
+	// node-0 is drained at 5 seconds, while the budget keeps 2 of the 3 pods labeled app=web
+	// available.
+	conf := newTestConfig(newTestNodeConfig("node-0", testAllocatable))
+	conf.Maintenance = []config.MaintenanceConfig{{At: 5, Node: "node-0", Operation: "drain"}}
+	conf.PodDisruptionBudgets = []config.PodDisruptionBudgetConfig{
+		{Namespace: "default", Name: "web", Selector: map[string]string{"app": "web"}, MinAvailable: "2"},
+	}
+
+	k, writer := newTestKubeSim(t, conf, "node-0")
+
+	events := []submitter.Event{}
+	for _, name := range []string{"web-0", "web-1", "web-2"} {
+		pod := newTestPod(name, "", v1.ResourceList{"cpu": resource.MustParse("1")}, 20)
+		pod.Labels = map[string]string{"app": "web"}
+		// The evicted pod leaves the node at once, rather than after the default grace period.
+		gracePeriod := int64(0)
+		pod.Spec.TerminationGracePeriodSeconds = &gracePeriod
+		events = append(events, &submitter.SubmitEvent{Pod: pod})
+	}
+	// The submitter keeps the simulation running after the pods finish, so that the drain finishes.
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{0: events, 25: {}}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one pod is evicted, and the others run to completion, after which the drain finishes.
+	want := []string{
+		"5 Deleted web-0",
+		"20 Finished web-1",
+		"20 Finished web-2",
+	}
+	got := writer.summaries(metrics.PodDeleted, metrics.PodFinished)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v\nwant: %v", got, want)
+	}
+
+	if n := k.nodes["node-0"]; n.IsDraining() || !n.ToV1().Spec.Unschedulable {
+		t.Errorf("got: draining %v, unschedulable %v\nwant: drained and cordoned",
+			n.IsDraining(), n.ToV1().Spec.Unschedulable)
+	}
+}
+
+func TestCordonExcludesNodeFromScheduling(t *testing.T) {
+	// node-0 is cordoned at the start and uncordoned at 5 seconds, and node-1 accepts 2 pods.
+	node1 := newTestNodeConfig("node-1", map[v1.ResourceName]string{"cpu": "4", "memory": "8Gi", "pods": "2"})
+	conf := newTestConfig(newTestNodeConfig("node-0", testAllocatable), node1)
+	conf.Maintenance = []config.MaintenanceConfig{
+		{At: 0, Node: "node-0", Operation: "cordon"},
+		{At: 5, Node: "node-0", Operation: "uncordon"},
+	}
+
+	k, writer := newTestKubeSim(t, conf)
+	sched := scheduler.NewGenericScheduler(false)
+	sched.AddPredicate("CheckNodeUnschedulable", predicates.CheckNodeUnschedulablePredicate)
+	sched.AddPredicate("PodFitsResources", predicates.PodFitsResources)
+	k.AddScheduler(v1.DefaultSchedulerName, queue.NewFIFOQueue(), &sched)
+
+	requests := v1.ResourceList{"cpu": resource.MustParse("1")}
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+		1: {
+			&submitter.SubmitEvent{Pod: newTestPod("pod-0", "", requests, 20)},
+			&submitter.SubmitEvent{Pod: newTestPod("pod-1", "", requests, 20)},
+		},
+		6: {&submitter.SubmitEvent{Pod: newTestPod("pod-2", "", requests, 20)}},
+	}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pods submitted while node-0 is cordoned are bound to node-1, and the one submitted after
+	// node-0 is uncordoned is bound to node-0, as node-1 is full.
+	want := map[string]string{"pod-0": "node-1", "pod-1": "node-1", "pod-2": "node-0"}
+	got := map[string]string{}
+	for _, e := range writer.events {
+		if e.Type == metrics.PodBound {
+			got[e.Name] = e.Node
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestNoExecuteTaintEvictsPods(t *testing.T) {
+	// node-0 is tainted with NoExecute at 5 seconds.
+	conf := newTestConfig(newTestNodeConfig("node-0", testAllocatable))
+	conf.Maintenance = []config.MaintenanceConfig{{
+		At:        5,
+		Node:      "node-0",
+		Operation: "taint",
+		Taint:     config.TaintConfig{Key: "maintenance", Effect: "NoExecute"},
+	}}
+
+	k, writer := newTestKubeSim(t, conf, "node-0")
+
+	requests := v1.ResourceList{"cpu": resource.MustParse("1")}
+	seconds := int64(10)
+	intolerant := newTestPod("intolerant", "", requests, 30)
+	tolerantFor10s := newTestPod("tolerant-for-10s", "", requests, 30)
+	tolerantFor10s.Spec.Tolerations = []v1.Toleration{{
+		Key:               "maintenance",
+		Operator:          v1.TolerationOpExists,
+		Effect:            v1.TaintEffectNoExecute,
+		TolerationSeconds: &seconds,
+	}}
+	tolerant := newTestPod("tolerant", "", requests, 30)
+	tolerant.Spec.Tolerations = []v1.Toleration{{
+		Key:      "maintenance",
+		Operator: v1.TolerationOpExists,
+		Effect:   v1.TaintEffectNoExecute,
+	}}
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+		0: {
+			&submitter.SubmitEvent{Pod: intolerant},
+			&submitter.SubmitEvent{Pod: tolerantFor10s},
+			&submitter.SubmitEvent{Pod: tolerant},
+		},
+	}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The intolerant pod is evicted as soon as the taint is added, the pod tolerating it for 10
+	// seconds is evicted when the toleration expires, and the pod tolerating it forever finishes.
+	want := []string{
+		"5 Deleted intolerant",
+		"15 Deleted tolerant-for-10s",
+		"30 Finished tolerant",
+	}
+	got := writer.summaries(metrics.PodDeleted, metrics.PodFinished)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v\nwant: %v", got, want)
+	}
+}
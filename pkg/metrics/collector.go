@@ -0,0 +1,152 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+// Collector defines the interface of collectors of custom metrics, which are computed each time the
+// metrics of a cluster are built, and stored in Metrics.Extensions.
+type Collector interface {
+	// Collect computes a metrics at the clock from the metrics of the nodes, the queues of the
+	// schedulers, and the pods ever bound to nodes keyed by namespace/name.
+	// Returns the name of the metrics and its value, which must be marshalable into JSON.
+	// The name must not be empty nor any of the keys of the sections of Metrics (e.g., "Nodes").
+	// This method must not modify the given arguments.
+	Collect(
+		clock clock.Clock,
+		nodes map[string]node.Metrics,
+		queues []queue.PodQueue,
+		boundPods map[string]*pod.Pod) (name string, value interface{})
+}
+
+// AddExtension adds the value of the custom metrics with the name to the extensions of this Metrics.
+// Returns error if the name is empty, is any of the keys of the sections, or has been added.
+func (m *Metrics) AddExtension(name string, value interface{}) error {
+	switch name {
+	case "":
+		return fmt.Errorf("Extension name must not be empty")
+	case ClockKey, NodesMetricsKey, PodsMetricsKey, QueueMetricsKey, TopologyMetricsKey, QOSMetricsKey,
+		SchedulersMetricsKey:
+		return fmt.Errorf("Extension name %q conflicts with a section of metrics", name)
+	}
+
+	if _, ok := m.Extensions[name]; ok {
+		return fmt.Errorf("Extension %q already exists", name)
+	}
+
+	if m.Extensions == nil {
+		m.Extensions = map[string]interface{}{}
+	}
+	m.Extensions[name] = value
+
+	return nil
+}
+
+// sortedExtensionNames returns the sorted names of the extensions of the metrics.
+func sortedExtensionNames(metrics *Metrics) []string {
+	names := make([]string, 0, len(metrics.Extensions))
+	for name := range metrics.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// extensionEntry is a scalar in the value of an extension, with its path from the value.
+type extensionEntry struct {
+	// path is the map keys in brackets and struct fields after dots (e.g., "[nvidia.com/gpu].Index"),
+	// or empty if the value itself is a scalar.
+	path  string
+	value string
+}
+
+// flattenExtension flattens the value of an extension into the scalars in maps (in the order of
+// their keys), structs, and slices of maps or structs in it.
+func flattenExtension(value interface{}) []extensionEntry {
+	entries := []extensionEntry{}
+	flattenValue(reflect.ValueOf(value), "", &entries)
+	return entries
+}
+
+func flattenValue(v reflect.Value, path string, entries *[]extensionEntry) {
+	if !v.IsValid() {
+		*entries = append(*entries, extensionEntry{path: path, value: "<nil>"})
+		return
+	}
+
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case resource.Quantity:
+			*entries = append(*entries, extensionEntry{path: path, value: value.String()})
+			return
+		case fmt.Stringer:
+			*entries = append(*entries, extensionEntry{path: path, value: value.String()})
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			*entries = append(*entries, extensionEntry{path: path, value: "<nil>"})
+			return
+		}
+		flattenValue(v.Elem(), path, entries)
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			flattenValue(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key.Interface()), entries)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.PkgPath == "" && field.Tag.Get("json") != "-" {
+				flattenValue(v.Field(i), path+"."+field.Name, entries)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if elemKind := v.Type().Elem().Kind(); elemKind == reflect.Map || elemKind == reflect.Struct {
+			for i := 0; i < v.Len(); i++ {
+				flattenValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), entries)
+			}
+			return
+		}
+		*entries = append(*entries, extensionEntry{path: path, value: fmt.Sprint(v.Interface())})
+
+	case reflect.Float32, reflect.Float64:
+		*entries = append(*entries, extensionEntry{path: path, value: fmt.Sprintf("%.6g", v.Float())})
+
+	default:
+		*entries = append(*entries, extensionEntry{path: path, value: fmt.Sprint(v.Interface())})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestAddExtension(t *testing.T) {
+	metrics := Metrics{}
+	if err := metrics.AddExtension("custom", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty names, the keys of the sections, and the names already added are rejected.
+	for _, name := range []string{
+		"", ClockKey, NodesMetricsKey, PodsMetricsKey, QueueMetricsKey, TopologyMetricsKey, QOSMetricsKey,
+		SchedulersMetricsKey, "custom",
+	} {
+		if err := metrics.AddExtension(name, 2); err == nil {
+			t.Errorf("%q: got: no error\nwant: error", name)
+		}
+	}
+
+	want := map[string]interface{}{"custom": 1}
+	if !reflect.DeepEqual(metrics.Extensions, want) {
+		t.Errorf("got: %v\nwant: %v", metrics.Extensions, want)
+	}
+}
+
+// testExtension is a struct value of an extension.
+type testExtension struct {
+	Count    int
+	Ratio    float64
+	Request  v1.ResourceList
+	Pointer  *int
+	Indices  []int
+	Items    []testExtensionItem
+	Ignored  string `json:"-"`
+	internal string
+}
+
+type testExtensionItem struct {
+	Name string
+}
+
+func TestFlattenExtension(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []extensionEntry
+	}{
+		{"scalar", 3, []extensionEntry{{"", "3"}}},
+		{"float", 2.0 / 3, []extensionEntry{{"", "0.666667"}}},
+		{"nil", nil, []extensionEntry{{"", "<nil>"}}},
+		{"quantity", resource.MustParse("1500m"), []extensionEntry{{"", "1500m"}}},
+		{
+			// Map entries are flattened in the order of their keys.
+			"map",
+			map[string]int{"b": 2, "a": 1},
+			[]extensionEntry{{"[a]", "1"}, {"[b]", "2"}},
+		},
+		{
+			"struct",
+			&testExtension{
+				Count:    1,
+				Ratio:    0.5,
+				Request:  v1.ResourceList{"cpu": resource.MustParse("2")},
+				Indices:  []int{0, 2},
+				Items:    []testExtensionItem{{"x"}, {"y"}},
+				Ignored:  "ignored",
+				internal: "internal",
+			},
+			[]extensionEntry{
+				{".Count", "1"},
+				{".Ratio", "0.5"},
+				{".Request[cpu]", "2"},
+				{".Pointer", "<nil>"},
+				{".Indices", "[0 2]"},
+				{".Items[0].Name", "x"},
+				{".Items[1].Name", "y"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := flattenExtension(test.value); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got: %v\nwant: %v", test.name, got, test.want)
+		}
+	}
+}
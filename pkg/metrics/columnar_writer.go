@@ -0,0 +1,537 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// columnType is the type of the values in a column of a table.
+type columnType int
+
+const (
+	stringColumn columnType = iota
+	int64Column
+	float64Column
+	// clockColumn is a column of time.Time, written in RFC3339 to CSV and as a timestamp in
+	// milliseconds to Parquet.
+	clockColumn
+)
+
+// column is a column of a table.
+type column struct {
+	name string
+	typ  columnType
+}
+
+// table is a table in the long format, with a row per observation (e.g., a resource of a node at a
+// clock).
+type table struct {
+	name    string
+	columns []column
+}
+
+var (
+	nodesTable = table{name: "nodes", columns: []column{
+		{"clock", clockColumn},
+		{"node", stringColumn},
+		{"resource", stringColumn},
+		{"allocatable", float64Column},
+		{"request", float64Column},
+		{"usage", float64Column},
+	}}
+
+	podsTable = table{name: "pods", columns: []column{
+		{"clock", clockColumn},
+		{"namespace", stringColumn},
+		{"pod", stringColumn},
+		{"node", stringColumn},
+		{"status", stringColumn},
+		{"phase", stringColumn},
+		{"priority", int64Column},
+		{"qos_class", stringColumn},
+		{"executed_seconds", int64Column},
+		{"resource", stringColumn},
+		{"request", float64Column},
+		{"limit", float64Column},
+		{"usage", float64Column},
+	}}
+
+	queueTable = table{name: "queue", columns: []column{
+		{"clock", clockColumn},
+		{"cluster_queue", stringColumn},
+		{"pending", int64Column},
+		{"admitted", int64Column},
+	}}
+
+	schedulersTable = table{name: "schedulers", columns: []column{
+		{"clock", clockColumn},
+		{"scheduler", stringColumn},
+		{"attempts", int64Column},
+		{"scheduled_attempts", int64Column},
+		{"unschedulable_attempts", int64Column},
+		{"error_attempts", int64Column},
+		{"evaluated_nodes", int64Column},
+		{"feasible_nodes", int64Column},
+		{"plugin_seconds", float64Column},
+		{"preemption_attempts", int64Column},
+		{"preemption_victims", int64Column},
+	}}
+
+	failedPredicatesTable = table{name: "failed_predicates", columns: []column{
+		{"clock", clockColumn},
+		{"scheduler", stringColumn},
+		{"reason", stringColumn},
+		{"nodes", int64Column},
+	}}
+
+	summaryTable = table{name: "summary", columns: []column{
+		{"metric", stringColumn},
+		{"resource", stringColumn},
+		{"value", float64Column},
+	}}
+)
+
+// tableEncoder defines the interface of encoders that write the rows of a table to a file.
+type tableEncoder interface {
+	// writeRow writes the row, whose values are of the types of the columns of the table.
+	writeRow(row []interface{}) error
+	// close writes the rows buffered, if any, and closes the file.
+	close() error
+}
+
+// ColumnarWriter is a Writer that writes metrics to a directory as tables in the long format, one file
+// per table: nodes (a row per resource of each node), pods (a row per resource of each pod), queue (a
+// row for the queue and each ClusterQueue), schedulers, and failed_predicates (a row per reason of
+// each scheduler).
+// The counts of the schedulers are cumulative since the start of the simulation.
+// The summary of a run is written as the summary table, with a row per metric.
+// Resources are in cores for cpu, in bytes for memory and storage, and in counts for the others.
+type ColumnarWriter struct {
+	dir        string
+	ext        string
+	newEncoder func(path string, table table) (tableEncoder, error)
+	encoders   map[string]tableEncoder
+	closed     bool
+}
+
+// NewCSVWriter creates a new ColumnarWriter that writes the tables to CSV files (e.g., nodes.csv) in
+// the directory, creating it if it does not exist.
+// The rows are flushed on each Write, so that the files can be read while the simulation runs.
+// Returns error if failed to create the directory or files.
+func NewCSVWriter(dir string) (*ColumnarWriter, error) {
+	return newColumnarWriter(dir, ".csv", newCSVEncoder)
+}
+
+// NewParquetWriter creates a new ColumnarWriter that writes the tables to Apache Parquet files (e.g.,
+// nodes.parquet) in the directory, creating it if it does not exist.
+// The files are complete only after the summary of the run is written or the writer is closed.
+// Returns error if failed to create the directory or files.
+func NewParquetWriter(dir string) (*ColumnarWriter, error) {
+	return newColumnarWriter(dir, ".parquet", newParquetEncoder)
+}
+
+func newColumnarWriter(
+	dir, ext string,
+	newEncoder func(path string, table table) (tableEncoder, error),
+) (*ColumnarWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	w := &ColumnarWriter{
+		dir:        dir,
+		ext:        ext,
+		newEncoder: newEncoder,
+		encoders:   map[string]tableEncoder{},
+	}
+	for _, t := range []table{nodesTable, podsTable, queueTable, schedulersTable, failedPredicatesTable} {
+		if err := w.addEncoder(t); err != nil {
+			w.close() // nolint: errcheck
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// Dir returns the directory to which this ColumnarWriter writes.
+func (w *ColumnarWriter) Dir() string { return w.dir }
+
+// Write implements Writer interface.
+// Returns error if failed to write the rows.
+func (w *ColumnarWriter) Write(metrics *Metrics) error {
+	if w.closed {
+		return nil
+	}
+
+	clk := metrics.Clock.ToMetaV1().Time
+
+	for _, rows := range []struct {
+		table string
+		rows  [][]interface{}
+	}{
+		{nodesTable.name, buildNodeRows(clk, metrics.Nodes)},
+		{podsTable.name, buildPodRows(clk, metrics.Pods)},
+		{queueTable.name, buildQueueRows(clk, metrics.Queue)},
+		{schedulersTable.name, buildSchedulerRows(clk, metrics.Schedulers)},
+		{failedPredicatesTable.name, buildFailedPredicateRows(clk, metrics.Schedulers)},
+	} {
+		if err := w.writeRows(rows.table, rows.rows); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// WriteSummary implements SummaryWriter interface.
+// It writes the summary table, and closes the files of all tables.
+// Metrics written after this are ignored.
+func (w *ColumnarWriter) WriteSummary(summary *Summary) error {
+	if w.closed {
+		return nil
+	}
+
+	if err := w.addEncoder(summaryTable); err != nil {
+		return err
+	}
+	if err := w.writeRows(summaryTable.name, buildSummaryRows(summary)); err != nil {
+		return err
+	}
+
+	return w.close()
+}
+
+// Close implements io.Closer interface.
+// If the summary has not been written (e.g., the run failed), it closes the files of the tables
+// written so far, without the summary table.
+func (w *ColumnarWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	return w.close()
+}
+
+// close closes the files of all tables, and returns the first error if any.
+func (w *ColumnarWriter) close() error {
+	w.closed = true
+
+	var err error
+	for _, name := range []string{
+		nodesTable.name, podsTable.name, queueTable.name, schedulersTable.name, failedPredicatesTable.name,
+		summaryTable.name,
+	} {
+		encoder, ok := w.encoders[name]
+		if !ok {
+			continue
+		}
+		if closeErr := encoder.close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+func (w *ColumnarWriter) addEncoder(t table) error {
+	encoder, err := w.newEncoder(filepath.Join(w.dir, t.name+w.ext), t)
+	if err != nil {
+		return err
+	}
+	w.encoders[t.name] = encoder
+
+	return nil
+}
+
+func (w *ColumnarWriter) writeRows(table string, rows [][]interface{}) error {
+	encoder := w.encoders[table]
+	for _, row := range rows {
+		if err := encoder.writeRow(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flush flushes the rows written to the encoders that can write them before closing.
+func (w *ColumnarWriter) flush() error {
+	for _, encoder := range w.encoders {
+		if f, ok := encoder.(interface{ flush() error }); ok {
+			if err := f.flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+var _ = Writer(&ColumnarWriter{})
+var _ = SummaryWriter(&ColumnarWriter{})
+var _ = io.Closer(&ColumnarWriter{})
+
+func buildNodeRows(clk time.Time, metrics map[string]node.Metrics) [][]interface{} {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := [][]interface{}{}
+	for _, name := range names {
+		met := metrics[name]
+		for _, rsrc := range unionResourceNames(met.Allocatable, met.TotalResourceRequest, met.TotalResourceUsage) {
+			rows = append(rows, []interface{}{
+				clk, name, string(rsrc),
+				resourceValue(met.Allocatable, rsrc),
+				resourceValue(met.TotalResourceRequest, rsrc),
+				resourceValue(met.TotalResourceUsage, rsrc),
+			})
+		}
+	}
+
+	return rows
+}
+
+// buildPodRows builds a row for each resource of each pod, or a row with an empty resource for a pod
+// without any resources.
+func buildPodRows(clk time.Time, metrics map[string]pod.Metrics) [][]interface{} {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rows := [][]interface{}{}
+	for _, key := range keys {
+		met := metrics[key]
+		namespace, name := util.SplitPodKey(key)
+
+		rsrcs := unionResourceNames(met.ResourceRequest, met.ResourceLimit, met.ResourceUsage)
+		if len(rsrcs) == 0 {
+			rsrcs = []v1.ResourceName{""}
+		}
+
+		for _, rsrc := range rsrcs {
+			rows = append(rows, []interface{}{
+				clk, namespace, name, met.Node, met.Status.String(), string(met.Phase),
+				int64(met.Priority), string(met.QOSClass), int64(met.ExecutedSeconds),
+				string(rsrc),
+				resourceValue(met.ResourceRequest, rsrc),
+				resourceValue(met.ResourceLimit, rsrc),
+				resourceValue(met.ResourceUsage, rsrc),
+			})
+		}
+	}
+
+	return rows
+}
+
+// buildQueueRows builds a row of the pending pods in the queue with an empty ClusterQueue, and a row
+// for each ClusterQueue.
+func buildQueueRows(clk time.Time, metrics queue.Metrics) [][]interface{} {
+	admitted := int64(0)
+	names := make([]string, 0, len(metrics.ClusterQueues))
+	for name, met := range metrics.ClusterQueues {
+		names = append(names, name)
+		admitted += int64(met.AdmittedPodsNum)
+	}
+	sort.Strings(names)
+
+	rows := [][]interface{}{{clk, "", int64(metrics.PendingPodsNum), admitted}}
+	for _, name := range names {
+		met := metrics.ClusterQueues[name]
+		rows = append(rows, []interface{}{
+			clk, name, int64(met.PendingPodsNum), int64(met.AdmittedPodsNum),
+		})
+	}
+
+	return rows
+}
+
+func buildSchedulerRows(clk time.Time, metrics SchedulersMetrics) [][]interface{} {
+	rows := [][]interface{}{}
+	for _, name := range sortedSchedulerNames(metrics) {
+		met := metrics[name]
+		rows = append(rows, []interface{}{
+			clk, name,
+			met.AttemptsNum, met.ScheduledAttemptsNum, met.UnschedulableAttemptsNum, met.ErrorAttemptsNum,
+			met.EvaluatedNodesNum, met.FeasibleNodesNum, met.PluginSeconds,
+			met.PreemptionAttemptsNum, met.PreemptionVictimsNum,
+		})
+	}
+
+	return rows
+}
+
+func buildFailedPredicateRows(clk time.Time, metrics SchedulersMetrics) [][]interface{} {
+	rows := [][]interface{}{}
+	for _, name := range sortedSchedulerNames(metrics) {
+		met := metrics[name]
+		for _, reason := range sortedFailedPredicates(met.SchedulerCounts) {
+			rows = append(rows, []interface{}{clk, name, reason, met.FailedPredicates[reason]})
+		}
+	}
+
+	return rows
+}
+
+func buildSummaryRows(summary *Summary) [][]interface{} {
+	rows := [][]interface{}{
+		{"makespan_seconds", "", summary.MakespanSeconds},
+		{"bound_pods", "", float64(summary.BoundPodsNum)},
+		{"unbound_pods", "", float64(summary.UnboundPodsNum)},
+		{"completed_pods", "", float64(summary.CompletedPodsNum)},
+		{"over_capacity_pods", "", float64(summary.OverCapacityPodsNum)},
+		{"preemptions", "", float64(summary.PreemptionsNum)},
+		{"wasted_pods", "", float64(summary.WastedWork.PodsNum)},
+		{"wasted_seconds", "", summary.WastedWork.Seconds},
+	}
+
+	for _, kpi := range []struct {
+		name string
+		dist Distribution
+	}{
+		{"queueing_delay_seconds", summary.QueueingDelay},
+		{"job_completion_time_seconds", summary.JobCompletionTime},
+		{"slowdown", summary.Slowdown},
+	} {
+		rows = append(rows,
+			[]interface{}{kpi.name + "_avg", "", kpi.dist.Avg},
+			[]interface{}{kpi.name + "_p50", "", kpi.dist.P50},
+			[]interface{}{kpi.name + "_p95", "", kpi.dist.P95},
+			[]interface{}{kpi.name + "_p99", "", kpi.dist.P99},
+			[]interface{}{kpi.name + "_max", "", kpi.dist.Max},
+		)
+	}
+
+	for _, rsrc := range sortedResourceNames(summary.Utilization) {
+		rows = append(rows, []interface{}{"utilization", string(rsrc), summary.Utilization[rsrc]})
+	}
+	for _, rsrc := range sortedResourceNames(summary.WastedWork.ResourceSeconds) {
+		rows = append(rows, []interface{}{
+			"wasted_resource_seconds", string(rsrc), summary.WastedWork.ResourceSeconds[rsrc],
+		})
+	}
+
+	return rows
+}
+
+// unionResourceNames returns the sorted names of the resources in any of the lists.
+func unionResourceNames(lists ...v1.ResourceList) []v1.ResourceName {
+	set := map[v1.ResourceName]struct{}{}
+	for _, list := range lists {
+		for rsrc := range list {
+			set[rsrc] = struct{}{}
+		}
+	}
+
+	names := make([]v1.ResourceName, 0, len(set))
+	for rsrc := range set {
+		names = append(names, rsrc)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
+
+// resourceValue returns the quantity of the resource in the list as a float, or 0 if the list does not
+// have the resource.
+func resourceValue(list v1.ResourceList, rsrc v1.ResourceName) float64 {
+	quantity, ok := list[rsrc]
+	if !ok {
+		return 0
+	}
+	return float64(quantity.MilliValue()) / 1000
+}
+
+// csvEncoder is a tableEncoder that writes a table to a CSV file with a header row.
+type csvEncoder struct {
+	file  *os.File
+	buf   *bufio.Writer
+	out   *csv.Writer
+	table table
+}
+
+func newCSVEncoder(path string, table table) (tableEncoder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(file)
+	e := &csvEncoder{file: file, buf: buf, out: csv.NewWriter(buf), table: table}
+
+	header := make([]string, 0, len(table.columns))
+	for _, col := range table.columns {
+		header = append(header, col.name)
+	}
+	if err := e.out.Write(header); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *csvEncoder) writeRow(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, col := range e.table.columns {
+		switch col.typ {
+		case stringColumn:
+			record[i] = row[i].(string)
+		case int64Column:
+			record[i] = strconv.FormatInt(row[i].(int64), 10)
+		case float64Column:
+			record[i] = strconv.FormatFloat(row[i].(float64), 'f', -1, 64)
+		case clockColumn:
+			record[i] = row[i].(time.Time).Format(time.RFC3339)
+		}
+	}
+
+	return e.out.Write(record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.out.Flush()
+	if err := e.out.Error(); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *csvEncoder) close() error {
+	if err := e.flush(); err != nil {
+		e.file.Close() // nolint: errcheck
+		return err
+	}
+	return e.file.Close()
+}
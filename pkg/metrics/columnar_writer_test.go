@@ -0,0 +1,198 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+)
+
+// newTestColumnarMetrics creates the metrics of newTestMetrics with a node and a scheduler.
+func newTestColumnarMetrics(seconds int, pendingPodsNum int) *Metrics {
+	met := newTestMetrics(seconds, pendingPodsNum)
+	met.Nodes = map[string]node.Metrics{
+		"node-0": {
+			Allocatable:          v1.ResourceList{"cpu": resource.MustParse("4"), "memory": resource.MustParse("1Gi")},
+			TotalResourceRequest: v1.ResourceList{"cpu": resource.MustParse("500m")},
+			TotalResourceUsage:   v1.ResourceList{"cpu": resource.MustParse("250m")},
+		},
+	}
+	met.Schedulers = SchedulersMetrics{
+		"default-scheduler": {SchedulerCounts: SchedulerCounts{
+			AttemptsNum:              int64(seconds),
+			ScheduledAttemptsNum:     int64(seconds - 1),
+			UnschedulableAttemptsNum: 1,
+			FailedPredicates:         map[string]int64{"Insufficient cpu": 2},
+			PluginSeconds:            0.125,
+		}},
+	}
+
+	return met
+}
+
+// testColumnarTables is the tables of the metrics of two ticks, with the summary of summaryRows.
+var testColumnarTables = map[string][][]string{
+	"nodes": {
+		{"clock", "node", "resource", "allocatable", "request", "usage"},
+		{"2019-01-01T00:00:10Z", "node-0", "cpu", "4", "0.5", "0.25"},
+		{"2019-01-01T00:00:10Z", "node-0", "memory", "1073741824", "0", "0"},
+		{"2019-01-01T00:00:20Z", "node-0", "cpu", "4", "0.5", "0.25"},
+		{"2019-01-01T00:00:20Z", "node-0", "memory", "1073741824", "0", "0"},
+	},
+	"pods": {
+		{"clock", "namespace", "pod", "node", "status", "phase", "priority", "qos_class", "executed_seconds",
+			"resource", "request", "limit", "usage"},
+		{"2019-01-01T00:00:10Z", "default", "pod-\"0\"\\\n", "node-0", "Ok", "Running", "0", "", "0",
+			"nvidia.com/gpu", "1", "0", "0"},
+		{"2019-01-01T00:00:20Z", "default", "pod-\"0\"\\\n", "node-0", "Ok", "Running", "0", "", "0",
+			"nvidia.com/gpu", "1", "0", "0"},
+	},
+	"queue": {
+		{"clock", "cluster_queue", "pending", "admitted"},
+		{"2019-01-01T00:00:10Z", "", "2", "0"},
+		{"2019-01-01T00:00:10Z", "cq-0", "2", "0"},
+		{"2019-01-01T00:00:20Z", "", "1", "0"},
+		{"2019-01-01T00:00:20Z", "cq-0", "1", "0"},
+	},
+	"schedulers": {
+		{"clock", "scheduler", "attempts", "scheduled_attempts", "unschedulable_attempts", "error_attempts",
+			"evaluated_nodes", "feasible_nodes", "plugin_seconds", "preemption_attempts", "preemption_victims"},
+		{"2019-01-01T00:00:10Z", "default-scheduler", "10", "9", "1", "0", "0", "0", "0.125", "0", "0"},
+		{"2019-01-01T00:00:20Z", "default-scheduler", "20", "19", "1", "0", "0", "0", "0.125", "0", "0"},
+	},
+	"failed_predicates": {
+		{"clock", "scheduler", "reason", "nodes"},
+		{"2019-01-01T00:00:10Z", "default-scheduler", "Insufficient cpu", "2"},
+		{"2019-01-01T00:00:20Z", "default-scheduler", "Insufficient cpu", "2"},
+	},
+}
+
+func TestColumnarWriter(t *testing.T) {
+	summary := Summary{
+		MakespanSeconds: 30,
+		BoundPodsNum:    1,
+		QueueingDelay:   Distribution{Count: 1, Avg: 1.5, P50: 1.5, P95: 1.5, P99: 1.5, Max: 1.5},
+		Utilization:     map[v1.ResourceName]float64{"cpu": 0.0625},
+	}
+	summaryRows := [][]string{
+		{"metric", "resource", "value"},
+		{"makespan_seconds", "", "30"},
+		{"bound_pods", "", "1"},
+	}
+
+	for _, newWriter := range []func(dir string) (*ColumnarWriter, error){NewCSVWriter, NewParquetWriter} {
+		dir, err := ioutil.TempDir("", "kubesim-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		w, err := newWriter(filepath.Join(dir, "tables"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, pendingPodsNum := range []int{2, 1} {
+			if err := w.Write(newTestColumnarMetrics(10*(i+1), pendingPodsNum)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.WriteSummary(&summary); err != nil {
+			t.Fatal(err)
+		}
+		// Metrics written after the summary are ignored.
+		if err := w.Write(newTestColumnarMetrics(30, 0)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, expected := range testColumnarTables {
+			actual := readTable(t, w, name)
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s %s: got: %q\nwant: %q", w.ext, name, actual, expected)
+			}
+		}
+
+		// The header, the 8 counts, the 5 stats of the 3 KPIs, and the utilization of cpu.
+		actual := readTable(t, w, "summary")
+		if len(actual) != 1+8+15+1 || !reflect.DeepEqual(actual[:len(summaryRows)], summaryRows) ||
+			!reflect.DeepEqual(actual[len(actual)-1], []string{"utilization", "cpu", "0.0625"}) {
+			t.Errorf("%s summary: got: %q\nwant: %d rows beginning with %q", w.ext, actual, 1+8+15+1,
+				summaryRows)
+		}
+	}
+}
+
+func TestColumnarWriterClose(t *testing.T) {
+	for _, newWriter := range []func(dir string) (*ColumnarWriter, error){NewCSVWriter, NewParquetWriter} {
+		dir, err := ioutil.TempDir("", "kubesim-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		w, err := newWriter(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(newTestColumnarMetrics(10, 2)); err != nil {
+			t.Fatal(err)
+		}
+		// Closed without the summary, e.g., when the run failed.
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := testColumnarTables["nodes"][:3]
+		if actual := readTable(t, w, "nodes"); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: got: %q\nwant: %q", w.ext, actual, expected)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "summary"+w.ext)); !os.IsNotExist(err) {
+			t.Errorf("%s: got: %v\nwant: no summary table", w.ext, err)
+		}
+	}
+}
+
+// readTable reads the table written by the writer, with the header as the first row.
+func readTable(t *testing.T, w *ColumnarWriter, name string) [][]string {
+	path := filepath.Join(w.Dir(), name+w.ext)
+	if w.ext == ".parquet" {
+		file := readParquet(t, path)
+		return append([][]string{file.header}, file.rows...)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
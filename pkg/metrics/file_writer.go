@@ -15,6 +15,7 @@
 package metrics
 
 import (
+	"io"
 	"os"
 	"strings"
 )
@@ -32,17 +33,9 @@ type FileWriter struct {
 // Otherwise, the file of a given path is set and it will be truncated if it exists.
 // Returns error if failed to create a file.
 func NewFileWriter(dest string, formatter Formatter) (*FileWriter, error) {
-	var file *os.File
-	if dest == "/dev/stdout" || strings.ToLower(dest) == "stdout" {
-		file = os.Stdout
-	} else if dest == "/dev/stderr" || strings.ToLower(dest) == "stderr" {
-		file = os.Stderr
-	} else {
-		f, err := os.Create(dest)
-		if err != nil {
-			return nil, err
-		}
-		file = f
+	file, err := openDest(dest)
+	if err != nil {
+		return nil, err
 	}
 
 	return &FileWriter{
@@ -51,6 +44,25 @@ func NewFileWriter(dest string, formatter Formatter) (*FileWriter, error) {
 	}, nil
 }
 
+// openDest opens the output device or file at the given path, as NewFileWriter does.
+func openDest(dest string) (*os.File, error) {
+	if dest == "/dev/stdout" || strings.ToLower(dest) == "stdout" {
+		return os.Stdout, nil
+	} else if dest == "/dev/stderr" || strings.ToLower(dest) == "stderr" {
+		return os.Stderr, nil
+	}
+
+	return os.Create(dest)
+}
+
+// closeDest closes the file opened by openDest, unless it is the standard out or error.
+func closeDest(file *os.File) error {
+	if file == os.Stdout || file == os.Stderr {
+		return nil
+	}
+	return file.Close()
+}
+
 // FileName returns the name of file underlying this FileWriter.
 func (w *FileWriter) FileName() string { return w.file.Name() }
 
@@ -70,4 +82,35 @@ func (w *FileWriter) Write(metrics *Metrics) error {
 	return err
 }
 
+// WriteSummary implements SummaryWriter interface.
+// The summary is formatted with the underlying formatter if it implements SummaryFormatter, or to
+// JSON otherwise.
+// Returns error if failed to format.
+func (w *FileWriter) WriteSummary(summary *Summary) error {
+	formatter, ok := w.formatter.(SummaryFormatter)
+	if !ok {
+		formatter = &JSONFormatter{}
+	}
+
+	str, err := formatter.FormatSummary(summary)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.WriteString(str)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write([]byte{'\n'})
+
+	return err
+}
+
+// Close implements io.Closer interface.
+// It closes the underlying file, unless it is the standard out or error.
+func (w *FileWriter) Close() error {
+	return closeDest(w.file)
+}
+
 var _ = Writer(&FileWriter{})
+var _ = SummaryWriter(&FileWriter{})
+var _ = io.Closer(&FileWriter{})
@@ -0,0 +1,58 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+func TestFormatClusterQueueUsage(t *testing.T) {
+	met := queue.Metrics{ClusterQueues: map[string]queue.ClusterQueueMetrics{
+		"cq-0": {Usage: map[string]v1.ResourceList{
+			"spot": {"nvidia.com/gpu": resource.MustParse("1")},
+			"on-demand": {
+				"pods":           resource.MustParse("2"),
+				"nvidia.com/gpu": resource.MustParse("2"),
+				"memory":         resource.MustParse("4Gi"),
+				"cpu":            resource.MustParse("8"),
+			},
+		}},
+	}}
+	expected := []string{
+		"on-demand/cpu", "on-demand/memory", "on-demand/nvidia.com/gpu", "on-demand/pods", "spot/nvidia.com/gpu",
+	}
+
+	h := &HumanReadableFormatter{}
+	tf := &TableFormatter{}
+	// Maps are iterated in random orders, so the order is checked repeatedly.
+	for i := 0; i < 20; i++ {
+		for _, str := range []string{h.formatQueueMetrics(met), tf.formatQueueMetrics(met)} {
+			last := -1
+			for _, col := range expected {
+				j := strings.Index(str, col)
+				if j <= last {
+					t.Fatalf("got: %s\nwant: usage in the order of %v", str, expected)
+				}
+				last = j
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// FragmentationMetricsName is the name of the extension of FragmentationMetrics.
+const FragmentationMetricsName = "Fragmentation"
+
+// FragmentationMetrics is the fragmentation of the free resources (i.e., allocatable minus requested)
+// of the schedulable nodes, relative to the distribution of the resource requests of the pending
+// pods.
+// Whether a pod fits on a node is judged by their total amounts of resources, ignoring the other
+// constraints (e.g., node selectors, taints, and the topology of GPUs).
+type FragmentationMetrics struct {
+	// PendingPodsNum is the number of the pending pods listed by the queues.
+	PendingPodsNum int
+	// Resources is the fragmentation of each resource over the cluster.
+	Resources map[v1.ResourceName]ResourceFragmentation
+	// Nodes is the fragmentation on each schedulable node.
+	Nodes map[string]NodeFragmentation
+	// PartiallyUsedGPUNodesNum is the number of the schedulable nodes with some, but not all, of their
+	// GPUs requested by pods.
+	PartiallyUsedGPUNodesNum int
+}
+
+// ResourceFragmentation is the fragmentation of a resource over the cluster.
+// Amounts are in cores for cpu, in bytes for memory and storage, and in counts for the others.
+type ResourceFragmentation struct {
+	// Free is the total free amount on the schedulable nodes.
+	Free float64
+	// LargestFree is the largest free amount on a node, i.e., the largest request of the resource
+	// that can be allocated to a pod.
+	LargestFree float64
+	// Unusable is the expected free amount that a pod drawn at random from the pending pods cannot
+	// use, because it does not fit on the nodes with the amount or does not request the resource.
+	Unusable float64
+	// Index is Unusable divided by Free, or 0 if there are no free amount or no pending pods.
+	Index float64
+}
+
+// NodeFragmentation is the fragmentation on a node.
+type NodeFragmentation struct {
+	// LargestPodShape is the free resources on the node, i.e., the largest resource requests of a pod
+	// that can be allocated on it.
+	LargestPodShape v1.ResourceList
+	// Index is the fragmentation index of each resource with free amount on the node.
+	Index map[v1.ResourceName]float64 `json:",omitempty"`
+}
+
+// FragmentationCollector is a Collector of FragmentationMetrics.
+// Pending pods are listed from the queues that implement queue.PendingPodLister.
+type FragmentationCollector struct{}
+
+// podShape is a distinct shape of the resource requests of the pending pods.
+type podShape struct {
+	requests v1.ResourceList
+	// weight is the ratio of the pending pods of this shape.
+	weight float64
+}
+
+// Collect implements Collector interface.
+func (c *FragmentationCollector) Collect(
+	_ clock.Clock,
+	nodes map[string]node.Metrics,
+	queues []queue.PodQueue,
+	_ map[string]*pod.Pod) (string, interface{}) {
+
+	shapes, pendingPodsNum := buildPodShapes(queues)
+	met := FragmentationMetrics{
+		PendingPodsNum: pendingPodsNum,
+		Resources:      map[v1.ResourceName]ResourceFragmentation{},
+		Nodes:          map[string]NodeFragmentation{},
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		nodeMet := nodes[name]
+		if nodeMet.Failed || nodeMet.Unschedulable {
+			continue
+		}
+
+		if gpuReq, gpuAlloc := nodeMet.TotalResourceRequest[node.GPUResourceName],
+			nodeMet.Allocatable[node.GPUResourceName]; gpuReq.Sign() > 0 && gpuReq.Cmp(gpuAlloc) < 0 {
+			met.PartiallyUsedGPUNodesNum++
+		}
+
+		free := freeResources(nodeMet)
+		nodeFrag := NodeFragmentation{LargestPodShape: free, Index: map[v1.ResourceName]float64{}}
+
+		fits := make([]bool, len(shapes))
+		for i, shape := range shapes {
+			fits[i] = fitsIn(shape.requests, free)
+		}
+
+		for rsrc := range free {
+			if rsrc == v1.ResourcePods {
+				continue
+			}
+
+			amount := resourceValue(free, rsrc)
+			unusable := 0.0
+			for i, shape := range shapes {
+				if req := shape.requests[rsrc]; req.Sign() == 0 || !fits[i] {
+					unusable += shape.weight * amount
+				}
+			}
+
+			frag := met.Resources[rsrc]
+			frag.Free += amount
+			frag.Unusable += unusable
+			if amount > frag.LargestFree {
+				frag.LargestFree = amount
+			}
+			met.Resources[rsrc] = frag
+
+			if amount > 0 {
+				nodeFrag.Index[rsrc] = unusable / amount
+			}
+		}
+
+		met.Nodes[name] = nodeFrag
+	}
+
+	for rsrc, frag := range met.Resources {
+		if frag.Free > 0 {
+			frag.Index = frag.Unusable / frag.Free
+			met.Resources[rsrc] = frag
+		}
+	}
+
+	return FragmentationMetricsName, met
+}
+
+var _ = Collector(&FragmentationCollector{})
+
+// buildPodShapes returns the distinct shapes of the resource requests of the pending pods in the
+// queues, and the number of the pods.
+func buildPodShapes(queues []queue.PodQueue) ([]podShape, int) {
+	counts := map[string]int{}
+	requests := map[string]v1.ResourceList{}
+	podsNum := 0
+
+	for _, q := range queues {
+		lister, ok := q.(queue.PendingPodLister)
+		if !ok {
+			continue
+		}
+
+		for _, p := range lister.PendingPods() {
+			req := util.PodTotalResourceRequests(p)
+			key := shapeKey(req)
+			counts[key]++
+			requests[key] = req
+			podsNum++
+		}
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	shapes := make([]podShape, 0, len(keys))
+	for _, key := range keys {
+		shapes = append(shapes, podShape{
+			requests: requests[key],
+			weight:   float64(counts[key]) / float64(podsNum),
+		})
+	}
+
+	return shapes, podsNum
+}
+
+// shapeKey returns a string that identifies the resource requests.
+func shapeKey(requests v1.ResourceList) string {
+	rsrcs := make([]string, 0, len(requests))
+	for rsrc, quantity := range requests {
+		if quantity.Sign() != 0 {
+			rsrcs = append(rsrcs, string(rsrc)+"="+quantity.String())
+		}
+	}
+	sort.Strings(rsrcs)
+
+	return strings.Join(rsrcs, ",")
+}
+
+// freeResources returns the allocatable resources of the node minus those requested by the pods on
+// it, which are not negative.
+// The starting pods are not counted separately in the pod slots, as they are counted as running.
+func freeResources(met node.Metrics) v1.ResourceList {
+	free := v1.ResourceList{}
+	for rsrc, alloc := range met.Allocatable {
+		amount := alloc.DeepCopy()
+		if rsrc == v1.ResourcePods {
+			amount.Sub(*resource.NewQuantity(met.RunningPodsNum+met.TerminatingPodsNum, resource.DecimalSI))
+		} else {
+			amount.Sub(met.TotalResourceRequest[rsrc])
+		}
+		if amount.Sign() < 0 {
+			amount = resource.Quantity{Format: alloc.Format}
+		}
+		free[rsrc] = amount
+	}
+
+	return free
+}
+
+// fitsIn returns whether the requests fit in the free resources, including a slot of pods.
+func fitsIn(requests, free v1.ResourceList) bool {
+	if pods, ok := free[v1.ResourcePods]; ok && pods.Value() < 1 {
+		return false
+	}
+
+	for rsrc, req := range requests {
+		if f := free[rsrc]; req.Cmp(f) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
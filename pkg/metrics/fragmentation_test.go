@@ -0,0 +1,183 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+func newTestResourceList(cpu, memory, gpu string) v1.ResourceList {
+	return v1.ResourceList{
+		"cpu":                resource.MustParse(cpu),
+		"memory":             resource.MustParse(memory),
+		node.GPUResourceName: resource.MustParse(gpu),
+	}
+}
+
+func newTestFragmentationNode(allocatable, requests v1.ResourceList) node.Metrics {
+	allocatable = allocatable.DeepCopy()
+	allocatable["pods"] = resource.MustParse("110")
+	return node.Metrics{Allocatable: allocatable, TotalResourceRequest: requests, RunningPodsNum: 2}
+}
+
+func TestFragmentationCollector(t *testing.T) {
+	nodes := map[string]node.Metrics{
+		// The free resources are 6 cpu, 24Gi memory, and 2 GPUs, half of which are used.
+		"node-0": newTestFragmentationNode(newTestResourceList("8", "32Gi", "4"), newTestResourceList("2", "8Gi", "2")),
+		// The free resources are 1 cpu, 12Gi memory, and no GPUs, all of which are used.
+		"node-1": newTestFragmentationNode(newTestResourceList("4", "16Gi", "4"), newTestResourceList("3", "4Gi", "4")),
+		// The nodes that have failed or been cordoned are excluded, even with more free resources.
+		"node-2": newTestFragmentationNode(newTestResourceList("32", "128Gi", "8"), newTestResourceList("1", "1Gi", "1")),
+		"node-3": newTestFragmentationNode(newTestResourceList("32", "128Gi", "8"), newTestResourceList("1", "1Gi", "1")),
+	}
+	failed := nodes["node-2"]
+	failed.Failed = true
+	nodes["node-2"] = failed
+	cordoned := nodes["node-3"]
+	cordoned.Unschedulable = true
+	nodes["node-3"] = cordoned
+
+	// Half of the pending pods request 2 cpu and a GPU, which fit only on node-0, a quarter request
+	// 1 cpu and 4Gi memory, which fit on both, and a quarter request 4 cpu and 4 GPUs, which fit on
+	// neither.
+	q := queue.NewFIFOQueue()
+	for i, requests := range []v1.ResourceList{
+		{"cpu": resource.MustParse("2"), node.GPUResourceName: resource.MustParse("1")},
+		{"cpu": resource.MustParse("2"), node.GPUResourceName: resource.MustParse("1")},
+		{"cpu": resource.MustParse("1"), "memory": resource.MustParse("4Gi")},
+		{"cpu": resource.MustParse("4"), node.GPUResourceName: resource.MustParse("4")},
+	} {
+		p := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("pod-%d", i)},
+			Spec: v1.PodSpec{Containers: []v1.Container{{
+				Name:      "container",
+				Resources: v1.ResourceRequirements{Requests: requests},
+			}}},
+		}
+		if err := q.Push(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name, value := (&FragmentationCollector{}).Collect(testStartClock, nodes, []queue.PodQueue{q}, nil)
+	met := value.(FragmentationMetrics)
+	if name != FragmentationMetricsName {
+		t.Errorf("got: %s\nwant: %s", name, FragmentationMetricsName)
+	}
+	if met.PendingPodsNum != 4 || met.PartiallyUsedGPUNodesNum != 1 {
+		t.Errorf("got: %d pending pods, %d partially used GPU nodes\nwant: 4, 1",
+			met.PendingPodsNum, met.PartiallyUsedGPUNodesNum)
+	}
+
+	gi := float64(1 << 30)
+	expectedResources := map[v1.ResourceName]ResourceFragmentation{
+		// On node-0, the pods of 4 GPUs cannot use 6 cpu, and on node-1, the pods of GPUs cannot use 1
+		// cpu.
+		"cpu": {Free: 7, LargestFree: 6, Unusable: 0.25*6 + 0.75*1, Index: (0.25*6 + 0.75*1) / 7},
+		// The pods of GPUs do not request memory.
+		"memory": {Free: 36 * gi, LargestFree: 24 * gi, Unusable: 0.75 * 36 * gi, Index: 0.75},
+		// On node-0, the pods of 4 GPUs do not fit, and the pods of memory do not request GPUs.
+		node.GPUResourceName: {Free: 2, LargestFree: 2, Unusable: 0.5 * 2, Index: 0.5},
+	}
+	if len(met.Resources) != len(expectedResources) {
+		t.Errorf("got: %v\nwant: %v", met.Resources, expectedResources)
+	}
+	for rsrc, expected := range expectedResources {
+		actual := met.Resources[rsrc]
+		if !almostEqual(actual.Free, expected.Free) || !almostEqual(actual.LargestFree, expected.LargestFree) ||
+			!almostEqual(actual.Unusable, expected.Unusable) || !almostEqual(actual.Index, expected.Index) {
+			t.Errorf("%s: got: %+v\nwant: %+v", rsrc, actual, expected)
+		}
+	}
+
+	expectedNodes := map[string]map[v1.ResourceName]float64{
+		"node-0": {"cpu": 0.25, "memory": 0.75, node.GPUResourceName: 0.5},
+		// No index of GPUs, which are not free.
+		"node-1": {"cpu": 0.75, "memory": 0.75},
+	}
+	if len(met.Nodes) != len(expectedNodes) {
+		t.Errorf("got: %v\nwant: %v", met.Nodes, expectedNodes)
+	}
+	for name, expected := range expectedNodes {
+		actual := met.Nodes[name]
+		if len(actual.Index) != len(expected) {
+			t.Errorf("%s: got: %v\nwant: %v", name, actual.Index, expected)
+		}
+		for rsrc, index := range expected {
+			if !almostEqual(actual.Index[rsrc], index) {
+				t.Errorf("%s: got: %v\nwant: %v", name, actual.Index, expected)
+			}
+		}
+	}
+	if shape := met.Nodes["node-0"].LargestPodShape; shape.Cpu().Cmp(resource.MustParse("6")) != 0 ||
+		shape.Pods().Value() != 108 {
+		t.Errorf("got: %v\nwant: 6 cpu and 108 pods", shape)
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+func TestFragmentationCollectorStartingPods(t *testing.T) {
+	// Both nodes have 3 pod slots and a pod starting (e.g., pulling its images), which is counted as
+	// running.
+	full := newTestFragmentationNode(newTestResourceList("8", "32Gi", "0"), newTestResourceList("3", "3Gi", "0"))
+	full.Allocatable["pods"] = resource.MustParse("3")
+	full.RunningPodsNum = 3
+	full.StartingPodsNum = 1
+	spare := newTestFragmentationNode(newTestResourceList("8", "32Gi", "0"), newTestResourceList("2", "2Gi", "0"))
+	spare.Allocatable["pods"] = resource.MustParse("3")
+	spare.RunningPodsNum = 2
+	spare.StartingPodsNum = 1
+	nodes := map[string]node.Metrics{"node-0": full, "node-1": spare}
+
+	q := queue.NewFIFOQueue()
+	if err := q.Push(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-0"},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:      "container",
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{"cpu": resource.MustParse("1")}},
+		}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, value := (&FragmentationCollector{}).Collect(testStartClock, nodes, []queue.PodQueue{q}, nil)
+	met := value.(FragmentationMetrics)
+
+	for name, expected := range map[string]int64{"node-0": 0, "node-1": 1} {
+		shape := met.Nodes[name].LargestPodShape
+		if actual := shape.Pods().Value(); actual != expected {
+			t.Errorf("%s: got: %d free pod slots\nwant: %d", name, actual, expected)
+		}
+	}
+	// The pod does not fit on node-0 without a slot, but fits on node-1.
+	expected := map[string]float64{"node-0": 1, "node-1": 0}
+	for name, index := range expected {
+		if actual := met.Nodes[name].Index["cpu"]; !almostEqual(actual, index) {
+			t.Errorf("%s: got: %v\nwant: cpu index %v", name, actual, index)
+		}
+	}
+}
@@ -16,6 +16,8 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
@@ -26,56 +28,38 @@ import (
 type HumanReadableFormatter struct{}
 
 // Format implements Formatter interface.
-// Returns error if the given metrics does not have valid structure.
 func (h *HumanReadableFormatter) Format(metrics *Metrics) (string, error) {
-	if err := validateMetrics(metrics); err != nil {
-		return "", err
-	}
-
-	// Clock
-	clk := (*metrics)[ClockKey].(string)
-	str := "Metrics " + clk + "\n"
+	str := "Metrics " + metrics.Clock.ToRFC3339() + "\n"
 
-	// Nodes
 	str += "  Nodes\n"
-	nodesMet := (*metrics)[NodesMetricsKey].(map[string]node.Metrics)
-	str += h.formatNodesMetrics(nodesMet)
+	str += h.formatNodesMetrics(metrics.Nodes)
 
-	// Pods
 	str += "  Pods\n"
-	podsMet := (*metrics)[PodsMetricsKey].(map[string]pod.Metrics)
-	str += h.formatPodsMetrics(podsMet)
+	str += h.formatPodsMetrics(metrics.Pods)
 
-	// Queue
 	str += "  Queue\n"
-	queueMet := (*metrics)[QueueMetricsKey].(queue.Metrics)
-	str += h.formatQueueMetrics(queueMet)
-
-	return str, nil
-}
+	str += h.formatQueueMetrics(metrics.Queue)
 
-func validateMetrics(metrics *Metrics) error {
-	keys := []string{ClockKey, NodesMetricsKey, PodsMetricsKey, QueueMetricsKey}
-	for _, key := range keys {
-		if _, ok := (*metrics)[key]; !ok {
-			return fmt.Errorf("No key %q in metrics", key)
-		}
+	if len(metrics.Topology) > 0 {
+		str += "  Topology\n"
+		str += h.formatTopologyMetrics(metrics.Topology)
 	}
 
-	if _, ok := (*metrics)[ClockKey].(string); !ok {
-		return fmt.Errorf("Type assertion failed: %q field of metrics is not string", ClockKey)
+	if len(metrics.QoS) > 0 {
+		str += "  QoS\n"
+		str += h.formatQOSMetrics(metrics.QoS)
 	}
-	if _, ok := (*metrics)[NodesMetricsKey].(map[string]node.Metrics); !ok {
-		return fmt.Errorf("Type assertion failed: %q field of metrics is not map[string]node.Metrics", NodesMetricsKey)
-	}
-	if _, ok := (*metrics)[PodsMetricsKey].(map[string]pod.Metrics); !ok {
-		return fmt.Errorf("Type assertion failed: %q field of metrics is not map[string]pod.Metrics", PodsMetricsKey)
+
+	if len(metrics.Schedulers) > 0 {
+		str += "  Schedulers\n"
+		str += h.formatSchedulersMetrics(metrics.Schedulers)
 	}
-	if _, ok := (*metrics)[QueueMetricsKey].(queue.Metrics); !ok {
-		return fmt.Errorf("Type assertion failed: %q field of metrics is not queue.Metrics", QueueMetricsKey)
+
+	for _, name := range sortedExtensionNames(metrics) {
+		str += h.formatExtension(name, metrics.Extensions[name])
 	}
 
-	return nil
+	return str, nil
 }
 
 func (h *HumanReadableFormatter) formatNodesMetrics(metrics map[string]node.Metrics) string {
@@ -83,6 +67,9 @@ func (h *HumanReadableFormatter) formatNodesMetrics(metrics map[string]node.Metr
 
 	for name, met := range metrics {
 		str += fmt.Sprintf("    %s: Pods %d(%d)/%d", name, met.RunningPodsNum, met.TerminatingPodsNum, met.Allocatable.Pods().Value())
+		if met.StartingPodsNum > 0 {
+			str += fmt.Sprintf(" (starting %d)", met.StartingPodsNum)
+		}
 		for rsrc, alloc := range met.Allocatable {
 			if rsrc == "pods" {
 				continue
@@ -99,7 +86,26 @@ func (h *HumanReadableFormatter) formatNodesMetrics(metrics map[string]node.Metr
 			}
 		}
 
-		str += fmt.Sprintf(", Failed %d\n", met.FailedPodsNum)
+		str += fmt.Sprintf(", Failed %d, Lost %d, Evicted %d, OOMKilled %d",
+			met.FailedPodsNum, met.LostPodsNum, met.EvictedPodsNum, met.OOMKilledPodsNum)
+		if met.FreeGPUs != nil {
+			str += fmt.Sprintf(", free GPUs by island %v", met.FreeGPUs)
+		}
+		if met.FreeMIGDevices != nil {
+			str += fmt.Sprintf(", free MIG instances %v", met.FreeMIGDevices)
+		}
+		if len(met.DrainingGPUs) > 0 {
+			str += fmt.Sprintf(", draining GPUs %v", met.DrainingGPUs)
+		}
+		if met.Failed {
+			str += ", node failed"
+		}
+		if met.Draining {
+			str += ", node draining"
+		} else if met.Unschedulable {
+			str += ", node cordoned"
+		}
+		str += "\n"
 	}
 
 	return str
@@ -109,8 +115,9 @@ func (h *HumanReadableFormatter) formatPodsMetrics(metrics map[string]pod.Metric
 	str := ""
 
 	for name, met := range metrics {
-		str += fmt.Sprintf("    %s: prio %d, bound at %s on %s, status %s, elapsed %d s",
-			name, met.Priority, met.BoundAt.ToRFC3339(), met.Node, met.Status, met.ExecutedSeconds)
+		str += fmt.Sprintf("    %s: prio %d, bound at %s on %s, started at %s, status %s, elapsed %d s",
+			name, met.Priority, met.BoundAt.ToRFC3339(), met.Node, met.StartedAt.ToRFC3339(), met.Status,
+			met.ExecutedSeconds)
 
 		for rsrc, req := range met.ResourceRequest {
 			lim := met.ResourceLimit[rsrc] // !ok -> usage == 0
@@ -124,6 +131,13 @@ func (h *HumanReadableFormatter) formatPodsMetrics(metrics map[string]pod.Metric
 			}
 		}
 
+		if len(met.GPUs) > 0 {
+			str += fmt.Sprintf(", GPUs %v", met.GPUs)
+		}
+		for _, dev := range met.MIGDevices {
+			str += fmt.Sprintf(", MIG %d/%s#%d", dev.GPU, dev.Profile, dev.Index)
+		}
+
 		str += "\n"
 	}
 
@@ -131,7 +145,175 @@ func (h *HumanReadableFormatter) formatPodsMetrics(metrics map[string]pod.Metric
 }
 
 func (h *HumanReadableFormatter) formatQueueMetrics(metrics queue.Metrics) string {
-	return fmt.Sprintf("    PendingPods %d\n", metrics.PendingPodsNum)
+	str := fmt.Sprintf("    PendingPods %d\n", metrics.PendingPodsNum)
+
+	names := make([]string, 0, len(metrics.ClusterQueues))
+	for name := range metrics.ClusterQueues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		met := metrics.ClusterQueues[name]
+		str += fmt.Sprintf("    %s: pending %d, admitted %d (total %d), wait avg %.1f s max %.1f s",
+			name, met.PendingPodsNum, met.AdmittedPodsNum, met.AdmittedPodsTotal,
+			met.AvgWaitSeconds, met.MaxWaitSeconds)
+
+		flavors := make([]string, 0, len(met.Usage))
+		for flavor := range met.Usage {
+			flavors = append(flavors, flavor)
+		}
+		sort.Strings(flavors)
+
+		for _, flavor := range flavors {
+			usage := met.Usage[flavor]
+			for _, rsrc := range sortedResourceListNames(usage) {
+				q := usage[rsrc]
+				str += fmt.Sprintf(", %s/%s %s", flavor, rsrc, q.String())
+			}
+		}
+
+		str += "\n"
+	}
+
+	return str
+}
+
+func (h *HumanReadableFormatter) formatTopologyMetrics(metrics TopologyMetrics) string {
+	str := ""
+
+	for _, level := range node.TopologyLevels {
+		levelMet, ok := metrics[level]
+		if !ok {
+			continue
+		}
+
+		str += fmt.Sprintf("    %s: pods skew %d, max share %.2f\n",
+			level, levelMet.RunningPodsSkew, levelMet.MaxRunningPodsShare)
+
+		domains := make([]string, 0, len(levelMet.Domains))
+		for domain := range levelMet.Domains {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+
+		for _, domain := range domains {
+			met := levelMet.Domains[domain]
+			str += fmt.Sprintf("      %s: Nodes %d (failed %d), Pods %d (lost %d)",
+				domain, met.NodesNum, met.FailedNodesNum, met.RunningPodsNum, met.LostPodsNum)
+			for _, rsrc := range sortedResourceNames(met.Utilization) {
+				str += fmt.Sprintf(", %s %.2f", rsrc, met.Utilization[rsrc])
+			}
+			str += "\n"
+		}
+	}
+
+	return str
+}
+
+func (h *HumanReadableFormatter) formatQOSMetrics(metrics QOSMetrics) string {
+	str := ""
+
+	for _, class := range qosClasses {
+		met, ok := metrics[class]
+		if !ok {
+			continue
+		}
+
+		str += fmt.Sprintf("    %s: Pods %d, Evicted %d, OOMKilled %d",
+			class, met.RunningPodsNum, met.EvictedPodsNum, met.OOMKilledPodsNum)
+		for _, rsrc := range sortedResourceListNames(met.TotalResourceUsage) {
+			usage := met.TotalResourceUsage[rsrc]
+			req := met.TotalResourceRequest[rsrc]
+			if rsrc == "memory" {
+				d := int64(1 << 20)
+				str += fmt.Sprintf(", memMB %d/%d", usage.Value()/d, req.Value()/d)
+			} else {
+				str += fmt.Sprintf(", %s %d/%d", rsrc, usage.Value(), req.Value())
+			}
+		}
+		str += "\n"
+	}
+
+	return str
+}
+
+func (h *HumanReadableFormatter) formatSchedulersMetrics(metrics SchedulersMetrics) string {
+	str := ""
+
+	for _, name := range sortedSchedulerNames(metrics) {
+		met := metrics[name]
+		str += fmt.Sprintf("    %s: %s\n", name, h.formatSchedulerCounts(met.SchedulerCounts))
+		str += fmt.Sprintf("      Last tick: %s\n", h.formatSchedulerCounts(met.LastTick))
+
+		if reasons := sortedFailedPredicates(met.SchedulerCounts); len(reasons) > 0 {
+			str += "      Failed predicates:"
+			for i, reason := range reasons {
+				if i > 0 {
+					str += ","
+				}
+				str += fmt.Sprintf(" %s %d", reason, met.FailedPredicates[reason])
+			}
+			str += "\n"
+		}
+	}
+
+	return str
+}
+
+func (h *HumanReadableFormatter) formatSchedulerCounts(counts SchedulerCounts) string {
+	return fmt.Sprintf(
+		"Attempts %d (Scheduled %d, Unschedulable %d, Error %d), Feasible nodes %.2f avg, Plugin time %.3fs, Preemptions %d, Victims %d",
+		counts.AttemptsNum, counts.ScheduledAttemptsNum, counts.UnschedulableAttemptsNum,
+		counts.ErrorAttemptsNum, counts.AvgFeasibleNodesNum, counts.PluginSeconds,
+		counts.PreemptionAttemptsNum, counts.PreemptionVictimsNum)
+}
+
+func (h *HumanReadableFormatter) formatExtension(name string, value interface{}) string {
+	entries := flattenExtension(value)
+	if len(entries) == 1 && entries[0].path == "" {
+		return fmt.Sprintf("  %s: %s\n", name, entries[0].value)
+	}
+
+	str := "  " + name + "\n"
+	for _, e := range entries {
+		str += fmt.Sprintf("    %s: %s\n", strings.TrimPrefix(e.path, "."), e.value)
+	}
+
+	return str
+}
+
+// FormatSummary implements SummaryFormatter interface.
+func (h *HumanReadableFormatter) FormatSummary(summary *Summary) (string, error) {
+	str := fmt.Sprintf("Summary %s - %s\n", summary.StartClock, summary.EndClock)
+	str += fmt.Sprintf("  Makespan %.0f s\n", summary.MakespanSeconds)
+	str += fmt.Sprintf("  Pods bound %d, unbound %d, completed %d, over capacity %d, preempted %d\n",
+		summary.BoundPodsNum, summary.UnboundPodsNum, summary.CompletedPodsNum, summary.OverCapacityPodsNum,
+		summary.PreemptionsNum)
+
+	str += h.formatDistribution("Queueing delay (s)", summary.QueueingDelay)
+	str += h.formatDistribution("Job completion time (s)", summary.JobCompletionTime)
+	str += h.formatDistribution("Slowdown", summary.Slowdown)
+
+	str += "  Utilization"
+	for _, rsrc := range sortedResourceNames(summary.Utilization) {
+		str += fmt.Sprintf(" %s %.2f", rsrc, summary.Utilization[rsrc])
+	}
+	str += "\n"
+
+	str += fmt.Sprintf("  Wasted work: pods %d, %.0f s", summary.WastedWork.PodsNum, summary.WastedWork.Seconds)
+	for _, rsrc := range sortedResourceNames(summary.WastedWork.ResourceSeconds) {
+		str += fmt.Sprintf(", %s-seconds %.0f", rsrc, summary.WastedWork.ResourceSeconds[rsrc])
+	}
+	str += "\n"
+
+	return str, nil
+}
+
+func (h *HumanReadableFormatter) formatDistribution(name string, dist Distribution) string {
+	return fmt.Sprintf("  %s: count %d, avg %.2f, p50 %.2f, p95 %.2f, p99 %.2f, max %.2f\n",
+		name, dist.Count, dist.Avg, dist.P50, dist.P95, dist.P99, dist.Max)
 }
 
 var _ = Formatter(&HumanReadableFormatter{})
+var _ = SummaryFormatter(&HumanReadableFormatter{})
@@ -34,4 +34,18 @@ func (j *JSONFormatter) Format(metrics *Metrics) (string, error) {
 	return string(bytes), nil
 }
 
+// FormatSummary implements SummaryFormatter interface.
+// It formats the given summary to a single JSON string {"Summary": summary}, without newline at the
+// end.
+// Returns error if failed to marshal.
+func (j *JSONFormatter) FormatSummary(summary *Summary) (string, error) {
+	bytes, err := json.Marshal(map[string]*Summary{"Summary": summary})
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
 var _ = Formatter(&JSONFormatter{})
+var _ = SummaryFormatter(&JSONFormatter{})
@@ -15,19 +15,48 @@
 package metrics
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
-	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
 )
 
-// Metrics represents a metrics at one time point, in the following structure.
-//   Metrics[ClockKey] = a formatted clock
-//   Metrics[NodesMetricsKey] = map from node name to node.Metrics
-//   Metrics[PodsMetricsKey] = map from pod name to pod.Metrics
-// 	 Metrics[QueueMetricsKey] = queue.Metrics
-type Metrics map[string]interface{}
+// Metrics is a snapshot of the metrics of a simulated cluster at one time point.
+type Metrics struct {
+	Clock clock.Clock
+	// Nodes is a map from node names to their metrics.
+	Nodes map[string]node.Metrics
+	// Pods is a map from pod keys (namespace/name) to the metrics of the pods bound to nodes.
+	Pods map[string]pod.Metrics
+	// Queue is the metrics summed up over all of the queues.
+	Queue queue.Metrics
+	// Topology is the metrics of the topology domains, if any node has topology labels.
+	Topology TopologyMetrics `json:",omitempty"`
+	// QoS is the metrics of the QoS classes, if any pod is bound.
+	QoS QOSMetrics `json:",omitempty"`
+	// Schedulers is the metrics of the schedulers, added by KubeSim.
+	Schedulers SchedulersMetrics `json:",omitempty"`
+
+	// Extensions is a map from the names of custom metrics (e.g., of collectors) to their values.
+	// They are written alongside the sections above, which take precedence over extensions with the
+	// same names (e.g., "Nodes").
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MetricsMap is the untyped representation of Metrics, in the following structure.
+//   MetricsMap[ClockKey] = a formatted clock
+//   MetricsMap[NodesMetricsKey] = map from node name to node.Metrics
+//   MetricsMap[PodsMetricsKey] = map from pod name to pod.Metrics
+//   MetricsMap[QueueMetricsKey] = queue.Metrics
+//   MetricsMap[TopologyMetricsKey] = TopologyMetrics, if any node has topology labels
+//   MetricsMap[QOSMetricsKey] = QOSMetrics, if any pod is bound
+//   MetricsMap[SchedulersMetricsKey] = SchedulersMetrics, if any scheduler
+//   MetricsMap[name] = the value of each extension
+type MetricsMap map[string]interface{}
 
 const (
 	// ClockKey is the key associated to a clock.Clock.
@@ -38,32 +67,139 @@ const (
 	PodsMetricsKey = "Pods"
 	// QueueMetricsKey is the key associated to a queue.Metrics.
 	QueueMetricsKey = "Queue"
+	// TopologyMetricsKey is the key associated to a TopologyMetrics.
+	TopologyMetricsKey = "Topology"
+	// QOSMetricsKey is the key associated to a QOSMetrics.
+	QOSMetricsKey = "QoS"
+	// SchedulersMetricsKey is the key associated to a SchedulersMetrics.
+	SchedulersMetricsKey = "Schedulers"
 )
 
-// BuildMetrics builds a Metrics at the given clock.
-func BuildMetrics(clock clock.Clock, nodes map[string]*node.Node, queue queue.PodQueue) (Metrics, error) {
-	metrics := make(map[string]interface{})
-	metrics[ClockKey] = clock.ToRFC3339()
+// ToMap converts this Metrics to a MetricsMap.
+func (m *Metrics) ToMap() MetricsMap {
+	metricsMap := make(MetricsMap, len(m.Extensions)+7)
+	for name, value := range m.Extensions {
+		metricsMap[name] = value
+	}
+
+	metricsMap[ClockKey] = m.Clock.ToRFC3339()
+	metricsMap[NodesMetricsKey] = m.Nodes
+	metricsMap[PodsMetricsKey] = m.Pods
+	metricsMap[QueueMetricsKey] = m.Queue
+	if len(m.Topology) > 0 {
+		metricsMap[TopologyMetricsKey] = m.Topology
+	}
+	if len(m.QoS) > 0 {
+		metricsMap[QOSMetricsKey] = m.QoS
+	}
+	if len(m.Schedulers) > 0 {
+		metricsMap[SchedulersMetricsKey] = m.Schedulers
+	}
+
+	return metricsMap
+}
+
+// MetricsFromMap converts the MetricsMap to a Metrics, with the values of the keys other than those
+// of the sections as the extensions.
+// Returns error if the given MetricsMap does not have valid structure.
+func MetricsFromMap(metricsMap MetricsMap) (Metrics, error) {
+	keys := []string{ClockKey, NodesMetricsKey, PodsMetricsKey, QueueMetricsKey}
+	for _, key := range keys {
+		if _, ok := metricsMap[key]; !ok {
+			return Metrics{}, fmt.Errorf("No key %q in metrics", key)
+		}
+	}
+
+	clk, ok := metricsMap[ClockKey].(string)
+	if !ok {
+		return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not string", ClockKey)
+	}
+	t, err := time.Parse(time.RFC3339, clk)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	m := Metrics{Clock: clock.NewClock(t)}
+	if m.Nodes, ok = metricsMap[NodesMetricsKey].(map[string]node.Metrics); !ok {
+		return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not map[string]node.Metrics", NodesMetricsKey)
+	}
+	if m.Pods, ok = metricsMap[PodsMetricsKey].(map[string]pod.Metrics); !ok {
+		return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not map[string]pod.Metrics", PodsMetricsKey)
+	}
+	if m.Queue, ok = metricsMap[QueueMetricsKey].(queue.Metrics); !ok {
+		return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not queue.Metrics", QueueMetricsKey)
+	}
 
-	nodesMetrics := make(map[string]node.Metrics)
-	podsMetrics := make(map[string]pod.Metrics)
+	for name, value := range metricsMap {
+		switch name {
+		case ClockKey, NodesMetricsKey, PodsMetricsKey, QueueMetricsKey:
+		case TopologyMetricsKey:
+			if m.Topology, ok = value.(TopologyMetrics); !ok {
+				return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not TopologyMetrics", name)
+			}
+		case QOSMetricsKey:
+			if m.QoS, ok = value.(QOSMetrics); !ok {
+				return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not QOSMetrics", name)
+			}
+		case SchedulersMetricsKey:
+			if m.Schedulers, ok = value.(SchedulersMetrics); !ok {
+				return Metrics{}, fmt.Errorf("Type assertion failed: %q field of metrics is not SchedulersMetrics", name)
+			}
+		default:
+			if m.Extensions == nil {
+				m.Extensions = map[string]interface{}{}
+			}
+			m.Extensions[name] = value
+		}
+	}
+
+	return m, nil
+}
+
+// MarshalJSON implements json.Marshaler interface.
+// It marshals this Metrics as its MetricsMap, so that the extensions are written at the top level.
+func (m Metrics) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.ToMap())
+}
+
+// BuildMetrics builds a Metrics at the given clock.
+// The queue metrics is summed up over all of the given queues.
+func BuildMetrics(clock clock.Clock, nodes map[string]*node.Node, queues ...queue.PodQueue) (Metrics, error) {
+	metrics := Metrics{
+		Clock: clock,
+		Nodes: make(map[string]node.Metrics),
+		Pods:  make(map[string]pod.Metrics),
+	}
 
 	for name, node := range nodes {
-		nodesMetrics[name] = node.Metrics(clock)
-		for _, pod := range node.PodList() {
-			if !pod.IsTerminated(clock) {
-				key, err := util.PodKey(pod.ToV1())
-				if err != nil {
-					return Metrics{}, err
-				}
-				podsMetrics[key] = pod.Metrics(clock)
+		metrics.Nodes[name] = node.Metrics(clock)
+		nodePodsMetrics, err := node.PodsMetrics(clock)
+		if err != nil {
+			return Metrics{}, err
+		}
+		for key, met := range nodePodsMetrics {
+			metrics.Pods[key] = met
+		}
+	}
+
+	for _, q := range queues {
+		met := q.Metrics()
+		metrics.Queue.PendingPodsNum += met.PendingPodsNum
+
+		for name, cqMet := range met.ClusterQueues {
+			if metrics.Queue.ClusterQueues == nil {
+				metrics.Queue.ClusterQueues = map[string]queue.ClusterQueueMetrics{}
 			}
+			metrics.Queue.ClusterQueues[name] = cqMet
 		}
 	}
 
-	metrics[NodesMetricsKey] = nodesMetrics
-	metrics[PodsMetricsKey] = podsMetrics
-	metrics[QueueMetricsKey] = queue.Metrics()
+	if topologyMetrics := buildTopologyMetrics(nodes, metrics.Nodes); len(topologyMetrics) > 0 {
+		metrics.Topology = topologyMetrics
+	}
+	if qosMetrics := buildQOSMetrics(metrics.Nodes); len(qosMetrics) > 0 {
+		metrics.QoS = qosMetrics
+	}
 
 	return metrics, nil
 }
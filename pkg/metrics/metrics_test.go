@@ -0,0 +1,147 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+// newTestFullMetrics creates metrics whose sections are all non-empty, with an extension.
+func newTestFullMetrics() *Metrics {
+	return &Metrics{
+		Clock: testStartClock,
+		Nodes: map[string]node.Metrics{"node-0": {RunningPodsNum: 1}},
+		Pods:  map[string]pod.Metrics{"default/pod-0": {Node: "node-0", Phase: v1.PodRunning}},
+		Queue: queue.Metrics{PendingPodsNum: 2},
+		Topology: TopologyMetrics{node.Rack: {
+			Domains: map[string]DomainMetrics{"rack-0": {NodesNum: 1, RunningPodsNum: 1}},
+		}},
+		QoS:        QOSMetrics{v1.PodQOSBurstable: {RunningPodsNum: 1}},
+		Schedulers: SchedulersMetrics{"default-scheduler": {SchedulerCounts: SchedulerCounts{AttemptsNum: 1}}},
+		Extensions: map[string]interface{}{"custom": 1.5},
+	}
+}
+
+func TestMetricsToMap(t *testing.T) {
+	metrics := newTestFullMetrics()
+	metricsMap := metrics.ToMap()
+
+	// Each section is written with the key of its field name.
+	typ := reflect.TypeOf(*metrics)
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Name != "Extensions" {
+			if _, ok := metricsMap[field.Name]; !ok {
+				t.Errorf("got: no key %q\nwant: the %s section", field.Name, field.Name)
+			}
+		}
+	}
+	if value := metricsMap["custom"]; value != 1.5 {
+		t.Errorf("got: %v\nwant: %v", value, 1.5)
+	}
+	if len(metricsMap) != typ.NumField() {
+		t.Errorf("got: %d keys\nwant: %d", len(metricsMap), typ.NumField())
+	}
+
+	// The extensions are written at the top level of JSON, alongside the sections.
+	bytes, err := json.Marshal(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(bytes, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for key := range metricsMap {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("got: no key %q in %s\nwant: the key", key, bytes)
+		}
+	}
+
+	// The optional sections are omitted if they are empty.
+	metrics.Topology, metrics.QoS, metrics.Schedulers = nil, nil, nil
+	for _, key := range []string{TopologyMetricsKey, QOSMetricsKey, SchedulersMetricsKey} {
+		if _, ok := metrics.ToMap()[key]; ok {
+			t.Errorf("got: key %q\nwant: omitted", key)
+		}
+	}
+
+	// The sections take precedence over the extensions with the same names.
+	metrics.Extensions[NodesMetricsKey] = "extension"
+	if _, ok := metrics.ToMap()[NodesMetricsKey].(map[string]node.Metrics); !ok {
+		t.Errorf("got: %v\nwant: the Nodes section", metrics.ToMap()[NodesMetricsKey])
+	}
+}
+
+func TestMetricsFromMap(t *testing.T) {
+	metrics := newTestFullMetrics()
+
+	actual, err := MetricsFromMap(metrics.ToMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, *metrics) {
+		t.Errorf("got: %+v\nwant: %+v", actual, *metrics)
+	}
+
+	// Without the optional sections nor extensions.
+	metrics = &Metrics{Clock: testStartClock, Nodes: map[string]node.Metrics{}, Pods: map[string]pod.Metrics{}}
+	actual, err = MetricsFromMap(metrics.ToMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, *metrics) {
+		t.Errorf("got: %+v\nwant: %+v", actual, *metrics)
+	}
+}
+
+func TestMetricsFromInvalidMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+	}{
+		{"missing clock", ClockKey, nil},
+		{"missing nodes", NodesMetricsKey, nil},
+		{"invalid clock", ClockKey, "2019-01-01"},
+		{"clock of wrong type", ClockKey, testStartClock},
+		{"nodes of wrong type", NodesMetricsKey, map[string]interface{}{}},
+		{"pods of wrong type", PodsMetricsKey, map[string]interface{}{}},
+		{"queue of wrong type", QueueMetricsKey, &queue.Metrics{}},
+		{"topology of wrong type", TopologyMetricsKey, map[string]interface{}{}},
+		{"qos of wrong type", QOSMetricsKey, map[string]interface{}{}},
+		{"schedulers of wrong type", SchedulersMetricsKey, map[string]SchedulerMetrics{}},
+	}
+
+	for _, test := range tests {
+		metricsMap := newTestFullMetrics().ToMap()
+		if test.value == nil {
+			delete(metricsMap, test.key)
+		} else {
+			metricsMap[test.key] = test.value
+		}
+
+		if _, err := MetricsFromMap(metricsMap); err == nil {
+			t.Errorf("%s: got: no error\nwant: error", test.name)
+		}
+	}
+}
@@ -0,0 +1,630 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// openMetricsContentType is the content type of the OpenMetrics text format.
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// openMetricsFamily is a metric family in the OpenMetrics text format.
+type openMetricsFamily struct {
+	name string
+	// typ is either "gauge" or "counter", whose samples are suffixed with "_total".
+	typ     string
+	help    string
+	samples []openMetricsSample
+}
+
+// openMetricsSample is a sample of a metric family, with its labels in order.
+type openMetricsSample struct {
+	labels []string // name, value, name, value, ...
+	value  float64
+}
+
+func (f *openMetricsFamily) add(value float64, labels ...string) {
+	f.samples = append(f.samples, openMetricsSample{labels: labels, value: value})
+}
+
+// header returns the TYPE and HELP lines of this family.
+func (f *openMetricsFamily) header() string {
+	return fmt.Sprintf("# TYPE %s %s\n# HELP %s %s\n", f.name, f.typ, f.name, f.help)
+}
+
+// format formats the samples of this family, with the timestamp in seconds if it is not empty.
+func (f *openMetricsFamily) format(timestamp string) string {
+	name := f.name
+	if f.typ == "counter" {
+		name += "_total"
+	}
+
+	var b strings.Builder
+	for _, s := range f.samples {
+		b.WriteString(name)
+		if len(s.labels) > 0 {
+			b.WriteByte('{')
+			for i := 0; i+1 < len(s.labels); i += 2 {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(s.labels[i])
+				b.WriteString(`="`)
+				b.WriteString(escapeLabelValue(s.labels[i+1]))
+				b.WriteByte('"')
+			}
+			b.WriteByte('}')
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		if timestamp != "" {
+			b.WriteByte(' ')
+			b.WriteString(timestamp)
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// buildOpenMetricsFamilies builds the metric families of the metrics, named after kube-state-metrics,
+// kube-scheduler, and Kueue where possible, or prefixed with "kubesim_" otherwise.
+func buildOpenMetricsFamilies(metrics *Metrics) []*openMetricsFamily {
+	families := buildNodeFamilies(metrics.Nodes)
+	families = append(families, buildPodFamilies(metrics.Pods)...)
+	families = append(families, buildQueueFamilies(metrics.Queue)...)
+	families = append(families, buildSchedulerFamilies(metrics.Schedulers)...)
+
+	return families
+}
+
+func buildNodeFamilies(metrics map[string]node.Metrics) []*openMetricsFamily {
+	allocatable := &openMetricsFamily{name: "kube_node_status_allocatable", typ: "gauge",
+		help: "The allocatable resources of a node."}
+	unschedulable := &openMetricsFamily{name: "kube_node_spec_unschedulable", typ: "gauge",
+		help: "Whether a node can schedule new pods."}
+	condition := &openMetricsFamily{name: "kube_node_status_condition", typ: "gauge",
+		help: "The condition of a node."}
+	requests := &openMetricsFamily{name: "kubesim_node_resource_requests", typ: "gauge",
+		help: "The total resource requests of the pods running on a node."}
+	usage := &openMetricsFamily{name: "kubesim_node_resource_usage", typ: "gauge",
+		help: "The total resource usage of the pods running on a node."}
+	pods := &openMetricsFamily{name: "kubesim_node_pods", typ: "gauge",
+		help: "The number of the pods on a node by their states."}
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		met := metrics[name]
+
+		addResources(allocatable, met.Allocatable, "node", name)
+		addResources(requests, met.TotalResourceRequest, "node", name)
+		addResources(usage, met.TotalResourceUsage, "node", name)
+
+		unschedulable.add(boolValue(met.Unschedulable), "node", name)
+		addConditions(condition, name, met)
+
+		for _, state := range []struct {
+			name string
+			num  int64
+		}{
+			{"running", met.RunningPodsNum},
+			{"starting", met.StartingPodsNum},
+			{"terminating", met.TerminatingPodsNum},
+			{"failed", met.FailedPodsNum},
+			{"lost", met.LostPodsNum},
+			{"evicted", met.EvictedPodsNum},
+			{"oom_killed", met.OOMKilledPodsNum},
+		} {
+			pods.add(float64(state.num), "node", name, "state", state.name)
+		}
+	}
+
+	return []*openMetricsFamily{allocatable, unschedulable, condition, requests, usage, pods}
+}
+
+// addConditions adds the samples of the conditions of the node to the family, with a sample for
+// each of the statuses true, false, and unknown, as kube-state-metrics does.
+// The Ready condition is derived from whether the node has failed if the metrics do not have it.
+func addConditions(family *openMetricsFamily, nodeName string, met node.Metrics) {
+	conditions := make(map[v1.NodeConditionType]v1.ConditionStatus, len(met.Conditions)+1)
+	for typ, status := range met.Conditions {
+		conditions[typ] = status
+	}
+	if _, ok := conditions[v1.NodeReady]; !ok {
+		conditions[v1.NodeReady] = v1.ConditionTrue
+		if met.Failed {
+			conditions[v1.NodeReady] = v1.ConditionUnknown
+		}
+	}
+
+	types := make([]string, 0, len(conditions))
+	for typ := range conditions {
+		types = append(types, string(typ))
+	}
+	sort.Strings(types)
+
+	for _, typ := range types {
+		status := conditions[v1.NodeConditionType(typ)]
+		for _, s := range []v1.ConditionStatus{v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown} {
+			family.add(boolValue(status == s),
+				"node", nodeName, "condition", typ, "status", strings.ToLower(string(s)))
+		}
+	}
+}
+
+func buildPodFamilies(metrics map[string]pod.Metrics) []*openMetricsFamily {
+	phase := &openMetricsFamily{name: "kube_pod_status_phase", typ: "gauge",
+		help: "The current phase of a pod."}
+	requests := &openMetricsFamily{name: "kube_pod_container_resource_requests", typ: "gauge",
+		help: "The total resource requests of the containers of a pod."}
+	limits := &openMetricsFamily{name: "kube_pod_container_resource_limits", typ: "gauge",
+		help: "The total resource limits of the containers of a pod."}
+	usage := &openMetricsFamily{name: "kubesim_pod_resource_usage", typ: "gauge",
+		help: "The resource usage of a pod."}
+
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		met := metrics[key]
+		if met.Phase == "" { // deleted
+			continue
+		}
+		namespace, name := util.SplitPodKey(key)
+
+		phase.add(1, "namespace", namespace, "pod", name, "phase", string(met.Phase))
+		addResources(requests, met.ResourceRequest, "namespace", namespace, "pod", name, "node", met.Node)
+		addResources(limits, met.ResourceLimit, "namespace", namespace, "pod", name, "node", met.Node)
+		addResources(usage, met.ResourceUsage, "namespace", namespace, "pod", name, "node", met.Node)
+	}
+
+	return []*openMetricsFamily{phase, requests, limits, usage}
+}
+
+func buildQueueFamilies(metrics queue.Metrics) []*openMetricsFamily {
+	pending := &openMetricsFamily{name: "scheduler_pending_pods", typ: "gauge",
+		help: "The number of the pending pods in the queues."}
+	pending.add(float64(metrics.PendingPodsNum))
+
+	cqPending := &openMetricsFamily{name: "kueue_pending_workloads", typ: "gauge",
+		help: "The number of the pods waiting for admission in a ClusterQueue."}
+	cqAdmitted := &openMetricsFamily{name: "kueue_admitted_active_workloads", typ: "gauge",
+		help: "The number of the pods holding quota of a ClusterQueue."}
+	cqAdmittedTotal := &openMetricsFamily{name: "kueue_admitted_workloads", typ: "counter",
+		help: "The number of the pods ever admitted by a ClusterQueue."}
+	cqUsage := &openMetricsFamily{name: "kueue_cluster_queue_resource_usage", typ: "gauge",
+		help: "The resources of a flavor held by the pods admitted by a ClusterQueue."}
+
+	names := make([]string, 0, len(metrics.ClusterQueues))
+	for name := range metrics.ClusterQueues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		met := metrics.ClusterQueues[name]
+		cqPending.add(float64(met.PendingPodsNum), "cluster_queue", name)
+		cqAdmitted.add(float64(met.AdmittedPodsNum), "cluster_queue", name)
+		cqAdmittedTotal.add(float64(met.AdmittedPodsTotal), "cluster_queue", name)
+
+		flavors := make([]string, 0, len(met.Usage))
+		for flavor := range met.Usage {
+			flavors = append(flavors, flavor)
+		}
+		sort.Strings(flavors)
+
+		for _, flavor := range flavors {
+			addResources(cqUsage, met.Usage[flavor], "cluster_queue", name, "flavor", flavor)
+		}
+	}
+
+	return []*openMetricsFamily{pending, cqPending, cqAdmitted, cqAdmittedTotal, cqUsage}
+}
+
+func buildSchedulerFamilies(metrics SchedulersMetrics) []*openMetricsFamily {
+	attempts := &openMetricsFamily{name: "scheduler_schedule_attempts", typ: "counter",
+		help: "The number of the scheduling attempts by their results."}
+	preemptions := &openMetricsFamily{name: "scheduler_preemption_attempts", typ: "counter",
+		help: "The number of the preemptions that nominated a node."}
+	victims := &openMetricsFamily{name: "kubesim_scheduler_preemption_victims", typ: "counter",
+		help: "The number of the pods preempted by a scheduler or its queue."}
+	failedPredicates := &openMetricsFamily{name: "kubesim_scheduler_failed_predicates", typ: "counter",
+		help: "The number of the nodes that failed the pods in the unschedulable attempts by reasons."}
+	evaluated := &openMetricsFamily{name: "kubesim_scheduler_evaluated_nodes", typ: "counter",
+		help: "The number of the nodes evaluated by the predicates in the scheduling attempts."}
+	feasible := &openMetricsFamily{name: "kubesim_scheduler_feasible_nodes", typ: "counter",
+		help: "The number of the nodes that passed the predicates in the scheduling attempts."}
+	pluginSeconds := &openMetricsFamily{name: "kubesim_scheduler_plugin_seconds", typ: "counter",
+		help: "The wall-clock time spent in the predicates, prioritizers, and extenders."}
+
+	for _, name := range sortedSchedulerNames(metrics) {
+		met := metrics[name]
+		attempts.add(float64(met.ScheduledAttemptsNum), "profile", name, "result", "scheduled")
+		attempts.add(float64(met.UnschedulableAttemptsNum), "profile", name, "result", "unschedulable")
+		attempts.add(float64(met.ErrorAttemptsNum), "profile", name, "result", "error")
+		preemptions.add(float64(met.PreemptionAttemptsNum), "profile", name)
+		victims.add(float64(met.PreemptionVictimsNum), "profile", name)
+		for _, reason := range sortedFailedPredicates(met.SchedulerCounts) {
+			failedPredicates.add(float64(met.FailedPredicates[reason]), "profile", name, "reason", reason)
+		}
+		evaluated.add(float64(met.EvaluatedNodesNum), "profile", name)
+		feasible.add(float64(met.FeasibleNodesNum), "profile", name)
+		pluginSeconds.add(met.PluginSeconds, "profile", name)
+	}
+
+	return []*openMetricsFamily{
+		attempts, preemptions, victims, failedPredicates, evaluated, feasible, pluginSeconds}
+}
+
+// buildSummaryFamilies builds the metric families of the summary of a run.
+func buildSummaryFamilies(summary *Summary) []*openMetricsFamily {
+	makespan := &openMetricsFamily{name: "kubesim_summary_makespan_seconds", typ: "gauge",
+		help: "The time from the first submission to the last completion of the pods."}
+	makespan.add(summary.MakespanSeconds)
+
+	pods := &openMetricsFamily{name: "kubesim_summary_pods", typ: "gauge",
+		help: "The number of the pods in the run by their results."}
+	pods.add(float64(summary.BoundPodsNum), "result", "bound")
+	pods.add(float64(summary.UnboundPodsNum), "result", "unbound")
+	pods.add(float64(summary.CompletedPodsNum), "result", "completed")
+	pods.add(float64(summary.OverCapacityPodsNum), "result", "over_capacity")
+	pods.add(float64(summary.PreemptionsNum), "result", "preempted")
+	pods.add(float64(summary.WastedWork.PodsNum), "result", "wasted")
+
+	kpis := &openMetricsFamily{name: "kubesim_summary_kpi", typ: "gauge",
+		help: "The distributions of the queueing delay, job completion time, and slowdown of the pods."}
+	for _, kpi := range []struct {
+		name string
+		dist Distribution
+	}{
+		{"queueing_delay_seconds", summary.QueueingDelay},
+		{"job_completion_time_seconds", summary.JobCompletionTime},
+		{"slowdown", summary.Slowdown},
+	} {
+		kpis.add(kpi.dist.Avg, "kpi", kpi.name, "stat", "avg")
+		kpis.add(kpi.dist.P50, "kpi", kpi.name, "stat", "p50")
+		kpis.add(kpi.dist.P95, "kpi", kpi.name, "stat", "p95")
+		kpis.add(kpi.dist.P99, "kpi", kpi.name, "stat", "p99")
+		kpis.add(kpi.dist.Max, "kpi", kpi.name, "stat", "max")
+	}
+
+	utilization := &openMetricsFamily{name: "kubesim_summary_utilization", typ: "gauge",
+		help: "The time-weighted utilization of a resource over the cluster."}
+	for _, rsrc := range sortedResourceNames(summary.Utilization) {
+		utilization.add(summary.Utilization[rsrc], "resource", sanitizeResourceName(rsrc))
+	}
+
+	return []*openMetricsFamily{makespan, pods, kpis, utilization}
+}
+
+// addResources adds a sample of each resource in the list, labeled with the resource and its unit,
+// as kube-state-metrics does.
+func addResources(family *openMetricsFamily, resources v1.ResourceList, labels ...string) {
+	names := make([]string, 0, len(resources))
+	for rsrc := range resources {
+		names = append(names, string(rsrc))
+	}
+	sort.Strings(names)
+
+	for _, rsrc := range names {
+		quantity := resources[v1.ResourceName(rsrc)]
+		unit, value := resourceUnitAndValue(v1.ResourceName(rsrc), quantity)
+
+		sampleLabels := append(append([]string{}, labels...),
+			"resource", sanitizeResourceName(v1.ResourceName(rsrc)), "unit", unit)
+		family.add(value, sampleLabels...)
+	}
+}
+
+// resourceUnitAndValue returns the unit of the resource in kube-state-metrics and the value of the
+// quantity in the unit.
+func resourceUnitAndValue(rsrc v1.ResourceName, quantity resource.Quantity) (string, float64) {
+	value := float64(quantity.MilliValue()) / 1000
+
+	switch {
+	case rsrc == v1.ResourceCPU:
+		return "core", value
+	case rsrc == v1.ResourceMemory || rsrc == v1.ResourceEphemeralStorage ||
+		rsrc == v1.ResourceStorage || strings.HasPrefix(string(rsrc), v1.ResourceHugePagesPrefix):
+		return "byte", value
+	default:
+		return "integer", value
+	}
+}
+
+// sanitizeResourceName replaces the characters not allowed in metric names (e.g., "nvidia.com/gpu")
+// with underscores, as kube-state-metrics does.
+func sanitizeResourceName(rsrc v1.ResourceName) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, string(rsrc))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// OpenMetricsFileWriter is a Writer that writes metrics to a file in the OpenMetrics text format,
+// with the simulated clocks as the timestamps, so that the file can be backfilled into Prometheus
+// (e.g., by `promtool tsdb create-blocks-from openmetrics`).
+// As the format requires the samples of a metric family to be contiguous, the samples are spooled
+// in a temporary directory, and the file is written when the summary of the run is written or this
+// writer is closed.
+type OpenMetricsFileWriter struct {
+	file *os.File
+	// spoolDir is the temporary directory with a file of the samples of each metric family.
+	spoolDir string
+	families []*openMetricsFamily
+	spools   map[string]*bufio.Writer
+	files    map[string]*os.File
+	closed   bool
+}
+
+// NewOpenMetricsFileWriter creates a new OpenMetricsFileWriter with an output device or file at the
+// given path, as NewFileWriter does.
+// Returns error if failed to create a file or the temporary directory.
+func NewOpenMetricsFileWriter(dest string) (*OpenMetricsFileWriter, error) {
+	file, err := openDest(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolDir, err := ioutil.TempDir("", "kubesim-openmetrics-")
+	if err != nil {
+		closeDest(file) // nolint: errcheck
+		return nil, err
+	}
+
+	return &OpenMetricsFileWriter{
+		file:     file,
+		spoolDir: spoolDir,
+		spools:   map[string]*bufio.Writer{},
+		files:    map[string]*os.File{},
+	}, nil
+}
+
+// FileName returns the name of file underlying this OpenMetricsFileWriter.
+func (w *OpenMetricsFileWriter) FileName() string { return w.file.Name() }
+
+// Write implements Writer interface.
+// Returns error if failed to spool the samples.
+func (w *OpenMetricsFileWriter) Write(metrics *Metrics) error {
+	return w.spool(buildOpenMetricsFamilies(metrics), metrics.Clock.ToMetaV1().Time)
+}
+
+// WriteSummary implements SummaryWriter interface.
+// It writes the summary as gauges at the end clock of the run, and then all the spooled samples to
+// the file, terminated by "# EOF".
+// Metrics written after this are ignored.
+func (w *OpenMetricsFileWriter) WriteSummary(summary *Summary) error {
+	if w.closed {
+		return nil
+	}
+
+	clk, err := time.Parse(time.RFC3339, summary.EndClock)
+	if err != nil {
+		return err
+	}
+	if err := w.spool(buildSummaryFamilies(summary), clk); err != nil {
+		return err
+	}
+
+	return w.close()
+}
+
+// Close implements io.Closer interface.
+// If the summary has not been written (e.g., the run failed), it writes the samples spooled so far
+// to the file, terminated by "# EOF".
+// The temporary directory is removed in either case.
+func (w *OpenMetricsFileWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	return w.close()
+}
+
+func (w *OpenMetricsFileWriter) spool(families []*openMetricsFamily, clk time.Time) error {
+	if w.closed {
+		return nil
+	}
+
+	timestamp := strconv.FormatInt(clk.Unix(), 10)
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+
+		spool, ok := w.spools[f.name]
+		if !ok {
+			file, err := os.Create(filepath.Join(w.spoolDir, f.name))
+			if err != nil {
+				return err
+			}
+			spool = bufio.NewWriter(file)
+			w.spools[f.name] = spool
+			w.files[f.name] = file
+			w.families = append(w.families, &openMetricsFamily{name: f.name, typ: f.typ, help: f.help})
+		}
+
+		if _, err := spool.WriteString(f.format(timestamp)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close writes the spooled samples of each metric family to the file and closes it, and removes the
+// temporary directory.
+func (w *OpenMetricsFileWriter) close() error {
+	w.closed = true
+	defer func() {
+		for _, file := range w.files {
+			file.Close() // nolint: errcheck
+		}
+		os.RemoveAll(w.spoolDir) // nolint: errcheck
+	}()
+
+	err := w.writeFile()
+	if closeErr := closeDest(w.file); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// writeFile writes the spooled samples of each metric family to the file, terminated by "# EOF".
+func (w *OpenMetricsFileWriter) writeFile() error {
+	out := bufio.NewWriter(w.file)
+	for _, f := range w.families {
+		if err := w.spools[f.name].Flush(); err != nil {
+			return err
+		}
+
+		file := w.files[f.name]
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := out.WriteString(f.header()); err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, file); err != nil {
+			return err
+		}
+	}
+	if _, err := out.WriteString("# EOF\n"); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
+
+var _ = Writer(&OpenMetricsFileWriter{})
+var _ = SummaryWriter(&OpenMetricsFileWriter{})
+var _ = io.Closer(&OpenMetricsFileWriter{})
+
+// OpenMetricsServer is a Writer that serves the latest metrics in the OpenMetrics text format at an
+// HTTP endpoint, to be scraped by Prometheus while the simulation runs.
+// Samples have no timestamps, so that they are stored at the scraping times.
+type OpenMetricsServer struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu   sync.RWMutex
+	body string
+}
+
+// NewOpenMetricsServer creates a new OpenMetricsServer serving at the path on the address (e.g.,
+// "localhost:9100").
+// Returns error if failed to listen on the address.
+func NewOpenMetricsServer(addr, path string) (*OpenMetricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &OpenMetricsServer{listener: listener, body: "# EOF\n"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, server.serveHTTP)
+	server.server = &http.Server{Handler: mux}
+	go func() {
+		if err := server.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.L.Debugf("OpenMetrics server at %s stopped: %s", listener.Addr(), err.Error())
+		}
+	}()
+
+	return server, nil
+}
+
+// Addr returns the address on which this OpenMetricsServer is listening.
+func (s *OpenMetricsServer) Addr() string { return s.listener.Addr().String() }
+
+// Write implements Writer interface.
+func (s *OpenMetricsServer) Write(metrics *Metrics) error {
+	var b strings.Builder
+	for _, f := range buildOpenMetricsFamilies(metrics) {
+		b.WriteString(f.header())
+		b.WriteString(f.format(""))
+	}
+	b.WriteString("# EOF\n")
+
+	s.mu.Lock()
+	s.body = b.String()
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *OpenMetricsServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	body := s.body
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", openMetricsContentType)
+	io.WriteString(w, body) // nolint: errcheck
+}
+
+// Close implements io.Closer interface.
+// It stops serving, closing the listener and all connections.
+func (s *OpenMetricsServer) Close() error {
+	return s.server.Close()
+}
+
+var _ = Writer(&OpenMetricsServer{})
+var _ = io.Closer(&OpenMetricsServer{})
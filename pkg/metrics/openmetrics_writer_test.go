@@ -0,0 +1,271 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+// newTestMetrics creates metrics with a pod whose name needs escaping, and a ClusterQueue.
+func newTestMetrics(seconds int, pendingPodsNum int) *Metrics {
+	return &Metrics{
+		Clock: testStartClock.Add(time.Duration(seconds) * time.Second),
+		Pods: map[string]pod.Metrics{
+			"default/pod-\"0\"\\\n": {
+				ResourceRequest: v1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+				Node:            "node-0",
+				Phase:           v1.PodRunning,
+			},
+		},
+		Queue: queue.Metrics{
+			PendingPodsNum: pendingPodsNum,
+			ClusterQueues: map[string]queue.ClusterQueueMetrics{
+				"cq-0": {PendingPodsNum: pendingPodsNum, AdmittedPodsTotal: seconds / 10},
+			},
+		},
+	}
+}
+
+// testOpenMetricsGolden is the exposition of the metrics of two ticks and the summary, whose samples
+// of each family are contiguous.
+const testOpenMetricsGolden = `# TYPE kube_pod_status_phase gauge
+# HELP kube_pod_status_phase The current phase of a pod.
+kube_pod_status_phase{namespace="default",pod="pod-\"0\"\\\n",phase="Running"} 1 1546300810
+kube_pod_status_phase{namespace="default",pod="pod-\"0\"\\\n",phase="Running"} 1 1546300820
+# TYPE kube_pod_container_resource_requests gauge
+# HELP kube_pod_container_resource_requests The total resource requests of the containers of a pod.
+kube_pod_container_resource_requests{namespace="default",pod="pod-\"0\"\\\n",node="node-0",resource="nvidia_com_gpu",unit="integer"} 1 1546300810
+kube_pod_container_resource_requests{namespace="default",pod="pod-\"0\"\\\n",node="node-0",resource="nvidia_com_gpu",unit="integer"} 1 1546300820
+# TYPE scheduler_pending_pods gauge
+# HELP scheduler_pending_pods The number of the pending pods in the queues.
+scheduler_pending_pods 2 1546300810
+scheduler_pending_pods 1 1546300820
+# TYPE kueue_pending_workloads gauge
+# HELP kueue_pending_workloads The number of the pods waiting for admission in a ClusterQueue.
+kueue_pending_workloads{cluster_queue="cq-0"} 2 1546300810
+kueue_pending_workloads{cluster_queue="cq-0"} 1 1546300820
+# TYPE kueue_admitted_active_workloads gauge
+# HELP kueue_admitted_active_workloads The number of the pods holding quota of a ClusterQueue.
+kueue_admitted_active_workloads{cluster_queue="cq-0"} 0 1546300810
+kueue_admitted_active_workloads{cluster_queue="cq-0"} 0 1546300820
+# TYPE kueue_admitted_workloads counter
+# HELP kueue_admitted_workloads The number of the pods ever admitted by a ClusterQueue.
+kueue_admitted_workloads_total{cluster_queue="cq-0"} 1 1546300810
+kueue_admitted_workloads_total{cluster_queue="cq-0"} 2 1546300820
+# TYPE kubesim_summary_makespan_seconds gauge
+# HELP kubesim_summary_makespan_seconds The time from the first submission to the last completion of the pods.
+kubesim_summary_makespan_seconds 30 1546300830
+# TYPE kubesim_summary_pods gauge
+# HELP kubesim_summary_pods The number of the pods in the run by their results.
+kubesim_summary_pods{result="bound"} 1 1546300830
+kubesim_summary_pods{result="unbound"} 0 1546300830
+kubesim_summary_pods{result="completed"} 0 1546300830
+kubesim_summary_pods{result="over_capacity"} 0 1546300830
+kubesim_summary_pods{result="preempted"} 0 1546300830
+kubesim_summary_pods{result="wasted"} 0 1546300830
+# TYPE kubesim_summary_kpi gauge
+# HELP kubesim_summary_kpi The distributions of the queueing delay, job completion time, and slowdown of the pods.
+kubesim_summary_kpi{kpi="queueing_delay_seconds",stat="avg"} 1.5 1546300830
+kubesim_summary_kpi{kpi="queueing_delay_seconds",stat="p50"} 1.5 1546300830
+kubesim_summary_kpi{kpi="queueing_delay_seconds",stat="p95"} 1.5 1546300830
+kubesim_summary_kpi{kpi="queueing_delay_seconds",stat="p99"} 1.5 1546300830
+kubesim_summary_kpi{kpi="queueing_delay_seconds",stat="max"} 1.5 1546300830
+kubesim_summary_kpi{kpi="job_completion_time_seconds",stat="avg"} 0 1546300830
+kubesim_summary_kpi{kpi="job_completion_time_seconds",stat="p50"} 0 1546300830
+kubesim_summary_kpi{kpi="job_completion_time_seconds",stat="p95"} 0 1546300830
+kubesim_summary_kpi{kpi="job_completion_time_seconds",stat="p99"} 0 1546300830
+kubesim_summary_kpi{kpi="job_completion_time_seconds",stat="max"} 0 1546300830
+kubesim_summary_kpi{kpi="slowdown",stat="avg"} 0 1546300830
+kubesim_summary_kpi{kpi="slowdown",stat="p50"} 0 1546300830
+kubesim_summary_kpi{kpi="slowdown",stat="p95"} 0 1546300830
+kubesim_summary_kpi{kpi="slowdown",stat="p99"} 0 1546300830
+kubesim_summary_kpi{kpi="slowdown",stat="max"} 0 1546300830
+# TYPE kubesim_summary_utilization gauge
+# HELP kubesim_summary_utilization The time-weighted utilization of a resource over the cluster.
+kubesim_summary_utilization{resource="nvidia_com_gpu"} 0.5 1546300830
+# EOF
+`
+
+func TestOpenMetricsFileWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubesim-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewOpenMetricsFileWriter(filepath.Join(dir, "kubesim.om"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, pendingPodsNum := range []int{2, 1} {
+		if err := w.Write(newTestMetrics(10*(i+1), pendingPodsNum)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summary := Summary{
+		EndClock:        testStartClock.Add(30 * time.Second).ToRFC3339(),
+		MakespanSeconds: 30,
+		BoundPodsNum:    1,
+		QueueingDelay:   Distribution{Count: 1, Avg: 1.5, P50: 1.5, P95: 1.5, P99: 1.5, Max: 1.5},
+		Utilization:     map[v1.ResourceName]float64{"nvidia.com/gpu": 0.5},
+	}
+	if err := w.WriteSummary(&summary); err != nil {
+		t.Fatal(err)
+	}
+	// Metrics written after the summary are ignored.
+	if err := w.Write(newTestMetrics(40, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(filepath.Join(dir, "kubesim.om"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != testOpenMetricsGolden {
+		t.Errorf("got:\n%s\nwant:\n%s", actual, testOpenMetricsGolden)
+	}
+	if _, err := os.Stat(w.spoolDir); !os.IsNotExist(err) {
+		t.Errorf("got: %v\nwant: %s removed", err, w.spoolDir)
+	}
+}
+
+func TestOpenMetricsFileWriterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubesim-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewOpenMetricsFileWriter(filepath.Join(dir, "kubesim.om"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(newTestMetrics(10, 2)); err != nil {
+		t.Fatal(err)
+	}
+	// Closed without the summary, e.g., when the run failed.
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(filepath.Join(dir, "kubesim.om"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# TYPE scheduler_pending_pods gauge\n" +
+		"# HELP scheduler_pending_pods The number of the pending pods in the queues.\n" +
+		"scheduler_pending_pods 2 1546300810\n"
+	if !strings.Contains(string(actual), expected) || !strings.HasSuffix(string(actual), "\n# EOF\n") ||
+		strings.Contains(string(actual), "kubesim_summary") {
+		t.Errorf("got:\n%s\nwant: the samples without the summary, terminated by # EOF", actual)
+	}
+	if _, err := os.Stat(w.spoolDir); !os.IsNotExist(err) {
+		t.Errorf("got: %v\nwant: %s removed", err, w.spoolDir)
+	}
+}
+
+func TestOpenMetricsServer(t *testing.T) {
+	s, err := NewOpenMetricsServer("localhost:0", "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(newTestMetrics(10, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get("http://" + s.Addr() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != openMetricsContentType {
+		t.Errorf("got: %s\nwant: %s", resp.Header.Get("Content-Type"), openMetricsContentType)
+	}
+	// The samples have no timestamps.
+	if !strings.Contains(string(body), "\nscheduler_pending_pods 2\n") || !strings.HasSuffix(string(body), "\n# EOF\n") {
+		t.Errorf("got:\n%s\nwant: the latest samples without timestamps", body)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := http.Get("http://" + s.Addr() + "/metrics"); err == nil {
+		resp.Body.Close()
+		t.Errorf("got: %s\nwant: error after Close", resp.Status)
+	}
+}
+
+func TestNodeStatusCondition(t *testing.T) {
+	metrics := map[string]node.Metrics{
+		// node-0 is made not ready by a condition operation, without failing.
+		"node-0": {Conditions: map[v1.NodeConditionType]v1.ConditionStatus{
+			v1.NodeReady:          v1.ConditionFalse,
+			v1.NodeMemoryPressure: v1.ConditionTrue,
+		}},
+		"node-1": {Failed: true, Conditions: map[v1.NodeConditionType]v1.ConditionStatus{
+			v1.NodeReady: v1.ConditionUnknown,
+		}},
+		// The Ready condition of node-2 is derived from whether it has failed.
+		"node-2": {Failed: true},
+		"node-3": {},
+	}
+
+	want := `kube_node_status_condition{node="node-0",condition="MemoryPressure",status="true"} 1
+kube_node_status_condition{node="node-0",condition="MemoryPressure",status="false"} 0
+kube_node_status_condition{node="node-0",condition="MemoryPressure",status="unknown"} 0
+kube_node_status_condition{node="node-0",condition="Ready",status="true"} 0
+kube_node_status_condition{node="node-0",condition="Ready",status="false"} 1
+kube_node_status_condition{node="node-0",condition="Ready",status="unknown"} 0
+kube_node_status_condition{node="node-1",condition="Ready",status="true"} 0
+kube_node_status_condition{node="node-1",condition="Ready",status="false"} 0
+kube_node_status_condition{node="node-1",condition="Ready",status="unknown"} 1
+kube_node_status_condition{node="node-2",condition="Ready",status="true"} 0
+kube_node_status_condition{node="node-2",condition="Ready",status="false"} 0
+kube_node_status_condition{node="node-2",condition="Ready",status="unknown"} 1
+kube_node_status_condition{node="node-3",condition="Ready",status="true"} 1
+kube_node_status_condition{node="node-3",condition="Ready",status="false"} 0
+kube_node_status_condition{node="node-3",condition="Ready",status="unknown"} 0
+`
+	for _, family := range buildNodeFamilies(metrics) {
+		if family.name != "kube_node_status_condition" {
+			continue
+		}
+		if got := family.format(""); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+		return
+	}
+	t.Errorf("got: no kube_node_status_condition\nwant: the conditions of the nodes")
+}
@@ -0,0 +1,380 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"time"
+)
+
+// This file implements a minimal writer of the Apache Parquet format, which writes required
+// (non-nullable) columns in uncompressed data pages with the PLAIN encoding, and the file metadata in
+// the Thrift compact protocol.
+
+// Parquet physical types, converted types, and encodings.
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+
+	parquetRequired  = 0
+	parquetDataPage  = 0
+	parquetCodecNone = 0
+)
+
+// parquetMagic is the magic number at the beginning and end of a Parquet file.
+var parquetMagic = []byte("PAR1")
+
+// parquetRowGroupSize is the number of rows buffered before they are written as a row group.
+const parquetRowGroupSize = 65536
+
+// parquetEncoder is a tableEncoder that writes a table to a Parquet file.
+type parquetEncoder struct {
+	file   *os.File
+	out    *bufio.Writer
+	offset int64
+	table  table
+
+	// columns is the PLAIN-encoded values of the rows buffered for each column.
+	columns   []bytes.Buffer
+	rowsNum   int64
+	rowGroups []parquetRowGroup
+}
+
+// parquetRowGroup is the metadata of a row group written.
+type parquetRowGroup struct {
+	rowsNum   int64
+	totalSize int64
+	columns   []parquetColumnChunk
+}
+
+// parquetColumnChunk is the metadata of a column chunk written, which has one data page.
+type parquetColumnChunk struct {
+	pageOffset int64
+	totalSize  int64
+}
+
+// newParquetEncoder creates a new parquetEncoder that writes the table to a file at the given path.
+func newParquetEncoder(path string, table table) (tableEncoder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &parquetEncoder{
+		file:    file,
+		out:     bufio.NewWriter(file),
+		table:   table,
+		columns: make([]bytes.Buffer, len(table.columns)),
+	}
+	if err := e.write(parquetMagic); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *parquetEncoder) writeRow(row []interface{}) error {
+	var b [8]byte
+	for i, col := range e.table.columns {
+		buf := &e.columns[i]
+		switch col.typ {
+		case stringColumn:
+			s := row[i].(string)
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+			buf.Write(b[:4])
+			buf.WriteString(s)
+		case int64Column:
+			binary.LittleEndian.PutUint64(b[:], uint64(row[i].(int64)))
+			buf.Write(b[:])
+		case float64Column:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(row[i].(float64)))
+			buf.Write(b[:])
+		case clockColumn:
+			millis := row[i].(time.Time).UnixNano() / int64(time.Millisecond)
+			binary.LittleEndian.PutUint64(b[:], uint64(millis))
+			buf.Write(b[:])
+		}
+	}
+
+	e.rowsNum++
+	if e.rowsNum >= parquetRowGroupSize {
+		return e.flushRowGroup()
+	}
+
+	return nil
+}
+
+// flushRowGroup writes the buffered rows as a row group, with a data page for each column.
+func (e *parquetEncoder) flushRowGroup() error {
+	if e.rowsNum == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rowsNum: e.rowsNum}
+	for i := range e.columns {
+		values := e.columns[i].Bytes()
+
+		var header thriftWriter
+		header.beginStruct()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(values)))
+		header.i32(3, int32(len(values)))
+		header.fieldStruct(5)
+		header.i32(1, int32(e.rowsNum))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingPlain)
+		header.i32(4, parquetEncodingPlain)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetColumnChunk{
+			pageOffset: e.offset,
+			totalSize:  int64(header.buf.Len() + len(values)),
+		}
+		if err := e.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := e.write(values); err != nil {
+			return err
+		}
+
+		group.columns = append(group.columns, chunk)
+		group.totalSize += chunk.totalSize
+		e.columns[i].Reset()
+	}
+
+	e.rowGroups = append(e.rowGroups, group)
+	e.rowsNum = 0
+
+	return nil
+}
+
+func (e *parquetEncoder) close() error {
+	if err := e.writeFooter(); err != nil {
+		e.file.Close() // nolint: errcheck
+		return err
+	}
+
+	return e.file.Close()
+}
+
+// writeFooter writes the rows buffered and the FileMetaData of the file.
+func (e *parquetEncoder) writeFooter() error {
+	if err := e.flushRowGroup(); err != nil {
+		return err
+	}
+
+	metadata := e.fileMetadata()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(metadata)))
+
+	for _, b := range [][]byte{metadata, length[:], parquetMagic} {
+		if err := e.write(b); err != nil {
+			return err
+		}
+	}
+
+	return e.out.Flush()
+}
+
+// fileMetadata returns the FileMetaData of the file, encoded in the Thrift compact protocol.
+func (e *parquetEncoder) fileMetadata() []byte {
+	var w thriftWriter
+	totalRows := int64(0)
+	for _, group := range e.rowGroups {
+		totalRows += group.rowsNum
+	}
+
+	w.beginStruct()
+	w.i32(1, 1) // version
+
+	// Schema, flattened in depth-first order from the root.
+	w.fieldList(2, thriftStruct, len(e.table.columns)+1)
+	w.beginStruct()
+	w.str(4, "schema")
+	w.i32(5, int32(len(e.table.columns)))
+	w.endStruct()
+	for _, col := range e.table.columns {
+		w.beginStruct()
+		w.i32(1, col.parquetType())
+		w.i32(3, parquetRequired)
+		w.str(4, col.name)
+		if converted, ok := col.parquetConvertedType(); ok {
+			w.i32(6, converted)
+		}
+		w.endStruct()
+	}
+
+	w.i64(3, totalRows)
+
+	w.fieldList(4, thriftStruct, len(e.rowGroups))
+	for _, group := range e.rowGroups {
+		w.beginStruct()
+		w.fieldList(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			col := e.table.columns[i]
+
+			w.beginStruct()
+			w.i64(2, chunk.pageOffset)
+			w.fieldStruct(3)
+			w.i32(1, col.parquetType())
+			w.fieldList(2, thriftI32, 1)
+			w.listI32(parquetEncodingPlain)
+			w.fieldList(3, thriftBinary, 1)
+			w.listStr(col.name)
+			w.i32(4, parquetCodecNone)
+			w.i64(5, group.rowsNum)
+			w.i64(6, chunk.totalSize)
+			w.i64(7, chunk.totalSize)
+			w.i64(9, chunk.pageOffset)
+			w.endStruct()
+			w.endStruct()
+		}
+		w.i64(2, group.totalSize)
+		w.i64(3, group.rowsNum)
+		w.endStruct()
+	}
+
+	w.str(6, "k8s-cluster-simulator")
+	w.endStruct()
+
+	return w.buf.Bytes()
+}
+
+func (e *parquetEncoder) write(b []byte) error {
+	n, err := e.out.Write(b)
+	e.offset += int64(n)
+	return err
+}
+
+func (c column) parquetType() int32 {
+	switch c.typ {
+	case stringColumn:
+		return parquetByteArray
+	case float64Column:
+		return parquetDouble
+	default:
+		return parquetInt64
+	}
+}
+
+func (c column) parquetConvertedType() (int32, bool) {
+	switch c.typ {
+	case stringColumn:
+		return parquetConvertedUTF8, true
+	case clockColumn:
+		return parquetConvertedTimestampMillis, true
+	default:
+		return 0, false
+	}
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol.
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastFields is the last field ID written in each of the nested structs.
+	lastFields []int16
+}
+
+func (w *thriftWriter) beginStruct() {
+	w.lastFields = append(w.lastFields, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0) // stop
+	w.lastFields = w.lastFields[:len(w.lastFields)-1]
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.lastFields[len(w.lastFields)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(uint64(zigzag(int64(id))))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(uint64(zigzag(int64(v))))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(uint64(zigzag(v)))
+}
+
+func (w *thriftWriter) str(id int16, s string) {
+	w.fieldHeader(id, thriftBinary)
+	w.listStr(s)
+}
+
+// fieldStruct writes the header of a struct field, which must be followed by its fields and
+// endStruct.
+func (w *thriftWriter) fieldStruct(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
+
+// fieldList writes the header of a list field, which must be followed by its elements.
+func (w *thriftWriter) fieldList(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *thriftWriter) listI32(v int32) {
+	w.varint(uint64(zigzag(int64(v))))
+}
+
+func (w *thriftWriter) listStr(s string) {
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func zigzag(v int64) int64 {
+	return (v << 1) ^ (v >> 63)
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes structs in the Thrift compact protocol into maps from the field IDs to the
+// values, independently of thriftWriter.
+type thriftReader struct {
+	b []byte
+	i int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.b[r.i]
+	r.i++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.i:])
+	r.i += n
+	return v
+}
+
+func (r *thriftReader) int() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2: // true, false
+		return typ == 1
+	case 3: // i8
+		return int64(int8(r.byte()))
+	case 4, 5, 6: // i16, i32, i64
+		return r.int()
+	case 7: // double
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.i:]))
+		r.i += 8
+		return v
+	case 8: // binary
+		n := int(r.varint())
+		v := string(r.b[r.i : r.i+n])
+		r.i += n
+		return v
+	case 9, 10: // list, set
+		header := r.byte()
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.varint())
+		}
+		list := make([]interface{}, n)
+		for k := range list {
+			list[k] = r.value(header & 0x0f)
+		}
+		return list
+	case 12: // struct
+		return r.structure()
+	}
+	panic(fmt.Sprintf("Unknown Thrift type %d", typ))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	last := int16(0)
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.int())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+// parquetFile is a Parquet file decoded, with the values formatted as the csvEncoder does.
+type parquetFile struct {
+	header       []string
+	rows         [][]string
+	rowGroupsNum int
+}
+
+// readParquet decodes a Parquet file of required columns in uncompressed PLAIN-encoded data pages,
+// following the field IDs of parquet.thrift.
+func readParquet(t *testing.T, path string) parquetFile {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatalf("%s: got: %q...%q\nwant: PAR1...PAR1", path, data[:4], data[len(data)-4:])
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{b: data[len(data)-8-footerLen : len(data)-8]}
+	meta := footer.structure()
+	if footer.i != footerLen {
+		t.Fatalf("%s: got: %d bytes of FileMetaData decoded\nwant: %d", path, footer.i, footerLen)
+	}
+
+	// The schema is the root, with the number of the columns, followed by the columns.
+	schema := meta[2].([]interface{})
+	if root := schema[0].(map[int16]interface{}); root[5] != int64(len(schema)-1) {
+		t.Fatalf("%s: got: root %v\nwant: %d children", path, root, len(schema)-1)
+	}
+	file := parquetFile{}
+	types := []int64{}
+	converted := []interface{}{}
+	for _, elem := range schema[1:] {
+		col := elem.(map[int16]interface{})
+		if col[3] != int64(parquetRequired) {
+			t.Fatalf("%s: got: %v\nwant: a required column", path, col)
+		}
+		file.header = append(file.header, col[4].(string))
+		types = append(types, col[1].(int64))
+		converted = append(converted, col[6])
+	}
+
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		rowsNum := int(group[3].(int64))
+		rows := make([][]string, rowsNum)
+
+		for c, chunk := range group[1].([]interface{}) {
+			md := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			if md[1] != types[c] || md[4] != int64(parquetCodecNone) || md[5] != int64(rowsNum) ||
+				!reflect.DeepEqual(md[3], []interface{}{file.header[c]}) {
+				t.Fatalf("%s: got: %v\nwant: metadata of %s", path, md, file.header[c])
+			}
+
+			offset := int(md[9].(int64))
+			page := &thriftReader{b: data, i: offset}
+			pageHeader := page.structure()
+			size := int(pageHeader[3].(int64))
+			dataPage := pageHeader[5].(map[int16]interface{})
+			if pageHeader[1] != int64(parquetDataPage) || dataPage[1] != int64(rowsNum) ||
+				dataPage[2] != int64(parquetEncodingPlain) || md[7] != int64(page.i-offset+size) {
+				t.Fatalf("%s: got: %v, %v\nwant: a data page of %s", path, md, pageHeader, file.header[c])
+			}
+
+			values := &thriftReader{b: data[page.i : page.i+size]}
+			for r := range rows {
+				rows[r] = append(rows[r], readPlainValue(values, types[c], converted[c]))
+			}
+			if values.i != size {
+				t.Fatalf("%s: got: %d bytes of %s decoded\nwant: %d", path, values.i, file.header[c], size)
+			}
+		}
+
+		file.rows = append(file.rows, rows...)
+		file.rowGroupsNum++
+	}
+
+	if meta[3] != int64(len(file.rows)) {
+		t.Fatalf("%s: got: %d rows\nwant: %v", path, len(file.rows), meta[3])
+	}
+
+	return file
+}
+
+// readPlainValue decodes a PLAIN-encoded value, and formats it as the csvEncoder does.
+func readPlainValue(r *thriftReader, typ int64, converted interface{}) string {
+	switch typ {
+	case parquetByteArray:
+		n := int(binary.LittleEndian.Uint32(r.b[r.i:]))
+		r.i += 4 + n
+		return string(r.b[r.i-n : r.i])
+	case parquetInt64:
+		v := int64(binary.LittleEndian.Uint64(r.b[r.i:]))
+		r.i += 8
+		if converted == int64(parquetConvertedTimestampMillis) {
+			return time.Unix(0, v*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+		}
+		return strconv.FormatInt(v, 10)
+	case parquetDouble:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.i:]))
+		r.i += 8
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	panic(fmt.Sprintf("Unknown Parquet type %d", typ))
+}
+
+func TestParquetEncoderRowGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubesim-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testTable := table{name: "test", columns: []column{
+		{"clock", clockColumn},
+		{"name", stringColumn},
+		{"count", int64Column},
+		{"value", float64Column},
+	}}
+	path := filepath.Join(dir, "test.parquet")
+	e, err := newParquetEncoder(path, testTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows are written in two row groups.
+	rowsNum := parquetRowGroupSize + 2
+	for i := 0; i < rowsNum; i++ {
+		row := []interface{}{
+			testStartClock.Add(time.Duration(i) * time.Second).ToMetaV1().Time,
+			fmt.Sprintf("row-%d", i), int64(i - 1), float64(i) / 4,
+		}
+		if err := e.writeRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := readParquet(t, path)
+	if file.rowGroupsNum != 2 || len(file.rows) != rowsNum {
+		t.Fatalf("got: %d rows in %d row groups\nwant: %d rows in 2 row groups",
+			len(file.rows), file.rowGroupsNum, rowsNum)
+	}
+	if expected := []string{"clock", "name", "count", "value"}; !reflect.DeepEqual(file.header, expected) {
+		t.Errorf("got: %v\nwant: %v", file.header, expected)
+	}
+	for _, i := range []int{0, 1, parquetRowGroupSize, rowsNum - 1} {
+		expected := []string{
+			testStartClock.Add(time.Duration(i) * time.Second).ToMetaV1().Time.UTC().Format(time.RFC3339),
+			fmt.Sprintf("row-%d", i), strconv.Itoa(i - 1), strconv.FormatFloat(float64(i)/4, 'f', -1, 64),
+		}
+		if !reflect.DeepEqual(file.rows[i], expected) {
+			t.Errorf("got: %v at %d\nwant: %v", file.rows[i], i, expected)
+		}
+	}
+}
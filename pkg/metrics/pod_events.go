@@ -0,0 +1,121 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// PodEventType is the type of a PodEvent.
+type PodEventType string
+
+const (
+	// PodSubmitted is the type of the event of a pod submitted to the queue of its scheduler.
+	PodSubmitted PodEventType = "Submitted"
+	// PodFailedScheduling is the type of the event of a scheduler failing to find a node for a pod.
+	PodFailedScheduling PodEventType = "FailedScheduling"
+	// PodNominated is the type of the event of a scheduler nominating a node for a pod that preempts
+	// other pods on the node.
+	PodNominated PodEventType = "Nominated"
+	// PodPreempted is the type of the event of a pod preempted by a scheduler or its queue.
+	PodPreempted PodEventType = "Preempted"
+	// PodScheduled is the type of the event of a scheduler deciding the node of a pod, which is bound
+	// to the node after the scheduling latency.
+	PodScheduled PodEventType = "Scheduled"
+	// PodBound is the type of the event of a pod bound to a node.
+	PodBound PodEventType = "Bound"
+	// PodBindRetried is the type of the event of a pod returned to the queue because it could not be
+	// bound to the node (e.g., by a bind conflict).
+	PodBindRetried PodEventType = "BindRetried"
+	// PodFailed is the type of the event of a pod failing to start on its node (e.g., OverCapacity).
+	PodFailed PodEventType = "Failed"
+	// PodStarted is the type of the event of the containers of a pod starting.
+	PodStarted PodEventType = "Started"
+	// PodFinished is the type of the event of a pod finishing its execution spontaneously.
+	PodFinished PodEventType = "Finished"
+	// PodDeleted is the type of the event of a pod deleted from the queue or its node.
+	PodDeleted PodEventType = "Deleted"
+	// PodKilled is the type of the event of a pod killed on its node (NodeLost, Evicted, or
+	// OOMKilled).
+	PodKilled PodEventType = "Killed"
+)
+
+// PodEvent is an event in the lifecycle of a pod.
+type PodEvent struct {
+	Clock     string
+	Type      PodEventType
+	Namespace string
+	Name      string
+	Node      string `json:",omitempty"`
+	Scheduler string `json:",omitempty"`
+	Priority  int32
+
+	// Reason and Message tell why the event happened (e.g., the status of a killed pod).
+	Reason  string `json:",omitempty"`
+	Message string `json:",omitempty"`
+	// Preemptor is the key (namespace/name) of the pod that preempted this pod.
+	Preemptor string `json:",omitempty"`
+
+	ResourceRequest v1.ResourceList `json:",omitempty"`
+	GPUs            []int           `json:",omitempty"`
+	// FailedPredicates is the number of the nodes that failed the pod for each reason.
+	FailedPredicates map[string]int `json:",omitempty"`
+}
+
+// PodEventWriter defines the interface of writer that writes the lifecycle events of pods.
+type PodEventWriter interface {
+	// WritePodEvents writes the given events to some location(s).
+	WritePodEvents(events []PodEvent) error
+}
+
+// PodEventFileWriter is a PodEventWriter that writes events to a file in JSON Lines format, one
+// event per line.
+type PodEventFileWriter struct {
+	file *os.File
+}
+
+// NewPodEventFileWriter creates a new PodEventFileWriter with an output device or file at the given
+// path, as NewFileWriter does.
+// Returns error if failed to create a file.
+func NewPodEventFileWriter(dest string) (*PodEventFileWriter, error) {
+	file, err := openDest(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PodEventFileWriter{file: file}, nil
+}
+
+// FileName returns the name of file underlying this PodEventFileWriter.
+func (w *PodEventFileWriter) FileName() string { return w.file.Name() }
+
+// WritePodEvents implements PodEventWriter interface.
+func (w *PodEventFileWriter) WritePodEvents(events []PodEvent) error {
+	buf := bufio.NewWriter(w.file)
+	encoder := json.NewEncoder(buf)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+var _ = PodEventWriter(&PodEventFileWriter{})
@@ -0,0 +1,50 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// QOSMetrics is a metrics of the pods of each QoS class, aggregated over all nodes.
+// Classes that no pod belongs to are omitted.
+type QOSMetrics map[v1.PodQOSClass]node.QOSMetrics
+
+// qosClasses is the order in which QoS classes are formatted.
+var qosClasses = []v1.PodQOSClass{v1.PodQOSGuaranteed, v1.PodQOSBurstable, v1.PodQOSBestEffort}
+
+// buildQOSMetrics aggregates the per-QoS metrics of the nodes.
+func buildQOSMetrics(nodesMetrics map[string]node.Metrics) QOSMetrics {
+	qosMetrics := QOSMetrics{}
+
+	for _, nodeMet := range nodesMetrics {
+		for class, met := range nodeMet.QOS {
+			total := qosMetrics[class]
+
+			total.RunningPodsNum += met.RunningPodsNum
+			total.EvictedPodsNum += met.EvictedPodsNum
+			total.OOMKilledPodsNum += met.OOMKilledPodsNum
+			total.TotalResourceRequest = util.ResourceListSum(total.TotalResourceRequest, met.TotalResourceRequest)
+			total.TotalResourceUsage = util.ResourceListSum(total.TotalResourceUsage, met.TotalResourceUsage)
+
+			qosMetrics[class] = total
+		}
+	}
+
+	return qosMetrics
+}
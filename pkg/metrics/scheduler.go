@@ -0,0 +1,128 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import "sort"
+
+// SchedulersMetrics is a map from scheduler names to their metrics.
+type SchedulersMetrics map[string]SchedulerMetrics
+
+// SchedulerMetrics is a metrics of a scheduler, counted since the start of the simulation.
+type SchedulerMetrics struct {
+	SchedulerCounts
+	// LastTick is the counts at the last tick.
+	LastTick SchedulerCounts
+}
+
+// SchedulerCounts is the counts of the scheduling attempts and preemptions of a scheduler.
+type SchedulerCounts struct {
+	// AttemptsNum is the number of the scheduling attempts.
+	AttemptsNum int64
+	// ScheduledAttemptsNum is the number of the scheduling attempts that selected a node.
+	ScheduledAttemptsNum int64
+	// UnschedulableAttemptsNum is the number of the scheduling attempts that found no node fitting
+	// the pod.
+	UnschedulableAttemptsNum int64
+	// ErrorAttemptsNum is the number of the scheduling attempts that failed with an error (e.g., of a
+	// plugin).
+	ErrorAttemptsNum int64
+	// FailedPredicates is the number of the nodes that failed the pods for each reason (e.g.,
+	// "Insufficient cpu"), summed over the unschedulable attempts.
+	FailedPredicates map[string]int64 `json:",omitempty"`
+
+	// EvaluatedNodesNum is the number of the nodes evaluated by the predicates, summed over the
+	// scheduled and unschedulable attempts.
+	EvaluatedNodesNum int64
+	// FeasibleNodesNum is the number of the nodes that passed the predicates, summed over the
+	// scheduled and unschedulable attempts.
+	FeasibleNodesNum int64
+	// AvgFeasibleNodesNum is FeasibleNodesNum divided by the number of the scheduled and
+	// unschedulable attempts, or 0 if there are no such attempts.
+	AvgFeasibleNodesNum float64
+	// PluginSeconds is the wall-clock time spent in the predicates, prioritizers, and extenders.
+	PluginSeconds float64
+
+	// PreemptionAttemptsNum is the number of the preemptions that nominated a node.
+	PreemptionAttemptsNum int64
+	// PreemptionVictimsNum is the number of the pods deleted by the scheduler or its queue to make
+	// room for other pods.
+	PreemptionVictimsNum int64
+}
+
+// StartTick resets the counts at the last tick for a new tick.
+func (m *SchedulerMetrics) StartTick() {
+	m.LastTick = SchedulerCounts{}
+}
+
+// Add adds the counts of the events at the current tick to both the cumulative counts and those at
+// the last tick.
+func (m *SchedulerMetrics) Add(counts SchedulerCounts) {
+	m.SchedulerCounts.add(counts)
+	m.LastTick.add(counts)
+}
+
+// DeepCopy returns a deep copy of this SchedulerMetrics, which is not affected by later Add.
+func (m *SchedulerMetrics) DeepCopy() SchedulerMetrics {
+	met := SchedulerMetrics{}
+	met.SchedulerCounts.add(m.SchedulerCounts)
+	met.LastTick.add(m.LastTick)
+	return met
+}
+
+func (c *SchedulerCounts) add(other SchedulerCounts) {
+	c.AttemptsNum += other.AttemptsNum
+	c.ScheduledAttemptsNum += other.ScheduledAttemptsNum
+	c.UnschedulableAttemptsNum += other.UnschedulableAttemptsNum
+	c.ErrorAttemptsNum += other.ErrorAttemptsNum
+
+	for reason, num := range other.FailedPredicates {
+		if c.FailedPredicates == nil {
+			c.FailedPredicates = map[string]int64{}
+		}
+		c.FailedPredicates[reason] += num
+	}
+
+	c.EvaluatedNodesNum += other.EvaluatedNodesNum
+	c.FeasibleNodesNum += other.FeasibleNodesNum
+	if attempts := c.ScheduledAttemptsNum + c.UnschedulableAttemptsNum; attempts > 0 {
+		c.AvgFeasibleNodesNum = float64(c.FeasibleNodesNum) / float64(attempts)
+	}
+	c.PluginSeconds += other.PluginSeconds
+
+	c.PreemptionAttemptsNum += other.PreemptionAttemptsNum
+	c.PreemptionVictimsNum += other.PreemptionVictimsNum
+}
+
+// sortedFailedPredicates returns the sorted reasons in the failed predicates of the counts.
+func sortedFailedPredicates(counts SchedulerCounts) []string {
+	reasons := make([]string, 0, len(counts.FailedPredicates))
+	for reason := range counts.FailedPredicates {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	return reasons
+}
+
+// sortedSchedulerNames returns the sorted names of the schedulers in the metrics.
+func sortedSchedulerNames(metrics SchedulersMetrics) []string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
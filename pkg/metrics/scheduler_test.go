@@ -0,0 +1,101 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchedulerMetrics(t *testing.T) {
+	met := SchedulerMetrics{}
+
+	// The first tick has a scheduled attempt and an unschedulable one.
+	met.StartTick()
+	met.Add(SchedulerCounts{AttemptsNum: 1, ScheduledAttemptsNum: 1, EvaluatedNodesNum: 4, FeasibleNodesNum: 3})
+	met.Add(SchedulerCounts{
+		AttemptsNum:              1,
+		UnschedulableAttemptsNum: 1,
+		FailedPredicates:         map[string]int64{"Insufficient cpu": 3, "node(s) were unschedulable": 1},
+		EvaluatedNodesNum:        4,
+		PreemptionAttemptsNum:    1,
+	})
+	copied := met.DeepCopy()
+
+	// The second tick has an attempt failing with an error, and a victim of the preemption.
+	met.StartTick()
+	met.Add(SchedulerCounts{AttemptsNum: 1, ErrorAttemptsNum: 1})
+	met.Add(SchedulerCounts{
+		AttemptsNum:              1,
+		UnschedulableAttemptsNum: 1,
+		FailedPredicates:         map[string]int64{"Insufficient cpu": 2},
+		EvaluatedNodesNum:        2,
+		PreemptionVictimsNum:     1,
+	})
+
+	want := SchedulerMetrics{
+		SchedulerCounts: SchedulerCounts{
+			AttemptsNum:              4,
+			ScheduledAttemptsNum:     1,
+			UnschedulableAttemptsNum: 2,
+			ErrorAttemptsNum:         1,
+			FailedPredicates:         map[string]int64{"Insufficient cpu": 5, "node(s) were unschedulable": 1},
+			EvaluatedNodesNum:        10,
+			FeasibleNodesNum:         3,
+			// The attempts failing with errors are excluded.
+			AvgFeasibleNodesNum:   1,
+			PreemptionAttemptsNum: 1,
+			PreemptionVictimsNum:  1,
+		},
+		LastTick: SchedulerCounts{
+			AttemptsNum:              2,
+			UnschedulableAttemptsNum: 1,
+			ErrorAttemptsNum:         1,
+			FailedPredicates:         map[string]int64{"Insufficient cpu": 2},
+			EvaluatedNodesNum:        2,
+			PreemptionVictimsNum:     1,
+		},
+	}
+	if !reflect.DeepEqual(met, want) {
+		t.Errorf("got: %+v\nwant: %+v", met, want)
+	}
+
+	// The copy is not affected by the second tick.
+	wantCopied := SchedulerMetrics{
+		SchedulerCounts: SchedulerCounts{
+			AttemptsNum:              2,
+			ScheduledAttemptsNum:     1,
+			UnschedulableAttemptsNum: 1,
+			FailedPredicates:         map[string]int64{"Insufficient cpu": 3, "node(s) were unschedulable": 1},
+			EvaluatedNodesNum:        8,
+			FeasibleNodesNum:         3,
+			AvgFeasibleNodesNum:      1.5,
+			PreemptionAttemptsNum:    1,
+		},
+		LastTick: SchedulerCounts{
+			AttemptsNum:              2,
+			ScheduledAttemptsNum:     1,
+			UnschedulableAttemptsNum: 1,
+			FailedPredicates:         map[string]int64{"Insufficient cpu": 3, "node(s) were unschedulable": 1},
+			EvaluatedNodesNum:        8,
+			FeasibleNodesNum:         3,
+			AvgFeasibleNodesNum:      1.5,
+			PreemptionAttemptsNum:    1,
+		},
+	}
+	if !reflect.DeepEqual(copied, wantCopied) {
+		t.Errorf("got: %+v\nwant: %+v", copied, wantCopied)
+	}
+}
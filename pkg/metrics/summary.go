@@ -0,0 +1,299 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+)
+
+// Summary is a summary of a simulation run, with its scheduling-quality KPIs.
+// Durations are in seconds.
+type Summary struct {
+	StartClock string
+	EndClock   string
+	// MakespanSeconds is the time from the first submission to the last completion of the pods.
+	MakespanSeconds float64
+
+	BoundPodsNum int
+	// UnboundPodsNum is the number of the pods submitted but never bound until the end of the run.
+	UnboundPodsNum   int
+	CompletedPodsNum int
+	// QueueingDelay is the time from the submission to the binding of each bound pod, or to the end
+	// of the run for each unbound pod.
+	QueueingDelay Distribution
+	// JobCompletionTime is the time from the submission to the completion of each completed pod.
+	JobCompletionTime Distribution
+	// Slowdown is the job completion time of each completed pod divided by the execution time in its
+	// spec.
+	Slowdown Distribution
+
+	// Utilization is the time-weighted ratio of the total resource usage to the total allocatable
+	// resources of the nodes.
+	Utilization map[v1.ResourceName]float64
+
+	OverCapacityPodsNum int
+	// PreemptionsNum is the number of the pods deleted by schedulers and queues to make room for other
+	// pods.
+	PreemptionsNum int
+	// WastedWork is the work done by the pods that did not complete, i.e., were preempted, deleted,
+	// lost, evicted, or OOM-killed.
+	WastedWork WastedWork
+}
+
+// Distribution is the distribution of values.
+type Distribution struct {
+	Count int
+	Avg   float64
+	P50   float64
+	P95   float64
+	P99   float64
+	Max   float64
+}
+
+// WastedWork is the work done by the pods that did not complete.
+type WastedWork struct {
+	PodsNum int
+	// Seconds is the total execution time of the pods.
+	Seconds float64
+	// ResourceSeconds is the total resource requests of the pods multiplied by their execution times
+	// (e.g., cpu in core-seconds and memory in byte-seconds).
+	ResourceSeconds map[v1.ResourceName]float64
+}
+
+// SummaryBuilder builds the Summary of a simulation run from the metrics of the nodes observed during
+// the run and the pods bound in it.
+type SummaryBuilder struct {
+	startClock clock.Clock
+
+	// lastClock is the clock at which the nodes were observed last.
+	lastClock clock.Clock
+	// lastUsage and lastAllocatable is the total resource usage and allocatable resources of the nodes
+	// observed last.
+	lastUsage       map[v1.ResourceName]float64
+	lastAllocatable map[v1.ResourceName]float64
+
+	// usageSeconds and allocatableSeconds is the integrals of the total resource usage and allocatable
+	// resources of the nodes over time.
+	usageSeconds       map[v1.ResourceName]float64
+	allocatableSeconds map[v1.ResourceName]float64
+
+	preemptionsNum int
+}
+
+// NewSummaryBuilder creates a new SummaryBuilder of a simulation run starting at the given clock.
+func NewSummaryBuilder(startClock clock.Clock) *SummaryBuilder {
+	return &SummaryBuilder{
+		startClock:         startClock,
+		lastClock:          startClock,
+		lastUsage:          map[v1.ResourceName]float64{},
+		lastAllocatable:    map[v1.ResourceName]float64{},
+		usageSeconds:       map[v1.ResourceName]float64{},
+		allocatableSeconds: map[v1.ResourceName]float64{},
+	}
+}
+
+// ObserveNodes observes the metrics of the nodes at the given clock, which hold until the next
+// observation.
+func (b *SummaryBuilder) ObserveNodes(clk clock.Clock, nodesMetrics map[string]node.Metrics) {
+	b.integrate(clk)
+
+	b.lastUsage = map[v1.ResourceName]float64{}
+	b.lastAllocatable = map[v1.ResourceName]float64{}
+	for _, met := range nodesMetrics {
+		for rsrc, alloc := range met.Allocatable {
+			if rsrc == v1.ResourcePods {
+				continue
+			}
+			usage := met.TotalResourceUsage[rsrc]
+			b.lastUsage[rsrc] += float64(usage.MilliValue())
+			b.lastAllocatable[rsrc] += float64(alloc.MilliValue())
+		}
+	}
+}
+
+// AddPreemptions counts the number of the pods preempted.
+func (b *SummaryBuilder) AddPreemptions(num int) {
+	b.preemptionsNum += num
+}
+
+// Build builds the Summary of the run ending at the given clock, with the pods bound in it and the
+// pods submitted but not bound until the end (e.g., pending in queues).
+func (b *SummaryBuilder) Build(endClock clock.Clock, pods []*pod.Pod, unboundPods []*v1.Pod) Summary {
+	b.integrate(endClock)
+
+	summary := Summary{
+		StartClock:     b.startClock.ToRFC3339(),
+		EndClock:       endClock.ToRFC3339(),
+		BoundPodsNum:   len(pods),
+		UnboundPodsNum: len(unboundPods),
+		Utilization:    make(map[v1.ResourceName]float64, len(b.allocatableSeconds)),
+
+		PreemptionsNum: b.preemptionsNum,
+		WastedWork:     WastedWork{ResourceSeconds: map[v1.ResourceName]float64{}},
+	}
+
+	for rsrc, alloc := range b.allocatableSeconds {
+		if alloc > 0 {
+			summary.Utilization[rsrc] = b.usageSeconds[rsrc] / alloc
+		}
+	}
+
+	var firstSubmission, lastCompletion *clock.Clock
+	queueingDelays := []float64{}
+	completionTimes := []float64{}
+	slowdowns := []float64{}
+
+	for _, p := range pods {
+		submittedAt := clock.NewClockWithMetaV1(p.ToV1().CreationTimestamp)
+		if firstSubmission == nil || submittedAt.Before(*firstSubmission) {
+			firstSubmission = &submittedAt
+		}
+
+		met := p.Metrics(endClock)
+		queueingDelays = append(queueingDelays, met.BoundAt.Sub(submittedAt).Seconds())
+
+		if met.Status == pod.OverCapacity {
+			summary.OverCapacityPodsNum++
+			continue
+		}
+
+		if finishedAt, ok := p.FinishedAt(endClock); ok {
+			if lastCompletion == nil || lastCompletion.Before(finishedAt) {
+				lastCompletion = &finishedAt
+			}
+
+			jct := finishedAt.Sub(submittedAt).Seconds()
+			completionTimes = append(completionTimes, jct)
+			if d := p.SpecDuration().Seconds(); d > 0 {
+				slowdowns = append(slowdowns, jct/d)
+			}
+			continue
+		}
+
+		if met.Status != pod.Ok && met.ExecutedSeconds > 0 {
+			executed := float64(met.ExecutedSeconds)
+			summary.WastedWork.PodsNum++
+			summary.WastedWork.Seconds += executed
+			for rsrc, req := range met.ResourceRequest {
+				summary.WastedWork.ResourceSeconds[rsrc] += float64(req.MilliValue()) / 1000 * executed
+			}
+		}
+	}
+
+	// The unbound pods have been waiting until the end, so that long waits are not left out.
+	for _, p := range unboundPods {
+		submittedAt := clock.NewClockWithMetaV1(p.CreationTimestamp)
+		queueingDelays = append(queueingDelays, endClock.Sub(submittedAt).Seconds())
+	}
+
+	if firstSubmission != nil && lastCompletion != nil {
+		summary.MakespanSeconds = lastCompletion.Sub(*firstSubmission).Seconds()
+	}
+	summary.CompletedPodsNum = len(completionTimes)
+	summary.QueueingDelay = buildDistribution(queueingDelays)
+	summary.JobCompletionTime = buildDistribution(completionTimes)
+	summary.Slowdown = buildDistribution(slowdowns)
+
+	return summary
+}
+
+// integrate integrates the resources observed last until the given clock.
+func (b *SummaryBuilder) integrate(clk clock.Clock) {
+	elapsed := clk.Sub(b.lastClock).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	for rsrc, usage := range b.lastUsage {
+		b.usageSeconds[rsrc] += usage / 1000 * elapsed
+	}
+	for rsrc, alloc := range b.lastAllocatable {
+		b.allocatableSeconds[rsrc] += alloc / 1000 * elapsed
+	}
+	b.lastClock = clk
+}
+
+// buildDistribution builds the Distribution of the values, with the nearest-rank percentiles.
+func buildDistribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+
+	return Distribution{
+		Count: len(sorted),
+		Avg:   sum / float64(len(sorted)),
+		P50:   percentile(50),
+		P95:   percentile(95),
+		P99:   percentile(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// sortedResourceNames returns the sorted resource names in the map.
+func sortedResourceNames(m map[v1.ResourceName]float64) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
+
+// sortedResourceListNames returns the sorted resource names in the list.
+func sortedResourceListNames(resources v1.ResourceList) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
+
+// SummaryFormatter defines the interface of formatter that also formats the summary of a run.
+type SummaryFormatter interface {
+	// FormatSummary formats the given summary to a string.
+	FormatSummary(summary *Summary) (string, error)
+}
+
+// SummaryWriter defines the interface of writer that also writes the summary of a run.
+type SummaryWriter interface {
+	// WriteSummary writes the given summary to some location(s).
+	WriteSummary(summary *Summary) error
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+)
+
+var testStartClock = clock.NewClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+func TestBuildDistribution(t *testing.T) {
+	// 1, 2, ..., 20 in a shuffled order.
+	values := []float64{}
+	for i := 0; i < 20; i++ {
+		values = append(values, float64((i*7)%20+1))
+	}
+
+	tests := []struct {
+		values   []float64
+		expected Distribution
+	}{
+		{values: nil, expected: Distribution{}},
+		{values: []float64{7}, expected: Distribution{Count: 1, Avg: 7, P50: 7, P95: 7, P99: 7, Max: 7}},
+		// The nearest ranks are ceil(0.5*4) = 2, ceil(0.95*4) = 4, and ceil(0.99*4) = 4.
+		{values: []float64{4, 1, 3, 2}, expected: Distribution{Count: 4, Avg: 2.5, P50: 2, P95: 4, P99: 4, Max: 4}},
+		// The nearest ranks are 10, 19, and 20.
+		{values: values, expected: Distribution{Count: 20, Avg: 10.5, P50: 10, P95: 19, P99: 20, Max: 20}},
+	}
+
+	for _, test := range tests {
+		original := append([]float64{}, test.values...)
+		if actual := buildDistribution(test.values); actual != test.expected {
+			t.Errorf("got: %+v\nwant: %+v", actual, test.expected)
+		}
+		if len(test.values) > 0 && !reflect.DeepEqual(test.values, original) {
+			t.Errorf("got: %v\nwant: %v unchanged", test.values, original)
+		}
+	}
+}
+
+func newTestNodeMetrics(cpuUsage, cpuAllocatable string) node.Metrics {
+	return node.Metrics{
+		Allocatable: v1.ResourceList{
+			"cpu":  resource.MustParse(cpuAllocatable),
+			"pods": resource.MustParse("110"),
+		},
+		TotalResourceUsage: v1.ResourceList{"cpu": resource.MustParse(cpuUsage)},
+	}
+}
+
+func TestSummaryUtilization(t *testing.T) {
+	b := NewSummaryBuilder(testStartClock)
+
+	// 2 of 4 cores are used for 10s, 4 of 4 for 20s, and 1 of 8 (after another node is added) for 10s.
+	b.ObserveNodes(testStartClock, map[string]node.Metrics{"node-0": newTestNodeMetrics("2", "4")})
+	b.ObserveNodes(testStartClock.Add(10*time.Second),
+		map[string]node.Metrics{"node-0": newTestNodeMetrics("4", "4")})
+	// Observing again at the same clock replaces the last observation without integrating it.
+	b.ObserveNodes(testStartClock.Add(30*time.Second),
+		map[string]node.Metrics{"node-0": newTestNodeMetrics("0", "4")})
+	b.ObserveNodes(testStartClock.Add(30*time.Second), map[string]node.Metrics{
+		"node-0": newTestNodeMetrics("500m", "4"),
+		"node-1": newTestNodeMetrics("500m", "4"),
+	})
+	summary := b.Build(testStartClock.Add(40*time.Second), nil, nil)
+
+	// (2*10 + 4*20 + 1*10) / (4*10 + 4*20 + 8*10)
+	expected := map[v1.ResourceName]float64{"cpu": 110.0 / 200}
+	if len(summary.Utilization) != len(expected) || math.Abs(summary.Utilization["cpu"]-expected["cpu"]) > 1e-9 {
+		t.Errorf("got: %v\nwant: %v", summary.Utilization, expected)
+	}
+}
+
+func newTestPod(name string, submittedAt clock.Clock, seconds int) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              name,
+			CreationTimestamp: submittedAt.ToMetaV1(),
+			Annotations: map[string]string{
+				"simSpec": fmt.Sprintf("- seconds: %d\n  resourceUsage:\n    cpu: 1\n", seconds),
+			},
+		},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "container"}}},
+	}
+}
+
+func TestSummaryQueueingDelay(t *testing.T) {
+	b := NewSummaryBuilder(testStartClock)
+
+	// pod-0 waits for 10s and completes in 20s, pod-1 waits for 30s and is still running, and pod-2
+	// and pod-3 are never bound.
+	pod0, err := pod.NewPod(newTestPod("pod-0", testStartClock, 20), testStartClock.Add(10*time.Second), pod.Ok, "node-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod1, err := pod.NewPod(newTestPod("pod-1", testStartClock, 100), testStartClock.Add(30*time.Second), pod.Ok, "node-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unbound := []*v1.Pod{
+		newTestPod("pod-2", testStartClock.Add(20*time.Second), 10),
+		newTestPod("pod-3", testStartClock, 10),
+	}
+
+	summary := b.Build(testStartClock.Add(60*time.Second), []*pod.Pod{pod0, pod1}, unbound)
+
+	if summary.BoundPodsNum != 2 || summary.UnboundPodsNum != 2 || summary.CompletedPodsNum != 1 {
+		t.Errorf("got: bound %d, unbound %d, completed %d\nwant: bound 2, unbound 2, completed 1",
+			summary.BoundPodsNum, summary.UnboundPodsNum, summary.CompletedPodsNum)
+	}
+
+	// The delays are 10s, 30s, 40s, and 60s, including those of the unbound pods until the end.
+	expected := Distribution{Count: 4, Avg: 35, P50: 30, P95: 60, P99: 60, Max: 60}
+	if summary.QueueingDelay != expected {
+		t.Errorf("got: %+v\nwant: %+v", summary.QueueingDelay, expected)
+	}
+	expected = Distribution{Count: 1, Avg: 1.5, P50: 1.5, P95: 1.5, P99: 1.5, Max: 1.5}
+	if summary.Slowdown != expected {
+		t.Errorf("got: %+v\nwant: %+v", summary.Slowdown, expected)
+	}
+	if summary.MakespanSeconds != 30 {
+		t.Errorf("got: %v\nwant: 30", summary.MakespanSeconds)
+	}
+}
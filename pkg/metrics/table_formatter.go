@@ -17,6 +17,7 @@ package metrics
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 
@@ -29,39 +30,83 @@ import (
 type TableFormatter struct{}
 
 // Format implements Formatter interface.
-// Returns error if the given metrics does not have valid structure.
 func (t *TableFormatter) Format(metrics *Metrics) (string, error) {
-	if err := validateMetrics(metrics); err != nil {
-		return "", err
+	str := metrics.Clock.ToRFC3339() + "\n\n"
+
+	s, resourceTypes := t.formatNodesMetrics(metrics.Nodes)
+	str += s + "\n"
+	str += t.formatPodsMetrics(metrics.Pods, resourceTypes) + "\n"
+	str += t.formatQueueMetrics(metrics.Queue) + "\n"
+
+	if len(metrics.Topology) > 0 {
+		str += t.formatTopologyMetrics(metrics.Topology) + "\n"
+	}
+	if len(metrics.QoS) > 0 {
+		str += t.formatQOSMetrics(metrics.QoS) + "\n"
+	}
+	if len(metrics.Schedulers) > 0 {
+		str += t.formatSchedulersMetrics(metrics.Schedulers) + "\n"
+	}
+	if len(metrics.Extensions) > 0 {
+		str += t.formatExtensions(metrics) + "\n"
 	}
 
-	// Clock
-	clk := (*metrics)[ClockKey].(string)
-	str := clk + "\n\n"
+	return str, nil
+}
 
-	// Nodes
-	nodesMet := (*metrics)[NodesMetricsKey].(map[string]node.Metrics)
-	s, resourceTypes := t.formatNodesMetrics(nodesMet)
-	str += s + "\n"
+var _ = Formatter(&TableFormatter{})
 
-	// Pods
-	podsMet := (*metrics)[PodsMetricsKey].(map[string]pod.Metrics)
-	str += t.formatPodsMetrics(podsMet, resourceTypes) + "\n"
+// FormatSummary implements SummaryFormatter interface.
+func (t *TableFormatter) FormatSummary(summary *Summary) (string, error) {
+	str := fmt.Sprintf("Summary %s - %s\n\n", summary.StartClock, summary.EndClock)
+
+	str += "Makespan (s)  Bound  Unbound Completed OverCapacity Preempted\n"
+	str += "------------------------------------------------------------\n"
+	str += fmt.Sprintf("%-13.0f %-6d %-7d %-9d %-12d %d\n\n",
+		summary.MakespanSeconds, summary.BoundPodsNum, summary.UnboundPodsNum, summary.CompletedPodsNum,
+		summary.OverCapacityPodsNum, summary.PreemptionsNum)
+
+	str += "KPI                     Count  Avg        P50        P95        P99        Max\n"
+	str += "------------------------------------------------------------------------------------\n"
+	for _, kpi := range []struct {
+		name string
+		dist Distribution
+	}{
+		{"Queueing delay (s)", summary.QueueingDelay},
+		{"Job completion time (s)", summary.JobCompletionTime},
+		{"Slowdown", summary.Slowdown},
+	} {
+		str += fmt.Sprintf("%-23s %-6d %-10.2f %-10.2f %-10.2f %-10.2f %.2f\n",
+			kpi.name, kpi.dist.Count, kpi.dist.Avg, kpi.dist.P50, kpi.dist.P95, kpi.dist.P99, kpi.dist.Max)
+	}
+	str += "\n"
 
-	// Queue
-	queueMet := (*metrics)[QueueMetricsKey].(queue.Metrics)
-	str += t.formatQueueMetrics(queueMet) + "\n"
+	str += "Resource         Utilization Wasted resource-seconds\n"
+	str += "----------------------------------------------------\n"
+	rsrcs := map[v1.ResourceName]float64{}
+	for rsrc := range summary.Utilization {
+		rsrcs[rsrc] = 0
+	}
+	for rsrc := range summary.WastedWork.ResourceSeconds {
+		rsrcs[rsrc] = 0
+	}
+	for _, rsrc := range sortedResourceNames(rsrcs) {
+		str += fmt.Sprintf("%-16s %-11.2f %.0f\n",
+			rsrc, summary.Utilization[rsrc], summary.WastedWork.ResourceSeconds[rsrc])
+	}
+	str += fmt.Sprintf("Wasted work: pods %d, %.0f s\n",
+		summary.WastedWork.PodsNum, summary.WastedWork.Seconds)
 
 	return str, nil
 }
 
-var _ = Formatter(&TableFormatter{})
+var _ = SummaryFormatter(&TableFormatter{})
 
 func (t *TableFormatter) formatNodesMetrics(metrics map[string]node.Metrics) (string, []string) {
 	nodes, resourceTypes := t.sortedNodeNamesAndResourceTypes(metrics)
 
 	// Header
-	str := "Node             Pods   Termi- Failed Capa-  "
+	str := "Node             Pods   Termi- Failed Lost   Evic-  Capa-  "
 	for _, r := range resourceTypes {
 		if r == "memory" {
 			str += "memory (MB)                   "
@@ -70,22 +115,23 @@ func (t *TableFormatter) formatNodesMetrics(metrics map[string]node.Metrics) (st
 		}
 	}
 	str += "\n"
-	str += "                        nating        city   "
+	str += "                        nating                      ted    city   "
 	line := ""
 	for range resourceTypes {
 		str += "Usage    Request  Allocatable "
 		line += "------------------------------"
 	}
 	str += "\n"
-	str += "---------------------------------------------" + line + "\n"
+	str += "-----------------------------------------------------------" + line + "\n"
 
 	// Body
 	for _, node := range nodes {
 		met := metrics[node]
 
 		str += fmt.Sprintf(
-			"%-16s %-6d %-6d %-6d %-6d ",
-			node, met.RunningPodsNum, met.TerminatingPodsNum, met.FailedPodsNum, met.Allocatable.Pods().Value())
+			"%-16s %-6d %-6d %-6d %-6d %-6d %-6d ",
+			node, met.RunningPodsNum, met.TerminatingPodsNum, met.FailedPodsNum, met.LostPodsNum,
+			met.EvictedPodsNum, met.Allocatable.Pods().Value())
 
 		for _, rsrc := range resourceTypes {
 			r := v1.ResourceName(rsrc)
@@ -173,6 +219,165 @@ func (t *TableFormatter) formatQueueMetrics(metrics queue.Metrics) string {
 	str := "      PendingPods \n"
 	str += "------------------\n"
 	str += fmt.Sprintf("Queue %-8d \n", metrics.PendingPodsNum)
+
+	if len(metrics.ClusterQueues) == 0 {
+		return str
+	}
+
+	names := make([]string, 0, len(metrics.ClusterQueues))
+	for name := range metrics.ClusterQueues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	str += "\n"
+	str += "ClusterQueue         Pending  Admitted Total    AvgWait  MaxWait  Usage\n"
+	str += "                                                Seconds  Seconds       \n"
+	str += "-----------------------------------------------------------------------\n"
+	for _, name := range names {
+		met := metrics.ClusterQueues[name]
+		str += fmt.Sprintf("%-20s %-8d %-8d %-8d %-8.1f %-8.1f ",
+			name, met.PendingPodsNum, met.AdmittedPodsNum, met.AdmittedPodsTotal,
+			met.AvgWaitSeconds, met.MaxWaitSeconds)
+
+		flavors := make([]string, 0, len(met.Usage))
+		for flavor := range met.Usage {
+			flavors = append(flavors, flavor)
+		}
+		sort.Strings(flavors)
+
+		for _, flavor := range flavors {
+			usage := met.Usage[flavor]
+			for _, rsrc := range sortedResourceListNames(usage) {
+				q := usage[rsrc]
+				str += fmt.Sprintf("%s/%s=%s ", flavor, rsrc, q.String())
+			}
+		}
+		str += "\n"
+	}
+
+	return str
+}
+
+func (t *TableFormatter) formatTopologyMetrics(metrics TopologyMetrics) string {
+	str := "Level  Domain           Nodes  Failed Pods   Lost   Utilization\n"
+	str += "----------------------------------------------------------------\n"
+
+	for _, level := range node.TopologyLevels {
+		levelMet, ok := metrics[level]
+		if !ok {
+			continue
+		}
+
+		domains := make([]string, 0, len(levelMet.Domains))
+		for domain := range levelMet.Domains {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+
+		for _, domain := range domains {
+			met := levelMet.Domains[domain]
+			str += fmt.Sprintf("%-6s %-16s %-6d %-6d %-6d %-6d ",
+				level, domain, met.NodesNum, met.FailedNodesNum, met.RunningPodsNum, met.LostPodsNum)
+
+			rsrcs := make([]string, 0, len(met.Utilization))
+			for rsrc := range met.Utilization {
+				rsrcs = append(rsrcs, rsrc.String())
+			}
+			sort.Strings(rsrcs)
+
+			for _, rsrc := range rsrcs {
+				str += fmt.Sprintf("%s=%.2f ", rsrc, met.Utilization[v1.ResourceName(rsrc)])
+			}
+			str += "\n"
+		}
+
+		str += fmt.Sprintf("%-6s Pods skew %d, max share %.2f\n",
+			level, levelMet.RunningPodsSkew, levelMet.MaxRunningPodsShare)
+	}
+
+	return str
+}
+
+func (t *TableFormatter) formatQOSMetrics(metrics QOSMetrics) string {
+	str := "QoS class    Pods   Evicted OOMKilled CPU usage/request (m) Memory usage/request (MB)\n"
+	str += "--------------------------------------------------------------------------------------\n"
+
+	for _, class := range qosClasses {
+		met, ok := metrics[class]
+		if !ok {
+			continue
+		}
+
+		cpuUsage, cpuReq := met.TotalResourceUsage[v1.ResourceCPU], met.TotalResourceRequest[v1.ResourceCPU]
+		memUsage, memReq := met.TotalResourceUsage[v1.ResourceMemory], met.TotalResourceRequest[v1.ResourceMemory]
+		d := int64(1 << 20)
+
+		str += fmt.Sprintf("%-12s %-6d %-7d %-9d %-21s %s\n",
+			class, met.RunningPodsNum, met.EvictedPodsNum, met.OOMKilledPodsNum,
+			fmt.Sprintf("%d/%d", cpuUsage.MilliValue(), cpuReq.MilliValue()),
+			fmt.Sprintf("%d/%d", memUsage.Value()/d, memReq.Value()/d))
+	}
+
+	return str
+}
+
+func (t *TableFormatter) formatSchedulersMetrics(metrics SchedulersMetrics) string {
+	str := "Scheduler            Attempts Scheduled Unschedulable Error    AvgFeasible Plugin(s) Preemptions Victims\n"
+	str += "-------------------------------------------------------------------------------------------------------\n"
+
+	for _, name := range sortedSchedulerNames(metrics) {
+		met := metrics[name]
+		str += t.formatSchedulerCounts(name, met.SchedulerCounts)
+		str += t.formatSchedulerCounts("  last tick", met.LastTick)
+	}
+
+	reasonsStr := ""
+	for _, name := range sortedSchedulerNames(metrics) {
+		met := metrics[name]
+		for _, reason := range sortedFailedPredicates(met.SchedulerCounts) {
+			reasonsStr += fmt.Sprintf("%-20s %-40s %d\n", name, reason, met.FailedPredicates[reason])
+		}
+	}
+	if reasonsStr != "" {
+		str += "\n"
+		str += "Scheduler            Failed predicate                         Nodes\n"
+		str += "-------------------------------------------------------------------\n"
+		str += reasonsStr
+	}
+
+	return str
+}
+
+func (t *TableFormatter) formatSchedulerCounts(name string, counts SchedulerCounts) string {
+	return fmt.Sprintf("%-20s %-8d %-9d %-13d %-8d %-11.2f %-9.3f %-11d %d\n",
+		name, counts.AttemptsNum, counts.ScheduledAttemptsNum, counts.UnschedulableAttemptsNum,
+		counts.ErrorAttemptsNum, counts.AvgFeasibleNodesNum, counts.PluginSeconds,
+		counts.PreemptionAttemptsNum, counts.PreemptionVictimsNum)
+}
+
+// formatExtensions formats the scalars in the extensions, with their paths from the names of the
+// extensions (e.g., "Fragmentation[nvidia.com/gpu].Index").
+func (t *TableFormatter) formatExtensions(metrics *Metrics) string {
+	keys := []string{}
+	values := []string{}
+	width := len("Extension")
+	for _, name := range sortedExtensionNames(metrics) {
+		for _, e := range flattenExtension(metrics.Extensions[name]) {
+			keys = append(keys, name+e.path)
+			values = append(values, e.value)
+			if len(name+e.path) > width {
+				width = len(name + e.path)
+			}
+		}
+	}
+
+	str := fmt.Sprintf("%-*s Value\n", width, "Extension")
+	str += strings.Repeat("-", width+16) + "\n"
+	for i := range keys {
+		str += fmt.Sprintf("%-*s %s\n", width, keys[i], values[i])
+	}
+
 	return str
 }
 
@@ -0,0 +1,132 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// TopologyMetrics is a metrics of the failure domains at each topology level (except for
+// node.Host, which is covered by the metrics of nodes).
+// Levels that no node is labeled with are omitted.
+type TopologyMetrics map[node.TopologyLevel]LevelMetrics
+
+// LevelMetrics is a metrics of the failure domains at one topology level.
+type LevelMetrics struct {
+	Domains map[string]DomainMetrics
+
+	// RunningPodsSkew is the difference between the maximum and minimum numbers of running pods
+	// over the domains.
+	RunningPodsSkew int64
+	// MaxRunningPodsShare is the largest fraction of the running pods on the cluster that run in a
+	// single domain, i.e., the fraction that the worst outage of one domain kills.
+	MaxRunningPodsShare float64
+}
+
+// DomainMetrics is a metrics of one failure domain, aggregated over its nodes.
+type DomainMetrics struct {
+	NodesNum       int64
+	FailedNodesNum int64
+
+	RunningPodsNum int64
+	LostPodsNum    int64
+
+	Allocatable          v1.ResourceList
+	TotalResourceRequest v1.ResourceList
+	TotalResourceUsage   v1.ResourceList
+	// Utilization is the ratio of the total resource request to the allocatable resource of
+	// each resource type other than pods.
+	Utilization map[v1.ResourceName]float64
+}
+
+// buildTopologyMetrics aggregates the metrics of the nodes by their failure domains.
+func buildTopologyMetrics(nodes map[string]*node.Node, nodesMetrics map[string]node.Metrics) TopologyMetrics {
+	topologyMetrics := TopologyMetrics{}
+
+	for _, level := range node.TopologyLevels {
+		if level == node.Host {
+			continue
+		}
+
+		domains := map[string]DomainMetrics{}
+		for name, n := range nodes {
+			domain, ok := node.DomainOf(n.ToV1(), level)
+			if !ok {
+				continue
+			}
+
+			met := nodesMetrics[name]
+			dom := domains[domain]
+
+			dom.NodesNum++
+			if met.Failed {
+				dom.FailedNodesNum++
+			}
+			dom.RunningPodsNum += met.RunningPodsNum
+			dom.LostPodsNum += met.LostPodsNum
+			dom.Allocatable = util.ResourceListSum(dom.Allocatable, met.Allocatable)
+			dom.TotalResourceRequest = util.ResourceListSum(dom.TotalResourceRequest, met.TotalResourceRequest)
+			dom.TotalResourceUsage = util.ResourceListSum(dom.TotalResourceUsage, met.TotalResourceUsage)
+
+			domains[domain] = dom
+		}
+
+		if len(domains) == 0 {
+			continue
+		}
+
+		topologyMetrics[level] = buildLevelMetrics(domains)
+	}
+
+	return topologyMetrics
+}
+
+// buildLevelMetrics fills the utilization of the domains and computes the spread of pods over them.
+func buildLevelMetrics(domains map[string]DomainMetrics) LevelMetrics {
+	var total, maxPods, minPods int64 = 0, -1, -1
+
+	for name, dom := range domains {
+		dom.Utilization = make(map[v1.ResourceName]float64, len(dom.Allocatable))
+		for rsrc, alloc := range dom.Allocatable {
+			if rsrc == v1.ResourcePods || alloc.IsZero() {
+				continue
+			}
+			req := dom.TotalResourceRequest[rsrc]
+			dom.Utilization[rsrc] = float64(req.MilliValue()) / float64(alloc.MilliValue())
+		}
+		domains[name] = dom
+
+		total += dom.RunningPodsNum
+		if maxPods < 0 || dom.RunningPodsNum > maxPods {
+			maxPods = dom.RunningPodsNum
+		}
+		if minPods < 0 || dom.RunningPodsNum < minPods {
+			minPods = dom.RunningPodsNum
+		}
+	}
+
+	met := LevelMetrics{
+		Domains:         domains,
+		RunningPodsSkew: maxPods - minPods,
+	}
+	if total > 0 {
+		met.MaxRunningPodsShare = float64(maxPods) / float64(total)
+	}
+
+	return met
+}
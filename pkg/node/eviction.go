@@ -0,0 +1,255 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// EvictionSignal is a signal of the kubelet that triggers evictions of pods.
+type EvictionSignal string
+
+const (
+	// SignalMemoryAvailable is the amount of memory available on a node, i.e., its allocatable memory
+	// minus the total memory usage of the pods on it.
+	SignalMemoryAvailable EvictionSignal = "memory.available"
+	// SignalNodeFsAvailable is the amount of ephemeral storage available on a node.
+	SignalNodeFsAvailable EvictionSignal = "nodefs.available"
+)
+
+// DefaultPressureTransitionPeriod is the default time for which a node keeps its pressure condition
+// after the pressure is relieved, as --eviction-pressure-transition-period of the kubelet.
+const DefaultPressureTransitionPeriod = 5 * time.Minute
+
+// EvictionThreshold is a threshold of an eviction signal, below which the kubelet evicts pods.
+type EvictionThreshold struct {
+	Signal EvictionSignal
+	// Quantity is the threshold in the absolute amount of the resource.
+	// If it is nil, Percentage is used instead.
+	Quantity *resource.Quantity
+	// Percentage is the threshold in the fraction of the allocatable resource, in [0, 1].
+	Percentage float64
+	// GracePeriod is the time for which the signal must stay below the threshold before pods are
+	// evicted, i.e., the threshold is soft if it is positive, or hard otherwise.
+	GracePeriod time.Duration
+}
+
+// EvictionPolicy is the configuration of the kubelet eviction of a node.
+type EvictionPolicy struct {
+	Thresholds []EvictionThreshold
+	// PressureTransitionPeriod is the time for which the node keeps its pressure condition after
+	// the pressure is relieved.
+	PressureTransitionPeriod time.Duration
+}
+
+// evictionState is the state of the kubelet eviction of a node.
+type evictionState struct {
+	policy EvictionPolicy
+	// observedAt is the clock at which each threshold was first observed to be met, which is
+	// deleted when the threshold is no longer met.
+	observedAt map[int]clock.Clock
+	// pressureAt is the clock at which the pressure of each condition was last observed.
+	pressureAt map[v1.NodeConditionType]clock.Clock
+}
+
+// signalResource returns the resource and the node condition of the eviction signal.
+// Returns false if the signal is not supported.
+func signalResource(signal EvictionSignal) (v1.ResourceName, v1.NodeConditionType, bool) {
+	switch signal {
+	case SignalMemoryAvailable:
+		return v1.ResourceMemory, v1.NodeMemoryPressure, true
+	case SignalNodeFsAvailable:
+		return v1.ResourceEphemeralStorage, v1.NodeDiskPressure, true
+	default:
+		return "", "", false
+	}
+}
+
+// IsValid returns whether the eviction signal is supported.
+func (signal EvictionSignal) IsValid() bool {
+	_, _, ok := signalResource(signal)
+	return ok
+}
+
+// SetEvictionPolicy enables the kubelet eviction of this Node with the policy.
+func (node *Node) SetEvictionPolicy(policy EvictionPolicy) {
+	policy.Thresholds = append([]EvictionThreshold{}, policy.Thresholds...)
+	node.eviction = &evictionState{
+		policy:     policy,
+		observedAt: map[int]clock.Clock{},
+		pressureAt: map[v1.NodeConditionType]clock.Clock{},
+	}
+}
+
+// EvictPodsUnderPressure observes the eviction signals of this Node at the given clock, updates its
+// MemoryPressure and DiskPressure conditions, and evicts a pod if a threshold has been met for its
+// grace period, as the kubelet does in each housekeeping.
+// The pod to evict is the first one ranked by its QoS class (BestEffort, Burstable, then
+// Guaranteed) and its usage of the starved resource over its request, then by its priority.
+// Returns the evicted pods.
+func (node *Node) EvictPodsUnderPressure(clk clock.Clock) []*pod.Pod {
+	if node.eviction == nil || node.IsFailed() {
+		return []*pod.Pod{}
+	}
+	state := node.eviction
+
+	usage := node.totalResourceUsage(clk)
+	var starved *EvictionThreshold
+	for i := range state.policy.Thresholds {
+		th := &state.policy.Thresholds[i]
+		rsrc, cond, _ := signalResource(th.Signal)
+
+		if !node.thresholdMet(th, rsrc, usage) {
+			delete(state.observedAt, i)
+			continue
+		}
+
+		observedAt, ok := state.observedAt[i]
+		if !ok {
+			observedAt = clk
+			state.observedAt[i] = clk
+		}
+		state.pressureAt[cond] = clk
+
+		if starved == nil && !clk.Before(observedAt.Add(th.GracePeriod)) {
+			starved = th
+		}
+	}
+
+	node.updatePressureConditions(clk)
+
+	if starved == nil {
+		return []*pod.Pod{}
+	}
+
+	rsrc, _, _ := signalResource(starved.Signal)
+	candidates := []*pod.Pod{}
+	for _, p := range node.pods {
+		if p.IsRunning(clk) || p.IsTerminating(clk) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return []*pod.Pod{}
+	}
+
+	rankForEviction(clk, candidates, rsrc)
+	victim := candidates[0]
+
+	message := fmt.Sprintf("The node was low on resource: %s.", rsrc)
+	if !victim.Evict(clk, message) {
+		return []*pod.Pod{}
+	}
+	victim.ToV1().Status = victim.BuildStatus(clk)
+
+	return []*pod.Pod{victim}
+}
+
+// thresholdMet returns whether the available amount of the resource is below the threshold.
+func (node *Node) thresholdMet(th *EvictionThreshold, rsrc v1.ResourceName, usage v1.ResourceList) bool {
+	alloc, ok := node.ToV1().Status.Allocatable[rsrc]
+	if !ok {
+		return false
+	}
+
+	available := alloc.DeepCopy()
+	if used, ok := usage[rsrc]; ok {
+		available.Sub(used)
+	}
+
+	threshold := th.Quantity
+	if threshold == nil {
+		threshold = resource.NewQuantity(int64(float64(alloc.Value())*th.Percentage), alloc.Format)
+	}
+
+	return available.Cmp(*threshold) < 0
+}
+
+// updatePressureConditions sets the pressure conditions of the signals with thresholds, which hold
+// until the pressure transition period has elapsed since the pressure was last observed.
+func (node *Node) updatePressureConditions(clk clock.Clock) {
+	state := node.eviction
+
+	conds := map[v1.NodeConditionType]struct{}{}
+	for _, th := range state.policy.Thresholds {
+		_, cond, _ := signalResource(th.Signal)
+		conds[cond] = struct{}{}
+	}
+
+	for cond := range conds {
+		pressureAt, ok := state.pressureAt[cond]
+		pressure := ok && !pressureAt.Add(state.policy.PressureTransitionPeriod).Before(clk)
+
+		switch {
+		case cond == v1.NodeMemoryPressure && pressure:
+			node.SetCondition(clk, cond, v1.ConditionTrue, "KubeletHasInsufficientMemory",
+				"kubelet has insufficient memory available")
+		case cond == v1.NodeMemoryPressure:
+			node.SetCondition(clk, cond, v1.ConditionFalse, "KubeletHasSufficientMemory",
+				"kubelet has sufficient memory available")
+		case cond == v1.NodeDiskPressure && pressure:
+			node.SetCondition(clk, cond, v1.ConditionTrue, "KubeletHasDiskPressure",
+				"kubelet has disk pressure")
+		case cond == v1.NodeDiskPressure:
+			node.SetCondition(clk, cond, v1.ConditionFalse, "KubeletHasNoDiskPressure",
+				"kubelet has no disk pressure")
+		}
+	}
+}
+
+// rankForEviction sorts the pods in the order in which they are evicted under the pressure of the
+// resource, as the kubelet ranks them: the pods whose usage of the resource exceeds their requests
+// come first, and then the pods are ordered by their priorities (lowest first), and then by their
+// usage over requests (largest first).
+// QoS classes are not compared directly, but BestEffort pods using the resource always exceed their
+// requests, and Guaranteed pods never do unless they exceed their limits.
+func rankForEviction(clk clock.Clock, pods []*pod.Pod, rsrc v1.ResourceName) {
+	overRequest := make(map[*pod.Pod]int64, len(pods))
+	for _, p := range pods {
+		usage := p.ResourceUsage(clk)[rsrc]
+		request := p.TotalResourceRequests()[rsrc]
+		overRequest[p] = usage.MilliValue() - request.MilliValue()
+	}
+
+	sort.SliceStable(pods, func(i, j int) bool {
+		pi, pj := pods[i], pods[j]
+
+		overI, overJ := overRequest[pi], overRequest[pj]
+		if exceedsI, exceedsJ := overI > 0, overJ > 0; exceedsI != exceedsJ {
+			return exceedsI
+		}
+
+		prioI, prioJ := util.PodPriority(pi.ToV1()), util.PodPriority(pj.ToV1())
+		if prioI != prioJ {
+			return prioI < prioJ
+		}
+
+		if overI != overJ {
+			return overI > overJ
+		}
+
+		return util.PodKeyFromNames(pi.ToV1().Namespace, pi.ToV1().Name) <
+			util.PodKeyFromNames(pj.ToV1().Namespace, pj.ToV1().Name)
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+)
+
+func memory(str string) v1.ResourceList {
+	return v1.ResourceList{"memory": resource.MustParse(str)}
+}
+
+func TestRankForEviction(t *testing.T) {
+	node := newTestNode("node-0", v1.ResourceList{
+		"cpu":    resource.MustParse("16"),
+		"memory": resource.MustParse("16Gi"),
+		"pods":   resource.MustParse("10"),
+	})
+	guaranteed := v1.ResourceList{"cpu": resource.MustParse("1"), "memory": resource.MustParse("1Gi")}
+
+	podsV1 := []*v1.Pod{
+		newTestPod("guaranteed", 0, guaranteed, guaranteed, memory("1Gi"), 100),
+		newTestPod("burstable-under", 0, memory("2Gi"), nil, memory("1Gi"), 100),
+		newTestPod("burstable-over", 0, memory("1Gi"), nil, memory("2Gi"), 100),
+		newTestPod("burstable-over-high", 10, memory("1Gi"), nil, memory("4Gi"), 100),
+		newTestPod("besteffort-idle", 0, nil, nil, v1.ResourceList{"cpu": resource.MustParse("1")}, 100),
+		newTestPod("besteffort-low", 10, nil, nil, memory("100Mi"), 100),
+		newTestPod("besteffort-high", 10, nil, nil, memory("500Mi"), 100),
+		newTestPod("besteffort-priority", 0, nil, nil, memory("500Mi"), 100),
+	}
+	pods := []*pod.Pod{}
+	for _, v1Pod := range podsV1 {
+		p, err := node.BindPod(testStartClock, v1Pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pods = append(pods, p)
+	}
+
+	// The pods exceeding their requests come first regardless of their QoS classes, and are ordered
+	// by their priorities and then by their usage over requests. So are the others, where
+	// besteffort-idle and guaranteed, with the same usage over requests, are ordered by their names.
+	expected := []string{
+		"burstable-over", "besteffort-priority", "burstable-over-high", "besteffort-high", "besteffort-low",
+		"besteffort-idle", "guaranteed", "burstable-under",
+	}
+	rankForEviction(testStartClock.Add(time.Second), pods, "memory")
+	for i, p := range pods {
+		if p.ToV1().Name != expected[i] {
+			t.Errorf("got: %s at %d\nwant: %s", p.ToV1().Name, i, expected[i])
+		}
+	}
+}
+
+func TestEvictPodsUnderPressure(t *testing.T) {
+	oneGi := resource.MustParse("1Gi")
+
+	// The pod uses 3.5Gi of the 4Gi memory for 30s, 1Gi for 100s, and 3.5Gi again for 100s.
+	spec := `
+- seconds: 30
+  resourceUsage:
+    memory: 3584Mi
+- seconds: 100
+  resourceUsage:
+    memory: 1Gi
+- seconds: 100
+  resourceUsage:
+    memory: 3584Mi
+`
+
+	tests := []struct {
+		name      string
+		threshold EvictionThreshold
+		// evictedAfter is the seconds after which the pod is evicted.
+		evictedAfter int
+	}{
+		{
+			name:         "hard",
+			threshold:    EvictionThreshold{Signal: SignalMemoryAvailable, Quantity: &oneGi},
+			evictedAfter: 0,
+		},
+		{
+			name:         "hard percentage",
+			threshold:    EvictionThreshold{Signal: SignalMemoryAvailable, Percentage: 0.25},
+			evictedAfter: 0,
+		},
+		{
+			// The threshold met at 0s is relieved at 30s, and met again at 130s, so the grace period
+			// starts over.
+			name: "soft",
+			threshold: EvictionThreshold{
+				Signal: SignalMemoryAvailable, Quantity: &oneGi, GracePeriod: 60 * time.Second,
+			},
+			evictedAfter: 190,
+		},
+		{
+			name:         "other signal",
+			threshold:    EvictionThreshold{Signal: SignalNodeFsAvailable, Quantity: &oneGi},
+			evictedAfter: -1,
+		},
+	}
+
+	for _, test := range tests {
+		node := newTestNode("node-0", v1.ResourceList{
+			"cpu":               resource.MustParse("4"),
+			"memory":            resource.MustParse("4Gi"),
+			"ephemeral-storage": resource.MustParse("10Gi"),
+			"pods":              resource.MustParse("10"),
+		})
+		node.SetEvictionPolicy(EvictionPolicy{
+			Thresholds:               []EvictionThreshold{test.threshold},
+			PressureTransitionPeriod: 5 * time.Minute,
+		})
+		p, err := node.BindPod(testStartClock, newTestPodWithSpec("pod-0", 0, memory("1Gi"), nil, spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evictedAt := -1
+		for sec := 0; sec < 230 && evictedAt < 0; sec += 10 {
+			if evicted := node.EvictPodsUnderPressure(testStartClock.Add(time.Duration(sec) * time.Second)); len(evicted) > 0 {
+				if evicted[0] != p {
+					t.Errorf("%s: got: %v\nwant: pod-0", test.name, evicted[0].ToV1().Name)
+				}
+				evictedAt = sec
+			}
+		}
+		if evictedAt != test.evictedAfter {
+			t.Errorf("%s: got: evicted at %ds\nwant: %ds", test.name, evictedAt, test.evictedAfter)
+		}
+		if evictedAt >= 0 && (!p.IsEvicted() || p.ToV1().Status.Reason != "Evicted") {
+			t.Errorf("%s: got: %+v\nwant: Evicted status", test.name, p.ToV1().Status)
+		}
+	}
+}
+
+func TestPressureTransitionPeriod(t *testing.T) {
+	oneGi := resource.MustParse("1Gi")
+	node := newTestNode("node-0", v1.ResourceList{
+		"cpu":    resource.MustParse("4"),
+		"memory": resource.MustParse("4Gi"),
+		"pods":   resource.MustParse("10"),
+	})
+	node.SetEvictionPolicy(EvictionPolicy{
+		Thresholds:               []EvictionThreshold{{Signal: SignalMemoryAvailable, Quantity: &oneGi}},
+		PressureTransitionPeriod: 5 * time.Minute,
+	})
+	if _, err := node.BindPod(testStartClock, newTestPod("pod-0", 0, memory("1Gi"), nil, memory("3584Mi"), 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		clock    clock.Clock
+		evicted  int
+		pressure v1.ConditionStatus
+	}{
+		{clock: testStartClock, evicted: 1, pressure: v1.ConditionTrue},
+		// The pressure is relieved by the eviction, but the condition holds for the transition period.
+		{clock: testStartClock.Add(time.Minute), evicted: 0, pressure: v1.ConditionTrue},
+		{clock: testStartClock.Add(5 * time.Minute), evicted: 0, pressure: v1.ConditionTrue},
+		{clock: testStartClock.Add(5*time.Minute + time.Second), evicted: 0, pressure: v1.ConditionFalse},
+	}
+
+	for _, test := range tests {
+		evicted := node.EvictPodsUnderPressure(test.clock)
+		if len(evicted) != test.evicted {
+			t.Errorf("at %v: got: %d evicted\nwant: %d", test.clock, len(evicted), test.evicted)
+		}
+		cond, ok := node.Condition(v1.NodeMemoryPressure)
+		if !ok || cond.Status != test.pressure {
+			t.Errorf("at %v: got: %+v\nwant: MemoryPressure %s", test.clock, cond, test.pressure)
+		}
+		if _, ok := node.Condition(v1.NodeDiskPressure); ok {
+			t.Errorf("at %v: got: DiskPressure condition\nwant: none without its threshold", test.clock)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/strongerrors"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// GPUResourceName is the extended resource of GPUs.
+	GPUResourceName v1.ResourceName = "nvidia.com/gpu"
+	// MIGResourcePrefix is the prefix of the extended resources of MIG instances, followed by their
+	// profiles (e.g., nvidia.com/mig-1g.5gb).
+	MIGResourcePrefix = "nvidia.com/mig-"
+
+	// migComputeSlices is the number of compute slices of a GPU that MIG instances partition.
+	migComputeSlices = 7
+)
+
+// GPU is a GPU device of a node.
+type GPU struct {
+	// Memory is the device memory of the GPU.
+	Memory resource.Quantity
+	// Island is the interconnect island (e.g., NVLink domain) of the GPU.
+	// GPUs in different islands communicate through PCIe.
+	Island int
+
+	// MIGProfiles is the partition layout of the GPU into MIG instances (e.g., "3g.20gb" and
+	// "1g.5gb"), each of which is provided as a MIGResourcePrefix resource instead of the whole GPU.
+	// MIG is disabled if it is empty.
+	MIGProfiles []string
+	// Replicas is the number of pods that can share the GPU by time-slicing, if MIG is disabled.
+	// Zero is regarded as 1 (i.e., no sharing).
+	Replicas int
+}
+
+// GPUDevices is the device model of the GPUs of a node.
+type GPUDevices struct {
+	// GPUs is the devices, whose indices are used as their IDs.
+	GPUs []GPU
+	// CrossIslandSlowdown is the factor by which the execution of a pod is stretched when the GPUs
+	// assigned to it span multiple islands.
+	// Zero is regarded as 1 (i.e., no slowdown).
+	CrossIslandSlowdown float64
+	// TimeSlicingSlowdown is the factor by which the execution of a pod is stretched when any of the
+	// GPUs assigned to it is shared by time-slicing.
+	// Zero is regarded as 1 (i.e., no slowdown).
+	TimeSlicingSlowdown float64
+	// RepartitionDuration is the duration for which a GPU is unavailable when its MIG partition
+	// layout is changed.
+	RepartitionDuration time.Duration
+}
+
+// GPUAllocator selects the GPUs of a node to be assigned to a pod.
+type GPUAllocator interface {
+	// Allocate selects n GPUs among the free ones, given by their indices in ascending order.
+	// Returns the indices of the selected GPUs, or false if they cannot be selected.
+	Allocate(gpus []GPU, free []int, n int) ([]int, bool)
+}
+
+// FirstFitAllocator is a GPUAllocator that selects the free GPUs with the smallest indices.
+type FirstFitAllocator struct{}
+
+// BestFitAllocator is a GPUAllocator that selects GPUs from the island with the fewest free GPUs
+// that can accommodate all of them, so that larger islands are left for larger pods.
+// If no single island can accommodate them, it falls back to FirstFitAllocator.
+type BestFitAllocator struct{}
+
+// TopologyAwareAllocator is a GPUAllocator that selects GPUs spanning the fewest islands.
+// If a single island can accommodate them, it selects the same GPUs as BestFitAllocator.
+// Otherwise, it takes GPUs from the islands with the most free GPUs first.
+type TopologyAwareAllocator struct{}
+
+// Allocate implements GPUAllocator interface.
+func (FirstFitAllocator) Allocate(_ []GPU, free []int, n int) ([]int, bool) {
+	if len(free) < n {
+		return nil, false
+	}
+	return append([]int{}, free[:n]...), true
+}
+
+// Allocate implements GPUAllocator interface.
+func (BestFitAllocator) Allocate(gpus []GPU, free []int, n int) ([]int, bool) {
+	if len(free) < n {
+		return nil, false
+	}
+
+	if island, ok := bestFitIsland(freeByIsland(gpus, free), n); ok {
+		return island[:n], true
+	}
+	return FirstFitAllocator{}.Allocate(gpus, free, n)
+}
+
+// Allocate implements GPUAllocator interface.
+func (TopologyAwareAllocator) Allocate(gpus []GPU, free []int, n int) ([]int, bool) {
+	if len(free) < n {
+		return nil, false
+	}
+
+	islands := freeByIsland(gpus, free)
+	if island, ok := bestFitIsland(islands, n); ok {
+		return island[:n], true
+	}
+
+	// islands are sorted by their numbers of free GPUs in ascending order.
+	selected := make([]int, 0, n)
+	for i := len(islands) - 1; i >= 0 && len(selected) < n; i-- {
+		for _, gpu := range islands[i] {
+			if len(selected) == n {
+				break
+			}
+			selected = append(selected, gpu)
+		}
+	}
+	sort.Ints(selected)
+
+	return selected, true
+}
+
+var _ = GPUAllocator(FirstFitAllocator{})
+var _ = GPUAllocator(BestFitAllocator{})
+var _ = GPUAllocator(TopologyAwareAllocator{})
+
+// MIGResourceName returns the extended resource of the MIG instances of the profile.
+func MIGResourceName(profile string) v1.ResourceName {
+	return v1.ResourceName(MIGResourcePrefix + profile)
+}
+
+// replicas returns the number of GPUResourceName that the GPU provides.
+func (gpu GPU) replicas() int {
+	switch {
+	case len(gpu.MIGProfiles) > 0:
+		return 0
+	case gpu.Replicas > 1:
+		return gpu.Replicas
+	default:
+		return 1
+	}
+}
+
+// GPUResources returns the extended resources that the GPUs provide, except for the ones whose
+// indices are in the excluded set.
+func GPUResources(gpus []GPU, excluded map[int]struct{}) v1.ResourceList {
+	counts := map[v1.ResourceName]int64{GPUResourceName: 0}
+	for i, gpu := range gpus {
+		_, isExcluded := excluded[i]
+		if !isExcluded {
+			counts[GPUResourceName] += int64(gpu.replicas())
+		}
+
+		for _, profile := range gpu.MIGProfiles {
+			count := counts[MIGResourceName(profile)]
+			if !isExcluded {
+				count++
+			}
+			counts[MIGResourceName(profile)] = count
+		}
+	}
+
+	resources := make(v1.ResourceList, len(counts))
+	for name, count := range counts {
+		resources[name] = *resource.NewQuantity(count, resource.DecimalSI)
+	}
+
+	return resources
+}
+
+// ValidateMIGLayout returns error if the MIG profiles cannot partition the GPU, i.e., if a profile
+// is not in the form of "<compute slices>g.<memory>gb", or the profiles need more compute slices or
+// memory than the GPU has.
+func ValidateMIGLayout(gpu GPU, profiles []string) error {
+	slices := 0
+	memory := resource.Quantity{}
+	for _, profile := range profiles {
+		var s, m int
+		if n, err := fmt.Sscanf(profile, "%dg.%dgb", &s, &m); err != nil || n != 2 || s <= 0 || m <= 0 ||
+			profile != fmt.Sprintf("%dg.%dgb", s, m) {
+			return strongerrors.InvalidArgument(errors.Errorf("invalid MIG profile %q", profile))
+		}
+
+		slices += s
+		memory.Add(*resource.NewQuantity(int64(m)<<30, resource.BinarySI))
+	}
+
+	if slices > migComputeSlices {
+		return strongerrors.InvalidArgument(
+			errors.Errorf("MIG profiles [%s] need %d compute slices, more than %d",
+				strings.Join(profiles, " "), slices, migComputeSlices))
+	}
+	if !gpu.Memory.IsZero() && memory.Cmp(gpu.Memory) > 0 {
+		return strongerrors.InvalidArgument(
+			errors.Errorf("MIG profiles [%s] need %s memory, more than %s",
+				strings.Join(profiles, " "), memory.String(), gpu.Memory.String()))
+	}
+
+	return nil
+}
+
+// IslandsNum returns the number of islands that the GPUs of the given indices span.
+func IslandsNum(gpus []GPU, indices []int) int {
+	islands := map[int]struct{}{}
+	for _, i := range indices {
+		islands[gpus[i].Island] = struct{}{}
+	}
+	return len(islands)
+}
+
+// freeByIsland groups the free GPUs by their islands.
+// The groups are sorted by their sizes, and then by the smallest indices in them.
+func freeByIsland(gpus []GPU, free []int) [][]int {
+	islandIdx := map[int]int{}
+	islands := [][]int{}
+	for _, i := range free {
+		island := gpus[i].Island
+		idx, ok := islandIdx[island]
+		if !ok {
+			idx = len(islands)
+			islandIdx[island] = idx
+			islands = append(islands, []int{})
+		}
+		islands[idx] = append(islands[idx], i)
+	}
+
+	sort.SliceStable(islands, func(i, j int) bool { return len(islands[i]) < len(islands[j]) })
+	return islands
+}
+
+// bestFitIsland returns the free GPUs of the smallest island that has at least n free GPUs.
+// Returns false if no such island exists.
+func bestFitIsland(islands [][]int, n int) ([]int, bool) {
+	for _, island := range islands {
+		if len(island) >= n {
+			return island, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+)
+
+var testStartClock = clock.NewClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+// newTestNode creates a Node with the allocatable resources.
+func newTestNode(name string, allocatable v1.ResourceList) *Node {
+	node := NewNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Allocatable: allocatable, Capacity: allocatable.DeepCopy()},
+	})
+	return &node
+}
+
+// newTestPod creates a pod with a container of the requests and limits, which runs for the seconds
+// using the resources in usage.
+func newTestPod(name string, priority int32, requests, limits, usage v1.ResourceList, seconds int) *v1.Pod {
+	spec := fmt.Sprintf("- seconds: %d\n  resourceUsage:\n", seconds)
+	for rsrc, q := range usage {
+		spec += fmt.Sprintf("    %s: %s\n", rsrc, q.String())
+	}
+	return newTestPodWithSpec(name, priority, requests, limits, spec)
+}
+
+// newTestPodWithSpec creates a pod with a container of the requests and limits, and the simSpec
+// annotation.
+func newTestPodWithSpec(name string, priority int32, requests, limits v1.ResourceList, spec string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: map[string]string{"simSpec": spec},
+		},
+		Spec: v1.PodSpec{
+			Priority: &priority,
+			Containers: []v1.Container{{
+				Name:      "container",
+				Resources: v1.ResourceRequirements{Requests: requests, Limits: limits},
+			}},
+		},
+	}
+}
+
+func TestGPUAllocators(t *testing.T) {
+	// Island 0 has GPUs 0-3, island 1 has GPUs 4-5, and island 2 has GPUs 6-8, and the free GPUs
+	// are fragmented: 1 in island 0, 2 in island 1, and 3 in island 2.
+	gpus := []GPU{
+		{Island: 0}, {Island: 0}, {Island: 0}, {Island: 0},
+		{Island: 1}, {Island: 1},
+		{Island: 2}, {Island: 2}, {Island: 2},
+	}
+	free := []int{1, 4, 5, 6, 7, 8}
+
+	tests := []struct {
+		n        int
+		expected map[string][]int
+		islands  map[string]int
+	}{
+		{
+			n: 2,
+			expected: map[string][]int{
+				"firstFit": {1, 4}, "bestFit": {4, 5}, "topologyAware": {4, 5},
+			},
+			islands: map[string]int{"firstFit": 2, "bestFit": 1, "topologyAware": 1},
+		},
+		{
+			n: 3,
+			expected: map[string][]int{
+				"firstFit": {1, 4, 5}, "bestFit": {6, 7, 8}, "topologyAware": {6, 7, 8},
+			},
+			islands: map[string]int{"firstFit": 2, "bestFit": 1, "topologyAware": 1},
+		},
+		{
+			// No single island can accommodate them, so bestFit falls back to firstFit, and
+			// topologyAware takes the largest islands first.
+			n: 4,
+			expected: map[string][]int{
+				"firstFit": {1, 4, 5, 6}, "bestFit": {1, 4, 5, 6}, "topologyAware": {4, 6, 7, 8},
+			},
+			islands: map[string]int{"firstFit": 3, "bestFit": 3, "topologyAware": 2},
+		},
+		{n: 7, expected: map[string][]int{"firstFit": nil, "bestFit": nil, "topologyAware": nil}},
+	}
+
+	allocators := map[string]GPUAllocator{
+		"firstFit":      FirstFitAllocator{},
+		"bestFit":       BestFitAllocator{},
+		"topologyAware": TopologyAwareAllocator{},
+	}
+
+	for _, test := range tests {
+		for name, allocator := range allocators {
+			actual, ok := allocator.Allocate(gpus, free, test.n)
+			expected := test.expected[name]
+			if expected == nil {
+				if ok {
+					t.Errorf("%s with %d GPUs: got: %v\nwant: false", name, test.n, actual)
+				}
+				continue
+			}
+
+			if !ok || !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s with %d GPUs: got: %v, %v\nwant: %v", name, test.n, actual, ok, expected)
+			}
+			if islands := IslandsNum(gpus, actual); islands != test.islands[name] {
+				t.Errorf("%s with %d GPUs: got: %d islands\nwant: %d", name, test.n, islands, test.islands[name])
+			}
+		}
+	}
+}
+
+func TestGPUCrossIslandSlowdown(t *testing.T) {
+	allocatable := v1.ResourceList{
+		"cpu":    resource.MustParse("8"),
+		"memory": resource.MustParse("16Gi"),
+		"pods":   resource.MustParse("10"),
+	}
+	oneGPU := v1.ResourceList{"cpu": resource.MustParse("1"), GPUResourceName: resource.MustParse("1")}
+	twoGPUs := v1.ResourceList{"cpu": resource.MustParse("1"), GPUResourceName: resource.MustParse("2")}
+
+	tests := []struct {
+		name      string
+		allocator GPUAllocator
+		gpus      []int
+		duration  time.Duration
+	}{
+		{name: "firstFit", allocator: FirstFitAllocator{}, gpus: []int{1, 2}, duration: 150 * time.Second},
+		{name: "topologyAware", allocator: TopologyAwareAllocator{}, gpus: []int{2, 3}, duration: 100 * time.Second},
+	}
+
+	for _, test := range tests {
+		node := newTestNode("node-0", allocatable)
+		// Islands 0 and 1 have 2 GPUs each.
+		node.SetGPUDevices(GPUDevices{
+			GPUs:                []GPU{{Island: 0}, {Island: 0}, {Island: 1}, {Island: 1}},
+			CrossIslandSlowdown: 1.5,
+		})
+		node.SetGPUAllocator(test.allocator)
+
+		// GPU 0 is taken, so the free GPUs of island 0 cannot accommodate the next pod.
+		if _, err := node.BindPod(testStartClock, newTestPod("pod-0", 0, oneGPU, oneGPU, oneGPU, 1000)); err != nil {
+			t.Fatal(err)
+		}
+		p, err := node.BindPod(testStartClock, newTestPod("pod-1", 0, twoGPUs, twoGPUs, twoGPUs, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(p.GPUs(), test.gpus) {
+			t.Errorf("%s: got: %v\nwant: %v", test.name, p.GPUs(), test.gpus)
+		}
+		finishedAt, ok := p.FinishedAt(testStartClock.Add(time.Hour))
+		if !ok || finishedAt.Sub(testStartClock) != test.duration {
+			t.Errorf("%s: got: %v, %v\nwant: finished in %v", test.name, finishedAt.Sub(testStartClock), ok,
+				test.duration)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/api"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+)
+
+const (
+	// DefaultNodeMonitorGracePeriod is the default time for which a node condition must hold before
+	// the node is tainted by it, as --node-monitor-grace-period of kube-controller-manager.
+	DefaultNodeMonitorGracePeriod = 40 * time.Second
+	// DefaultTolerationSeconds is the default tolerationSeconds of the tolerations to the not-ready
+	// NoExecute taint added to pods, as the DefaultTolerationSeconds admission plugin does.
+	DefaultTolerationSeconds int64 = 300
+)
+
+// conditionTaint is a taint that the node lifecycle controller of kubernetes adds to a node while
+// its condition has the status.
+type conditionTaint struct {
+	condition v1.NodeConditionType
+	status    v1.ConditionStatus
+	key       string
+	effects   []v1.TaintEffect
+}
+
+var conditionTaints = []conditionTaint{
+	{v1.NodeReady, v1.ConditionFalse, api.TaintNodeNotReady,
+		[]v1.TaintEffect{v1.TaintEffectNoSchedule, v1.TaintEffectNoExecute}},
+	{v1.NodeReady, v1.ConditionUnknown, api.TaintNodeUnreachable,
+		[]v1.TaintEffect{v1.TaintEffectNoSchedule, v1.TaintEffectNoExecute}},
+	{v1.NodeMemoryPressure, v1.ConditionTrue, api.TaintNodeMemoryPressure,
+		[]v1.TaintEffect{v1.TaintEffectNoSchedule}},
+	{v1.NodeDiskPressure, v1.ConditionTrue, api.TaintNodeDiskPressure,
+		[]v1.TaintEffect{v1.TaintEffectNoSchedule}},
+	{v1.NodePIDPressure, v1.ConditionTrue, api.TaintNodePIDPressure,
+		[]v1.TaintEffect{v1.TaintEffectNoSchedule}},
+}
+
+// SetCondition sets the status of the condition of this Node at the given clock, adding the
+// condition if this Node does not have it.
+// The transition time of the condition is updated only if its status changes.
+// Returns true if the status changes.
+func (node *Node) SetCondition(
+	clock clock.Clock, condType v1.NodeConditionType, status v1.ConditionStatus, reason, message string,
+) bool {
+	v1Node := node.ToV1()
+	now := clock.ToMetaV1()
+
+	for i, cond := range v1Node.Status.Conditions {
+		if cond.Type != condType {
+			continue
+		}
+
+		changed := cond.Status != status
+		cond.LastHeartbeatTime = now
+		if changed {
+			cond.LastTransitionTime = now
+		}
+		cond.Status = status
+		cond.Reason = reason
+		cond.Message = message
+		v1Node.Status.Conditions[i] = cond
+
+		return changed
+	}
+
+	v1Node.Status.Conditions = append(v1Node.Status.Conditions, v1.NodeCondition{
+		Type:               condType,
+		Status:             status,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	})
+	return true
+}
+
+// Condition returns the condition of the type of this Node.
+// Returns false if this Node does not have the condition.
+func (node *Node) Condition(condType v1.NodeConditionType) (v1.NodeCondition, bool) {
+	for _, cond := range node.ToV1().Status.Conditions {
+		if cond.Type == condType {
+			return cond, true
+		}
+	}
+	return v1.NodeCondition{}, false
+}
+
+// conditionStatuses returns the status of each condition of this Node.
+func (node *Node) conditionStatuses() map[v1.NodeConditionType]v1.ConditionStatus {
+	statuses := make(map[v1.NodeConditionType]v1.ConditionStatus, len(node.ToV1().Status.Conditions))
+	for _, cond := range node.ToV1().Status.Conditions {
+		statuses[cond.Type] = cond.Status
+	}
+	return statuses
+}
+
+// IsReady returns whether the Ready condition of this Node is True.
+func (node *Node) IsReady() bool {
+	cond, ok := node.Condition(v1.NodeReady)
+	return ok && cond.Status == v1.ConditionTrue
+}
+
+// UpdateConditionTaints adds the node.kubernetes.io/* taints of the conditions of this Node that
+// have held for the grace period by the given clock, and removes the ones of the conditions that no
+// longer hold, as the node lifecycle controller of kubernetes does.
+// The taints are added at the end of the grace period.
+// Returns true if any taint has been removed, i.e., this Node may have become schedulable.
+func (node *Node) UpdateConditionTaints(clk clock.Clock, gracePeriod time.Duration) bool {
+	removed := false
+
+	for _, ct := range conditionTaints {
+		cond, ok := node.Condition(ct.condition)
+		holds := ok && cond.Status == ct.status
+
+		for _, effect := range ct.effects {
+			taint := v1.Taint{Key: ct.key, Effect: effect}
+
+			if !holds {
+				if node.RemoveTaint(taint) {
+					removed = true
+				}
+				continue
+			}
+
+			taintAt := clock.NewClockWithMetaV1(cond.LastTransitionTime).Add(gracePeriod)
+			if clk.Before(taintAt) || node.hasTaint(taint) {
+				continue
+			}
+			node.AddTaint(taintAt, taint)
+		}
+	}
+
+	return removed
+}
+
+// hasTaint returns whether this Node has the taint with the same key and effect as the given one.
+func (node *Node) hasTaint(taint v1.Taint) bool {
+	for _, t := range node.ToV1().Spec.Taints {
+		if t.MatchTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddDefaultTolerations adds the toleration to the not-ready NoExecute taint for the given seconds
+// to the pod, unless the pod already tolerates it, as the DefaultTolerationSeconds admission plugin
+// of kubernetes does.
+// Unlike the plugin, no toleration to the unreachable NoExecute taint is added, since pods are lost
+// as soon as their node fails (see Node.Fail), and the toleration would never take effect.
+func AddDefaultTolerations(pod *v1.Pod, seconds int64) {
+	taint := v1.Taint{Key: api.TaintNodeNotReady, Effect: v1.TaintEffectNoExecute}
+	for _, toleration := range pod.Spec.Tolerations {
+		if toleration.ToleratesTaint(&taint) {
+			return
+		}
+	}
+
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, v1.Toleration{
+		Key:               api.TaintNodeNotReady,
+		Operator:          v1.TolerationOpExists,
+		Effect:            v1.TaintEffectNoExecute,
+		TolerationSeconds: &seconds,
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/api"
+)
+
+// taintSummaries returns the taints of the node as "<key>:<effect>@<seconds from the start>".
+func taintSummaries(node *Node) []string {
+	taints := []string{}
+	for _, taint := range node.ToV1().Spec.Taints {
+		added := taint.TimeAdded.Sub(testStartClock.ToMetaV1().Time)
+		taints = append(taints, taint.Key+":"+string(taint.Effect)+"@"+added.String())
+	}
+	sort.Strings(taints)
+	return taints
+}
+
+func TestUpdateConditionTaints(t *testing.T) {
+	node := newTestNode("node-0", cpuMemory("4", "16Gi"))
+	gracePeriod := 40 * time.Second
+
+	// The node becomes not ready at the start and under memory pressure at 10 seconds.
+	node.SetCondition(testStartClock, v1.NodeReady, v1.ConditionFalse, "KubeletNotReady", "")
+	node.SetCondition(testStartClock.Add(10*time.Second), v1.NodeMemoryPressure, v1.ConditionTrue,
+		"KubeletHasInsufficientMemory", "")
+
+	tests := []struct {
+		seconds     int
+		ready       bool
+		wantRemoved bool
+		want        []string
+	}{
+		// The taints are not added until the conditions have held for the grace period.
+		{39, false, false, []string{}},
+		// The taints are added at the end of the grace period of each condition.
+		{40, false, false, []string{
+			api.TaintNodeNotReady + ":NoExecute@40s",
+			api.TaintNodeNotReady + ":NoSchedule@40s",
+		}},
+		{60, false, false, []string{
+			api.TaintNodeMemoryPressure + ":NoSchedule@50s",
+			api.TaintNodeNotReady + ":NoExecute@40s",
+			api.TaintNodeNotReady + ":NoSchedule@40s",
+		}},
+		// The taints are removed at once when the condition is cleared.
+		{70, true, true, []string{api.TaintNodeMemoryPressure + ":NoSchedule@50s"}},
+		{80, true, false, []string{api.TaintNodeMemoryPressure + ":NoSchedule@50s"}},
+	}
+
+	for _, test := range tests {
+		clock := testStartClock.Add(time.Duration(test.seconds) * time.Second)
+		if test.ready {
+			node.SetCondition(clock, v1.NodeReady, v1.ConditionTrue, "KubeletReady", "")
+		}
+
+		removed := node.UpdateConditionTaints(clock, gracePeriod)
+		if got := taintSummaries(node); removed != test.wantRemoved || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("at %ds: got: %v, removed %v\nwant: %v, removed %v",
+				test.seconds, got, removed, test.want, test.wantRemoved)
+		}
+	}
+}
+
+func TestAddDefaultTolerations(t *testing.T) {
+	seconds, userSeconds := int64(300), int64(60)
+	notReady := v1.Toleration{
+		Key:               api.TaintNodeNotReady,
+		Operator:          v1.TolerationOpExists,
+		Effect:            v1.TaintEffectNoExecute,
+		TolerationSeconds: &seconds,
+	}
+	userNotReady := *notReady.DeepCopy()
+	userNotReady.TolerationSeconds = &userSeconds
+	unreachable := v1.Toleration{Key: api.TaintNodeUnreachable, Operator: v1.TolerationOpExists}
+	tolerateAll := v1.Toleration{Operator: v1.TolerationOpExists}
+
+	tests := []struct {
+		name        string
+		tolerations []v1.Toleration
+		want        []v1.Toleration
+	}{
+		{"no tolerations", nil, []v1.Toleration{notReady}},
+		// No toleration to the unreachable taint is added, as pods are lost when their node fails.
+		{"tolerating unreachable", []v1.Toleration{unreachable}, []v1.Toleration{unreachable, notReady}},
+		{"tolerating not-ready", []v1.Toleration{userNotReady}, []v1.Toleration{userNotReady}},
+		{"tolerating all taints", []v1.Toleration{tolerateAll}, []v1.Toleration{tolerateAll}},
+	}
+
+	for _, test := range tests {
+		pod := &v1.Pod{Spec: v1.PodSpec{Tolerations: test.tolerations}}
+		AddDefaultTolerations(pod, seconds)
+		if !reflect.DeepEqual(pod.Spec.Tolerations, test.want) {
+			t.Errorf("%s: got: %v\nwant: %v", test.name, pod.Spec.Tolerations, test.want)
+		}
+	}
+}
@@ -15,7 +15,13 @@
 package node
 
 import (
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/containerd/containerd/log"
+	"github.com/cpuguy83/strongerrors"
+	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
 
@@ -26,25 +32,59 @@ import (
 
 // Node represents a simulated computing node.
 type Node struct {
-	v1   *v1.Node
-	pods map[string]*pod.Pod
+	v1       *v1.Node
+	pods     map[string]*pod.Pod
+	failures int
+	draining bool
+	eviction *evictionState
+	oomKill  bool
+
+	gpus         *GPUDevices
+	gpuAllocator GPUAllocator
+	// gpuReadyAt is the clock at which each GPU being repartitioned becomes available.
+	gpuReadyAt map[int]clock.Clock
+
+	startup *StartupModel
+	images  map[string]imageState
+	// pullingUntil is the clock at which the image being pulled has been pulled.
+	pullingUntil clock.Clock
 }
 
 // Metrics is a metrics of a Node at one point of time.
 type Metrics struct {
 	Allocatable          v1.ResourceList
 	RunningPodsNum       int64
+	StartingPodsNum      int64
 	TerminatingPodsNum   int64
 	FailedPodsNum        int64
+	LostPodsNum          int64
+	EvictedPodsNum       int64
+	OOMKilledPodsNum     int64
 	TotalResourceRequest v1.ResourceList
 	TotalResourceUsage   v1.ResourceList
+	Failed               bool
+	Unschedulable        bool
+	Draining             bool
+	// Conditions is the status of each condition of this Node (e.g., Ready).
+	Conditions map[v1.NodeConditionType]v1.ConditionStatus `json:",omitempty"`
+
+	// FreeGPUs is the number of free GPUs in each island, if this Node has the GPU device model.
+	FreeGPUs map[int]int `json:",omitempty"`
+	// FreeMIGDevices is the number of free MIG instances of each profile.
+	FreeMIGDevices map[string]int `json:",omitempty"`
+	// DrainingGPUs is the indices of the GPUs being repartitioned.
+	DrainingGPUs []int `json:",omitempty"`
+	// QOS is the metrics of the pods of each QoS class on this Node.
+	QOS map[v1.PodQOSClass]QOSMetrics `json:",omitempty"`
 }
 
 // NewNode creates a new Node with the given v1.Node.
 func NewNode(node *v1.Node) Node {
 	return Node{
-		v1:   node,
-		pods: map[string]*pod.Pod{},
+		v1:         node,
+		pods:       map[string]*pod.Pod{},
+		gpuReadyAt: map[int]clock.Clock{},
+		images:     map[string]imageState{},
 	}
 }
 
@@ -57,6 +97,7 @@ func (node *Node) ToV1() *v1.Node {
 func (node *Node) ToNodeInfo(clock clock.Clock) (*nodeinfo.NodeInfo, error) {
 	pods := node.runningAndTerminatingPodsV1WithStatus(clock)
 	nodeInfo := nodeinfo.NewNodeInfo(pods...)
+	node.updateImagesStatus(clock)
 	err := nodeInfo.SetNode(node.ToV1())
 	if err != nil {
 		return nil, err
@@ -69,18 +110,55 @@ func (node *Node) Metrics(clock clock.Clock) Metrics {
 	return Metrics{
 		Allocatable:          node.ToV1().Status.Allocatable,
 		RunningPodsNum:       node.runningPodsNum(clock),
+		StartingPodsNum:      node.startingPodsNum(clock),
 		TerminatingPodsNum:   node.terminatingPodsNum(clock),
 		FailedPodsNum:        node.bindingFailedPodsNum(),
+		LostPodsNum:          node.lostPodsNum(),
+		EvictedPodsNum:       node.evictedPodsNum(),
+		OOMKilledPodsNum:     node.oomKilledPodsNum(),
 		TotalResourceRequest: node.totalResourceRequest(clock),
 		TotalResourceUsage:   node.totalResourceUsage(clock),
+		Failed:               node.IsFailed(),
+		Unschedulable:        node.ToV1().Spec.Unschedulable,
+		Draining:             node.IsDraining(),
+		Conditions:           node.conditionStatuses(),
+		FreeGPUs:             node.freeGPUsByIsland(clock),
+		FreeMIGDevices:       node.freeMIGDevicesNum(clock),
+		DrainingGPUs:         node.drainingGPUs(),
+		QOS:                  node.qosMetrics(clock),
 	}
 }
 
+// SetGPUDevices sets the GPU device model of this Node, and updates the allocatable GPUResourceName
+// and MIG resources of this Node with the ones that the GPUs provide.
+// Pods bound to this Node are assigned concrete GPUs by the GPUAllocator, instead of just a
+// quantity of GPUResourceName.
+func (node *Node) SetGPUDevices(devices GPUDevices) {
+	devices.GPUs = append([]GPU{}, devices.GPUs...)
+	node.gpus = &devices
+	node.updateGPUAllocatable()
+}
+
+// SetGPUAllocator sets the allocator of GPUs on this Node (FirstFitAllocator by default).
+func (node *Node) SetGPUAllocator(allocator GPUAllocator) {
+	node.gpuAllocator = allocator
+}
+
 // BindPod accepts the given pod and try to start it.
-// The pod will fail to be started if there is not sufficient resources.
+// The pod will fail to be started if there is not sufficient resources, and will be lost if this
+// Node has failed.
 // Returns the bound pod in pod.Pod representation, or error if the pod has invalid name or failed
 // to create a simulated pod.
 func (node *Node) BindPod(clock clock.Clock, v1Pod *v1.Pod) (*pod.Pod, error) {
+	return node.BindPodWithAttachDuration(clock, v1Pod, 0)
+}
+
+// BindPodWithAttachDuration is the same as BindPod, except that the pod starts after its volumes
+// have been attached to this Node in the given duration.
+func (node *Node) BindPodWithAttachDuration(
+	clock clock.Clock, v1Pod *v1.Pod, attachDuration time.Duration,
+) (*pod.Pod, error) {
+
 	key, err := util.PodKey(v1Pod)
 	if err != nil {
 		return nil, err
@@ -89,14 +167,20 @@ func (node *Node) BindPod(clock clock.Clock, v1Pod *v1.Pod) (*pod.Pod, error) {
 	log.L.Tracef("Node %s: Pod %s bound", node.ToV1().Name, key)
 
 	// Check node capacity
-	newTotalReq := util.ResourceListSum(node.totalResourceRequest(clock), util.PodTotalResourceRequests(v1Pod))
-	allocatable := node.ToV1().Status.Allocatable
 	var podStatus pod.Status
-
-	if !util.ResourceListGE(allocatable, newTotalReq) || node.runningPodsNum(clock) >= allocatable.Pods().Value() {
-		podStatus = pod.OverCapacity
+	var gpus []int
+	var migDevices []pod.MIGDevice
+	if node.IsFailed() {
+		podStatus = pod.NodeLost
+	} else if node.HasCapacityFor(clock, v1Pod) {
+		var ok bool
+		if gpus, migDevices, ok = node.allocateGPUs(clock, v1Pod); ok {
+			podStatus = pod.Ok
+		} else {
+			podStatus = pod.OverCapacity
+		}
 	} else {
-		podStatus = pod.Ok
+		podStatus = pod.OverCapacity
 	}
 
 	// Create simulated pod
@@ -104,12 +188,42 @@ func (node *Node) BindPod(clock clock.Clock, v1Pod *v1.Pod) (*pod.Pod, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(gpus) > 0 || len(migDevices) > 0 {
+		simPod.AssignDevices(gpus, migDevices, node.gpuSlowdown(gpus))
+		log.L.Tracef("Node %s: Pod %s assigned GPUs %v and MIG instances %v",
+			node.ToV1().Name, key, gpus, migDevices)
+	}
+	if podStatus == pod.Ok {
+		if err := node.startPod(clock, simPod, attachDuration); err != nil {
+			return nil, err
+		}
+	}
 	v1Pod.Status = simPod.BuildStatus(clock)
 	node.pods[key] = simPod
 
 	return simPod, nil
 }
 
+// HasCapacityFor returns whether this Node has sufficient resources to start the given pod at the
+// given clock.
+func (node *Node) HasCapacityFor(clock clock.Clock, v1Pod *v1.Pod) bool {
+	newTotalReq := util.ResourceListSum(node.totalResourceRequest(clock), util.PodTotalResourceRequests(v1Pod))
+	allocatable := node.ToV1().Status.Allocatable
+
+	return util.ResourceListGE(allocatable, newTotalReq) && node.runningPodsNum(clock) < allocatable.Pods().Value()
+}
+
+// CanStartPod returns whether this Node has sufficient resources to start the given pod at the
+// given clock, and the GPUs and the MIG instances requested by the pod can be allocated to it.
+// A pod bound to this Node fails to be started if this returns false.
+func (node *Node) CanStartPod(clock clock.Clock, v1Pod *v1.Pod) bool {
+	if !node.HasCapacityFor(clock, v1Pod) {
+		return false
+	}
+	_, _, ok := node.allocateGPUs(clock, v1Pod)
+	return ok
+}
+
 // DeletePod start deleting the given pod from this Node.
 // Returns true if the pod is found in this Node, or false otherwise.
 func (node *Node) DeletePod(clock clock.Clock, podNamespace, podName string) bool {
@@ -152,15 +266,330 @@ func (node *Node) PodsNum(clock clock.Clock) int64 {
 	return node.runningPodsNum(clock) + node.terminatingPodsNum(clock)
 }
 
-// GCTerminatedPods deletes terminated or deleted pods at the given clock from this Node.
+// GCTerminatedPods deletes terminated, deleted, or killed (i.e., lost, evicted, or OOM-killed) pods
+// at the given clock from this Node.
 func (node *Node) GCTerminatedPods(clock clock.Clock) {
 	for name, pod := range node.pods {
-		if pod.IsTerminated(clock) || pod.IsDeleted(clock) {
+		if pod.IsTerminated(clock) || pod.IsDeleted(clock) || pod.IsKilled() {
 			delete(node.pods, name)
 		}
 	}
 }
 
+// Fail makes this Node fail at the given clock.
+// The Ready condition of the node becomes Unknown, and all pods running or terminating on it are
+// lost.
+// Failures may overlap (e.g., of a rack and of its zone), and the node recovers when all of them
+// are recovered.
+// Returns the lost pods.
+func (node *Node) Fail(clock clock.Clock) []*pod.Pod {
+	node.failures++
+	if node.failures == 1 {
+		node.setReady(clock, false)
+	}
+
+	lost := []*pod.Pod{}
+	for _, pod := range node.pods {
+		if pod.Lose(clock) {
+			pod.ToV1().Status = pod.BuildStatus(clock)
+			lost = append(lost, pod)
+		}
+	}
+
+	return lost
+}
+
+// Recover recovers this Node from one of its failures at the given clock.
+// Pods lost by the failure are not restarted.
+func (node *Node) Recover(clock clock.Clock) {
+	if node.failures == 0 {
+		return
+	}
+
+	node.failures--
+	if node.failures == 0 {
+		node.setReady(clock, true)
+	}
+}
+
+// IsFailed returns whether this Node has failed and not recovered yet.
+func (node *Node) IsFailed() bool {
+	return node.failures > 0
+}
+
+// setReady updates the Ready condition of this Node.
+// The node.kubernetes.io/unreachable taints are added later by UpdateConditionTaints.
+func (node *Node) setReady(clock clock.Clock, ready bool) {
+	if ready {
+		node.SetCondition(clock, v1.NodeReady, v1.ConditionTrue, "KubeletReady", "kubelet is posting ready status")
+	} else {
+		node.SetCondition(clock, v1.NodeReady, v1.ConditionUnknown, "NodeStatusUnknown",
+			"Kubelet stopped posting node status.")
+	}
+}
+
+// RepartitionGPU changes the MIG partition layout of the GPU of the index at the given clock.
+// An empty layout disables MIG of the GPU.
+// The GPU is unavailable for the RepartitionDuration of the GPU device model (i.e., the drain cost),
+// and pods using it must be evicted, which are returned.
+// Returns error if this Node does not have the GPU, or the layout is invalid.
+func (node *Node) RepartitionGPU(clock clock.Clock, gpu int, profiles []string) ([]*pod.Pod, error) {
+	if node.gpus == nil || gpu < 0 || gpu >= len(node.gpus.GPUs) {
+		return nil, strongerrors.NotFound(errors.Errorf("No GPU %d on node %q", gpu, node.ToV1().Name))
+	}
+	if err := ValidateMIGLayout(node.gpus.GPUs[gpu], profiles); err != nil {
+		return nil, err
+	}
+
+	victims := []*pod.Pod{}
+	for _, pod := range node.pods {
+		if (pod.IsRunning(clock) || pod.IsTerminating(clock)) && usesGPU(pod, gpu) {
+			victims = append(victims, pod)
+		}
+	}
+
+	node.gpus.GPUs[gpu].MIGProfiles = append([]string{}, profiles...)
+	if node.gpus.RepartitionDuration > 0 {
+		node.gpuReadyAt[gpu] = clock.Add(node.gpus.RepartitionDuration)
+	}
+	node.updateGPUAllocatable()
+
+	return victims, nil
+}
+
+// UpdateGPUs makes the GPUs whose repartitioning has completed by the given clock available.
+// Returns true if any GPU has become available.
+func (node *Node) UpdateGPUs(clock clock.Clock) bool {
+	updated := false
+	for gpu, readyAt := range node.gpuReadyAt {
+		if !clock.Before(readyAt) {
+			delete(node.gpuReadyAt, gpu)
+			updated = true
+		}
+	}
+
+	if updated {
+		node.updateGPUAllocatable()
+	}
+	return updated
+}
+
+// updateGPUAllocatable updates the allocatable (and capacity) resources of this Node with the
+// resources that its available GPUs provide.
+func (node *Node) updateGPUAllocatable() {
+	excluded := make(map[int]struct{}, len(node.gpuReadyAt))
+	for gpu := range node.gpuReadyAt {
+		excluded[gpu] = struct{}{}
+	}
+
+	status := &node.ToV1().Status
+	for _, list := range []v1.ResourceList{status.Allocatable, status.Capacity} {
+		for name := range list {
+			if strings.HasPrefix(string(name), MIGResourcePrefix) {
+				delete(list, name)
+			}
+		}
+	}
+
+	for name, quantity := range GPUResources(node.gpus.GPUs, excluded) {
+		if status.Allocatable == nil {
+			status.Allocatable = v1.ResourceList{}
+		}
+		status.Allocatable[name] = quantity
+		if status.Capacity != nil {
+			status.Capacity[name] = quantity
+		}
+	}
+}
+
+// allocateGPUs selects the GPUs and the MIG instances to be assigned to the pod, if this Node has
+// the GPU device model.
+// Returns false if they cannot be allocated.
+func (node *Node) allocateGPUs(clock clock.Clock, v1Pod *v1.Pod) ([]int, []pod.MIGDevice, bool) {
+	if node.gpus == nil {
+		return nil, nil, true
+	}
+
+	requests := util.PodTotalResourceRequests(v1Pod)
+
+	var gpus []int
+	if req, ok := requests[GPUResourceName]; ok && req.Value() > 0 {
+		allocator := node.gpuAllocator
+		if allocator == nil {
+			allocator = FirstFitAllocator{}
+		}
+
+		n := int(req.Value())
+		gpus, ok = allocator.Allocate(node.gpus.GPUs, node.freeGPUs(clock), n)
+		if !ok {
+			return nil, nil, false
+		}
+		if len(gpus) != n {
+			log.L.Warnf("Node %s: GPU allocator selected %d GPUs instead of %d", node.ToV1().Name, len(gpus), n)
+			return nil, nil, false
+		}
+	}
+
+	free := node.freeMIGDevices(clock)
+	migDevices := []pod.MIGDevice{}
+	for name, req := range requests {
+		if !strings.HasPrefix(string(name), MIGResourcePrefix) || req.Value() == 0 {
+			continue
+		}
+
+		profile := strings.TrimPrefix(string(name), MIGResourcePrefix)
+		n := int(req.Value())
+		if len(free[profile]) < n {
+			return nil, nil, false
+		}
+		migDevices = append(migDevices, free[profile][:n]...)
+	}
+	sort.Slice(migDevices, func(i, j int) bool {
+		if migDevices[i].GPU != migDevices[j].GPU {
+			return migDevices[i].GPU < migDevices[j].GPU
+		}
+		return migDevices[i].Index < migDevices[j].Index
+	})
+
+	return gpus, migDevices, true
+}
+
+// gpuSlowdown returns the slowdown factor of a pod assigned the given GPUs.
+func (node *Node) gpuSlowdown(gpus []int) float64 {
+	slowdown := 1.0
+	if node.gpus.CrossIslandSlowdown > 0 && IslandsNum(node.gpus.GPUs, gpus) > 1 {
+		slowdown *= node.gpus.CrossIslandSlowdown
+	}
+
+	if node.gpus.TimeSlicingSlowdown > 0 {
+		for _, gpu := range gpus {
+			if node.gpus.GPUs[gpu].replicas() > 1 {
+				slowdown *= node.gpus.TimeSlicingSlowdown
+				break
+			}
+		}
+	}
+
+	return slowdown
+}
+
+// freeGPUs returns the indices of the available GPUs without MIG, in ascending order, at the given
+// clock.
+// A GPU shared by time-slicing appears as many times as its replicas not assigned to pods running
+// or terminating.
+func (node *Node) freeGPUs(clock clock.Clock) []int {
+	used := map[int]int{}
+	for _, pod := range node.pods {
+		if pod.IsRunning(clock) || pod.IsTerminating(clock) {
+			for _, gpu := range pod.GPUs() {
+				used[gpu]++
+			}
+		}
+	}
+
+	free := []int{}
+	for i, gpu := range node.gpus.GPUs {
+		if _, ok := node.gpuReadyAt[i]; ok {
+			continue
+		}
+		for r := used[i]; r < gpu.replicas(); r++ {
+			free = append(free, i)
+		}
+	}
+
+	return free
+}
+
+// freeMIGDevices returns the available MIG instances not assigned to pods running or terminating at
+// the given clock, grouped by their profiles.
+func (node *Node) freeMIGDevices(clock clock.Clock) map[string][]pod.MIGDevice {
+	used := map[pod.MIGDevice]struct{}{}
+	for _, pod := range node.pods {
+		if pod.IsRunning(clock) || pod.IsTerminating(clock) {
+			for _, dev := range pod.MIGDevices() {
+				used[dev] = struct{}{}
+			}
+		}
+	}
+
+	free := map[string][]pod.MIGDevice{}
+	for i, gpu := range node.gpus.GPUs {
+		if _, ok := node.gpuReadyAt[i]; ok {
+			continue
+		}
+		for j, profile := range gpu.MIGProfiles {
+			dev := pod.MIGDevice{GPU: i, Profile: profile, Index: j}
+			if _, ok := used[dev]; !ok {
+				free[profile] = append(free[profile], dev)
+			}
+		}
+	}
+
+	return free
+}
+
+// freeGPUsByIsland returns the number of free GPUs (or their replicas) in each island at the given
+// clock, or nil if this Node does not have the GPU device model.
+func (node *Node) freeGPUsByIsland(clock clock.Clock) map[int]int {
+	if node.gpus == nil {
+		return nil
+	}
+
+	freeGPUs := map[int]int{}
+	for _, gpu := range node.gpus.GPUs {
+		freeGPUs[gpu.Island] = 0
+	}
+	for _, i := range node.freeGPUs(clock) {
+		freeGPUs[node.gpus.GPUs[i].Island]++
+	}
+
+	return freeGPUs
+}
+
+// freeMIGDevicesNum returns the number of free MIG instances of each profile at the given clock, or
+// nil if this Node has no MIG instances.
+func (node *Node) freeMIGDevicesNum(clock clock.Clock) map[string]int {
+	if node.gpus == nil {
+		return nil
+	}
+
+	var freeNum map[string]int
+	for profile, devs := range node.freeMIGDevices(clock) {
+		if freeNum == nil {
+			freeNum = map[string]int{}
+		}
+		freeNum[profile] = len(devs)
+	}
+
+	return freeNum
+}
+
+// drainingGPUs returns the indices of the GPUs being repartitioned, in ascending order.
+func (node *Node) drainingGPUs() []int {
+	var gpus []int
+	for gpu := range node.gpuReadyAt {
+		gpus = append(gpus, gpu)
+	}
+	sort.Ints(gpus)
+
+	return gpus
+}
+
+// usesGPU returns whether the pod is assigned the GPU or any of its MIG instances.
+func usesGPU(pod *pod.Pod, gpu int) bool {
+	for _, g := range pod.GPUs() {
+		if g == gpu {
+			return true
+		}
+	}
+	for _, dev := range pod.MIGDevices() {
+		if dev.GPU == gpu {
+			return true
+		}
+	}
+	return false
+}
+
 // runningAndTerminatingPodsV1WithStatus returns all running or terminating pods on this Node in
 // *v1.Pod representation at the given clock, with their status updated.
 func (node *Node) runningAndTerminatingPodsV1WithStatus(clock clock.Clock) []*v1.Pod {
@@ -201,6 +630,19 @@ func (node *Node) runningPodsNum(clock clock.Clock) int64 {
 	return num
 }
 
+// startingPodsNum returns the number of running pods on this Node whose containers have not started
+// at the given clock.
+func (node *Node) startingPodsNum(clock clock.Clock) int64 {
+	num := int64(0)
+	for _, pod := range node.pods {
+		if pod.IsStarting(clock) {
+			num++
+		}
+	}
+
+	return num
+}
+
 // terminatingPodsNum returns the number of all terminating pods no this Node at the given clock.
 func (node *Node) terminatingPodsNum(clock clock.Clock) int64 {
 	num := int64(0)
@@ -225,14 +667,50 @@ func (node *Node) bindingFailedPodsNum() int64 {
 	return num
 }
 
+// lostPodsNum returns the number of pods killed by failures of this Node.
+func (node *Node) lostPodsNum() int64 {
+	num := int64(0)
+	for _, pod := range node.pods {
+		if pod.IsLost() {
+			num++
+		}
+	}
+
+	return num
+}
+
+// evictedPodsNum returns the number of pods evicted by the kubelet of this Node.
+func (node *Node) evictedPodsNum() int64 {
+	num := int64(0)
+	for _, pod := range node.pods {
+		if pod.IsEvicted() {
+			num++
+		}
+	}
+
+	return num
+}
+
+// oomKilledPodsNum returns the number of pods killed by the OOM killer of this Node.
+func (node *Node) oomKilledPodsNum() int64 {
+	num := int64(0)
+	for _, pod := range node.pods {
+		if pod.IsOOMKilled() {
+			num++
+		}
+	}
+
+	return num
+}
+
 // totalResourceUsage calculates the total resource usage (not request) of all running or
-// terminating pods at the given clock.
+// terminating pods at the given clock, with their CPU usage throttled under contention.
 func (node *Node) totalResourceUsage(clock clock.Clock) v1.ResourceList {
+	cpuUsage := node.cpuUsage(clock)
+
 	total := v1.ResourceList{}
-	for _, pod := range node.pods {
-		if pod.IsRunning(clock) || pod.IsTerminating(clock) {
-			total = util.ResourceListSum(total, pod.ResourceUsage(clock))
-		}
+	for _, pod := range node.alivePods(clock) {
+		total = util.ResourceListSum(total, podResourceUsage(clock, pod, cpuUsage))
 	}
 
 	return total
@@ -0,0 +1,184 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+)
+
+// OperationType is a type of runtime operations on a node.
+type OperationType string
+
+const (
+	// Cordon marks the node unschedulable.
+	Cordon OperationType = "cordon"
+	// Uncordon marks the node schedulable, and cancels its drain.
+	Uncordon OperationType = "uncordon"
+	// Drain cordons the node, and evicts all pods on it.
+	Drain OperationType = "drain"
+	// AddTaint adds a taint to the node, replacing the one with the same key and effect.
+	AddTaint OperationType = "taint"
+	// RemoveTaint removes the taint with the same key and effect from the node.
+	RemoveTaint OperationType = "untaint"
+	// SetCondition sets the status of a condition of the node (e.g., Ready becomes False).
+	SetCondition OperationType = "condition"
+)
+
+// Operation is a runtime operation on a node.
+type Operation struct {
+	Type OperationType
+	// Taint is the taint to add or remove, used only if Type is AddTaint or RemoveTaint.
+	Taint *v1.Taint
+	// Condition is the condition to set, used only if Type is SetCondition.
+	// Its times are ignored.
+	Condition *v1.NodeCondition
+}
+
+// Cordon marks this Node unschedulable.
+func (node *Node) Cordon() {
+	node.ToV1().Spec.Unschedulable = true
+}
+
+// Uncordon marks this Node schedulable, and cancels its drain.
+func (node *Node) Uncordon() {
+	node.ToV1().Spec.Unschedulable = false
+	node.draining = false
+}
+
+// StartDrain cordons this Node and marks it draining, until FinishDrain or Uncordon is called.
+// The pods on the node are evicted by the caller, which may respect pod disruption budgets.
+func (node *Node) StartDrain() {
+	node.Cordon()
+	node.draining = true
+}
+
+// FinishDrain unmarks this Node draining, leaving it cordoned.
+func (node *Node) FinishDrain() {
+	node.draining = false
+}
+
+// IsDraining returns whether this Node is being drained.
+func (node *Node) IsDraining() bool {
+	return node.draining
+}
+
+// AddTaint adds the taint to this Node at the given clock, replacing the one with the same key and
+// effect.
+func (node *Node) AddTaint(clock clock.Clock, taint v1.Taint) {
+	if taint.TimeAdded == nil {
+		now := clock.ToMetaV1()
+		taint.TimeAdded = &now
+	}
+
+	node.RemoveTaint(taint)
+	node.ToV1().Spec.Taints = append(node.ToV1().Spec.Taints, taint)
+}
+
+// RemoveTaint removes the taint with the same key and effect as the given one from this Node.
+// Returns true if the taint is found.
+func (node *Node) RemoveTaint(taint v1.Taint) bool {
+	found := false
+	taints := make([]v1.Taint, 0, len(node.ToV1().Spec.Taints))
+	for _, t := range node.ToV1().Spec.Taints {
+		if t.MatchTaint(&taint) {
+			found = true
+			continue
+		}
+		taints = append(taints, t)
+	}
+
+	node.ToV1().Spec.Taints = taints
+	return found
+}
+
+// PodsToEvictByTaints returns the pods running on this Node at the given clock that do not tolerate
+// its NoExecute taints, or whose tolerationSeconds have elapsed since the taints were added, as the
+// taint manager of kubernetes does.
+func (node *Node) PodsToEvictByTaints(clock clock.Clock) []*pod.Pod {
+	noExecuteTaints := []v1.Taint{}
+	for _, taint := range node.ToV1().Spec.Taints {
+		if taint.Effect == v1.TaintEffectNoExecute {
+			noExecuteTaints = append(noExecuteTaints, taint)
+		}
+	}
+	if len(noExecuteTaints) == 0 {
+		return []*pod.Pod{}
+	}
+
+	victims := []*pod.Pod{}
+	for _, pod := range node.pods {
+		if !pod.IsRunning(clock) {
+			continue
+		}
+		if evictAt, ok := evictionClock(pod.ToV1(), noExecuteTaints); ok && !clock.Before(evictAt) {
+			victims = append(victims, pod)
+		}
+	}
+
+	return victims
+}
+
+// evictionClock returns the clock at which the pod should be evicted by the NoExecute taints.
+// Returns false if the pod tolerates the taints forever.
+func evictionClock(v1Pod *v1.Pod, taints []v1.Taint) (clock.Clock, bool) {
+	var evictAt clock.Clock
+	found := false
+
+	for i := range taints {
+		taint := &taints[i]
+		addedAt := clock.NewClockWithMetaV1(*taint.TimeAdded)
+
+		// As the taint manager of kubernetes does, the shortest tolerationSeconds of the tolerations
+		// applies, and the pod tolerates the taint forever only if none of them has tolerationSeconds.
+		var tolerationSeconds *int64
+		tolerated := false
+		for _, toleration := range v1Pod.Spec.Tolerations {
+			if !toleration.ToleratesTaint(taint) {
+				continue
+			}
+
+			tolerated = true
+			if toleration.TolerationSeconds == nil {
+				continue
+			}
+			if seconds := *toleration.TolerationSeconds; tolerationSeconds == nil || seconds < *tolerationSeconds {
+				if seconds < 0 {
+					seconds = 0
+				}
+				tolerationSeconds = &seconds
+			}
+		}
+
+		if tolerated && tolerationSeconds == nil {
+			continue
+		}
+
+		at := addedAt
+		if tolerated {
+			at = addedAt.Add(time.Duration(*tolerationSeconds) * time.Second)
+		}
+		if !found || at.Before(evictAt) {
+			evictAt = at
+			found = true
+		}
+	}
+
+	return evictAt, found
+}
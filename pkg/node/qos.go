@@ -0,0 +1,293 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// QOSMetrics is a metrics of the pods of one QoS class.
+type QOSMetrics struct {
+	RunningPodsNum       int64
+	EvictedPodsNum       int64
+	OOMKilledPodsNum     int64
+	TotalResourceRequest v1.ResourceList
+	TotalResourceUsage   v1.ResourceList
+}
+
+const (
+	// minCPUShares is the minimum cpu.shares of a container, given to BestEffort pods.
+	minCPUShares = 2
+	// guaranteedOOMScoreAdj is the oom_score_adj of the containers of Guaranteed pods.
+	guaranteedOOMScoreAdj = -998
+	// besteffortOOMScoreAdj is the oom_score_adj of the containers of BestEffort pods.
+	besteffortOOMScoreAdj = 1000
+)
+
+// EnableOOMKill enables the OOM killer of this Node, which kills pods exceeding their memory limits,
+// and pods in the order of their OOM scores while the total memory usage exceeds the allocatable
+// memory.
+func (node *Node) EnableOOMKill() {
+	node.oomKill = true
+}
+
+// KillOOMPods kills pods by the OOM killer at the given clock, if it is enabled.
+// As the kubelet sets oom_score_adj by QoS classes, Guaranteed pods are killed last, and BestEffort
+// pods first.
+// Returns the killed pods.
+func (node *Node) KillOOMPods(clk clock.Clock) []*pod.Pod {
+	killed := []*pod.Pod{}
+	if !node.oomKill || node.IsFailed() {
+		return killed
+	}
+
+	for _, p := range node.alivePods(clk) {
+		limit, ok := memoryLimit(p.ToV1())
+		usage := p.ResourceUsage(clk)[v1.ResourceMemory]
+		if ok && usage.Cmp(limit) > 0 && p.OOMKill(clk) {
+			killed = append(killed, p)
+		}
+	}
+
+	if capacity, ok := node.ToV1().Status.Allocatable[v1.ResourceMemory]; ok && !capacity.IsZero() {
+		for {
+			usage := node.totalResourceUsage(clk)[v1.ResourceMemory]
+			if usage.Cmp(capacity) <= 0 {
+				break
+			}
+
+			candidates := node.alivePods(clk)
+			if len(candidates) == 0 {
+				break
+			}
+			sort.SliceStable(candidates, func(i, j int) bool {
+				return oomScore(clk, candidates[i], capacity) > oomScore(clk, candidates[j], capacity)
+			})
+
+			if !candidates[0].OOMKill(clk) {
+				break
+			}
+			killed = append(killed, candidates[0])
+		}
+	}
+
+	for _, p := range killed {
+		p.ToV1().Status = p.BuildStatus(clk)
+	}
+
+	return killed
+}
+
+// PodsMetrics returns the metrics of the pods on this Node that have not terminated at the given
+// clock, with their CPU usage throttled under contention.
+// Returns error if a pod has an invalid name.
+func (node *Node) PodsMetrics(clk clock.Clock) (map[string]pod.Metrics, error) {
+	cpuUsage := node.cpuUsage(clk)
+
+	metrics := make(map[string]pod.Metrics, len(node.pods))
+	for _, p := range node.pods {
+		if p.IsTerminated(clk) {
+			continue
+		}
+
+		key, err := util.PodKey(p.ToV1())
+		if err != nil {
+			return nil, err
+		}
+		met := p.Metrics(clk)
+		met.ResourceUsage = podResourceUsage(clk, p, cpuUsage)
+		metrics[key] = met
+	}
+
+	return metrics, nil
+}
+
+// cpuUsage returns the CPU usage (in millicores) of each pod running or terminating on this Node at
+// the given clock.
+// If the total demand exceeds the allocatable CPU, the CPU is divided among the pods in proportion
+// to their cpu.shares (i.e., their CPU requests, or the minimum for BestEffort pods), and no pod
+// gets more than its demand.
+func (node *Node) cpuUsage(clk clock.Clock) map[*pod.Pod]int64 {
+	pods := node.alivePods(clk)
+	demands := make(map[*pod.Pod]int64, len(pods))
+	var totalDemand int64
+	for _, p := range pods {
+		usage := p.ResourceUsage(clk)[v1.ResourceCPU]
+		demands[p] = usage.MilliValue()
+		totalDemand += demands[p]
+	}
+
+	capacity, ok := node.ToV1().Status.Allocatable[v1.ResourceCPU]
+	if !ok || totalDemand <= capacity.MilliValue() {
+		return demands
+	}
+
+	// Water-filling in the ascending order of demand per share.
+	sort.SliceStable(pods, func(i, j int) bool {
+		return float64(demands[pods[i]])/float64(cpuShares(pods[i])) <
+			float64(demands[pods[j]])/float64(cpuShares(pods[j]))
+	})
+
+	remaining := capacity.MilliValue()
+	var sharesSum int64
+	for _, p := range pods {
+		sharesSum += cpuShares(p)
+	}
+
+	usage := make(map[*pod.Pod]int64, len(pods))
+	for i, p := range pods {
+		if demands[p]*sharesSum <= remaining*cpuShares(p) {
+			usage[p] = demands[p]
+			remaining -= demands[p]
+			sharesSum -= cpuShares(p)
+			continue
+		}
+
+		for _, q := range pods[i:] {
+			usage[q] = remaining * cpuShares(q) / sharesSum
+		}
+		break
+	}
+
+	return usage
+}
+
+// qosMetrics returns the metrics of the pods of each QoS class on this Node at the given clock.
+func (node *Node) qosMetrics(clk clock.Clock) map[v1.PodQOSClass]QOSMetrics {
+	cpuUsage := node.cpuUsage(clk)
+
+	metrics := map[v1.PodQOSClass]QOSMetrics{}
+	for _, p := range node.pods {
+		met := metrics[p.QOSClass()]
+
+		switch {
+		case p.IsRunning(clk):
+			met.RunningPodsNum++
+		case p.IsEvicted():
+			met.EvictedPodsNum++
+		case p.IsOOMKilled():
+			met.OOMKilledPodsNum++
+		}
+
+		if p.IsRunning(clk) || p.IsTerminating(clk) {
+			met.TotalResourceRequest = util.ResourceListSum(met.TotalResourceRequest, p.TotalResourceRequests())
+			met.TotalResourceUsage = util.ResourceListSum(met.TotalResourceUsage, podResourceUsage(clk, p, cpuUsage))
+		}
+
+		metrics[p.QOSClass()] = met
+	}
+
+	return metrics
+}
+
+// alivePods returns the pods running or terminating on this Node at the given clock.
+func (node *Node) alivePods(clk clock.Clock) []*pod.Pod {
+	pods := []*pod.Pod{}
+	for _, p := range node.pods {
+		if p.IsRunning(clk) || p.IsTerminating(clk) {
+			pods = append(pods, p)
+		}
+	}
+
+	return pods
+}
+
+// podResourceUsage returns the resource usage of the pod with the CPU usage given by cpuUsage.
+func podResourceUsage(clk clock.Clock, p *pod.Pod, cpuUsage map[*pod.Pod]int64) v1.ResourceList {
+	usage := p.ResourceUsage(clk)
+	cpu, ok := cpuUsage[p]
+	if _, has := usage[v1.ResourceCPU]; !ok || !has {
+		return usage
+	}
+
+	throttled := make(v1.ResourceList, len(usage))
+	for name, quantity := range usage {
+		throttled[name] = quantity
+	}
+	throttled[v1.ResourceCPU] = *resource.NewMilliQuantity(cpu, resource.DecimalSI)
+
+	return throttled
+}
+
+// cpuShares returns the cpu.shares of the pod, computed from its CPU request as the kubelet does.
+func cpuShares(p *pod.Pod) int64 {
+	if p.QOSClass() == v1.PodQOSBestEffort {
+		return minCPUShares
+	}
+
+	request := p.TotalResourceRequests()[v1.ResourceCPU]
+	shares := request.MilliValue() * 1024 / 1000
+	if shares < minCPUShares {
+		return minCPUShares
+	}
+	return shares
+}
+
+// oomScore returns the OOM score of the pod, i.e., its memory usage in permille of the capacity plus
+// its oom_score_adj, which the kubelet sets by its QoS class.
+func oomScore(clk clock.Clock, p *pod.Pod, capacity resource.Quantity) int64 {
+	usage := p.ResourceUsage(clk)[v1.ResourceMemory]
+	score := usage.Value() * 1000 / capacity.Value()
+
+	switch p.QOSClass() {
+	case v1.PodQOSGuaranteed:
+		return score + guaranteedOOMScoreAdj
+	case v1.PodQOSBestEffort:
+		return score + besteffortOOMScoreAdj
+	default:
+		request := p.TotalResourceRequests()[v1.ResourceMemory]
+		adj := 1000 - request.Value()*1000/capacity.Value()
+		if adj < 2 {
+			adj = 2
+		} else if adj > 999 {
+			adj = 999
+		}
+		return score + adj
+	}
+}
+
+// memoryLimit returns the memory limit of the pod, as the kubelet sets it to the cgroup of the pod:
+// the larger of the total limit of its containers and the largest limit of its init containers,
+// which run one at a time before them.
+// Returns false if any container or init container of the pod has no memory limit.
+func memoryLimit(v1Pod *v1.Pod) (resource.Quantity, bool) {
+	total := resource.Quantity{}
+	for _, container := range v1Pod.Spec.Containers {
+		limit, ok := container.Resources.Limits[v1.ResourceMemory]
+		if !ok || limit.IsZero() {
+			return resource.Quantity{}, false
+		}
+		total.Add(limit)
+	}
+
+	for _, container := range v1Pod.Spec.InitContainers {
+		limit, ok := container.Resources.Limits[v1.ResourceMemory]
+		if !ok || limit.IsZero() {
+			return resource.Quantity{}, false
+		}
+		if limit.Cmp(total) > 0 {
+			total = limit
+		}
+	}
+
+	return total, len(v1Pod.Spec.Containers) > 0
+}
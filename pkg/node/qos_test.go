@@ -0,0 +1,269 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// cpuMemory returns the resource list of the cpu and the memory, omitting empty ones.
+func cpuMemory(cpu, memory string) v1.ResourceList {
+	resources := v1.ResourceList{}
+	if cpu != "" {
+		resources["cpu"] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		resources["memory"] = resource.MustParse(memory)
+	}
+	return resources
+}
+
+func TestCPUUsageUnderContention(t *testing.T) {
+	type testPod struct {
+		name             string
+		requests, limits v1.ResourceList
+		demand           string
+	}
+
+	tests := []struct {
+		name string
+		pods []testPod
+		// want is the CPU usage of each pod in millicores.
+		want map[string]int64
+	}{
+		{
+			name: "no contention",
+			pods: []testPod{
+				{"guaranteed", cpuMemory("1", "1Gi"), cpuMemory("1", "1Gi"), "1"},
+				{"burstable", cpuMemory("1", ""), nil, "2"},
+				{"besteffort", nil, nil, "1"},
+			},
+			want: map[string]int64{"guaranteed": 1000, "burstable": 2000, "besteffort": 1000},
+		},
+		{
+			// The Guaranteed pod demands less than its share and gets its demand. The rest is divided
+			// between the Burstable pod (1024 shares) and the BestEffort pod (2 shares).
+			name: "all QoS classes",
+			pods: []testPod{
+				{"guaranteed", cpuMemory("1", "1Gi"), cpuMemory("1", "1Gi"), "1"},
+				{"burstable", cpuMemory("1", ""), nil, "3"},
+				{"besteffort", nil, nil, "2"},
+			},
+			want: map[string]int64{"guaranteed": 1000, "burstable": 3000 * 1024 / 1026, "besteffort": 3000 * 2 / 1026},
+		},
+		{
+			// Both pods demand more than their shares, so the CPU is divided in proportion to their
+			// requests.
+			name: "proportional to requests",
+			pods: []testPod{
+				{"burstable-small", cpuMemory("1", ""), nil, "4"},
+				{"burstable-large", cpuMemory("3", ""), nil, "4"},
+			},
+			want: map[string]int64{"burstable-small": 1000, "burstable-large": 3000},
+		},
+		{
+			// The BestEffort pod that demands little gets its demand even under contention.
+			name: "small BestEffort demand",
+			pods: []testPod{
+				{"burstable", cpuMemory("2", ""), nil, "5"},
+				{"besteffort", nil, nil, "1m"},
+			},
+			want: map[string]int64{"burstable": 3999, "besteffort": 1},
+		},
+	}
+
+	for _, test := range tests {
+		node := newTestNode("node-0", cpuMemory("4", "16Gi"))
+		node.ToV1().Status.Allocatable["pods"] = resource.MustParse("10")
+		for _, p := range test.pods {
+			if _, err := node.BindPod(testStartClock, newTestPod(p.name, 0, p.requests, p.limits, cpuMemory(p.demand, ""), 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		metrics, err := node.PodsMetrics(testStartClock.Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range test.want {
+			usage := metrics["default/"+name].ResourceUsage[v1.ResourceCPU]
+			if usage.MilliValue() != want {
+				t.Errorf("%s: %s: got: %dm\nwant: %dm", test.name, name, usage.MilliValue(), want)
+			}
+		}
+	}
+}
+
+func TestOOMScore(t *testing.T) {
+	// The scores are computed against the capacity of 10Gi, while the node accepts all the pods.
+	node := newTestNode("node-0", cpuMemory("4", "100Gi"))
+	node.ToV1().Status.Allocatable["pods"] = resource.MustParse("10")
+	capacity := resource.MustParse("10Gi")
+
+	tests := []struct {
+		name             string
+		requests, limits v1.ResourceList
+		usage            string
+		want             int64
+	}{
+		{"guaranteed", cpuMemory("1", "5Gi"), cpuMemory("1", "5Gi"), "5Gi", 500 - 998},
+		{"burstable-small-request", cpuMemory("", "1Gi"), nil, "2Gi", 200 + 900},
+		{"burstable-large-request", cpuMemory("", "5Gi"), nil, "5Gi", 500 + 500},
+		// oom_score_adj of Burstable pods is at least 2, even if they request all the memory.
+		{"burstable-full-request", cpuMemory("", "10Gi"), nil, "1Gi", 100 + 2},
+		{"besteffort", nil, nil, "1Gi", 100 + 1000},
+	}
+
+	for _, test := range tests {
+		p, err := node.BindPod(testStartClock, newTestPod(test.name, 0, test.requests, test.limits, cpuMemory("", test.usage), 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if score := oomScore(testStartClock.Add(time.Second), p, capacity); score != test.want {
+			t.Errorf("%s: got: %d\nwant: %d", test.name, score, test.want)
+		}
+	}
+}
+
+func TestKillOOMPods(t *testing.T) {
+	type testPod struct {
+		name             string
+		requests, limits v1.ResourceList
+		usage            string
+	}
+
+	tests := []struct {
+		name string
+		pods []testPod
+		want []string
+	}{
+		{
+			name: "under capacity",
+			pods: []testPod{
+				{"guaranteed", cpuMemory("1", "1Gi"), cpuMemory("1", "1Gi"), "1Gi"},
+				{"besteffort", nil, nil, "2Gi"},
+			},
+			want: []string{},
+		},
+		{
+			// The BestEffort pod has the highest OOM score (500 + 1000) and is killed first, after which
+			// the usage fits the capacity.
+			name: "over capacity",
+			pods: []testPod{
+				{"guaranteed", cpuMemory("1", "1Gi"), cpuMemory("1", "1Gi"), "1Gi"},
+				{"burstable", cpuMemory("", "1Gi"), nil, "2Gi"},
+				{"besteffort", nil, nil, "2Gi"},
+			},
+			want: []string{"besteffort"},
+		},
+		{
+			// The Burstable pod uses more memory than the BestEffort pod, so that it has a higher OOM
+			// score (750 + 750 > 250 + 1000), and its kill alone relieves the node.
+			name: "Burstable with large usage",
+			pods: []testPod{
+				{"burstable", cpuMemory("", "1Gi"), nil, "3Gi"},
+				{"besteffort", nil, nil, "1Gi"},
+				{"guaranteed", cpuMemory("1", "1Gi"), cpuMemory("1", "1Gi"), "1Gi"},
+			},
+			want: []string{"burstable"},
+		},
+		{
+			// Among the pods of the same QoS class, the one using more memory is killed first.
+			name: "BestEffort with large usage",
+			pods: []testPod{
+				{"besteffort-small", nil, nil, "1Gi"},
+				{"besteffort-large", nil, nil, "2Gi"},
+				{"guaranteed", cpuMemory("1", "2Gi"), cpuMemory("1", "2Gi"), "2Gi"},
+			},
+			want: []string{"besteffort-large"},
+		},
+		{
+			// A pod exceeding its own memory limit is killed, even if the node has enough memory.
+			name: "over limit",
+			pods: []testPod{
+				{"burstable", cpuMemory("", "1Gi"), cpuMemory("", "2Gi"), "3Gi"},
+				{"besteffort", nil, nil, "1Gi"},
+			},
+			want: []string{"burstable"},
+		},
+	}
+
+	for _, test := range tests {
+		node := newTestNode("node-0", cpuMemory("4", "4Gi"))
+		node.ToV1().Status.Allocatable["pods"] = resource.MustParse("10")
+		node.EnableOOMKill()
+		for _, p := range test.pods {
+			v1Pod := newTestPod(p.name, 0, p.requests, p.limits, cpuMemory("", p.usage), 100)
+			if _, err := node.BindPod(testStartClock, v1Pod); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		killed := []string{}
+		for _, p := range node.KillOOMPods(testStartClock.Add(time.Second)) {
+			killed = append(killed, p.ToV1().Name)
+		}
+		if len(killed) != len(test.want) {
+			t.Errorf("%s: got: %v\nwant: %v", test.name, killed, test.want)
+			continue
+		}
+		for i := range killed {
+			if killed[i] != test.want[i] {
+				t.Errorf("%s: got: %v\nwant: %v", test.name, killed, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		containers     []v1.ResourceList
+		initContainers []v1.ResourceList
+		want           string
+		wantOK         bool
+	}{
+		{"containers", []v1.ResourceList{cpuMemory("", "1Gi"), cpuMemory("", "2Gi")}, nil, "3Gi", true},
+		{"container without limit", []v1.ResourceList{cpuMemory("", "1Gi"), cpuMemory("1", "")}, nil, "0", false},
+		{"smaller init container", []v1.ResourceList{cpuMemory("", "2Gi")}, []v1.ResourceList{cpuMemory("", "1Gi")}, "2Gi", true},
+		{"larger init container", []v1.ResourceList{cpuMemory("", "2Gi")},
+			[]v1.ResourceList{cpuMemory("", "1Gi"), cpuMemory("", "4Gi")}, "4Gi", true},
+		{"init container without limit", []v1.ResourceList{cpuMemory("", "2Gi")}, []v1.ResourceList{nil}, "0", false},
+		{"no containers", nil, nil, "0", false},
+	}
+
+	for _, test := range tests {
+		v1Pod := &v1.Pod{}
+		for _, limits := range test.containers {
+			v1Pod.Spec.Containers = append(v1Pod.Spec.Containers,
+				v1.Container{Resources: v1.ResourceRequirements{Limits: limits}})
+		}
+		for _, limits := range test.initContainers {
+			v1Pod.Spec.InitContainers = append(v1Pod.Spec.InitContainers,
+				v1.Container{Resources: v1.ResourceRequirements{Limits: limits}})
+		}
+
+		limit, ok := memoryLimit(v1Pod)
+		if want := resource.MustParse(test.want); ok != test.wantOK || limit.Cmp(want) != 0 {
+			t.Errorf("%s: got: %s, %v\nwant: %s, %v", test.name, limit.String(), ok, test.want, test.wantOK)
+		}
+	}
+}
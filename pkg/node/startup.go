@@ -0,0 +1,212 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/strongerrors"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+)
+
+// StartupModel is the model of the startup latency of pods on a node, i.e., the time to pull their
+// images, run their init containers, and create their containers.
+type StartupModel struct {
+	// ImagePullBandwidth is the bandwidth in bytes per second with which the node pulls images, one
+	// at a time as the kubelet does by default.
+	// Images are pulled instantly if it is zero.
+	ImagePullBandwidth int64
+	// ImageSizes is the sizes in bytes of the images whose sizes are not annotated in pods.
+	// Images of unknown sizes are pulled instantly.
+	ImageSizes map[string]int64
+	// ContainerStartDuration is the time to create and start the containers of a pod after its
+	// init containers.
+	ContainerStartDuration time.Duration
+}
+
+// imageState is the state of an image on a node.
+type imageState struct {
+	size int64
+	// pulledAt is the clock at which the image has been pulled on the node.
+	pulledAt clock.Clock
+}
+
+// SetStartupModel enables the startup latency of pods on this Node with the model.
+func (node *Node) SetStartupModel(model StartupModel) {
+	sizes := make(map[string]int64, len(model.ImageSizes))
+	for image, size := range model.ImageSizes {
+		sizes[normalizedImageName(image)] = size
+	}
+	model.ImageSizes = sizes
+
+	node.startup = &model
+}
+
+// AddImage adds the image to the image cache of this Node, as if it had been pulled before the
+// simulation.
+// Its size is taken from the startup model of this Node, if any.
+func (node *Node) AddImage(image string) {
+	name := normalizedImageName(image)
+
+	var size int64
+	if node.startup != nil {
+		size = node.startup.ImageSizes[name]
+	}
+	node.images[name] = imageState{size: size}
+}
+
+// ImageSizes returns the sizes in bytes of the images that have been pulled on this Node by the
+// given clock.
+func (node *Node) ImageSizes(clk clock.Clock) map[string]int64 {
+	sizes := make(map[string]int64, len(node.images))
+	for name, state := range node.images {
+		if !clk.Before(state.pulledAt) {
+			sizes[name] = state.size
+		}
+	}
+	return sizes
+}
+
+// startPod sets the startup of the pod bound to this Node at the given clock, pulling its images
+// that this Node has not pulled once its volumes have been attached in attachDuration.
+// Returns error if the pod has invalid image sizes.
+func (node *Node) startPod(clk clock.Clock, p *pod.Pod, attachDuration time.Duration) error {
+	attachedAt := clk.Add(attachDuration)
+	if node.startup == nil {
+		p.SetStartup(attachedAt, 0)
+		return nil
+	}
+
+	pulledAt, err := node.pullImages(attachedAt, p.ToV1())
+	if err != nil {
+		return err
+	}
+	p.SetStartup(pulledAt, node.startup.ContainerStartDuration)
+
+	return nil
+}
+
+// pullImages pulls the images of the pod that this Node has neither pulled nor been pulling at the
+// given clock, after the images being pulled.
+// Returns the clock at which all images of the pod have been pulled, or error if the pod has invalid
+// image sizes.
+func (node *Node) pullImages(clk clock.Clock, v1Pod *v1.Pod) (clock.Clock, error) {
+	annotatedSizes, err := podImageSizes(v1Pod)
+	if err != nil {
+		return clk, err
+	}
+
+	containers := append(append([]v1.Container{}, v1Pod.Spec.InitContainers...), v1Pod.Spec.Containers...)
+
+	pulledAt := clk
+	for _, container := range containers {
+		if container.Image == "" {
+			continue
+		}
+		name := normalizedImageName(container.Image)
+
+		state, ok := node.images[name]
+		if !ok {
+			size, ok := annotatedSizes[name]
+			if !ok {
+				size = node.startup.ImageSizes[name]
+			}
+
+			pullAt := clk
+			if clk.Before(node.pullingUntil) {
+				pullAt = node.pullingUntil
+			}
+
+			state = imageState{size: size, pulledAt: pullAt.Add(node.pullDuration(size))}
+			node.images[name] = state
+			node.pullingUntil = state.pulledAt
+		}
+
+		if pulledAt.Before(state.pulledAt) {
+			pulledAt = state.pulledAt
+		}
+	}
+
+	return pulledAt, nil
+}
+
+// pullDuration returns the time to pull an image of the size in bytes on this Node.
+func (node *Node) pullDuration(size int64) time.Duration {
+	if node.startup.ImagePullBandwidth <= 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / float64(node.startup.ImagePullBandwidth) * float64(time.Second))
+}
+
+// updateImagesStatus updates the images in the status of this Node with the ones pulled by the given
+// clock, in the descending order of their sizes as the kubelet reports.
+func (node *Node) updateImagesStatus(clk clock.Clock) {
+	sizes := node.ImageSizes(clk)
+	images := make([]v1.ContainerImage, 0, len(sizes))
+	for name, size := range sizes {
+		images = append(images, v1.ContainerImage{Names: []string{name}, SizeBytes: size})
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].SizeBytes != images[j].SizeBytes {
+			return images[i].SizeBytes > images[j].SizeBytes
+		}
+		return images[i].Names[0] < images[j].Names[0]
+	})
+
+	node.ToV1().Status.Images = images
+}
+
+// podImageSizes parses the pod's "simImageSizes" annotation, a YAML map from images to their sizes
+// (e.g., 100Mi).
+// Returns error if failed to parse.
+func podImageSizes(v1Pod *v1.Pod) (map[string]int64, error) {
+	sizesAnnot, ok := v1Pod.Annotations["simImageSizes"]
+	if !ok {
+		return map[string]int64{}, nil
+	}
+
+	sizesYAML := map[string]string{}
+	if err := yaml.Unmarshal([]byte(sizesAnnot), &sizesYAML); err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(sizesYAML))
+	for image, sizeStr := range sizesYAML {
+		size, err := resource.ParseQuantity(sizeStr)
+		if err != nil || size.Sign() < 0 {
+			return nil, strongerrors.InvalidArgument(errors.Errorf("Invalid size %q of image %q", sizeStr, image))
+		}
+		sizes[normalizedImageName(image)] = size.Value()
+	}
+
+	return sizes, nil
+}
+
+// normalizedImageName returns the image name with the default tag "latest" if it has no tag nor
+// digest, as ImageLocalityPriority does.
+func normalizedImageName(name string) string {
+	if strings.LastIndex(name, ":") <= strings.LastIndex(name, "/") {
+		name = name + ":latest"
+	}
+	return name
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// TopologyLevel is a level of the failure domains that nodes belong to.
+type TopologyLevel string
+
+const (
+	// Region is the topology level of regions, which consist of zones.
+	Region TopologyLevel = "region"
+	// Zone is the topology level of zones, which consist of racks.
+	Zone TopologyLevel = "zone"
+	// Rack is the topology level of racks, which consist of hosts.
+	Rack TopologyLevel = "rack"
+	// Host is the topology level of individual nodes.
+	Host TopologyLevel = "host"
+)
+
+// TopologyLevels is the list of all topology levels, from the largest to the smallest.
+var TopologyLevels = []TopologyLevel{Region, Zone, Rack, Host}
+
+const (
+	// RegionLabel is the label of a node that specifies its region.
+	RegionLabel = "topology.kubernetes.io/region"
+	// ZoneLabel is the label of a node that specifies its zone.
+	ZoneLabel = "topology.kubernetes.io/zone"
+	// RackLabel is the label of a node that specifies its rack.
+	// Kubernetes has no well-known label for racks.
+	RackLabel = "topology.kubernetes.io/rack"
+)
+
+// Label returns the label of a node that specifies its domain at this topology level.
+// Returns an empty string if the level is unknown.
+func (level TopologyLevel) Label() string {
+	switch level {
+	case Region:
+		return RegionLabel
+	case Zone:
+		return ZoneLabel
+	case Rack:
+		return RackLabel
+	case Host:
+		return v1.LabelHostname
+	default:
+		return ""
+	}
+}
+
+// IsValid returns whether this is one of TopologyLevels.
+func (level TopologyLevel) IsValid() bool {
+	return level.Label() != ""
+}
+
+// DomainOf returns the domain of the node at the topology level, given by the label of the level.
+// Returns false if the node does not have the label.
+func DomainOf(node *v1.Node, level TopologyLevel) (string, bool) {
+	domain, ok := node.Labels[level.Label()]
+	return domain, ok && domain != ""
+}
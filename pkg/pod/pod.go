@@ -16,10 +16,12 @@ package pod
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/containerd/containerd/log"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/apis/core/v1/helper/qos"
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
@@ -30,8 +32,30 @@ type Pod struct {
 	v1      *v1.Pod
 	spec    spec
 	boundAt clock.Clock
-	status  Status
-	node    string
+	// killedAt is the clock at which this Pod was lost, evicted, or OOM-killed.
+	killedAt clock.Clock
+	status   Status
+	node     string
+	// evictionMessage is the reason why this Pod was evicted by the kubelet.
+	evictionMessage string
+	// qosClass is the QoS class of this Pod, computed when it is bound.
+	qosClass v1.PodQOSClass
+
+	// initSpecs is the specs of the init containers of this Pod, in order.
+	initSpecs []spec
+	// imagesPulledAt is the clock at which the images of this Pod have been pulled on its node, and
+	// its init containers start.
+	imagesPulledAt clock.Clock
+	// startAt is the clock at which the containers of this Pod start, after its init containers and
+	// the creation of the containers.
+	startAt clock.Clock
+
+	// gpus is the indices of the GPU devices assigned to this Pod.
+	gpus []int
+	// migDevices is the MIG instances assigned to this Pod.
+	migDevices []MIGDevice
+	// slowdown is the factor by which the execution of this Pod is stretched.
+	slowdown float64
 }
 
 // Metrics is a metrics of a pod at one time point.
@@ -41,11 +65,28 @@ type Metrics struct {
 	ResourceUsage   v1.ResourceList
 
 	BoundAt         clock.Clock
+	StartedAt       clock.Clock
 	Node            string
 	ExecutedSeconds int32
 
 	Priority int32
+	QOSClass v1.PodQOSClass
 	Status   Status
+	// Phase is the phase of the pod, which is empty if the pod has been deleted.
+	Phase v1.PodPhase `json:",omitempty"`
+
+	GPUs       []int       `json:",omitempty"`
+	MIGDevices []MIGDevice `json:",omitempty"`
+}
+
+// MIGDevice is a MIG instance of a GPU device.
+type MIGDevice struct {
+	// GPU is the index of the GPU.
+	GPU int
+	// Profile is the MIG profile of the instance (e.g., "1g.5gb").
+	Profile string
+	// Index is the index of the instance in the partition layout of the GPU.
+	Index int
 }
 
 // Status represents status of a Pod.
@@ -64,6 +105,16 @@ const (
 
 	// OverCapacity indicates that the pod failed to start due to over capacity.
 	OverCapacity
+
+	// NodeLost indicates that the pod was killed by a failure of its node.
+	NodeLost
+
+	// Evicted indicates that the pod was killed by the kubelet of its node under resource pressure.
+	Evicted
+
+	// OOMKilled indicates that the pod was killed by the OOM killer of its node, because it exceeded
+	// its memory limit or the node ran out of memory.
+	OOMKilled
 )
 
 // String implements Stringer interface.
@@ -75,6 +126,12 @@ func (status Status) String() string {
 		return "Deleted"
 	case OverCapacity:
 		return "OverCapacity"
+	case NodeLost:
+		return "NodeLost"
+	case Evicted:
+		return "Evicted"
+	case OOMKilled:
+		return "OOMKilled"
 	default:
 		log.L.Panic("Unknown pod.Status")
 		return ""
@@ -86,6 +143,24 @@ func (status Status) MarshalJSON() ([]byte, error) {
 	return json.Marshal(status.String())
 }
 
+// UnmarshalJSON implements json.Unmarshaler interface.
+// Returns error if the value is not the string of any Status.
+func (status *Status) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	for s := Ok; s <= OOMKilled; s++ {
+		if s.String() == str {
+			*status = s
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown pod status %q", str)
+}
+
 // NewPod creates a pod with the given v1.Pod, the clock at which the pod was bound to a node, and
 // the pod's status.
 // Returns error if fails to parse the simulation spec of the pod.
@@ -94,14 +169,27 @@ func NewPod(pod *v1.Pod, boundAt clock.Clock, status Status, node string) (*Pod,
 	if err != nil {
 		return nil, err
 	}
+	initSpecs, err := parseInitSpecs(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	simPod := &Pod{
+		v1:       pod,
+		spec:     spec,
+		boundAt:  boundAt,
+		killedAt: boundAt, // used only if status is NodeLost, Evicted, or OOMKilled
+		status:   status,
+		node:     node,
+		qosClass: qos.GetPodQOS(pod),
+
+		initSpecs: initSpecs,
 
-	return &Pod{
-		v1:      pod,
-		spec:    spec,
-		boundAt: boundAt,
-		status:  status,
-		node:    node,
-	}, nil
+		slowdown: 1.0,
+	}
+	simPod.SetStartup(boundAt, 0)
+
+	return simPod, nil
 }
 
 // ToV1 returns v1.Pod representation of this Pod.
@@ -117,11 +205,17 @@ func (pod *Pod) Metrics(clock clock.Clock) Metrics {
 		ResourceUsage:   pod.ResourceUsage(clock),
 
 		BoundAt:         pod.boundAt,
+		StartedAt:       pod.startAt,
 		Node:            pod.node,
 		ExecutedSeconds: int32(pod.executedDuration(clock).Seconds()),
 
 		Priority: util.PodPriority(pod.ToV1()),
+		QOSClass: pod.qosClass,
 		Status:   pod.status,
+		Phase:    pod.Phase(clock),
+
+		GPUs:       pod.gpus,
+		MIGDevices: pod.migDevices,
 	}
 }
 
@@ -145,12 +239,15 @@ func (pod *Pod) ResourceUsage(clock clock.Clock) v1.ResourceList {
 		// pod is not using resource
 		return v1.ResourceList{}
 	}
+	if at := pod.startingClock(clock); at.Before(pod.startAt) {
+		return pod.initResourceUsage(at)
+	}
 
-	executedSeconds := int32(pod.executedDuration(clock).Seconds())
-	phaseDurationAcc := int32(0)
+	executed := pod.executedDuration(clock)
+	phaseDurationAcc := time.Duration(0)
 	for _, phase := range pod.spec {
-		phaseDurationAcc += phase.seconds
-		if executedSeconds < phaseDurationAcc {
+		phaseDurationAcc += pod.scaledDuration(phase.seconds)
+		if executed < phaseDurationAcc {
 			return phase.resourceUsage
 		}
 	}
@@ -159,16 +256,65 @@ func (pod *Pod) ResourceUsage(clock clock.Clock) v1.ResourceList {
 	return v1.ResourceList{}
 }
 
-// IsRunning returns whether this Pod is running at the given clock.
+// IsRunning returns whether this Pod is running at the given clock, including its startup.
 // Returns false if this Pod has failed to start.
 func (pod *Pod) IsRunning(clock clock.Clock) bool {
-	return pod.status == Ok && pod.executedDuration(clock) < pod.totalExecutionDuration()
+	return pod.status == Ok &&
+		(clock.Before(pod.startAt) || pod.executedDuration(clock) < pod.totalExecutionDuration())
+}
+
+// IsStarting returns whether this Pod is running at the given clock but its containers have not
+// started yet, i.e., its images are being pulled, its init containers are running, or its containers
+// are being created.
+func (pod *Pod) IsStarting(clock clock.Clock) bool {
+	return pod.IsRunning(clock) && clock.Before(pod.startAt)
+}
+
+// SetStartup sets the startup of this Pod, in which its init containers run in order once its
+// images have been pulled at imagesPulledAt, and its containers start after them and the container
+// start overhead.
+func (pod *Pod) SetStartup(imagesPulledAt clock.Clock, containerStart time.Duration) {
+	pod.imagesPulledAt = imagesPulledAt
+	pod.startAt = pod.initializedAt().Add(containerStart)
+}
+
+// StartAt returns the clock at which the containers of this Pod start.
+func (pod *Pod) StartAt() clock.Clock {
+	return pod.startAt
+}
+
+// FinishedAt returns the clock at which this Pod finished its execution spontaneously.
+// Returns false if it has not finished by the given clock (e.g., it is running, or has been deleted
+// or killed).
+func (pod *Pod) FinishedAt(clock clock.Clock) (clock.Clock, bool) {
+	if !pod.IsTerminated(clock) {
+		return clock, false
+	}
+	return pod.finishAt(), true
+}
+
+// SpecDuration returns the execution duration of this Pod given by its spec, i.e., without the
+// startup and the slowdown.
+func (pod *Pod) SpecDuration() time.Duration {
+	phaseSecondsTotal := int32(0)
+	for _, phase := range pod.spec {
+		phaseSecondsTotal += phase.seconds
+	}
+	return time.Duration(phaseSecondsTotal) * time.Second
+}
+
+// Phase returns the phase of this Pod at the given clock, or an empty phase if it has been deleted.
+func (pod *Pod) Phase(clock clock.Clock) v1.PodPhase {
+	if pod.IsDeleted(clock) {
+		return ""
+	}
+	return pod.BuildStatus(clock).Phase
 }
 
 // IsTerminated returns whether this Pod is terminated at the clock.
 // If this Pod failed to start, false is returned.
 func (pod *Pod) IsTerminated(clock clock.Clock) bool {
-	return pod.status == Ok && pod.executedDuration(clock) >= pod.totalExecutionDuration()
+	return pod.status == Ok && !pod.IsRunning(clock)
 }
 
 // IsTerminating returns whether this Pod is terminating (i.e. in its grace period).
@@ -189,7 +335,7 @@ func (pod *Pod) IsDeleted(clk clock.Clock) bool {
 
 // Delete starts to delete this Pod.
 func (pod *Pod) Delete(clock clock.Clock) {
-	if pod.IsTerminated(clock) || pod.status == Deleted {
+	if pod.IsTerminated(clock) || pod.status == Deleted || pod.IsKilled() {
 		return
 	}
 
@@ -200,6 +346,93 @@ func (pod *Pod) Delete(clock clock.Clock) {
 	pod.ToV1().DeletionTimestamp = &deletedAt
 }
 
+// Lose kills this Pod at the given clock due to a failure of its node, if it is running or
+// terminating.
+// Returns true if the pod is killed, or false otherwise.
+func (pod *Pod) Lose(clock clock.Clock) bool {
+	if !(pod.IsRunning(clock) || pod.IsTerminating(clock)) {
+		return false
+	}
+
+	pod.status = NodeLost
+	pod.killedAt = clock
+	return true
+}
+
+// IsLost returns whether this Pod has been killed by a failure of its node.
+func (pod *Pod) IsLost() bool {
+	return pod.status == NodeLost
+}
+
+// Evict kills this Pod at the given clock by the kubelet of its node under resource pressure, if it
+// is running or terminating.
+// The message tells the reason of the eviction.
+// Returns true if the pod is killed, or false otherwise.
+func (pod *Pod) Evict(clock clock.Clock, message string) bool {
+	if !(pod.IsRunning(clock) || pod.IsTerminating(clock)) {
+		return false
+	}
+
+	pod.status = Evicted
+	pod.killedAt = clock
+	pod.evictionMessage = message
+	return true
+}
+
+// IsEvicted returns whether this Pod has been evicted by the kubelet of its node.
+func (pod *Pod) IsEvicted() bool {
+	return pod.status == Evicted
+}
+
+// OOMKill kills this Pod at the given clock by the OOM killer of its node, if it is running or
+// terminating.
+// Returns true if the pod is killed, or false otherwise.
+func (pod *Pod) OOMKill(clock clock.Clock) bool {
+	if !(pod.IsRunning(clock) || pod.IsTerminating(clock)) {
+		return false
+	}
+
+	pod.status = OOMKilled
+	pod.killedAt = clock
+	return true
+}
+
+// IsOOMKilled returns whether this Pod has been killed by the OOM killer of its node.
+func (pod *Pod) IsOOMKilled() bool {
+	return pod.status == OOMKilled
+}
+
+// IsKilled returns whether this Pod has been lost, evicted, or OOM-killed.
+func (pod *Pod) IsKilled() bool {
+	return pod.status == NodeLost || pod.status == Evicted || pod.status == OOMKilled
+}
+
+// QOSClass returns the QoS class of this Pod.
+func (pod *Pod) QOSClass() v1.PodQOSClass {
+	return pod.qosClass
+}
+
+// AssignDevices assigns the GPU devices and MIG instances to this Pod, and stretches its execution
+// by the slowdown factor (e.g., because the devices span interconnect islands).
+// A GPU shared by time-slicing may appear more than once in gpus.
+func (pod *Pod) AssignDevices(gpus []int, migDevices []MIGDevice, slowdown float64) {
+	pod.gpus = gpus
+	pod.migDevices = migDevices
+	if slowdown > 0 {
+		pod.slowdown = slowdown
+	}
+}
+
+// GPUs returns the indices of the GPU devices assigned to this Pod.
+func (pod *Pod) GPUs() []int {
+	return pod.gpus
+}
+
+// MIGDevices returns the MIG instances assigned to this Pod.
+func (pod *Pod) MIGDevices() []MIGDevice {
+	return pod.migDevices
+}
+
 // HasFailedToStart returns whether this Pod has failed to start to a node.
 func (pod *Pod) HasFailedToStart() bool {
 	return pod.status == OverCapacity
@@ -209,6 +442,7 @@ func (pod *Pod) HasFailedToStart() bool {
 // deleted (but it can be terminating).
 func (pod *Pod) BuildStatus(clock clock.Clock) v1.PodStatus {
 	status := pod.ToV1().Status
+	status.QOSClass = pod.qosClass
 
 	switch pod.status {
 	case OverCapacity:
@@ -216,18 +450,45 @@ func (pod *Pod) BuildStatus(clock clock.Clock) v1.PodStatus {
 		// status.Conditions =
 		status.Reason = "CapacityExceeded"
 		status.Message = "Pod cannot be started due to the requested resource exceeds the capacity"
+	case NodeLost:
+		status.Phase = v1.PodFailed
+		status.Reason = "NodeLost"
+		status.Message = "Pod was killed due to a failure of its node"
+	case Evicted:
+		status.Phase = v1.PodFailed
+		status.Reason = "Evicted"
+		status.Message = pod.evictionMessage
+	case OOMKilled:
+		status.Phase = v1.PodFailed
+		status.Reason = "OOMKilled"
+		status.Message = "Pod was killed due to out of memory"
 	case Ok, Deleted:
 		startTime := pod.boundAt.ToMetaV1()
 		status.StartTime = &startTime
 
+		at := pod.startingClock(clock)
+		initializedAt := pod.initializedAt()
+		initialized := !at.Before(initializedAt)
+		started := !at.Before(pod.startAt)
+		containersStartedAt := pod.startAt.ToMetaV1()
+
 		var containerState v1.ContainerState
-		if pod.IsRunning(clock) || pod.IsTerminating(clock) {
+		switch {
+		case !started && !initialized:
+			status.Phase = v1.PodPending
+			containerState = v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: "PodInitializing"}}
+		case !started:
+			status.Phase = v1.PodPending
+			containerState = v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"}}
+		case pod.IsRunning(clock) || pod.IsTerminating(clock):
 			status.Phase = v1.PodRunning
 			containerState = v1.ContainerState{
 				Running: &v1.ContainerStateRunning{
-					StartedAt: startTime,
+					StartedAt: containersStartedAt,
 				}}
-		} else {
+		default:
 			status.Phase = v1.PodSucceeded
 			containerState = v1.ContainerState{
 				Terminated: &v1.ContainerStateTerminated{
@@ -235,21 +496,36 @@ func (pod *Pod) BuildStatus(clock clock.Clock) v1.PodStatus {
 					// Signal:
 					Reason:     "Succeeded",
 					Message:    "All containers in the pod have voluntarily terminated",
-					StartedAt:  startTime,
+					StartedAt:  containersStartedAt,
 					FinishedAt: pod.finishAt().ToMetaV1(),
 					// ContainerID:
 				}}
 		}
 
-		for _, conditionType := range []v1.PodConditionType{v1.PodInitialized, v1.PodReady} {
-			util.UpdatePodCondition(clock, &status, &v1.PodCondition{
-				Type:               conditionType,
-				Status:             v1.ConditionTrue,
-				LastProbeTime:      clock.ToMetaV1(),
-				LastTransitionTime: startTime,
+		for _, cond := range []struct {
+			conditionType v1.PodConditionType
+			ok            bool
+			reason        string
+		}{
+			{v1.PodInitialized, initialized, "ContainersNotInitialized"},
+			{v1.PodReady, started, "ContainersNotReady"},
+		} {
+			condition := &v1.PodCondition{
+				Type:          cond.conditionType,
+				Status:        v1.ConditionTrue,
+				LastProbeTime: clock.ToMetaV1(),
 				// Reason:
 				// Message:
-			})
+			}
+			if !cond.ok {
+				condition.Status = v1.ConditionFalse
+				condition.Reason = cond.reason
+			}
+			util.UpdatePodCondition(clock, &status, condition)
+		}
+
+		if len(pod.ToV1().Spec.InitContainers) > 0 {
+			status.InitContainerStatuses = pod.buildInitContainerStatuses(at)
 		}
 
 		containerStatuses := make([]v1.ContainerStatus, 0, len(pod.ToV1().Spec.Containers))
@@ -258,7 +534,7 @@ func (pod *Pod) BuildStatus(clock clock.Clock) v1.PodStatus {
 				Name:  container.Name,
 				State: containerState,
 				// LastTerminationState:
-				Ready:        true,
+				Ready:        started,
 				RestartCount: 0,
 				Image:        container.Image,
 				// ImageId:
@@ -272,22 +548,104 @@ func (pod *Pod) BuildStatus(clock clock.Clock) v1.PodStatus {
 	return status
 }
 
-// executedDuration returns the elapsed duration after this Pod started.
-// Returns 0 if the pod failed to start.
+// buildInitContainerStatuses builds the statuses of the init containers of this Pod at the given
+// clock, before which the pod has not been deleted.
+func (pod *Pod) buildInitContainerStatuses(clk clock.Clock) []v1.ContainerStatus {
+	statuses := make([]v1.ContainerStatus, 0, len(pod.ToV1().Spec.InitContainers))
+
+	startAt := pod.imagesPulledAt
+	for i, container := range pod.ToV1().Spec.InitContainers {
+		finishAt := startAt.Add(pod.initSpecs[i].duration())
+
+		var state v1.ContainerState
+		switch {
+		case clk.Before(startAt):
+			state.Waiting = &v1.ContainerStateWaiting{Reason: "PodInitializing"}
+		case clk.Before(finishAt):
+			state.Running = &v1.ContainerStateRunning{StartedAt: startAt.ToMetaV1()}
+		default:
+			state.Terminated = &v1.ContainerStateTerminated{
+				ExitCode:   0,
+				Reason:     "Completed",
+				StartedAt:  startAt.ToMetaV1(),
+				FinishedAt: finishAt.ToMetaV1(),
+			}
+		}
+
+		statuses = append(statuses, v1.ContainerStatus{
+			Name:  container.Name,
+			State: state,
+			Ready: state.Terminated != nil,
+			Image: container.Image,
+		})
+		startAt = finishAt
+	}
+
+	return statuses
+}
+
+// initResourceUsage returns the resource usage of the init container of this Pod running at the
+// given clock.
+// Returns an empty list if no init container is running.
+func (pod *Pod) initResourceUsage(clk clock.Clock) v1.ResourceList {
+	if clk.Before(pod.imagesPulledAt) {
+		return v1.ResourceList{}
+	}
+
+	elapsed := clk.Sub(pod.imagesPulledAt)
+	phaseDurationAcc := time.Duration(0)
+	for _, initSpec := range pod.initSpecs {
+		for _, phase := range initSpec {
+			phaseDurationAcc += time.Duration(phase.seconds) * time.Second
+			if elapsed < phaseDurationAcc {
+				return phase.resourceUsage
+			}
+		}
+	}
+
+	return v1.ResourceList{}
+}
+
+// initializedAt returns the clock at which all init containers of this Pod finish.
+func (pod *Pod) initializedAt() clock.Clock {
+	initClock := pod.imagesPulledAt
+	for _, initSpec := range pod.initSpecs {
+		initClock = initClock.Add(initSpec.duration())
+	}
+	return initClock
+}
+
+// startingClock returns the given clock, or the clock at which this Pod was deleted if it is
+// earlier, as the startup of the pod stops at its deletion.
+func (pod *Pod) startingClock(clk clock.Clock) clock.Clock {
+	if pod.status == Deleted {
+		if deletedAt := clock.NewClockWithMetaV1(*pod.ToV1().DeletionTimestamp); deletedAt.Before(clk) {
+			return deletedAt
+		}
+	}
+	return clk
+}
+
+// executedDuration returns the elapsed duration after the containers of this Pod started.
+// Returns 0 if the pod failed to start, or its containers have not started.
 func (pod *Pod) executedDuration(clock clock.Clock) time.Duration {
+	var elapsed time.Duration
 	switch pod.status {
 	case Ok:
-		elapsed := clock.Sub(pod.boundAt)
-		total := pod.totalExecutionDuration()
-		if elapsed < total {
-			return elapsed
+		elapsed = clock.Sub(pod.startAt)
+		if total := pod.totalExecutionDuration(); elapsed > total {
+			elapsed = total
 		}
-		return total
 	case Deleted:
-		return pod.ToV1().DeletionTimestamp.Sub(pod.boundAt.ToMetaV1().Time)
-	default:
+		elapsed = pod.ToV1().DeletionTimestamp.Sub(pod.startAt.ToMetaV1().Time)
+	case NodeLost, Evicted, OOMKilled:
+		elapsed = pod.killedAt.Sub(pod.startAt)
+	}
+
+	if elapsed < 0 {
 		return 0
 	}
+	return elapsed
 }
 
 // totalExecutionDuration returns the total execution duration of this Pod.
@@ -296,10 +654,16 @@ func (pod *Pod) totalExecutionDuration() time.Duration {
 	for _, phase := range pod.spec {
 		phaseSecondsTotal += phase.seconds
 	}
-	return time.Duration(phaseSecondsTotal) * time.Second
+	return pod.scaledDuration(phaseSecondsTotal)
+}
+
+// scaledDuration returns the duration of the given seconds of execution, stretched by the slowdown
+// of this Pod.
+func (pod *Pod) scaledDuration(seconds int32) time.Duration {
+	return time.Duration(float64(seconds) * pod.slowdown * float64(time.Second))
 }
 
 // finishAt returns the clock at which this Pod will finish spontaneously.
 func (pod *Pod) finishAt() clock.Clock {
-	return pod.boundAt.Add(pod.totalExecutionDuration())
+	return pod.startAt.Add(pod.totalExecutionDuration())
 }
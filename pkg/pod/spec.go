@@ -15,6 +15,8 @@
 package pod
 
 import (
+	"time"
+
 	"github.com/cpuguy83/strongerrors"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
@@ -32,6 +34,15 @@ type specPhase struct {
 	resourceUsage v1.ResourceList
 }
 
+// duration returns the total duration of the phases of the spec.
+func (spec spec) duration() time.Duration {
+	seconds := int32(0)
+	for _, phase := range spec {
+		seconds += phase.seconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 // parseSpec parses the pod's "simSpec" annotation into spec.
 // Returns error if the "simSpec" annotation does not exist, or the failed to parse.
 func parseSpec(pod *v1.Pod) (spec, error) {
@@ -43,6 +54,48 @@ func parseSpec(pod *v1.Pod) (spec, error) {
 	return parseSpecYAML(specAnnot)
 }
 
+// parseInitSpecs parses the pod's "simInitSpec" annotation, a YAML map from the names of its init
+// containers to their specs, into the specs of the init containers in order.
+// Init containers without specs finish immediately.
+// Returns error if failed to parse, or the annotation has a spec of an unknown init container.
+func parseInitSpecs(pod *v1.Pod) ([]spec, error) {
+	specs := make([]spec, len(pod.Spec.InitContainers))
+
+	specAnnot, ok := pod.ObjectMeta.Annotations["simInitSpec"]
+	if !ok {
+		return specs, nil
+	}
+
+	specsYAML := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(specAnnot), &specsYAML); err != nil {
+		return nil, err
+	}
+
+	for name, specYAML := range specsYAML {
+		index := -1
+		for i, container := range pod.Spec.InitContainers {
+			if container.Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("simInitSpec of unknown init container %q", name))
+		}
+
+		bytes, err := yaml.Marshal(specYAML)
+		if err != nil {
+			return nil, err
+		}
+		if specs[index], err = parseSpecYAML(string(bytes)); err != nil {
+			return nil, err
+		}
+	}
+
+	return specs, nil
+}
+
 // parseSpecYAML parses the YAML into spec.
 // Returns error if failed to parse.
 func parseSpecYAML(specYAML string) (spec, error) {
@@ -136,3 +136,48 @@ func TestParseSpecYAML(t *testing.T) {
 	_, err = parseSpecYAML(yamlStrInvalid)
 	assert.EqualError(t, err, "Invalid spec.resoruceUsage field")
 }
+
+func TestParseInitSpecs(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod",
+			Namespace:   "default",
+			Annotations: map[string]string{},
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init-0"}, {Name: "init-1"}},
+		},
+	}
+
+	actual, err := parseInitSpecs(pod)
+	assert.NoError(t, err)
+	assert.Equal(t, []spec{nil, nil}, actual)
+
+	pod.Annotations["simInitSpec"] = `
+init-1:
+- seconds: 5
+  resourceUsage:
+    cpu: 1
+`
+	actual, err = parseInitSpecs(pod)
+	assert.NoError(t, err)
+	assert.Len(t, actual, 2)
+	assert.Nil(t, actual[0])
+
+	expected := specPhase{
+		seconds:       5,
+		resourceUsage: v1.ResourceList{"cpu": resource.MustParse("1")},
+	}
+	if len(actual[1]) != 1 || specPhaseNE(expected, actual[1][0]) {
+		t.Errorf("got: %+v\nwant: %+v", actual[1], expected)
+	}
+
+	pod.Annotations["simInitSpec"] = `
+init-2:
+- seconds: 5
+  resourceUsage:
+    cpu: 1
+`
+	_, err = parseInitSpecs(pod)
+	assert.EqualError(t, err, `simInitSpec of unknown init container "init-2"`)
+}
@@ -0,0 +1,283 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubesim
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/scheduler"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// podEventRecorder records the lifecycle events of pods, and writes them in the order of their
+// clocks once in each tick.
+// All methods are no-ops on a nil podEventRecorder, i.e., if no writer is configured.
+type podEventRecorder struct {
+	writer metrics.PodEventWriter
+	events []recordedPodEvent
+	// pods is the pods submitted that have not ended (i.e., finished, been deleted, been killed, or
+	// failed to start), keyed by their keys.
+	pods map[string]*podLifecycle
+}
+
+// recordedPodEvent is a PodEvent with its clock.
+type recordedPodEvent struct {
+	clock clock.Clock
+	event metrics.PodEvent
+}
+
+// podLifecycle is the lifecycle of a pod that has not ended.
+type podLifecycle struct {
+	v1        *v1.Pod
+	scheduler string
+	// pod is the pod bound to a node, or nil if it has not been bound.
+	pod     *pod.Pod
+	started bool
+}
+
+// newPodEventRecorder creates a new podEventRecorder that writes events with the writer.
+func newPodEventRecorder(writer metrics.PodEventWriter) *podEventRecorder {
+	return &podEventRecorder{
+		writer: writer,
+		pods:   map[string]*podLifecycle{},
+	}
+}
+
+// submitted records the submission of the pod to the queue of the scheduler.
+func (r *podEventRecorder) submitted(clk clock.Clock, v1Pod *v1.Pod, schedName string) {
+	if r == nil {
+		return
+	}
+
+	key := util.PodKeyFromNames(v1Pod.Namespace, v1Pod.Name)
+	r.pods[key] = &podLifecycle{v1: v1Pod, scheduler: schedName}
+
+	event := r.newEvent(clk, metrics.PodSubmitted, key)
+	event.ResourceRequest = util.PodTotalResourceRequests(v1Pod)
+	r.add(clk, event)
+}
+
+// failedScheduling records the failure of the scheduler to find a node for the pod in the event.
+func (r *podEventRecorder) failedScheduling(clk clock.Clock, e *scheduler.FailedSchedulingEvent) {
+	if r == nil {
+		return
+	}
+
+	event := r.newEvent(clk, metrics.PodFailedScheduling, util.PodKeyFromNames(e.Pod.Namespace, e.Pod.Name))
+	event.Message = e.Message
+	if len(e.Reasons) > 0 {
+		event.FailedPredicates = e.Reasons
+	}
+	r.add(clk, event)
+}
+
+// nominated records the nomination of the node for the pod in the event, and the preemption of the
+// victims by it.
+func (r *podEventRecorder) nominated(clk clock.Clock, e *scheduler.NominateEvent) {
+	if r == nil {
+		return
+	}
+
+	preemptor := util.PodKeyFromNames(e.Pod.Namespace, e.Pod.Name)
+	event := r.newEvent(clk, metrics.PodNominated, preemptor)
+	event.Node = e.NodeName
+	r.add(clk, event)
+
+	for _, victim := range e.Victims {
+		// Terminating pods may be selected as victims again.
+		if _, ok := r.pods[victim]; !ok {
+			continue
+		}
+
+		event := r.newEvent(clk, metrics.PodPreempted, victim)
+		event.Preemptor = preemptor
+		r.add(clk, event)
+	}
+}
+
+// preempted records the preemption of the pod for the reason (e.g., by the queue of its scheduler).
+func (r *podEventRecorder) preempted(clk clock.Clock, key, reason string) {
+	if r == nil {
+		return
+	}
+	if _, ok := r.pods[key]; !ok {
+		return
+	}
+
+	event := r.newEvent(clk, metrics.PodPreempted, key)
+	event.Reason = reason
+	r.add(clk, event)
+}
+
+// scheduled records the scheduling decision in the event, which takes effect after its latency.
+func (r *podEventRecorder) scheduled(clk clock.Clock, e *scheduler.BindEvent) {
+	if r == nil {
+		return
+	}
+
+	event := r.newEvent(clk, metrics.PodScheduled, util.PodKeyFromNames(e.Pod.Namespace, e.Pod.Name))
+	event.Node = e.ScheduleResult.SuggestedHost
+	r.add(clk, event)
+}
+
+// bindRetried records the return of the pod to the queue, failing to be bound to the node for the
+// reason.
+func (r *podEventRecorder) bindRetried(clk clock.Clock, v1Pod *v1.Pod, nodeName, reason, message string) {
+	if r == nil {
+		return
+	}
+
+	event := r.newEvent(clk, metrics.PodBindRetried, util.PodKeyFromNames(v1Pod.Namespace, v1Pod.Name))
+	event.Node = nodeName
+	event.Reason = reason
+	event.Message = message
+	r.add(clk, event)
+}
+
+// bound records the binding of the pod to its node, and its failure to start if it did.
+func (r *podEventRecorder) bound(clk clock.Clock, p *pod.Pod) {
+	if r == nil {
+		return
+	}
+
+	key := util.PodKeyFromNames(p.ToV1().Namespace, p.ToV1().Name)
+	lifecycle, ok := r.pods[key]
+	if !ok {
+		lifecycle = &podLifecycle{v1: p.ToV1(), scheduler: p.ToV1().Spec.SchedulerName}
+		r.pods[key] = lifecycle
+	}
+	lifecycle.v1 = p.ToV1()
+	lifecycle.pod = p
+
+	event := r.newEvent(clk, metrics.PodBound, key)
+	event.ResourceRequest = p.TotalResourceRequests()
+	event.GPUs = p.GPUs()
+	r.add(clk, event)
+
+	if status := p.Metrics(clk).Status; status != pod.Ok {
+		event := r.newEvent(clk, metrics.PodFailed, key)
+		event.Reason = status.String()
+		r.add(clk, event)
+		delete(r.pods, key)
+	}
+}
+
+// deleted records the deletion of the pod, from the queue or its node.
+func (r *podEventRecorder) deleted(clk clock.Clock, podNamespace, podName string) {
+	if r == nil {
+		return
+	}
+
+	key := util.PodKeyFromNames(podNamespace, podName)
+	if _, ok := r.pods[key]; !ok {
+		return
+	}
+
+	r.add(clk, r.newEvent(clk, metrics.PodDeleted, key))
+	delete(r.pods, key)
+}
+
+// killed records the kill of the pod on its node (i.e., NodeLost, Evicted, or OOMKilled).
+func (r *podEventRecorder) killed(clk clock.Clock, p *pod.Pod) {
+	if r == nil {
+		return
+	}
+
+	key := util.PodKeyFromNames(p.ToV1().Namespace, p.ToV1().Name)
+	if _, ok := r.pods[key]; !ok {
+		return
+	}
+
+	event := r.newEvent(clk, metrics.PodKilled, key)
+	event.Reason = p.Metrics(clk).Status.String()
+	event.Message = p.BuildStatus(clk).Message
+	r.add(clk, event)
+	delete(r.pods, key)
+}
+
+// observe records the start and finish of the bound pods by the given clock.
+// It must be called before the pods are deleted or killed at the clock, so that the pods that
+// started by the clock are recorded as started.
+func (r *podEventRecorder) observe(clk clock.Clock) {
+	if r == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(r.pods))
+	for key, lifecycle := range r.pods {
+		if lifecycle.pod != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		lifecycle := r.pods[key]
+		p := lifecycle.pod
+
+		if !lifecycle.started && !clk.Before(p.StartAt()) {
+			lifecycle.started = true
+			r.add(p.StartAt(), r.newEvent(p.StartAt(), metrics.PodStarted, key))
+		}
+
+		if finishedAt, ok := p.FinishedAt(clk); ok {
+			r.add(finishedAt, r.newEvent(finishedAt, metrics.PodFinished, key))
+			delete(r.pods, key)
+		}
+	}
+}
+
+// flush writes the events recorded so far, in the order of their clocks.
+func (r *podEventRecorder) flush() error {
+	if r == nil || len(r.events) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(r.events, func(i, j int) bool {
+		return r.events[i].clock.Before(r.events[j].clock)
+	})
+
+	events := make([]metrics.PodEvent, 0, len(r.events))
+	for _, e := range r.events {
+		events = append(events, e.event)
+	}
+	r.events = r.events[:0]
+
+	return r.writer.WritePodEvents(events)
+}
+
+// newEvent creates a new PodEvent of the pod with the key.
+func (r *podEventRecorder) newEvent(clk clock.Clock, typ metrics.PodEventType, key string) metrics.PodEvent {
+	event := metrics.PodEvent{Clock: clk.ToRFC3339(), Type: typ}
+	event.Namespace, event.Name = util.SplitPodKey(key)
+
+	if lifecycle, ok := r.pods[key]; ok {
+		event.Scheduler = lifecycle.scheduler
+		event.Priority = util.PodPriority(lifecycle.v1)
+		event.Node = lifecycle.v1.Spec.NodeName
+	}
+
+	return event
+}
+
+// add adds the event at the clock.
+func (r *podEventRecorder) add(clk clock.Clock, event metrics.PodEvent) {
+	r.events = append(r.events, recordedPodEvent{clock: clk, event: event})
+}
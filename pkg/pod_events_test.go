@@ -0,0 +1,132 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubesim
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/config"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/scheduler"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/submitter"
+)
+
+// newTestPodOnNode creates a pod of newTestPod with the priority, which is scheduled only to the
+// node, and is deleted without a grace period.
+func newTestPodOnNode(name, schedulerName, nodeName string, priority int32, cpu string, seconds int) *v1.Pod {
+	pod := newTestPod(name, schedulerName, v1.ResourceList{"cpu": resource.MustParse(cpu)}, seconds)
+	pod.Spec.Priority = &priority
+	pod.Spec.NodeSelector = map[string]string{v1.LabelHostname: nodeName}
+	gracePeriod := int64(0)
+	pod.Spec.TerminationGracePeriodSeconds = &gracePeriod
+
+	return pod
+}
+
+func TestPodEvents(t *testing.T) {
+	// node-1 in rack-1 fails at 8 seconds, and binds that conflict are retried.
+	allocatable := map[v1.ResourceName]string{"cpu": "2", "memory": "8Gi", "pods": "4"}
+	node0 := newTestNodeConfig("node-0", allocatable)
+	node0.Topology = config.NodeTopology{Rack: "rack-0", Host: "node-0"}
+	node1 := newTestNodeConfig("node-1", allocatable)
+	node1.Topology = config.NodeTopology{Rack: "rack-1", Host: "node-1"}
+	conf := newTestConfig(node0, node1)
+	conf.Failures = []config.FailureConfig{{Level: "rack", Domain: "rack-1", At: 8}}
+	conf.BindConflictPolicy = config.BindConflictRetry
+
+	// The default scheduler preempts pods, and the other scheduler binds pods to node-0 at their
+	// first attempts, and to node-1 after that.
+	k, writer := newTestKubeSim(t, conf, "node-0", "node-1")
+	sched := scheduler.NewGenericScheduler(true)
+	sched.AddPredicate("PodMatchNodeSelector", predicates.PodMatchNodeSelector)
+	sched.AddPredicate("PodFitsResources", predicates.PodFitsResources)
+	k.AddScheduler(v1.DefaultSchedulerName, queue.NewPriorityQueue(), &sched)
+	k.AddScheduler("other", queue.NewFIFOQueue(), &testScheduler{nodeNames: []string{"node-0", "node-1"}})
+
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+		0: {
+			&submitter.SubmitEvent{Pod: newTestPodOnNode("low", "", "node-0", 0, "2", 30)},
+			&submitter.SubmitEvent{Pod: newTestPodOnNode("conflict", "other", "node-1", 0, "1", 30)},
+		},
+		5: {&submitter.SubmitEvent{Pod: newTestPodOnNode("high", "", "node-0", 100, "2", 5)}},
+	}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"0 Submitted low",
+		"0 Submitted conflict",
+		"0 Scheduled low",
+		"0 Bound low",
+		// low takes all CPUs of node-0, so that conflict is bound to node-1 at its second attempt.
+		"0 Scheduled conflict",
+		"0 BindRetried conflict BindConflict",
+		"0 Started low",
+		"1 Scheduled conflict",
+		"1 Bound conflict",
+		"1 Started conflict",
+		// high preempts low, and is bound once low is deleted.
+		"5 Submitted high",
+		"5 FailedScheduling high",
+		"5 Nominated high",
+		"5 Preempted low",
+		"5 Deleted low",
+		"6 Scheduled high",
+		"6 Bound high",
+		"6 Started high",
+		// The pod on node-1 is lost by the failure.
+		"8 Killed conflict NodeLost",
+		"11 Finished high",
+	}
+	got := writer.summaries(
+		metrics.PodSubmitted, metrics.PodFailedScheduling, metrics.PodNominated, metrics.PodPreempted,
+		metrics.PodScheduled, metrics.PodBound, metrics.PodBindRetried, metrics.PodFailed,
+		metrics.PodStarted, metrics.PodFinished, metrics.PodDeleted, metrics.PodKilled,
+	)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestPodEventsWithoutWriter(t *testing.T) {
+	// No events are recorded without a writer, while the simulation runs as usual.
+	conf := newTestConfig(newTestNodeConfig("node-0", testAllocatable))
+	k, _ := newTestKubeSim(t, conf, "node-0")
+	k.podEvents = nil
+
+	k.AddSubmitter("submitter", &testSubmitter{events: map[int][]submitter.Event{
+		0: {
+			&submitter.SubmitEvent{Pod: newTestPod("pod-0", "", v1.ResourceList{"cpu": resource.MustParse("1")}, 10)},
+			&submitter.SubmitEvent{Pod: newTestPod("pod-1", "", v1.ResourceList{"cpu": resource.MustParse("1")}, 10)},
+		},
+		5: {&submitter.DeleteEvent{PodNamespace: "default", PodName: "pod-1"}},
+	}})
+
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if k.podEvents != nil {
+		t.Errorf("got: %v\nwant: nil", k.podEvents)
+	}
+}
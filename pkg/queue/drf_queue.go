@@ -0,0 +1,314 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// DRFQueue stores pods in per-tenant priority queues, and pops pods in the order of Dominant
+// Resource Fairness (DRF): the front pod is the one of the tenant with the smallest dominant share.
+// The dominant share of a tenant is the maximum, over resource types, of the ratio of the resources
+// requested by the tenant's pods running on the cluster to the allocatable resources of the
+// cluster, and is recomputed by UpdateCluster.
+// Pods popped but not bound yet are also counted in their tenants' shares, until they are bound or
+// returned to this queue.
+// Pods of the same tenant are stored in a SchedulingQueue, and sorted by DefaultComparator.
+// A pod that has failed to be scheduled is kept aside by AddUnschedulable, so that it blocks
+// neither the other pods of its tenant nor the other tenants.
+type DRFQueue struct {
+	tenantLabel string
+	tenants     map[string]*SchedulingQueue
+
+	capacity    v1.ResourceList
+	allocations map[string]v1.ResourceList
+	inFlight    map[string]*v1.Pod
+}
+
+// NewDRFQueue creates a new DRFQueue.
+// The tenant of each pod is given by the value of tenantLabel of the pod, or by its namespace if
+// tenantLabel is empty or the pod does not have the label.
+func NewDRFQueue(tenantLabel string) *DRFQueue {
+	return &DRFQueue{
+		tenantLabel: tenantLabel,
+		tenants:     map[string]*SchedulingQueue{},
+
+		capacity:    v1.ResourceList{},
+		allocations: map[string]v1.ResourceList{},
+		inFlight:    map[string]*v1.Pod{},
+	}
+}
+
+// Push pushes the pod to the queue of its tenant.
+// If the pod has been popped and not bound (e.g., it has conflicted with another pod on the node),
+// it is no longer counted in the share of its tenant.
+func (q *DRFQueue) Push(pod *v1.Pod) error {
+	q.release(pod)
+	return q.tenantQueue(pod).Push(pod)
+}
+
+// Pop pops the front pod of the tenant with the smallest dominant share, and adds its requests to
+// the allocation of the tenant.
+func (q *DRFQueue) Pop() (*v1.Pod, error) {
+	tenant, ok := q.frontTenant()
+	if !ok {
+		return nil, ErrEmptyQueue
+	}
+
+	pod, err := q.tenants[tenant].Pop()
+	if err != nil {
+		return nil, err
+	}
+
+	key, _ := util.PodKey(pod) // stored pod never have invalid key
+	q.inFlight[key] = pod
+	q.allocations[tenant] = util.ResourceListSum(q.allocations[tenant], util.PodTotalResourceRequests(pod))
+
+	return pod, nil
+}
+
+// Front refers the front pod of the tenant with the smallest dominant share.
+func (q *DRFQueue) Front() (*v1.Pod, error) {
+	tenant, ok := q.frontTenant()
+	if !ok {
+		return nil, ErrEmptyQueue
+	}
+
+	return q.tenants[tenant].Front()
+}
+
+func (q *DRFQueue) Delete(podNamespace, podName string) bool {
+	delete(q.inFlight, util.PodKeyFromNames(podNamespace, podName))
+
+	for _, pq := range q.tenants {
+		if pq.Delete(podNamespace, podName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Update updates the pod to the newPod.
+// If the tenant of the pod is changed, the pod is moved to the queue of the new tenant.
+func (q *DRFQueue) Update(podNamespace, podName string, newPod *v1.Pod) error {
+	keyOrig := util.PodKeyFromNames(podNamespace, podName)
+	keyNew, err := util.PodKey(newPod)
+	if err != nil {
+		return err
+	}
+	if keyOrig != keyNew {
+		return ErrDifferentNames
+	}
+
+	for tenant, pq := range q.tenants {
+		if !pq.contains(keyOrig) {
+			continue
+		}
+
+		if tenant == q.tenantOf(newPod) {
+			return pq.Update(podNamespace, podName, newPod)
+		}
+
+		pq.Delete(podNamespace, podName)
+		return q.Push(newPod)
+	}
+
+	return &ErrNoMatchingPod{key: keyOrig}
+}
+
+func (q *DRFQueue) UpdateNominatedNode(pod *v1.Pod, nodeName string) error {
+	return q.tenantQueue(pod).UpdateNominatedNode(pod, nodeName)
+}
+
+func (q *DRFQueue) RemoveNominatedNode(pod *v1.Pod) error {
+	return q.tenantQueue(pod).RemoveNominatedNode(pod)
+}
+
+func (q *DRFQueue) NominatedPods(nodeName string) []*v1.Pod {
+	pods := []*v1.Pod{}
+	for _, pq := range q.tenants {
+		pods = append(pods, pq.NominatedPods(nodeName)...)
+	}
+
+	return pods
+}
+
+func (q *DRFQueue) Metrics() Metrics {
+	pendingPodsNum := 0
+	for _, pq := range q.tenants {
+		pendingPodsNum += pq.Metrics().PendingPodsNum
+	}
+
+	return Metrics{
+		PendingPodsNum: pendingPodsNum,
+	}
+}
+
+func (q *DRFQueue) PendingPods() []*v1.Pod {
+	pods := []*v1.Pod{}
+	for _, pq := range q.tenants {
+		pods = append(pods, pq.PendingPods()...)
+	}
+
+	return pods
+}
+
+// UpdateCluster recomputes the allocatable resources of the cluster and the allocations of the
+// tenants from the pods running on the nodes.
+// Never requests evictions.
+func (q *DRFQueue) UpdateCluster(_ clock.Clock, nodeInfoMap map[string]*nodeinfo.NodeInfo) []*v1.Pod {
+	q.capacity = v1.ResourceList{}
+	q.allocations = map[string]v1.ResourceList{}
+
+	for _, info := range nodeInfoMap {
+		q.capacity = util.ResourceListSum(q.capacity, info.Node().Status.Allocatable)
+
+		for _, pod := range info.Pods() {
+			tenant := q.tenantOf(pod)
+			q.allocations[tenant] = util.ResourceListSum(q.allocations[tenant], util.PodTotalResourceRequests(pod))
+		}
+	}
+
+	for key, pod := range q.inFlight {
+		if pod.Spec.NodeName != "" { // bound, and counted above if running
+			delete(q.inFlight, key)
+			continue
+		}
+
+		tenant := q.tenantOf(pod)
+		q.allocations[tenant] = util.ResourceListSum(q.allocations[tenant], util.PodTotalResourceRequests(pod))
+	}
+
+	return []*v1.Pod{}
+}
+
+// DominantShare returns the dominant share of the tenant, in [0, 1] unless the cluster is
+// overcommitted.
+func (q *DRFQueue) DominantShare(tenant string) float64 {
+	share := 0.0
+	for name, used := range q.allocations[tenant] {
+		capacity, ok := q.capacity[name]
+		if !ok || capacity.IsZero() {
+			continue
+		}
+
+		if s := float64(used.MilliValue()) / float64(capacity.MilliValue()); s > share {
+			share = s
+		}
+	}
+
+	return share
+}
+
+// AddUnschedulable adds the pod, which has failed to be scheduled at the given clock, to the
+// unschedulable queue of its tenant, and stops counting it in the share of the tenant.
+func (q *DRFQueue) AddUnschedulable(clock clock.Clock, pod *v1.Pod) error {
+	q.release(pod)
+	return q.tenantQueue(pod).AddUnschedulable(clock, pod)
+}
+
+// Flush flushes the queues of all tenants at the given clock.
+func (q *DRFQueue) Flush(clock clock.Clock) {
+	for _, pq := range q.tenants {
+		pq.Flush(clock)
+	}
+}
+
+// MoveAllToActive moves all unschedulable pods of all tenants to their active (or backoff) queues
+// at the given clock.
+func (q *DRFQueue) MoveAllToActive(clock clock.Clock) {
+	for _, pq := range q.tenants {
+		pq.MoveAllToActive(clock)
+	}
+}
+
+var _ = ClusterAwarePodQueue(&DRFQueue{})
+var _ = UnschedulablePodQueue(&DRFQueue{})
+var _ = PendingPodLister(&DRFQueue{})
+
+// frontTenant returns the tenant with pending pods and the smallest dominant share.
+// Ties are broken by DefaultComparator on the front pods, and then by the tenant names.
+// Returns false if no pods are pending.
+func (q *DRFQueue) frontTenant() (string, bool) {
+	found := false
+	var minTenant string
+	var minShare float64
+	var minPod *v1.Pod
+
+	for tenant, pq := range q.tenants {
+		pod, err := pq.Front()
+		if err != nil {
+			continue
+		}
+
+		share := q.DominantShare(tenant)
+		if !found || share < minShare ||
+			(share == minShare && DefaultComparator(pod, minPod)) ||
+			(share == minShare && !DefaultComparator(minPod, pod) && tenant < minTenant) {
+			found = true
+			minTenant, minShare, minPod = tenant, share, pod
+		}
+	}
+
+	return minTenant, found
+}
+
+// tenantOf returns the tenant of the pod.
+func (q *DRFQueue) tenantOf(pod *v1.Pod) string {
+	if tenant, ok := pod.Labels[q.tenantLabel]; q.tenantLabel != "" && ok {
+		return tenant
+	}
+
+	return pod.Namespace
+}
+
+// release removes the pod from the in-flight pods, and subtracts its requests from the allocation of
+// its tenant, if it has been popped and not bound.
+func (q *DRFQueue) release(pod *v1.Pod) {
+	key, err := util.PodKey(pod)
+	if err != nil {
+		return
+	}
+	if _, ok := q.inFlight[key]; !ok {
+		return
+	}
+	delete(q.inFlight, key)
+
+	tenant := q.tenantOf(pod)
+	allocation := q.allocations[tenant]
+	for name, request := range util.PodTotalResourceRequests(pod) {
+		if used, ok := allocation[name]; ok {
+			used.Sub(request)
+			allocation[name] = used
+		}
+	}
+}
+
+// tenantQueue returns the queue of the tenant of the pod, creating it if it does not exist.
+func (q *DRFQueue) tenantQueue(pod *v1.Pod) *SchedulingQueue {
+	tenant := q.tenantOf(pod)
+	pq, ok := q.tenants[tenant]
+	if !ok {
+		pq = NewSchedulingQueue()
+		q.tenants[tenant] = pq
+	}
+
+	return pq
+}
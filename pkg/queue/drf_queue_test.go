@@ -0,0 +1,173 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+func newTenantPod(namespace, name, cpu, memory string) *v1.Pod {
+	pod := newPod(name)
+	pod.Namespace = namespace
+	pod.Spec.Containers = []v1.Container{{
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				"cpu":    resource.MustParse(cpu),
+				"memory": resource.MustParse(memory),
+			},
+		},
+	}}
+	return pod
+}
+
+func newNodeInfo(t *testing.T, pods ...*v1.Pod) *nodeinfo.NodeInfo {
+	info := nodeinfo.NewNodeInfo(pods...)
+	err := info.SetNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				"cpu":    resource.MustParse("10"),
+				"memory": resource.MustParse("100Gi"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+	return info
+}
+
+func TestDRFQueueOrdersByDominantShare(t *testing.T) {
+	q := queue.NewDRFQueue("")
+	clk := clock.NewClock(time.Now())
+
+	running := newTenantPod("team-a", "running", "1", "40Gi") // memory-dominant share 0.4
+	running.Spec.NodeName = "node-0"
+	q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{"node-0": newNodeInfo(t, running)})
+	assert.InDelta(t, 0.4, q.DominantShare("team-a"), 1e-9)
+
+	_ = q.Push(newTenantPod("team-a", "pod-a0", "1", "1Gi"))
+	_ = q.Push(newTenantPod("team-b", "pod-b0", "5", "1Gi"))
+	_ = q.Push(newTenantPod("team-b", "pod-b1", "1", "1Gi"))
+
+	pod, _ := q.Front()
+	assert.Equal(t, "pod-b0", pod.Name)
+
+	// The popped pod counts in the share of team-b (0.5) before it is bound.
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-b0", pod.Name)
+	assert.InDelta(t, 0.5, q.DominantShare("team-b"), 1e-9)
+
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-a0", pod.Name)
+
+	q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{"node-0": newNodeInfo(t, running)})
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-b1", pod.Name)
+
+	_, err := q.Pop()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+}
+
+func TestDRFQueueTenantLabel(t *testing.T) {
+	q := queue.NewDRFQueue("tenant")
+
+	pod0 := newTenantPod("default", "pod-0", "1", "1Gi")
+	pod0.Labels = map[string]string{"tenant": "team-a"}
+	_ = q.Push(pod0)
+	_ = q.Push(newTenantPod("default", "pod-1", "1", "1Gi"))
+	assert.Equal(t, 2, q.Metrics().PendingPodsNum)
+
+	pod02 := pod0.DeepCopy()
+	pod02.Labels["tenant"] = "team-b"
+	if err := q.Update("default", "pod-0", pod02); err != nil {
+		t.Errorf("error %+v", err)
+	}
+	assert.Equal(t, 2, q.Metrics().PendingPodsNum)
+
+	assert.True(t, q.Delete("default", "pod-0"))
+	assert.True(t, q.Delete("default", "pod-1"))
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+}
+
+func TestDRFQueuePushReleasesShare(t *testing.T) {
+	q := queue.NewDRFQueue("")
+	clk := clock.NewClock(time.Now())
+	q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{"node-0": newNodeInfo(t)})
+
+	_ = q.Push(newTenantPod("team-a", "pod-a0", "5", "1Gi"))
+	_ = q.Push(newTenantPod("team-b", "pod-b0", "1", "1Gi"))
+
+	// Ties in the shares are broken by the tenant names.
+	pod, _ := q.Pop()
+	assert.Equal(t, "pod-a0", pod.Name)
+	assert.InDelta(t, 0.5, q.DominantShare("team-a"), 1e-9)
+
+	// The pod is returned to the queue (e.g., by a bind conflict), and no longer counted in the share
+	// of team-a.
+	_ = q.Push(pod)
+	assert.InDelta(t, 0.0, q.DominantShare("team-a"), 1e-9)
+	pod, _ = q.Front()
+	assert.Equal(t, "pod-a0", pod.Name)
+
+	q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{"node-0": newNodeInfo(t)})
+	assert.InDelta(t, 0.0, q.DominantShare("team-a"), 1e-9)
+	assert.Equal(t, 2, q.Metrics().PendingPodsNum)
+}
+
+func TestDRFQueueUnschedulable(t *testing.T) {
+	q := queue.NewDRFQueue("")
+	clk := clock.NewClock(time.Now())
+	q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{"node-0": newNodeInfo(t)})
+
+	_ = q.Push(newTenantPod("team-a", "pod-a0", "5", "1Gi"))
+	_ = q.Push(newTenantPod("team-a", "pod-a1", "1", "1Gi"))
+	_ = q.Push(newTenantPod("team-b", "pod-b0", "1", "1Gi"))
+
+	// pod-a0 does not fit in any node, and is kept aside without blocking the other pods.
+	pod, _ := q.Pop()
+	assert.Equal(t, "pod-a0", pod.Name)
+	assert.NoError(t, q.AddUnschedulable(clk, pod))
+	assert.InDelta(t, 0.0, q.DominantShare("team-a"), 1e-9)
+	assert.Equal(t, 3, q.Metrics().PendingPodsNum)
+	assert.Len(t, q.PendingPods(), 3)
+
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-a1", pod.Name)
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-b0", pod.Name)
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	// pod-a0 is not retried until a cluster event, even after its backoff.
+	q.Flush(clk.Add(5 * time.Second))
+	_, err = q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	q.MoveAllToActive(clk.Add(5 * time.Second))
+	pod, _ = q.Front()
+	assert.Equal(t, "pod-a0", pod.Name)
+}
@@ -123,4 +123,17 @@ func (fifo *FIFOQueue) Metrics() Metrics {
 	}
 }
 
+// PendingPods returns the pods in this FIFOQueue in FIFO order.
+func (fifo *FIFOQueue) PendingPods() []*v1.Pod {
+	pods := make([]*v1.Pod, 0, len(fifo.pods))
+	for _, key := range fifo.queue {
+		if pod, ok := fifo.pods[key]; ok {
+			pods = append(pods, pod)
+		}
+	}
+
+	return pods
+}
+
 var _ = PodQueue(&FIFOQueue{})
+var _ = PendingPodLister(&FIFOQueue{})
@@ -167,7 +167,12 @@ func (pq *PriorityQueue) Metrics() Metrics {
 	}
 }
 
+func (pq *PriorityQueue) PendingPods() []*v1.Pod {
+	return pq.inner.pendingPods()
+}
+
 var _ = PodQueue(&PriorityQueue{})
+var _ = PendingPodLister(&PriorityQueue{})
 
 type item struct {
 	pod   *v1.Pod
@@ -223,7 +228,6 @@ func (pq *rawPriorityQueue) Pop() interface{} {
 	return item
 }
 
-// nolint
 func (pq *rawPriorityQueue) front() *item {
 	return pq.items[pq.keys[0]]
 }
@@ -19,11 +19,16 @@ import (
 	"fmt"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
 )
 
 // Metrics represents a metrics of a PodQueue at one time point.
 type Metrics struct {
 	PendingPodsNum int
+	// ClusterQueues is a map from ClusterQueue names to their metrics, populated only by QuotaQueue.
+	ClusterQueues map[string]ClusterQueueMetrics `json:",omitempty"`
 }
 
 var (
@@ -77,3 +82,45 @@ type PodQueue interface {
 	// Metrics returns a metrics of this PodQueue.
 	Metrics() Metrics
 }
+
+// UnschedulablePodQueue defines the interface of pod queues that can keep pods that failed to be
+// scheduled aside from the other pending pods, so that they do not block the pods behind them.
+type UnschedulablePodQueue interface {
+	PodQueue
+
+	// AddUnschedulable adds the pod, which has been popped and failed to be scheduled at the given
+	// clock, back to this UnschedulablePodQueue.
+	// The pod will not be returned from Pop or Front until it is moved to the active queue by Flush
+	// or MoveAllToActive.
+	AddUnschedulable(clock clock.Clock, pod *v1.Pod) error
+
+	// Flush moves pods whose backoff has been completed, or that have stayed unschedulable for too
+	// long, to the active queue at the given clock.
+	Flush(clock clock.Clock)
+
+	// MoveAllToActive moves all unschedulable pods to the active queue (or the backoff queue if
+	// their backoff has not been completed) at the given clock.
+	// This method is called on cluster events that may make the unschedulable pods schedulable.
+	MoveAllToActive(clock clock.Clock)
+}
+
+// ClusterAwarePodQueue defines the interface of pod queues whose behavior depends on the state of
+// the cluster.
+type ClusterAwarePodQueue interface {
+	PodQueue
+
+	// UpdateCluster notifies this queue of the nodes and the pods running (or terminating) on them
+	// at the given clock.
+	// This method is called before every scheduling.
+	// Returns a list of bound pods that this queue requests to be evicted (e.g., to reclaim quota).
+	UpdateCluster(clock clock.Clock, nodeInfoMap map[string]*nodeinfo.NodeInfo) []*v1.Pod
+}
+
+// PendingPodLister defines the interface of pod queues that can list their pending pods.
+type PendingPodLister interface {
+	PodQueue
+
+	// PendingPods returns the pods in this queue, including those kept aside (e.g., unschedulable
+	// pods), in no particular order.
+	PendingPods() []*v1.Pod
+}
@@ -0,0 +1,695 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cpuguy83/strongerrors"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// QueueNameLabel is the label of a pod that specifies the LocalQueue (in the pod's namespace) to
+// which the pod is submitted.
+const QueueNameLabel = "kueue.x-k8s.io/queue-name"
+
+// ResourceFlavor represents a variant of resources (e.g., a GPU model or a spot instance), which
+// is provided by the nodes with the NodeLabels.
+type ResourceFlavor struct {
+	Name       string
+	NodeLabels map[string]string
+}
+
+// ResourceQuota is the quota of a resource of a flavor in a ClusterQueue.
+// BorrowingLimit is the maximum amount that the ClusterQueue can borrow from its cohort beyond the
+// Nominal quota. If BorrowingLimit is nil, the ClusterQueue can borrow all unused quota of its
+// cohort.
+type ResourceQuota struct {
+	Nominal        resource.Quantity
+	BorrowingLimit *resource.Quantity
+}
+
+// FlavorQuotas is the quotas of resources of a flavor in a ClusterQueue.
+type FlavorQuotas struct {
+	Flavor    string
+	Resources map[v1.ResourceName]ResourceQuota
+}
+
+// ClusterQueue is a pool of quotas, shared by the pods submitted to the LocalQueues pointing to
+// it.
+// ClusterQueues in the same Cohort lend their unused quotas to each other.
+// Flavors are tried in the listed order when a pod is admitted.
+type ClusterQueue struct {
+	Name    string
+	Cohort  string
+	Flavors []FlavorQuotas
+}
+
+// LocalQueue is a namespaced queue to which pods are submitted by QueueNameLabel.
+type LocalQueue struct {
+	Namespace    string
+	Name         string
+	ClusterQueue string
+}
+
+// ClusterQueueMetrics represents a metrics of a ClusterQueue at one time point.
+type ClusterQueueMetrics struct {
+	// PendingPodsNum is the number of pods waiting for admission.
+	PendingPodsNum int
+	// AdmittedPodsNum is the number of pods currently holding quota of the ClusterQueue.
+	AdmittedPodsNum int
+	// Usage is a map from flavor names to the amount of resources held by the admitted pods.
+	Usage map[string]v1.ResourceList
+
+	// AdmittedPodsTotal is the number of pods that have ever been admitted.
+	AdmittedPodsTotal int
+	// AvgWaitSeconds and MaxWaitSeconds are the average and maximum durations between submission
+	// and admission of the pods that have ever been admitted.
+	AvgWaitSeconds float64
+	MaxWaitSeconds float64
+}
+
+// QuotaQueue is a PodQueue that admits pods to the scheduler only when the quota of their
+// ClusterQueue permits, in the same way as Kueue.
+// Pods labeled with QueueNameLabel wait in their ClusterQueue until admitted, and then are pushed
+// to an internal SchedulingQueue, from which the scheduler pops them.
+// Pods without the label bypass the quota and are pushed to the SchedulingQueue directly.
+//
+// Pending pods in each ClusterQueue are admitted in the order of DefaultComparator, but a pod that
+// does not fit does not block the pods behind it (i.e., BestEffortFIFO).
+// Pods are first admitted within the nominal quota of their ClusterQueues, and then by borrowing
+// unused quota of the cohort.
+// All resources of a pod are assigned the same flavor, and the NodeLabels of the flavor are added
+// to the pod's node selector.
+// If a pod fits in the nominal quota of its ClusterQueue but the cohort has lent the quota to
+// other ClusterQueues, the pods of the borrowing ClusterQueues are evicted (preempted) in the
+// order of lower priority and newer admission to reclaim the quota.
+// Evicted pods are deleted, in the same way as pods preempted by the scheduler.
+// An admitted pod keeps holding the quota until it terminates or is deleted.
+type QuotaQueue struct {
+	flavors       map[string]*ResourceFlavor
+	clusterQueues []*clusterQueue // sorted by name
+	localQueues   map[string]*clusterQueue
+
+	admitted  *SchedulingQueue
+	workloads map[string]*workload
+}
+
+// clusterQueue is the internal state of a ClusterQueue.
+type clusterQueue struct {
+	name    string
+	cohort  string
+	flavors []string
+
+	nominal        map[string]v1.ResourceList
+	borrowingLimit map[string]v1.ResourceList // only resources with BorrowingLimit
+
+	pending *PriorityQueue
+
+	admittedTotal int
+	waitSum       time.Duration
+	waitMax       time.Duration
+}
+
+// workload is a pod admitted to a ClusterQueue, holding the quota.
+type workload struct {
+	pod        *v1.Pod
+	cq         *clusterQueue
+	flavor     string
+	requests   v1.ResourceList
+	admittedAt clock.Clock
+
+	deleted  bool
+	evicting bool
+}
+
+// quotaUsage is a map from ClusterQueues to flavor names to the resources held by the workloads.
+type quotaUsage map[*clusterQueue]map[string]v1.ResourceList
+
+// NewQuotaQueue creates a new QuotaQueue with the given resource flavors, ClusterQueues, and
+// LocalQueues.
+// Returns error if a ClusterQueue or a LocalQueue refers to an undefined flavor or ClusterQueue, or
+// names are duplicated.
+func NewQuotaQueue(
+	flavors []ResourceFlavor, clusterQueues []ClusterQueue, localQueues []LocalQueue,
+) (*QuotaQueue, error) {
+
+	q := QuotaQueue{
+		flavors:       map[string]*ResourceFlavor{},
+		clusterQueues: make([]*clusterQueue, 0, len(clusterQueues)),
+		localQueues:   map[string]*clusterQueue{},
+
+		admitted:  NewSchedulingQueue(),
+		workloads: map[string]*workload{},
+	}
+
+	for i := range flavors {
+		flavor := &flavors[i]
+		if _, ok := q.flavors[flavor.Name]; ok {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("Resource flavor %q is duplicated", flavor.Name))
+		}
+		q.flavors[flavor.Name] = flavor
+	}
+
+	cqs := map[string]*clusterQueue{}
+	for _, conf := range clusterQueues {
+		if _, ok := cqs[conf.Name]; ok {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("ClusterQueue %q is duplicated", conf.Name))
+		}
+
+		cq := &clusterQueue{
+			name:           conf.Name,
+			cohort:         conf.Cohort,
+			flavors:        make([]string, 0, len(conf.Flavors)),
+			nominal:        map[string]v1.ResourceList{},
+			borrowingLimit: map[string]v1.ResourceList{},
+			pending:        NewPriorityQueue(),
+		}
+
+		for _, fq := range conf.Flavors {
+			if _, ok := q.flavors[fq.Flavor]; !ok {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("ClusterQueue %q refers to undefined flavor %q", conf.Name, fq.Flavor))
+			}
+			if _, ok := cq.nominal[fq.Flavor]; ok {
+				return nil, strongerrors.InvalidArgument(
+					errors.Errorf("ClusterQueue %q has duplicated flavor %q", conf.Name, fq.Flavor))
+			}
+
+			cq.flavors = append(cq.flavors, fq.Flavor)
+			cq.nominal[fq.Flavor] = v1.ResourceList{}
+			cq.borrowingLimit[fq.Flavor] = v1.ResourceList{}
+			for name, quota := range fq.Resources {
+				cq.nominal[fq.Flavor][name] = quota.Nominal.DeepCopy()
+				if quota.BorrowingLimit != nil {
+					cq.borrowingLimit[fq.Flavor][name] = quota.BorrowingLimit.DeepCopy()
+				}
+			}
+		}
+
+		cqs[conf.Name] = cq
+		q.clusterQueues = append(q.clusterQueues, cq)
+	}
+	sort.Slice(q.clusterQueues, func(i, j int) bool {
+		return q.clusterQueues[i].name < q.clusterQueues[j].name
+	})
+
+	for _, lq := range localQueues {
+		key := util.PodKeyFromNames(lq.Namespace, lq.Name)
+		if _, ok := q.localQueues[key]; ok {
+			return nil, strongerrors.InvalidArgument(errors.Errorf("LocalQueue %q is duplicated", key))
+		}
+
+		cq, ok := cqs[lq.ClusterQueue]
+		if !ok {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("LocalQueue %q refers to undefined ClusterQueue %q", key, lq.ClusterQueue))
+		}
+		q.localQueues[key] = cq
+	}
+
+	return &q, nil
+}
+
+// Push pushes the pod to the ClusterQueue of the LocalQueue specified by QueueNameLabel.
+// If the pod does not have the label, or has already been admitted (e.g., it is returned to this
+// queue on a bind conflict), it is pushed to the SchedulingQueue directly.
+// Returns error if the LocalQueue is not defined.
+func (q *QuotaQueue) Push(pod *v1.Pod) error {
+	key, err := util.PodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	queueName, ok := pod.Labels[QueueNameLabel]
+	if _, admitted := q.workloads[key]; !ok || admitted {
+		return q.admitted.Push(pod)
+	}
+
+	cq, ok := q.localQueues[util.PodKeyFromNames(pod.Namespace, queueName)]
+	if !ok {
+		return strongerrors.NotFound(errors.Errorf("No LocalQueue %q in namespace %q for pod %q",
+			queueName, pod.Namespace, key))
+	}
+
+	return cq.pending.Push(pod)
+}
+
+// Pop pops the pod on the front of the SchedulingQueue of admitted pods.
+func (q *QuotaQueue) Pop() (*v1.Pod, error) {
+	return q.admitted.Pop()
+}
+
+// Front refers the pod on the front of the SchedulingQueue of admitted pods.
+func (q *QuotaQueue) Front() (*v1.Pod, error) {
+	return q.admitted.Front()
+}
+
+// Delete deletes the pod from this QuotaQueue, and releases its quota if it has been admitted.
+// If the pod has been admitted and popped from this queue, it returns false, and its quota is
+// released once the pod is not running on any node.
+func (q *QuotaQueue) Delete(podNamespace, podName string) bool {
+	for _, cq := range q.clusterQueues {
+		if cq.pending.Delete(podNamespace, podName) {
+			return true
+		}
+	}
+
+	key := util.PodKeyFromNames(podNamespace, podName)
+	if q.admitted.Delete(podNamespace, podName) {
+		delete(q.workloads, key)
+		return true
+	}
+
+	if w, ok := q.workloads[key]; ok {
+		w.deleted = true
+	}
+
+	return false
+}
+
+// Update updates the pod to the newPod.
+// The quota held by an admitted pod is not changed, even if its resource requests are updated.
+func (q *QuotaQueue) Update(podNamespace, podName string, newPod *v1.Pod) error {
+	key := util.PodKeyFromNames(podNamespace, podName)
+	for _, cq := range q.clusterQueues {
+		if _, ok := cq.pending.inner.items[key]; ok {
+			return cq.pending.Update(podNamespace, podName, newPod)
+		}
+	}
+
+	if w, ok := q.workloads[key]; ok {
+		q.assignFlavor(newPod, w.flavor)
+	}
+
+	return q.admitted.Update(podNamespace, podName, newPod)
+}
+
+func (q *QuotaQueue) UpdateNominatedNode(pod *v1.Pod, nodeName string) error {
+	return q.admitted.UpdateNominatedNode(pod, nodeName)
+}
+
+func (q *QuotaQueue) RemoveNominatedNode(pod *v1.Pod) error {
+	return q.admitted.RemoveNominatedNode(pod)
+}
+
+func (q *QuotaQueue) NominatedPods(nodeName string) []*v1.Pod {
+	return q.admitted.NominatedPods(nodeName)
+}
+
+// PendingPods returns both the pods waiting for admission and the admitted pods waiting for
+// scheduling.
+func (q *QuotaQueue) PendingPods() []*v1.Pod {
+	pods := q.admitted.PendingPods()
+	for _, cq := range q.clusterQueues {
+		pods = append(pods, cq.pending.PendingPods()...)
+	}
+
+	return pods
+}
+
+// Metrics returns a metrics of this QuotaQueue.
+// PendingPodsNum counts both pods waiting for admission and admitted pods waiting for scheduling.
+func (q *QuotaQueue) Metrics() Metrics {
+	usage := q.usage(true)
+
+	pendingPodsNum := q.admitted.Metrics().PendingPodsNum
+	cqMetrics := make(map[string]ClusterQueueMetrics, len(q.clusterQueues))
+	for _, cq := range q.clusterQueues {
+		pendingPodsNum += cq.pending.inner.Len()
+
+		admittedPodsNum := 0
+		for _, w := range q.workloads {
+			if w.cq == cq {
+				admittedPodsNum++
+			}
+		}
+
+		avgWait := 0.0
+		if cq.admittedTotal > 0 {
+			avgWait = cq.waitSum.Seconds() / float64(cq.admittedTotal)
+		}
+
+		cqMetrics[cq.name] = ClusterQueueMetrics{
+			PendingPodsNum:    cq.pending.inner.Len(),
+			AdmittedPodsNum:   admittedPodsNum,
+			Usage:             usage[cq],
+			AdmittedPodsTotal: cq.admittedTotal,
+			AvgWaitSeconds:    avgWait,
+			MaxWaitSeconds:    cq.waitMax.Seconds(),
+		}
+	}
+
+	return Metrics{
+		PendingPodsNum: pendingPodsNum,
+		ClusterQueues:  cqMetrics,
+	}
+}
+
+// AddUnschedulable adds the admitted pod to the unschedulable queue of the SchedulingQueue.
+// The pod keeps holding the quota.
+func (q *QuotaQueue) AddUnschedulable(clock clock.Clock, pod *v1.Pod) error {
+	return q.admitted.AddUnschedulable(clock, pod)
+}
+
+func (q *QuotaQueue) Flush(clock clock.Clock) {
+	q.admitted.Flush(clock)
+}
+
+func (q *QuotaQueue) MoveAllToActive(clock clock.Clock) {
+	q.admitted.MoveAllToActive(clock)
+}
+
+// UpdateCluster releases the quota held by pods that have terminated or been deleted, and admits
+// pending pods as the quotas permit.
+// Returns pods of borrowing ClusterQueues to be evicted to reclaim the quota.
+func (q *QuotaQueue) UpdateCluster(
+	clk clock.Clock, nodeInfoMap map[string]*nodeinfo.NodeInfo,
+) []*v1.Pod {
+
+	alive := map[string]bool{}
+	for _, info := range nodeInfoMap {
+		for _, pod := range info.Pods() {
+			key, _ := util.PodKey(pod) // pods on nodes never have invalid key
+			alive[key] = true
+		}
+	}
+
+	for key, w := range q.workloads {
+		if alive[key] {
+			continue
+		}
+		// A pod that is neither bound nor queued is waiting for its binding to take effect.
+		if w.pod.Spec.NodeName != "" || (w.deleted && !q.admitted.contains(key)) {
+			delete(q.workloads, key)
+		}
+	}
+
+	usage := q.usage(true)
+	usageWithoutEvicting := q.usage(false)
+	victims := []*v1.Pod{}
+
+	for _, borrow := range []bool{false, true} {
+		for _, cq := range q.clusterQueues {
+			pods := cq.pending.inner.pendingPods()
+			sort.Slice(pods, func(i, j int) bool {
+				if DefaultComparator(pods[i], pods[j]) || DefaultComparator(pods[j], pods[i]) {
+					return DefaultComparator(pods[i], pods[j])
+				}
+				return pods[i].Name < pods[j].Name // for determinism
+			})
+
+			for _, pod := range pods {
+				requests := util.PodTotalResourceRequests(pod)
+
+				if flavor, ok := q.findFlavor(usage, cq, requests, borrow); ok {
+					q.admit(clk, usage, usageWithoutEvicting, cq, pod, flavor, requests)
+					continue
+				}
+
+				if !borrow {
+					evicted := q.reclaim(usageWithoutEvicting, alive, cq, requests)
+					victims = append(victims, evicted...)
+				}
+			}
+		}
+	}
+
+	return victims
+}
+
+var _ = UnschedulablePodQueue(&QuotaQueue{})
+var _ = ClusterAwarePodQueue(&QuotaQueue{})
+var _ = PendingPodLister(&QuotaQueue{})
+
+// findFlavor returns the first flavor of the ClusterQueue in which the requests fit under the given
+// usage.
+// If borrow is false, the requests must fit in the nominal quota of the ClusterQueue.
+func (q *QuotaQueue) findFlavor(
+	usage quotaUsage, cq *clusterQueue, requests v1.ResourceList, borrow bool,
+) (string, bool) {
+
+	for _, flavor := range cq.flavors {
+		if q.fitsInClusterQueue(usage, cq, flavor, requests, borrow) &&
+			q.fitsInCohort(usage, cq, flavor, requests) {
+			return flavor, true
+		}
+	}
+
+	return "", false
+}
+
+// admit admits the pod to the ClusterQueue with the flavor, and pushes it to the SchedulingQueue.
+func (q *QuotaQueue) admit(
+	clk clock.Clock, usage, usageWithoutEvicting quotaUsage,
+	cq *clusterQueue, pod *v1.Pod, flavor string, requests v1.ResourceList,
+) {
+
+	key, _ := util.PodKey(pod) // stored pod never have invalid key
+	cq.pending.Delete(pod.Namespace, pod.Name)
+
+	q.workloads[key] = &workload{
+		pod:        pod,
+		cq:         cq,
+		flavor:     flavor,
+		requests:   requests,
+		admittedAt: clk,
+	}
+	usage.add(cq, flavor, requests)
+	usageWithoutEvicting.add(cq, flavor, requests)
+
+	wait := clk.Sub(clock.NewClockWithMetaV1(pod.CreationTimestamp))
+	cq.admittedTotal++
+	cq.waitSum += wait
+	if wait > cq.waitMax {
+		cq.waitMax = wait
+	}
+
+	q.assignFlavor(pod, flavor)
+	_ = q.admitted.Push(pod) // stored pod never have invalid key
+}
+
+// reclaim selects pods of borrowing ClusterQueues in the cohort to be evicted, so that the requests
+// fit in the nominal quota of the ClusterQueue.
+// The requests are reserved in usageWithoutEvicting, so that the other pods do not take the quota
+// being reclaimed.
+// Returns nothing if the quota is being reclaimed already, or cannot be reclaimed.
+func (q *QuotaQueue) reclaim(
+	usageWithoutEvicting quotaUsage, alive map[string]bool, cq *clusterQueue, requests v1.ResourceList,
+) []*v1.Pod {
+
+	for _, flavor := range cq.flavors {
+		if !q.fitsInClusterQueue(usageWithoutEvicting, cq, flavor, requests, false) {
+			continue
+		}
+		if q.fitsInCohort(usageWithoutEvicting, cq, flavor, requests) {
+			// Evictions in progress will make room for the requests.
+			usageWithoutEvicting.add(cq, flavor, requests)
+			return nil
+		}
+
+		candidates := []*workload{}
+		for key, w := range q.workloads {
+			if w.cq != cq && w.cq.cohort == cq.cohort && w.cq.cohort != "" && w.flavor == flavor &&
+				alive[key] && !w.deleted && !w.evicting {
+				candidates = append(candidates, w)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			w0, w1 := candidates[i], candidates[j]
+			prio0 := util.PodPriority(w0.pod)
+			prio1 := util.PodPriority(w1.pod)
+			if prio0 != prio1 {
+				return prio0 < prio1
+			}
+			if w0.admittedAt.Before(w1.admittedAt) || w1.admittedAt.Before(w0.admittedAt) {
+				return w1.admittedAt.Before(w0.admittedAt)
+			}
+			// Pods admitted at the same clock are evicted in the reverse order of admission.
+			if DefaultComparator(w0.pod, w1.pod) || DefaultComparator(w1.pod, w0.pod) {
+				return DefaultComparator(w1.pod, w0.pod)
+			}
+			return w0.pod.Name > w1.pod.Name
+		})
+
+		selected := []*workload{}
+		for _, w := range candidates {
+			if !usageWithoutEvicting.isBorrowing(w.cq, flavor) {
+				continue
+			}
+
+			usageWithoutEvicting.sub(w.cq, flavor, w.requests)
+			selected = append(selected, w)
+			if q.fitsInCohort(usageWithoutEvicting, cq, flavor, requests) {
+				break
+			}
+		}
+
+		if !q.fitsInCohort(usageWithoutEvicting, cq, flavor, requests) {
+			for _, w := range selected {
+				usageWithoutEvicting.add(w.cq, flavor, w.requests)
+			}
+			continue
+		}
+
+		victims := make([]*v1.Pod, 0, len(selected))
+		for _, w := range selected {
+			w.evicting = true
+			victims = append(victims, w.pod)
+		}
+		usageWithoutEvicting.add(cq, flavor, requests)
+
+		return victims
+	}
+
+	return nil
+}
+
+// fitsInClusterQueue returns whether the requests fit in the quota of the flavor of the
+// ClusterQueue under the given usage.
+// If borrow is false, the requests must fit in the nominal quota.
+func (q *QuotaQueue) fitsInClusterQueue(
+	usage quotaUsage, cq *clusterQueue, flavor string, requests v1.ResourceList, borrow bool,
+) bool {
+
+	used := util.ResourceListSum(usage[cq][flavor], requests)
+	for name, quantity := range used {
+		if quantity.IsZero() {
+			continue
+		}
+
+		nominal, ok := cq.nominal[flavor][name]
+		if !ok {
+			return false
+		}
+		if !borrow || cq.cohort == "" {
+			if quantity.Cmp(nominal) > 0 {
+				return false
+			}
+			continue
+		}
+
+		if limit, ok := cq.borrowingLimit[flavor][name]; ok {
+			limit = limit.DeepCopy()
+			limit.Add(nominal)
+			if quantity.Cmp(limit) > 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// fitsInCohort returns whether the requests fit in the sum of the nominal quotas of the flavor of
+// the ClusterQueues in the cohort of cq under the given usage.
+func (q *QuotaQueue) fitsInCohort(
+	usage quotaUsage, cq *clusterQueue, flavor string, requests v1.ResourceList,
+) bool {
+
+	capacity := v1.ResourceList{}
+	used := requests
+	for _, member := range q.clusterQueues {
+		if member != cq && (cq.cohort == "" || member.cohort != cq.cohort) {
+			continue
+		}
+		capacity = util.ResourceListSum(capacity, member.nominal[flavor])
+		used = util.ResourceListSum(used, usage[member][flavor])
+	}
+
+	for name, quantity := range used {
+		if quantity.IsZero() {
+			continue
+		}
+		if c, ok := capacity[name]; !ok || quantity.Cmp(c) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// usage computes the resources held by the workloads.
+// If includeEvicting is false, workloads being evicted are excluded.
+func (q *QuotaQueue) usage(includeEvicting bool) quotaUsage {
+	usage := quotaUsage{}
+	for _, cq := range q.clusterQueues {
+		usage[cq] = make(map[string]v1.ResourceList, len(cq.flavors))
+		for _, flavor := range cq.flavors {
+			usage[cq][flavor] = v1.ResourceList{}
+		}
+	}
+
+	for _, w := range q.workloads {
+		if includeEvicting || !w.evicting {
+			usage.add(w.cq, w.flavor, w.requests)
+		}
+	}
+
+	return usage
+}
+
+// assignFlavor adds the NodeLabels of the flavor to the node selector of the pod.
+func (q *QuotaQueue) assignFlavor(pod *v1.Pod, flavor string) {
+	labels := q.flavors[flavor].NodeLabels
+	if len(labels) == 0 {
+		return
+	}
+
+	if pod.Spec.NodeSelector == nil {
+		pod.Spec.NodeSelector = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		pod.Spec.NodeSelector[k] = v
+	}
+}
+
+func (u quotaUsage) add(cq *clusterQueue, flavor string, requests v1.ResourceList) {
+	u[cq][flavor] = util.ResourceListSum(u[cq][flavor], requests)
+}
+
+func (u quotaUsage) sub(cq *clusterQueue, flavor string, requests v1.ResourceList) {
+	used := u[cq][flavor].DeepCopy()
+	for name, quantity := range requests {
+		if val, ok := used[name]; ok {
+			val.Sub(quantity)
+			used[name] = val
+		}
+	}
+	u[cq][flavor] = used
+}
+
+// isBorrowing returns whether the ClusterQueue uses more than its nominal quota of the flavor.
+func (u quotaUsage) isBorrowing(cq *clusterQueue, flavor string) bool {
+	for name, quantity := range u[cq][flavor] {
+		if quantity.IsZero() {
+			continue
+		}
+		if nominal, ok := cq.nominal[flavor][name]; !ok || quantity.Cmp(nominal) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
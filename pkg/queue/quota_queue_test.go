@@ -0,0 +1,187 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+func newQueuedPod(name, queueName, cpu string) *v1.Pod {
+	pod := newPod(name)
+	pod.Labels = map[string]string{queue.QueueNameLabel: queueName}
+	pod.Spec.Containers = []v1.Container{{
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{"cpu": resource.MustParse(cpu)},
+		},
+	}}
+	return pod
+}
+
+func newQuotaQueue(t *testing.T, borrowingLimit *resource.Quantity) *queue.QuotaQueue {
+	flavors := []queue.ResourceFlavor{{Name: "default", NodeLabels: map[string]string{"pool": "a"}}}
+
+	clusterQueues := []queue.ClusterQueue{}
+	for _, name := range []string{"team-a", "team-b"} {
+		clusterQueues = append(clusterQueues, queue.ClusterQueue{
+			Name:   name,
+			Cohort: "all",
+			Flavors: []queue.FlavorQuotas{{
+				Flavor: "default",
+				Resources: map[v1.ResourceName]queue.ResourceQuota{
+					"cpu": {Nominal: resource.MustParse("2"), BorrowingLimit: borrowingLimit},
+				},
+			}},
+		})
+	}
+
+	localQueues := []queue.LocalQueue{
+		{Namespace: "default", Name: "lq-a", ClusterQueue: "team-a"},
+		{Namespace: "default", Name: "lq-b", ClusterQueue: "team-b"},
+	}
+
+	q, err := queue.NewQuotaQueue(flavors, clusterQueues, localQueues)
+	if err != nil {
+		t.Fatalf("error %+v", err)
+	}
+	return q
+}
+
+// popAll pops all pods from the queue and returns them as if they are bound to a node.
+func popAll(q queue.PodQueue) []*v1.Pod {
+	pods := []*v1.Pod{}
+	for {
+		pod, err := q.Pop()
+		if err != nil {
+			return pods
+		}
+		pod.Spec.NodeName = "node-0"
+		pods = append(pods, pod)
+	}
+}
+
+func TestQuotaQueueAdmitWithinNominal(t *testing.T) {
+	zero := resource.MustParse("0")
+	q := newQuotaQueue(t, &zero)
+	clk := clock.NewClock(time.Now())
+
+	for _, name := range []string{"pod-0", "pod-1", "pod-2"} {
+		pod := newQueuedPod(name, "lq-a", "1")
+		pod.CreationTimestamp = clk.ToMetaV1()
+		_ = q.Push(pod)
+	}
+
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	victims := q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{})
+	assert.Empty(t, victims)
+
+	pods := popAll(q)
+	assert.Equal(t, 2, len(pods))
+	assert.Equal(t, "a", pods[0].Spec.NodeSelector["pool"])
+
+	met := q.Metrics()
+	assert.Equal(t, 1, met.PendingPodsNum)
+	assert.Equal(t, 1, met.ClusterQueues["team-a"].PendingPodsNum)
+	assert.Equal(t, 2, met.ClusterQueues["team-a"].AdmittedPodsNum)
+	usage := met.ClusterQueues["team-a"].Usage["default"]["cpu"]
+	assert.Equal(t, int64(2), usage.Value())
+
+	// The quota is released once the pods terminate.
+	q.UpdateCluster(clk.Add(time.Second), map[string]*nodeinfo.NodeInfo{})
+	pod, _ := q.Front()
+	assert.Equal(t, "pod-2", pod.Name)
+	assert.Equal(t, 1.0, q.Metrics().ClusterQueues["team-a"].MaxWaitSeconds)
+}
+
+func TestQuotaQueueBorrowAndReclaim(t *testing.T) {
+	q := newQuotaQueue(t, nil)
+	clk := clock.NewClock(time.Now())
+
+	for i, name := range []string{"pod-a0", "pod-a1", "pod-a2"} {
+		pod := newQueuedPod(name, "lq-a", "1")
+		pod.CreationTimestamp = clk.Add(time.Duration(i) * time.Millisecond).ToMetaV1()
+		_ = q.Push(pod)
+	}
+	q.UpdateCluster(clk, map[string]*nodeinfo.NodeInfo{})
+
+	// team-a borrows 1 cpu from team-b.
+	running := popAll(q)
+	assert.Equal(t, 3, len(running))
+	nodeInfoMap := map[string]*nodeinfo.NodeInfo{"node-0": nodeinfo.NewNodeInfo(running...)}
+
+	_ = q.Push(newQueuedPod("pod-b0", "lq-b", "1"))
+	_ = q.Push(newQueuedPod("pod-b1", "lq-b", "1"))
+
+	victims := q.UpdateCluster(clk.Add(time.Second), nodeInfoMap)
+	assert.Equal(t, 1, len(victims))
+	assert.Equal(t, "pod-a2", victims[0].Name)
+
+	pod, _ := q.Pop()
+	assert.Equal(t, "pod-b0", pod.Name)
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	// The victim is being evicted, so no more pods are evicted.
+	victims = q.UpdateCluster(clk.Add(2*time.Second), nodeInfoMap)
+	assert.Empty(t, victims)
+
+	// The reclaimed quota is admitted to team-b after the victim terminates.
+	pod.Spec.NodeName = "node-0"
+	nodeInfoMap["node-0"] = nodeinfo.NewNodeInfo(running[0], running[1], pod)
+	victims = q.UpdateCluster(clk.Add(3*time.Second), nodeInfoMap)
+	assert.Empty(t, victims)
+
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-b1", pod.Name)
+}
+
+func TestQuotaQueuePush(t *testing.T) {
+	q := newQuotaQueue(t, nil)
+
+	// Pods without the label bypass the quota.
+	_ = q.Push(newPod("pod-0"))
+	pod, _ := q.Front()
+	assert.Equal(t, "pod-0", pod.Name)
+
+	err := q.Push(newQueuedPod("pod-1", "lq-c", "1"))
+	assert.EqualError(t, err, "No LocalQueue \"lq-c\" in namespace \"default\" for pod \"default/pod-1\"")
+
+	_ = q.Push(newQueuedPod("pod-2", "lq-a", "1"))
+	assert.True(t, q.Delete("default", "pod-2"))
+	assert.Equal(t, 1, q.Metrics().PendingPodsNum)
+}
+
+func TestNewQuotaQueueInvalid(t *testing.T) {
+	_, err := queue.NewQuotaQueue(nil, []queue.ClusterQueue{{
+		Name:    "team-a",
+		Flavors: []queue.FlavorQuotas{{Flavor: "default"}},
+	}}, nil)
+	assert.EqualError(t, err, "ClusterQueue \"team-a\" refers to undefined flavor \"default\"")
+
+	_, err = queue.NewQuotaQueue(nil, nil, []queue.LocalQueue{
+		{Namespace: "default", Name: "lq-a", ClusterQueue: "team-a"},
+	})
+	assert.EqualError(t, err, "LocalQueue \"default/lq-a\" refers to undefined ClusterQueue \"team-a\"")
+}
@@ -0,0 +1,402 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+const (
+	// DefaultInitialBackoff is the default backoff duration of a pod that has failed to be
+	// scheduled once.
+	DefaultInitialBackoff = 1 * time.Second
+	// DefaultMaxBackoff is the default upper limit of the backoff duration of a pod.
+	DefaultMaxBackoff = 10 * time.Second
+	// DefaultUnschedulableTimeout is the default maximum duration for which a pod stays in the
+	// unschedulable queue without any cluster event.
+	DefaultUnschedulableTimeout = 60 * time.Second
+)
+
+// SchedulingQueue stores pods in three sub-queues, in the same way as kube-scheduler's
+// SchedulingQueue: activeQ holds pods to be scheduled sorted by the comparator, podBackoffQ holds
+// pods waiting for their backoff to be completed, and unschedulableQ holds pods that have failed to
+// be scheduled and are waiting for a cluster event.
+// Only pods in activeQ are returned from Pop and Front, so a pod that does not fit in any node
+// does not block the pods behind it.
+// The backoff duration of each pod grows exponentially in the simulated time, every time the pod
+// fails to be scheduled.
+type SchedulingQueue struct {
+	// A pod exists in at most one of the sub-queues.
+
+	activeQ        rawPriorityQueue
+	podBackoffQ    rawPriorityQueue
+	unschedulableQ map[string]*unschedulablePod
+
+	podBackoff    map[string]*podBackoffEntry
+	nominatedPods map[string]map[string]*v1.Pod
+
+	initialBackoff       time.Duration
+	maxBackoff           time.Duration
+	unschedulableTimeout time.Duration
+}
+
+// unschedulablePod is a pod stored in the unschedulable queue with the clock at which it was added.
+type unschedulablePod struct {
+	pod     *v1.Pod
+	addedAt clock.Clock
+}
+
+// podBackoffEntry records how many times a pod has failed to be scheduled, and the clock until
+// which the pod is backing off.
+type podBackoffEntry struct {
+	attempts     int
+	backoffUntil clock.Clock
+}
+
+// NewSchedulingQueue creates a new SchedulingQueue with DefaultComparator and the default backoff
+// durations.
+func NewSchedulingQueue() *SchedulingQueue {
+	return NewSchedulingQueueWithBackoff(DefaultComparator, DefaultInitialBackoff, DefaultMaxBackoff)
+}
+
+// NewSchedulingQueueWithBackoff creates a new SchedulingQueue with the given comparator and backoff
+// durations.
+func NewSchedulingQueueWithBackoff(
+	comparator Compare, initialBackoff, maxBackoff time.Duration,
+) *SchedulingQueue {
+
+	q := &SchedulingQueue{
+		activeQ: rawPriorityQueue{
+			items:      map[string]*item{},
+			keys:       []string{},
+			comparator: comparator,
+		},
+		unschedulableQ: map[string]*unschedulablePod{},
+
+		podBackoff:    map[string]*podBackoffEntry{},
+		nominatedPods: map[string]map[string]*v1.Pod{},
+
+		initialBackoff:       initialBackoff,
+		maxBackoff:           maxBackoff,
+		unschedulableTimeout: DefaultUnschedulableTimeout,
+	}
+
+	q.podBackoffQ = rawPriorityQueue{
+		items:      map[string]*item{},
+		keys:       []string{},
+		comparator: q.backoffCompletesEarlier,
+	}
+
+	return q
+}
+
+// Push pushes the pod to the active queue.
+// If the pod is in the backoff or unschedulable queue, it is moved to the active queue.
+func (q *SchedulingQueue) Push(pod *v1.Pod) error {
+	key, err := util.PodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	if itm, ok := q.podBackoffQ.items[key]; ok {
+		heap.Remove(&q.podBackoffQ, itm.index)
+	}
+	delete(q.unschedulableQ, key)
+
+	if itm, ok := q.activeQ.items[key]; ok {
+		itm.pod = pod
+		heap.Fix(&q.activeQ, itm.index)
+		return nil
+	}
+
+	heap.Push(&q.activeQ, &item{pod: pod})
+	return nil
+}
+
+// Pop pops the pod on the front of the active queue.
+func (q *SchedulingQueue) Pop() (*v1.Pod, error) {
+	if q.activeQ.Len() == 0 {
+		return nil, ErrEmptyQueue
+	}
+	return heap.Pop(&q.activeQ).(*item).pod, nil
+}
+
+// Front refers the pod on the front of the active queue.
+func (q *SchedulingQueue) Front() (*v1.Pod, error) {
+	if q.activeQ.Len() == 0 {
+		return nil, ErrEmptyQueue
+	}
+	return q.activeQ.front().pod, nil
+}
+
+func (q *SchedulingQueue) Delete(podNamespace, podName string) bool {
+	key := util.PodKeyFromNames(podNamespace, podName)
+
+	var pod *v1.Pod
+	if itm, ok := q.activeQ.items[key]; ok {
+		pod = itm.pod
+		heap.Remove(&q.activeQ, itm.index)
+	} else if itm, ok := q.podBackoffQ.items[key]; ok {
+		pod = itm.pod
+		heap.Remove(&q.podBackoffQ, itm.index)
+	} else if upod, ok := q.unschedulableQ[key]; ok {
+		pod = upod.pod
+		delete(q.unschedulableQ, key)
+	} else {
+		return false
+	}
+
+	nominatedNodeName := pod.Status.NominatedNodeName
+	pod.Status.NominatedNodeName = ""
+	delete(q.nominatedPods[nominatedNodeName], key)
+	delete(q.podBackoff, key)
+
+	return true
+}
+
+// Update updates the pod to the newPod.
+// If the pod is in the backoff queue, it is moved to the active queue.
+// If the pod is in the unschedulable queue, it is moved to the backoff queue.
+func (q *SchedulingQueue) Update(podNamespace, podName string, newPod *v1.Pod) error {
+	keyOrig := util.PodKeyFromNames(podNamespace, podName)
+	keyNew, err := util.PodKey(newPod)
+	if err != nil {
+		return err
+	}
+	if keyOrig != keyNew {
+		return ErrDifferentNames
+	}
+
+	if itm, ok := q.activeQ.items[keyOrig]; ok {
+		itm.pod = newPod
+		heap.Fix(&q.activeQ, itm.index)
+		return nil
+	}
+
+	if itm, ok := q.podBackoffQ.items[keyOrig]; ok {
+		heap.Remove(&q.podBackoffQ, itm.index)
+		heap.Push(&q.activeQ, &item{pod: newPod})
+		return nil
+	}
+
+	if _, ok := q.unschedulableQ[keyOrig]; ok {
+		// The pod will be moved to the active queue by Flush once its backoff is completed.
+		delete(q.unschedulableQ, keyOrig)
+		heap.Push(&q.podBackoffQ, &item{pod: newPod})
+		return nil
+	}
+
+	return &ErrNoMatchingPod{key: keyOrig}
+}
+
+func (q *SchedulingQueue) UpdateNominatedNode(pod *v1.Pod, nodeName string) error {
+	if err := q.RemoveNominatedNode(pod); err != nil {
+		return err
+	}
+
+	pod.Status.NominatedNodeName = nodeName
+	key, err := util.PodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := q.nominatedPods[nodeName]; !ok {
+		q.nominatedPods[nodeName] = map[string]*v1.Pod{}
+	}
+	q.nominatedPods[nodeName][key] = pod
+
+	return nil
+}
+
+func (q *SchedulingQueue) RemoveNominatedNode(pod *v1.Pod) error {
+	nodeName := pod.Status.NominatedNodeName
+	if nodeName == "" {
+		return nil
+	}
+
+	key, err := util.PodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	pod.Status.NominatedNodeName = ""
+	delete(q.nominatedPods[nodeName], key)
+
+	return nil
+}
+
+func (q *SchedulingQueue) NominatedPods(nodeName string) []*v1.Pod {
+	pods := make([]*v1.Pod, 0, len(q.nominatedPods[nodeName]))
+	for _, pod := range q.nominatedPods[nodeName] {
+		pods = append(pods, pod)
+	}
+
+	return pods
+}
+
+// Metrics returns a metrics of this SchedulingQueue.
+// PendingPodsNum counts pods in all of the sub-queues.
+func (q *SchedulingQueue) Metrics() Metrics {
+	return Metrics{
+		PendingPodsNum: q.activeQ.Len() + q.podBackoffQ.Len() + len(q.unschedulableQ),
+	}
+}
+
+// PendingPods returns the pods in all of the sub-queues of this SchedulingQueue.
+func (q *SchedulingQueue) PendingPods() []*v1.Pod {
+	pods := append(q.activeQ.pendingPods(), q.podBackoffQ.pendingPods()...)
+	for _, p := range q.unschedulableQ {
+		pods = append(pods, p.pod)
+	}
+
+	return pods
+}
+
+// AddUnschedulable adds the pod to the unschedulable queue, and extends the backoff duration of
+// the pod.
+// Returns error if the pod is already in this SchedulingQueue.
+func (q *SchedulingQueue) AddUnschedulable(clk clock.Clock, pod *v1.Pod) error {
+	key, err := util.PodKey(pod)
+	if err != nil {
+		return err
+	}
+
+	if q.contains(key) {
+		return fmt.Errorf("Pod %q already exists in the queue", key)
+	}
+
+	entry, ok := q.podBackoff[key]
+	if !ok {
+		entry = &podBackoffEntry{}
+		q.podBackoff[key] = entry
+	}
+	entry.attempts++
+	entry.backoffUntil = clk.Add(q.backoffDuration(entry.attempts))
+
+	q.unschedulableQ[key] = &unschedulablePod{pod: pod, addedAt: clk}
+
+	return nil
+}
+
+// Flush moves pods whose backoff has been completed at the given clock from the backoff queue to
+// the active queue, and pods that have stayed in the unschedulable queue longer than
+// DefaultUnschedulableTimeout to the active (or backoff) queue.
+func (q *SchedulingQueue) Flush(clk clock.Clock) {
+	for q.podBackoffQ.Len() > 0 {
+		pod := q.podBackoffQ.front().pod
+		key, _ := util.PodKey(pod) // stored pod never have invalid key
+		if q.isPodBackingOff(clk, key) {
+			break
+		}
+
+		heap.Pop(&q.podBackoffQ)
+		heap.Push(&q.activeQ, &item{pod: pod})
+	}
+
+	for key, upod := range q.unschedulableQ {
+		if clk.Sub(upod.addedAt) > q.unschedulableTimeout {
+			q.moveFromUnschedulable(clk, key)
+		}
+	}
+
+	// Forget backoff of pods that have left this queue long before.
+	for key, entry := range q.podBackoff {
+		if !q.contains(key) && clk.Sub(entry.backoffUntil) > q.maxBackoff {
+			delete(q.podBackoff, key)
+		}
+	}
+}
+
+// MoveAllToActive moves all pods in the unschedulable queue to the active queue, or to the backoff
+// queue if their backoff has not been completed at the given clock.
+func (q *SchedulingQueue) MoveAllToActive(clk clock.Clock) {
+	for key := range q.unschedulableQ {
+		q.moveFromUnschedulable(clk, key)
+	}
+}
+
+var _ = UnschedulablePodQueue(&SchedulingQueue{})
+var _ = PendingPodLister(&SchedulingQueue{})
+
+// moveFromUnschedulable moves the pod associated with the key from the unschedulable queue to the
+// active or backoff queue.
+func (q *SchedulingQueue) moveFromUnschedulable(clk clock.Clock, key string) {
+	upod := q.unschedulableQ[key]
+	delete(q.unschedulableQ, key)
+
+	if q.isPodBackingOff(clk, key) {
+		heap.Push(&q.podBackoffQ, &item{pod: upod.pod})
+	} else {
+		heap.Push(&q.activeQ, &item{pod: upod.pod})
+	}
+}
+
+// contains returns whether the pod associated with the key is in any of the sub-queues.
+func (q *SchedulingQueue) contains(key string) bool {
+	if _, ok := q.activeQ.items[key]; ok {
+		return true
+	}
+	if _, ok := q.podBackoffQ.items[key]; ok {
+		return true
+	}
+	_, ok := q.unschedulableQ[key]
+	return ok
+}
+
+// isPodBackingOff returns whether the pod associated with the key is backing off at the given
+// clock.
+func (q *SchedulingQueue) isPodBackingOff(clk clock.Clock, key string) bool {
+	entry, ok := q.podBackoff[key]
+	return ok && clk.Before(entry.backoffUntil)
+}
+
+// backoffDuration calculates the backoff duration of a pod that has failed to be scheduled the
+// given times, doubling from initialBackoff up to maxBackoff.
+func (q *SchedulingQueue) backoffDuration(attempts int) time.Duration {
+	dur := q.initialBackoff
+	for i := 1; i < attempts; i++ {
+		dur *= 2
+		if dur > q.maxBackoff {
+			return q.maxBackoff
+		}
+	}
+
+	if dur > q.maxBackoff {
+		return q.maxBackoff
+	}
+	return dur
+}
+
+// backoffCompletesEarlier is the comparator of the backoff queue.
+// Returns true if the backoff of pod0 completes earlier than that of pod1.
+func (q *SchedulingQueue) backoffCompletesEarlier(pod0, pod1 *v1.Pod) bool {
+	key0, _ := util.PodKey(pod0) // stored pod never have invalid key
+	key1, _ := util.PodKey(pod1)
+
+	entry0, ok0 := q.podBackoff[key0]
+	entry1, ok1 := q.podBackoff[key1]
+	if !ok0 || !ok1 {
+		return !ok0
+	}
+
+	return entry0.backoffUntil.Before(entry1.backoffUntil)
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+func TestSchedulingQueuePushAndPop(t *testing.T) {
+	q := queue.NewSchedulingQueue()
+
+	_ = q.Push(newPod("pod-0"))
+	_ = q.Push(newPod("pod-1"))
+
+	pod, _ := q.Pop()
+	assert.Equal(t, "pod-0", pod.Name)
+
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-1", pod.Name)
+
+	_, err := q.Pop()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+}
+
+func TestSchedulingQueueUnschedulableDoesNotBlock(t *testing.T) {
+	q := queue.NewSchedulingQueue()
+	clk := clock.NewClock(time.Now())
+
+	_ = q.Push(newPod("pod-0"))
+	_ = q.Push(newPod("pod-1"))
+
+	pod0, _ := q.Pop()
+	if err := q.AddUnschedulable(clk, pod0); err != nil {
+		t.Errorf("error %+v", err)
+	}
+
+	pod, _ := q.Front()
+	assert.Equal(t, "pod-1", pod.Name)
+	assert.Equal(t, 2, q.Metrics().PendingPodsNum)
+
+	_, _ = q.Pop()
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+	assert.Equal(t, 1, q.Metrics().PendingPodsNum)
+
+	err = q.AddUnschedulable(clk, pod0)
+	assert.EqualError(t, err, "Pod \"default/pod-0\" already exists in the queue")
+}
+
+func TestSchedulingQueuePendingPods(t *testing.T) {
+	q := queue.NewSchedulingQueue()
+	clk := clock.NewClock(time.Now())
+
+	_ = q.Push(newPod("pod-0"))
+	_ = q.Push(newPod("pod-1"))
+	pod0, _ := q.Pop()
+	_ = q.AddUnschedulable(clk, pod0)
+
+	names := []string{}
+	for _, pod := range q.PendingPods() {
+		names = append(names, pod.Name)
+	}
+	assert.ElementsMatch(t, []string{"pod-0", "pod-1"}, names)
+}
+
+func TestSchedulingQueueMoveAllToActive(t *testing.T) {
+	q := queue.NewSchedulingQueueWithBackoff(queue.DefaultComparator, 10*time.Second, 40*time.Second)
+	clk := clock.NewClock(time.Now())
+
+	_ = q.Push(newPod("pod-0"))
+	pod, _ := q.Pop()
+	_ = q.AddUnschedulable(clk, pod)
+
+	// Still backing off; the pod is moved to the backoff queue.
+	q.MoveAllToActive(clk.Add(5 * time.Second))
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	q.Flush(clk.Add(5 * time.Second))
+	_, err = q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	q.Flush(clk.Add(10 * time.Second))
+	pod, _ = q.Pop()
+	assert.Equal(t, "pod-0", pod.Name)
+
+	// The backoff duration is doubled.
+	clk = clk.Add(10 * time.Second)
+	_ = q.AddUnschedulable(clk, pod)
+	q.MoveAllToActive(clk.Add(15 * time.Second))
+	_, err = q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	q.Flush(clk.Add(20 * time.Second))
+	pod, _ = q.Front()
+	assert.Equal(t, "pod-0", pod.Name)
+}
+
+func TestSchedulingQueueFlushUnschedulableTimeout(t *testing.T) {
+	q := queue.NewSchedulingQueue()
+	clk := clock.NewClock(time.Now())
+
+	_ = q.Push(newPod("pod-0"))
+	pod, _ := q.Pop()
+	_ = q.AddUnschedulable(clk, pod)
+
+	q.Flush(clk.Add(queue.DefaultUnschedulableTimeout))
+	_, err := q.Front()
+	assert.Equal(t, queue.ErrEmptyQueue, err)
+
+	q.Flush(clk.Add(queue.DefaultUnschedulableTimeout + time.Second))
+	pod, _ = q.Front()
+	assert.Equal(t, "pod-0", pod.Name)
+}
+
+func TestSchedulingQueueDelete(t *testing.T) {
+	q := queue.NewSchedulingQueue()
+	clk := clock.NewClock(time.Now())
+
+	_ = q.Push(newPod("pod-0"))
+	_ = q.Push(newPod("pod-1"))
+	pod, _ := q.Pop()
+	_ = q.AddUnschedulable(clk, pod)
+
+	assert.True(t, q.Delete("default", "pod-0"))
+	assert.False(t, q.Delete("default", "pod-0"))
+	assert.True(t, q.Delete("default", "pod-1"))
+	assert.Equal(t, 0, q.Metrics().PendingPodsNum)
+}
+
+func TestSchedulingQueueUpdate(t *testing.T) {
+	q := queue.NewSchedulingQueue()
+	clk := clock.NewClock(time.Now())
+
+	pod0 := newPod("pod-0")
+
+	err := q.Update("default", "pod-0", pod0)
+	assert.EqualError(t, err, "No pod with key \"default/pod-0\"")
+
+	_ = q.Push(pod0)
+	err = q.Update("default", "pod-0", newPod("pod-1"))
+	assert.EqualError(t, err, "Original and new pods have different names")
+
+	pod, _ := q.Pop()
+	_ = q.AddUnschedulable(clk, pod)
+
+	pod02 := pod0.DeepCopy()
+	prio := int32(1)
+	pod02.Spec.Priority = &prio
+	if err := q.Update("default", "pod-0", pod02); err != nil {
+		t.Errorf("error %+v", err)
+	}
+
+	// The updated pod is moved to the active queue once its backoff is completed.
+	q.Flush(clk.Add(queue.DefaultInitialBackoff))
+	pod, _ = q.Pop()
+	assert.Equal(t, prio, *pod.Spec.Priority)
+}
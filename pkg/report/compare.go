@@ -0,0 +1,385 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+)
+
+// maxSeriesPoints is the maximum number of the points of an aligned time series.
+const maxSeriesPoints = 500
+
+// Variant is a configuration under comparison (e.g., a scheduler), with its runs (e.g., with
+// different seeds).
+type Variant struct {
+	Name string
+	Runs []*Run
+}
+
+// KPI is a key performance indicator of a run.
+type KPI struct {
+	// Name is the name of the KPI, with its unit if any (e.g., "JCT avg (s)").
+	Name string
+	// HigherIsBetter is whether larger values of the KPI are better.
+	HigherIsBetter bool
+}
+
+// Comparison is a comparison of the KPIs and time series of variants, the first of which is the
+// baseline.
+type Comparison struct {
+	Variants []Variant
+	KPIs     []KPI
+	// Estimates[i][k] is the estimate of the k-th KPI of the i-th variant.
+	Estimates [][]Estimate
+	// Deltas[i][k] is the delta of the k-th KPI of the i-th variant from the baseline.
+	Deltas [][]Delta
+	// Series is the time series of the variants aligned on the simulated time elapsed since the start
+	// of each run.
+	Series []Series
+}
+
+// Series is a time series of a metric of each variant, averaged over its runs, at common elapsed
+// times.
+type Series struct {
+	// Name is the name of the metric (e.g., "Pending pods").
+	Name string
+	// Unit is the unit of the values (e.g., "pods").
+	Unit    string
+	Elapsed []time.Duration
+	// Values[i][j] is the average over the runs of the i-th variant at Elapsed[j], or NaN if all of
+	// the runs have ended.
+	Values [][]float64
+}
+
+// Compare compares the variants, the first of which is the baseline.
+// KPIs are computed for each run:
+//   - JCT and waiting time (i.e., queueing delay) from the summary of the run.
+//   - Makespan from the summary of the run.
+//   - Utilization of each resource (the time-weighted ratio of the total usage to the total
+//     allocatable resources of the nodes) from the summary of the run, or from its metrics if the
+//     run has no summary.
+//   - Fairness, Jain's index of the time-weighted dominant shares of the namespaces, where the share
+//     of a namespace is the total requests of its running pods divided by the total allocatable
+//     resources of the nodes.
+//
+// Returns error if there are fewer than 2 variants, or any variant has no runs.
+func Compare(variants []Variant) (*Comparison, error) {
+	if len(variants) < 2 {
+		return nil, fmt.Errorf("At least 2 variants are required, but got %d", len(variants))
+	}
+	for _, variant := range variants {
+		if len(variant.Runs) == 0 {
+			return nil, fmt.Errorf("Variant %q has no runs", variant.Name)
+		}
+	}
+
+	resources := resourceNames(variants)
+	kpis := []KPI{
+		{Name: "JCT avg (s)"},
+		{Name: "JCT p95 (s)"},
+		{Name: "Waiting time avg (s)"},
+		{Name: "Waiting time p95 (s)"},
+		{Name: "Makespan (s)"},
+	}
+	for _, rsrc := range resources {
+		kpis = append(kpis, KPI{Name: "Utilization " + string(rsrc), HigherIsBetter: true})
+	}
+	kpis = append(kpis, KPI{Name: "Fairness (Jain)", HigherIsBetter: true})
+
+	c := &Comparison{
+		Variants:  variants,
+		KPIs:      kpis,
+		Estimates: make([][]Estimate, len(variants)),
+		Deltas:    make([][]Delta, len(variants)),
+	}
+
+	for i, variant := range variants {
+		values := make([][]float64, len(kpis))
+		for _, run := range variant.Runs {
+			for k, v := range runKPIs(run, resources) {
+				values[k] = append(values[k], v)
+			}
+		}
+
+		c.Estimates[i] = make([]Estimate, len(kpis))
+		for k := range kpis {
+			c.Estimates[i][k] = newEstimate(values[k])
+		}
+	}
+
+	for i := range variants {
+		c.Deltas[i] = make([]Delta, len(kpis))
+		for k := range kpis {
+			c.Deltas[i][k] = newDelta(c.Estimates[0][k], c.Estimates[i][k])
+		}
+	}
+
+	c.Series = alignSeries(variants, resources)
+
+	return c, nil
+}
+
+// resourceNames returns the sorted names of the allocatable resources of the nodes in the runs,
+// except pods.
+func resourceNames(variants []Variant) []v1.ResourceName {
+	set := map[v1.ResourceName]struct{}{}
+	for _, variant := range variants {
+		for _, run := range variant.Runs {
+			for _, met := range run.Metrics {
+				for _, nodeMet := range met.Nodes {
+					for rsrc := range nodeMet.Allocatable {
+						if rsrc != v1.ResourcePods {
+							set[rsrc] = struct{}{}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	names := make([]v1.ResourceName, 0, len(set))
+	for rsrc := range set {
+		names = append(names, rsrc)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
+
+// runKPIs returns the KPIs of the run in the order of Compare, which are NaN if not available.
+func runKPIs(run *Run, resources []v1.ResourceName) []float64 {
+	nan := math.NaN()
+	kpis := []float64{nan, nan, nan, nan, nan}
+
+	if s := run.Summary; s != nil {
+		if s.JobCompletionTime.Count > 0 {
+			kpis[0], kpis[1] = s.JobCompletionTime.Avg, s.JobCompletionTime.P95
+		}
+		if s.QueueingDelay.Count > 0 {
+			kpis[2], kpis[3] = s.QueueingDelay.Avg, s.QueueingDelay.P95
+		}
+		if s.CompletedPodsNum > 0 {
+			kpis[4] = s.MakespanSeconds
+		}
+	}
+
+	weights := timeWeights(run)
+	for _, rsrc := range resources {
+		if run.Summary != nil {
+			if util, ok := run.Summary.Utilization[rsrc]; ok {
+				kpis = append(kpis, util)
+				continue
+			}
+		}
+
+		usage, alloc := 0.0, 0.0
+		for i, met := range run.Metrics {
+			u, a := totalUsageAndAllocatable(met, rsrc)
+			usage += weights[i] * u
+			alloc += weights[i] * a
+		}
+		if alloc > 0 {
+			kpis = append(kpis, usage/alloc)
+		} else {
+			kpis = append(kpis, nan)
+		}
+	}
+
+	return append(kpis, fairness(run, weights))
+}
+
+// timeWeights returns the simulated time for which each metrics of the run holds, i.e., until the
+// next metrics, or a time unit for all of them if the run has only one metrics.
+func timeWeights(run *Run) []float64 {
+	weights := make([]float64, len(run.Metrics))
+	if len(run.Metrics) == 1 {
+		weights[0] = 1
+		return weights
+	}
+
+	for i := 0; i+1 < len(run.Metrics); i++ {
+		weights[i] = (run.elapsed(i+1) - run.elapsed(i)).Seconds()
+	}
+
+	return weights
+}
+
+// totalUsageAndAllocatable returns the total usage and allocatable amount of the resource of the
+// nodes, in milli-units.
+func totalUsageAndAllocatable(met metrics.Metrics, rsrc v1.ResourceName) (float64, float64) {
+	usage, alloc := 0.0, 0.0
+	for _, nodeMet := range met.Nodes {
+		if a, ok := nodeMet.Allocatable[rsrc]; ok {
+			u := nodeMet.TotalResourceUsage[rsrc]
+			usage += float64(u.MilliValue())
+			alloc += float64(a.MilliValue())
+		}
+	}
+
+	return usage, alloc
+}
+
+// fairness returns Jain's fairness index of the time-weighted dominant shares of the namespaces in
+// the run, or NaN if no namespace has any share.
+func fairness(run *Run, weights []float64) float64 {
+	shares := map[string]float64{}
+	for i, met := range run.Metrics {
+		alloc := map[v1.ResourceName]float64{}
+		for _, nodeMet := range met.Nodes {
+			for rsrc, a := range nodeMet.Allocatable {
+				if rsrc != v1.ResourcePods {
+					alloc[rsrc] += float64(a.MilliValue())
+				}
+			}
+		}
+
+		requests := map[string]map[v1.ResourceName]float64{}
+		for key, podMet := range met.Pods {
+			if podMet.Phase != v1.PodRunning {
+				continue
+			}
+			ns := key
+			if j := strings.Index(key, "/"); j >= 0 {
+				ns = key[:j]
+			}
+			if requests[ns] == nil {
+				requests[ns] = map[v1.ResourceName]float64{}
+			}
+			for rsrc, req := range podMet.ResourceRequest {
+				requests[ns][rsrc] += float64(req.MilliValue())
+			}
+		}
+
+		for ns, req := range requests {
+			dominant := 0.0
+			for rsrc, r := range req {
+				if a := alloc[rsrc]; a > 0 && r/a > dominant {
+					dominant = r / a
+				}
+			}
+			shares[ns] += weights[i] * dominant
+		}
+	}
+
+	sum, sqSum := 0.0, 0.0
+	for _, share := range shares {
+		sum += share
+		sqSum += share * share
+	}
+	if sqSum == 0 {
+		return math.NaN()
+	}
+
+	return sum * sum / (float64(len(shares)) * sqSum)
+}
+
+// alignSeries builds the time series of the pending pods and the utilization of the resources,
+// averaged over the runs of each variant at common elapsed times.
+func alignSeries(variants []Variant, resources []v1.ResourceName) []Series {
+	// The step is the shortest interval between metrics, but long enough to bound the number of the
+	// points.
+	step, end := time.Duration(0), time.Duration(0)
+	for _, variant := range variants {
+		for _, run := range variant.Runs {
+			for i := 1; i < len(run.Metrics); i++ {
+				if d := run.elapsed(i) - run.elapsed(i-1); d > 0 && (step == 0 || d < step) {
+					step = d
+				}
+			}
+			if e := run.elapsed(len(run.Metrics) - 1); e > end {
+				end = e
+			}
+		}
+	}
+	if minStep := end / maxSeriesPoints; step < minStep {
+		step = minStep
+	}
+
+	elapsed := []time.Duration{0}
+	if step > 0 {
+		for t := step; t <= end; t += step {
+			elapsed = append(elapsed, t)
+		}
+	}
+
+	pending := Series{Name: "Pending pods", Unit: "pods"}
+	pendingOf := func(met metrics.Metrics) float64 { return float64(met.Queue.PendingPodsNum) }
+	series := []Series{pending}
+	valueFuncs := []func(metrics.Metrics) float64{pendingOf}
+
+	for _, rsrc := range resources {
+		rsrc := rsrc
+		series = append(series, Series{Name: "Utilization " + string(rsrc), Unit: "ratio"})
+		valueFuncs = append(valueFuncs, func(met metrics.Metrics) float64 {
+			usage, alloc := totalUsageAndAllocatable(met, rsrc)
+			if alloc == 0 {
+				return math.NaN()
+			}
+			return usage / alloc
+		})
+	}
+
+	for s := range series {
+		series[s].Elapsed = elapsed
+		series[s].Values = make([][]float64, len(variants))
+		for i, variant := range variants {
+			series[s].Values[i] = averageAt(variant.Runs, elapsed, valueFuncs[s])
+		}
+	}
+
+	return series
+}
+
+// averageAt returns the average of the values of the runs at each elapsed time, where each metrics
+// of a run holds until the next one, and the run is excluded after its last metrics.
+func averageAt(runs []*Run, elapsed []time.Duration, value func(metrics.Metrics) float64) []float64 {
+	sums := make([]float64, len(elapsed))
+	counts := make([]int, len(elapsed))
+
+	for _, run := range runs {
+		i := 0
+		for j, t := range elapsed {
+			for i+1 < len(run.Metrics) && run.elapsed(i+1) <= t {
+				i++
+			}
+			if t > run.elapsed(len(run.Metrics)-1) {
+				break
+			}
+			if v := value(run.Metrics[i]); !math.IsNaN(v) {
+				sums[j] += v
+				counts[j]++
+			}
+		}
+	}
+
+	averages := make([]float64, len(elapsed))
+	for j := range elapsed {
+		if counts[j] > 0 {
+			averages[j] = sums[j] / float64(counts[j])
+		} else {
+			averages[j] = math.NaN()
+		}
+	}
+
+	return averages
+}
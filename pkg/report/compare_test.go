@@ -0,0 +1,145 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/pod"
+)
+
+func TestReadRun(t *testing.T) {
+	formatter := metrics.JSONFormatter{}
+	start := clock.NewClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	lines := []string{}
+	for i := 0; i < 2; i++ {
+		met := metrics.Metrics{
+			Clock: start.Add(time.Duration(i) * time.Minute),
+			Nodes: map[string]node.Metrics{"node-0": {
+				Allocatable: v1.ResourceList{"cpu": resource.MustParse("4")},
+			}},
+			Pods: map[string]pod.Metrics{"default/pod-0": {
+				BoundAt: start,
+				Status:  pod.OOMKilled,
+			}},
+		}
+		line, err := formatter.Format(&met)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	summary, err := formatter.FormatSummary(&metrics.Summary{MakespanSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines = append(lines, summary)
+
+	run, err := ReadRun(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(run.Metrics) != 2 {
+		t.Fatalf("got: %d metrics\nwant: 2", len(run.Metrics))
+	}
+	if actual := run.elapsed(1); actual != time.Minute {
+		t.Errorf("got: %v\nwant: %v", actual, time.Minute)
+	}
+	if actual := run.Metrics[1].Pods["default/pod-0"].Status; actual != pod.OOMKilled {
+		t.Errorf("got: %v\nwant: %v", actual, pod.OOMKilled)
+	}
+	if run.Summary == nil || run.Summary.MakespanSeconds != 60 {
+		t.Errorf("got: %+v\nwant: summary with MakespanSeconds 60", run.Summary)
+	}
+
+	if _, err := ReadRun(strings.NewReader("Clock 2019-01-01T00:00:00Z\n")); err == nil {
+		t.Errorf("got: nil\nwant: error")
+	}
+}
+
+// newTestRun creates a run with the JCT, and with a node whose cpu usage is constant at the ratio.
+func newTestRun(jct, utilization float64) *Run {
+	start := clock.NewClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	run := &Run{Summary: &metrics.Summary{
+		JobCompletionTime: metrics.Distribution{Count: 1, Avg: jct, P95: jct},
+	}}
+	for i := 0; i < 3; i++ {
+		run.Metrics = append(run.Metrics, metrics.Metrics{
+			Clock: start.Add(time.Duration(i) * 10 * time.Second),
+			Nodes: map[string]node.Metrics{"node-0": {
+				Allocatable:        v1.ResourceList{"cpu": resource.MustParse("10")},
+				TotalResourceUsage: v1.ResourceList{"cpu": *resource.NewMilliQuantity(int64(utilization*10000), resource.DecimalSI)},
+			}},
+		})
+	}
+
+	return run
+}
+
+func TestCompare(t *testing.T) {
+	variants := []Variant{
+		{Name: "a", Runs: []*Run{newTestRun(10, 0.5), newTestRun(12, 0.5), newTestRun(14, 0.5)}},
+		{Name: "b", Runs: []*Run{newTestRun(20, 0.8), newTestRun(22, 0.8), newTestRun(24, 0.8)}},
+	}
+
+	c, err := Compare(variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jct := c.Estimates[0][0]
+	// Mean 12, standard deviation 2, and t(0.975, 2) = 4.303.
+	if jct.N != 3 || jct.Mean != 12 || math.Abs(jct.HalfWidth-4.303*2/math.Sqrt(3)) > 1e-9 {
+		t.Errorf("got: %+v\nwant: N 3, Mean 12, HalfWidth %v", jct, 4.303*2/math.Sqrt(3))
+	}
+
+	delta := c.Deltas[1][0]
+	// Welch's degrees of freedom is 4 with the equal variances.
+	if delta.Diff != 10 || math.Abs(delta.HalfWidth-2.776*math.Sqrt(8.0/3)) > 1e-9 || !delta.Significant() {
+		t.Errorf("got: %+v\nwant: Diff 10, HalfWidth %v", delta, 2.776*math.Sqrt(8.0/3))
+	}
+
+	var utilization int
+	for k, kpi := range c.KPIs {
+		if kpi.Name == "Utilization cpu" {
+			utilization = k
+		}
+	}
+	if est := c.Estimates[1][utilization]; math.Abs(est.Mean-0.8) > 1e-9 || est.HalfWidth > 1e-9 {
+		t.Errorf("got: %+v\nwant: Mean 0.8, HalfWidth 0", est)
+	}
+
+	for _, series := range c.Series {
+		if len(series.Elapsed) != 3 || series.Elapsed[2] != 20*time.Second {
+			t.Errorf("got: %v\nwant: [0s 10s 20s]", series.Elapsed)
+		}
+	}
+
+	if _, err := Compare(variants[:1]); err == nil {
+		t.Errorf("got: nil\nwant: error")
+	}
+}
@@ -0,0 +1,394 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+)
+
+// Formats of reports.
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// reportTable is a table in a report.
+type reportTable struct {
+	title  string
+	header []string
+	rows   [][]string
+}
+
+// tables returns the tables of the comparison: the runs of the variants, the KPIs of the variants,
+// and the deltas of each variant from the baseline.
+func (c *Comparison) tables() []reportTable {
+	runs := reportTable{title: "Runs", header: []string{"Variant", "Runs", "Files"}}
+	for _, variant := range c.Variants {
+		files := make([]string, 0, len(variant.Runs))
+		for _, run := range variant.Runs {
+			files = append(files, filepath.Base(run.Path))
+		}
+		runs.rows = append(runs.rows,
+			[]string{variant.Name, fmt.Sprint(len(variant.Runs)), strings.Join(files, ", ")})
+	}
+
+	kpis := reportTable{title: "KPIs (mean ± 95% CI over runs)", header: []string{"KPI"}}
+	for _, variant := range c.Variants {
+		kpis.header = append(kpis.header, variant.Name)
+	}
+	for k, kpi := range c.KPIs {
+		row := []string{kpi.Name}
+		for i := range c.Variants {
+			row = append(row, formatEstimate(c.Estimates[i][k]))
+		}
+		kpis.rows = append(kpis.rows, row)
+	}
+
+	tables := []reportTable{runs, kpis}
+
+	baseline := c.Variants[0].Name
+	for i := 1; i < len(c.Variants); i++ {
+		deltas := reportTable{
+			title:  fmt.Sprintf("%s vs %s (difference ± 95%% CI, Welch's t)", c.Variants[i].Name, baseline),
+			header: []string{"KPI", "Difference", "Relative", "Verdict"},
+		}
+		for k, kpi := range c.KPIs {
+			d := c.Deltas[i][k]
+			deltas.rows = append(deltas.rows, []string{
+				kpi.Name, formatDelta(d), formatPercent(d.Relative), verdict(kpi, d),
+			})
+		}
+		tables = append(tables, deltas)
+	}
+
+	return tables
+}
+
+// Plots returns the plots of the comparison: the relative deltas of the KPIs from the baseline, and
+// the aligned time series.
+func (c *Comparison) Plots() []Plot {
+	names := make([]string, 0, len(c.Variants))
+	for _, variant := range c.Variants {
+		names = append(names, variant.Name)
+	}
+
+	plots := []Plot{}
+
+	intervals := []interval{}
+	for k, kpi := range c.KPIs {
+		for i := 1; i < len(c.Variants); i++ {
+			d := c.Deltas[i][k]
+			if math.IsNaN(d.Relative) {
+				continue
+			}
+			label := kpi.Name
+			if len(c.Variants) > 2 {
+				label += " " + c.Variants[i].Name
+			}
+			intervals = append(intervals, interval{
+				label:     label,
+				value:     100 * d.Relative,
+				halfWidth: 100 * d.HalfWidth / math.Abs(c.Estimates[0][k].Mean),
+				color:     i,
+			})
+		}
+	}
+	if len(intervals) > 0 {
+		title := "KPI differences from " + c.Variants[0].Name
+		plots = append(plots, Plot{
+			Name:  "kpi-differences",
+			Title: title,
+			SVG:   renderIntervals(title, "Relative difference (%)", intervals, names[1:], 1),
+		})
+	}
+
+	for _, series := range c.Series {
+		plots = append(plots, Plot{
+			Name:  plotName(series.Name),
+			Title: series.Name,
+			SVG:   renderSeries(series, names),
+		})
+	}
+
+	return plots
+}
+
+// WriteText writes the report of the comparison in plain text, without plots.
+func (c *Comparison) WriteText(w io.Writer) error {
+	str := "Run comparison\n\n"
+	for _, t := range c.tables() {
+		str += t.title + "\n\n" + formatTextTable(t.header, t.rows) + "\n"
+	}
+
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// WriteMarkdown writes the report of the comparison in Markdown, with the plots as images linked at
+// the paths returned by plotPath for the plots (e.g., the files to which their SVG are written).
+func (c *Comparison) WriteMarkdown(w io.Writer, plotPath func(plot Plot) string) error {
+	str := "# Run comparison\n\n"
+	for _, t := range c.tables() {
+		str += "## " + t.title + "\n\n" + formatMarkdownTable(t.header, t.rows) + "\n"
+	}
+
+	str += "## Plots\n\n"
+	for _, plot := range c.Plots() {
+		str += fmt.Sprintf("![%s](%s)\n\n", plot.Title, plotPath(plot))
+	}
+
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// WriteHTML writes the report of the comparison as a self-contained HTML document, with the plots
+// inlined.
+func (c *Comparison) WriteHTML(w io.Writer) error {
+	str := htmlHeader("Run comparison")
+	for _, t := range c.tables() {
+		str += "<h2>" + html.EscapeString(t.title) + "</h2>\n" + formatHTMLTable(t.header, t.rows)
+	}
+
+	str += "<h2>Plots</h2>\n"
+	for _, plot := range c.Plots() {
+		str += "<figure>\n" + plot.SVG + "</figure>\n"
+	}
+	str += htmlFooter
+
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// WriteHTML writes the timeline as a self-contained HTML document, with a summary of the pods and
+// the chart inlined.
+func (t *Timeline) WriteHTML(w io.Writer) error {
+	pods := map[string]struct{}{}
+	ends := map[metrics.PodEventType]int{}
+	for _, bar := range t.Bars {
+		key := bar.Namespace + "/" + bar.Name + "@" + bar.Bound.String()
+		if _, ok := pods[key]; ok {
+			continue
+		}
+		pods[key] = struct{}{}
+		ends[bar.EndType]++
+	}
+
+	peak := 0.0
+	for _, step := range t.Queue {
+		peak = math.Max(peak, step.Value)
+	}
+
+	summary := [][]string{
+		{"Start", t.Start.Format(time.RFC3339)},
+		{"End", t.End.Format(time.RFC3339)},
+		{"Lanes", fmt.Sprint(len(t.Lanes))},
+		{"Bindings", fmt.Sprint(len(pods))},
+		{"Peak pending pods", fmt.Sprint(peak)},
+	}
+	for _, typ := range []metrics.PodEventType{
+		metrics.PodFinished, metrics.PodDeleted, metrics.PodKilled, metrics.PodFailed, metrics.PodBindRetried,
+	} {
+		if ends[typ] > 0 {
+			summary = append(summary, []string{"Bindings ended by " + string(typ), fmt.Sprint(ends[typ])})
+		}
+	}
+	if ends[""] > 0 {
+		summary = append(summary, []string{"Bindings running at the end", fmt.Sprint(ends[""])})
+	}
+
+	str := htmlHeader("Pod timeline")
+	str += formatHTMLTable([]string{"Summary", ""}, summary)
+	str += "<p>Bars are translucent during the startup of pods, and end with a black line if the pods " +
+		"were killed, failed, or preempted. Hover over a bar for the details of its pod.</p>\n"
+	str += `<figure style="overflow-x: auto">` + "\n" + renderTimeline(t) + "</figure>\n"
+	str += htmlFooter
+
+	_, err := io.WriteString(w, str)
+	return err
+}
+
+// decimals returns the number of the decimal places with which a KPI of the magnitude is formatted.
+func decimals(v float64) int {
+	switch abs := math.Abs(v); {
+	case abs >= 1000:
+		return 0
+	case abs >= 1:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// formatNumbers formats the value and the half width of its confidence interval (if not NaN) with
+// the same decimal places, suited to the magnitude of the value.
+func formatNumbers(v, halfWidth float64) (string, string) {
+	if math.IsNaN(v) {
+		return "n/a", ""
+	}
+
+	d := decimals(v)
+	if math.IsNaN(halfWidth) {
+		return strconv.FormatFloat(v, 'f', d, 64), ""
+	}
+	if hd := decimals(halfWidth); hd < d && v != 0 {
+		d = hd
+	}
+	return strconv.FormatFloat(v, 'f', d, 64), strconv.FormatFloat(halfWidth, 'f', d, 64)
+}
+
+func formatEstimate(est Estimate) string {
+	if est.N == 0 {
+		return "n/a"
+	}
+
+	mean, halfWidth := formatNumbers(est.Mean, est.HalfWidth)
+	if halfWidth == "" {
+		return fmt.Sprintf("%s (n=%d)", mean, est.N)
+	}
+	return fmt.Sprintf("%s ± %s (n=%d)", mean, halfWidth, est.N)
+}
+
+func formatDelta(d Delta) string {
+	diff, halfWidth := formatNumbers(d.Diff, d.HalfWidth)
+	if d.Diff > 0 {
+		diff = "+" + diff
+	}
+	if halfWidth == "" {
+		return diff
+	}
+	return diff + " ± " + halfWidth
+}
+
+func formatPercent(v float64) string {
+	if math.IsNaN(v) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", 100*v)
+}
+
+// verdict returns whether the delta of the KPI is significantly better or worse, "n.s." if not
+// significant, or empty if the confidence interval is not available.
+func verdict(kpi KPI, d Delta) string {
+	switch {
+	case math.IsNaN(d.HalfWidth):
+		return ""
+	case !d.Significant():
+		return "n.s."
+	case (d.Diff > 0) == kpi.HigherIsBetter:
+		return "better"
+	default:
+		return "worse"
+	}
+}
+
+func formatTextTable(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if n := len([]rune(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	formatRow := func(row []string) string {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell + strings.Repeat(" ", widths[i]-len([]rune(cell)))
+		}
+		return strings.TrimRight(strings.Join(cells, "  "), " ") + "\n"
+	}
+
+	str := formatRow(header)
+	total := 0
+	for _, w := range widths {
+		total += w + 2
+	}
+	str += strings.Repeat("-", total-2) + "\n"
+	for _, row := range rows {
+		str += formatRow(row)
+	}
+
+	return str
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`)
+
+func formatMarkdownTable(header []string, rows [][]string) string {
+	formatRow := func(row []string) string {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = markdownEscaper.Replace(cell)
+		}
+		return "| " + strings.Join(cells, " | ") + " |\n"
+	}
+
+	str := formatRow(header)
+	str += "|" + strings.Repeat("---|", len(header)) + "\n"
+	for _, row := range rows {
+		str += formatRow(row)
+	}
+
+	return str
+}
+
+func formatHTMLTable(header []string, rows [][]string) string {
+	str := "<table>\n<tr>"
+	for _, cell := range header {
+		str += "<th>" + html.EscapeString(cell) + "</th>"
+	}
+	str += "</tr>\n"
+	for _, row := range rows {
+		str += "<tr>"
+		for _, cell := range row {
+			str += "<td>" + html.EscapeString(cell) + "</td>"
+		}
+		str += "</tr>\n"
+	}
+
+	return str + "</table>\n"
+}
+
+// htmlHeader returns the beginning of an HTML document with the title, styled without external
+// resources.
+func htmlHeader(title string) string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>` + html.EscapeString(title) + `</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+figure { margin: 1em 0; }
+</style>
+</head>
+<body>
+<h1>` + html.EscapeString(title) + "</h1>\n"
+}
+
+const htmlFooter = "</body>\n</html>\n"
@@ -0,0 +1,104 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+)
+
+// maxLineSize is the maximum size of a line of metrics, which grows with the numbers of nodes and
+// pods.
+const maxLineSize = 256 * 1024 * 1024
+
+// Run is a simulation run loaded from the output of a metrics logger with the JSON formatter.
+type Run struct {
+	// Path is the path of the file from which the run was loaded, if any.
+	Path string
+	// Metrics is the metrics written at each metrics tick, in the order of their clocks.
+	// Extensions are not loaded.
+	Metrics []metrics.Metrics
+	// Summary is the summary of the run, or nil if it was not written (e.g., the run was interrupted).
+	Summary *metrics.Summary
+}
+
+// LoadRun loads a Run from the file at the path.
+// Returns error if failed to read the file or to decode any of its lines.
+func LoadRun(path string) (*Run, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	run, err := ReadRun(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	run.Path = path
+
+	return run, nil
+}
+
+// ReadRun reads a Run from the lines of JSON, each of which is either metrics or {"Summary": summary}.
+// Returns error if failed to decode any of the lines, or no metrics are read.
+func ReadRun(r io.Reader) (*Run, error) {
+	run := &Run{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var decoded struct {
+			metrics.Metrics
+			Summary *metrics.Summary
+		}
+		if err := json.Unmarshal(line, &decoded); err != nil {
+			return nil, fmt.Errorf("Line %d is not metrics written by the JSON formatter: %s",
+				lineNum, err.Error())
+		}
+
+		if decoded.Summary != nil {
+			run.Summary = decoded.Summary
+		} else {
+			run.Metrics = append(run.Metrics, decoded.Metrics)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(run.Metrics) == 0 {
+		return nil, fmt.Errorf("No metrics found")
+	}
+
+	return run, nil
+}
+
+// elapsed returns the simulated time from the first metrics of this Run to its i-th metrics.
+func (r *Run) elapsed(i int) time.Duration {
+	return r.Metrics[i].Clock.Sub(r.Metrics[0].Clock)
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import "math"
+
+// Estimate is the mean of a KPI over the runs of a variant, with its 95% confidence interval.
+type Estimate struct {
+	// N is the number of the runs with the KPI.
+	N      int
+	Mean   float64
+	StdDev float64
+	// HalfWidth is the half width of the confidence interval, or NaN if N < 2.
+	HalfWidth float64
+}
+
+// Delta is the difference of the mean of a KPI of a variant from that of the baseline, with its 95%
+// confidence interval by Welch's t-test.
+type Delta struct {
+	Diff float64
+	// HalfWidth is the half width of the confidence interval, or NaN if either of the variants has
+	// fewer than 2 runs with the KPI.
+	HalfWidth float64
+	// Relative is Diff divided by the mean of the baseline, or NaN if the mean is 0.
+	Relative float64
+}
+
+// Significant returns whether the confidence interval of this Delta excludes 0.
+func (d Delta) Significant() bool {
+	return !math.IsNaN(d.HalfWidth) && math.Abs(d.Diff) > d.HalfWidth
+}
+
+// newEstimate estimates the mean of the values, ignoring NaN.
+func newEstimate(values []float64) Estimate {
+	est := Estimate{Mean: math.NaN(), StdDev: math.NaN(), HalfWidth: math.NaN()}
+
+	sum := 0.0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sum += v
+			est.N++
+		}
+	}
+	if est.N == 0 {
+		return est
+	}
+	est.Mean = sum / float64(est.N)
+
+	if est.N < 2 {
+		return est
+	}
+
+	sqSum := 0.0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sqSum += (v - est.Mean) * (v - est.Mean)
+		}
+	}
+	est.StdDev = math.Sqrt(sqSum / float64(est.N-1))
+	est.HalfWidth = tQuantile975(float64(est.N-1)) * est.StdDev / math.Sqrt(float64(est.N))
+
+	return est
+}
+
+// newDelta returns the Delta of the estimate from the baseline.
+func newDelta(baseline, est Estimate) Delta {
+	d := Delta{Diff: est.Mean - baseline.Mean, HalfWidth: math.NaN(), Relative: math.NaN()}
+	if baseline.Mean != 0 {
+		d.Relative = d.Diff / math.Abs(baseline.Mean)
+	}
+
+	if baseline.N < 2 || est.N < 2 {
+		return d
+	}
+
+	vb := baseline.StdDev * baseline.StdDev / float64(baseline.N)
+	ve := est.StdDev * est.StdDev / float64(est.N)
+	if vb+ve == 0 {
+		d.HalfWidth = 0
+		return d
+	}
+
+	// Welch-Satterthwaite degrees of freedom.
+	df := (vb + ve) * (vb + ve) / (vb*vb/float64(baseline.N-1) + ve*ve/float64(est.N-1))
+	d.HalfWidth = tQuantile975(df) * math.Sqrt(vb+ve)
+
+	return d
+}
+
+// tQuantiles975 is the 97.5th percentiles of Student's t-distribution with 1 to 30 degrees of
+// freedom.
+var tQuantiles975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tQuantile975 returns the 97.5th percentile of Student's t-distribution with the degrees of
+// freedom, rounded down to the nearest in the table, which makes confidence intervals conservative.
+func tQuantile975(df float64) float64 {
+	switch {
+	case df < 1:
+		return tQuantiles975[0]
+	case df <= 30:
+		return tQuantiles975[int(df)-1]
+	case df < 40:
+		return tQuantiles975[29]
+	case df < 60:
+		return 2.021
+	case df < 120:
+		return 2.000
+	default:
+		return 1.980
+	}
+}
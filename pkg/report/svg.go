@@ -0,0 +1,518 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+)
+
+// Plot is a chart of a report rendered as a standalone SVG document.
+type Plot struct {
+	// Name is the name of the plot, usable in file names (e.g., "pending-pods").
+	Name  string
+	Title string
+	SVG   string
+}
+
+// plotColors is the colors of the series of plots, in order.
+var plotColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+func plotColor(i int) string {
+	return plotColors[i%len(plotColors)]
+}
+
+// Layout of charts in pixels.
+const (
+	chartWidth        = 760
+	chartHeight       = 320
+	chartMarginLeft   = 72
+	chartMarginRight  = 24
+	chartMarginTop    = 40
+	chartMarginBottom = 48
+)
+
+// svgBuilder builds an SVG document.
+type svgBuilder struct {
+	b strings.Builder
+}
+
+func newSVGBuilder(width, height int) *svgBuilder {
+	s := &svgBuilder{}
+	fmt.Fprintf(&s.b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	s.rect(0, 0, float64(width), float64(height), "#ffffff", "")
+	return s
+}
+
+func (s *svgBuilder) rect(x, y, w, h float64, fill, title string) {
+	if title == "" {
+		fmt.Fprintf(&s.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, fill)
+		return
+	}
+	fmt.Fprintf(&s.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`+"\n",
+		x, y, w, h, fill, html.EscapeString(title))
+}
+
+func (s *svgBuilder) line(x1, y1, x2, y2 float64, stroke string, width float64) {
+	fmt.Fprintf(&s.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%.1f"/>`+"\n",
+		x1, y1, x2, y2, stroke, width)
+}
+
+// polyline draws the points as a line, breaking it at NaN.
+func (s *svgBuilder) polyline(xs, ys []float64, stroke string) {
+	points := []string{}
+	flush := func() {
+		if len(points) > 0 {
+			fmt.Fprintf(&s.b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n",
+				strings.Join(points, " "), stroke)
+			points = points[:0]
+		}
+	}
+
+	for i := range xs {
+		if math.IsNaN(ys[i]) {
+			flush()
+			continue
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", xs[i], ys[i]))
+	}
+	flush()
+}
+
+func (s *svgBuilder) circle(x, y, r float64, fill string) {
+	fmt.Fprintf(&s.b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`+"\n", x, y, r, fill)
+}
+
+// text draws the text with the anchor ("start", "middle", or "end").
+func (s *svgBuilder) text(x, y float64, anchor, str string) {
+	fmt.Fprintf(&s.b, `<text x="%.1f" y="%.1f" text-anchor="%s">%s</text>`+"\n",
+		x, y, anchor, html.EscapeString(str))
+}
+
+func (s *svgBuilder) title(width int, str string) {
+	fmt.Fprintf(&s.b, `<text x="%d" y="20" text-anchor="middle" font-size="14" font-weight="bold">%s</text>`+"\n",
+		width/2, html.EscapeString(str))
+}
+
+// legend draws the names with their colors, from the firstColor-th of plotColors, in a row from the
+// right edge.
+func (s *svgBuilder) legend(right, y float64, names []string, firstColor int) {
+	x := right
+	for i := len(names) - 1; i >= 0; i-- {
+		x -= float64(7*len(names[i]) + 28)
+		s.rect(x, y-9, 12, 12, plotColor(firstColor+i), "")
+		s.text(x+16, y+1, "start", names[i])
+	}
+}
+
+func (s *svgBuilder) String() string {
+	return s.b.String() + "</svg>\n"
+}
+
+// axis maps values in a range to pixels.
+type axis struct {
+	lo, hi       float64
+	pixLo, pixHi float64
+	ticks        []float64
+}
+
+// newAxis creates an axis covering the values with round ticks, starting at 0 if all the values are
+// not negative.
+func newAxis(values []float64, pixLo, pixHi float64) axis {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		lo, hi = 0, 1
+	}
+	if lo >= 0 {
+		lo = 0
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+
+	ticks := niceTicks(lo, hi, 5)
+	return axis{lo: ticks[0], hi: ticks[len(ticks)-1], pixLo: pixLo, pixHi: pixHi, ticks: ticks}
+}
+
+func (a axis) pixel(v float64) float64 {
+	return a.pixLo + (v-a.lo)/(a.hi-a.lo)*(a.pixHi-a.pixLo)
+}
+
+// niceTicks returns about n round values of ticks from at most lo to at least hi.
+func niceTicks(lo, hi float64, n int) []float64 {
+	step := niceNum((hi - lo) / float64(n))
+	start := math.Floor(lo/step) * step
+	end := math.Ceil(hi/step) * step
+
+	ticks := []float64{}
+	for i := 0; start+float64(i)*step <= end+step/2; i++ {
+		// Round off the errors accumulated in the steps.
+		v, _ := strconv.ParseFloat(strconv.FormatFloat(start+float64(i)*step, 'g', 12, 64), 64)
+		ticks = append(ticks, v)
+	}
+
+	return ticks
+}
+
+// niceNum returns 1, 2, or 5 times a power of 10 close to x.
+func niceNum(x float64) float64 {
+	exp := math.Pow(10, math.Floor(math.Log10(x)))
+	switch f := x / exp; {
+	case f < 1.5:
+		return exp
+	case f < 3:
+		return 2 * exp
+	case f < 7:
+		return 5 * exp
+	default:
+		return 10 * exp
+	}
+}
+
+// formatTick formats the value of a tick concisely.
+func formatTick(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e9 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// durationUnit returns the unit of time (hours, minutes, or seconds) in which the durations up to max
+// read well, and its name.
+func durationUnit(max time.Duration) (time.Duration, string) {
+	switch {
+	case max >= 2*time.Hour:
+		return time.Hour, "h"
+	case max >= 2*time.Minute:
+		return time.Minute, "min"
+	default:
+		return time.Second, "s"
+	}
+}
+
+// drawAxes draws the x and y axes with their ticks, grid lines, and labels in the plot area.
+func (s *svgBuilder) drawAxes(x, y axis, xLabel, yLabel string) {
+	for _, t := range y.ticks {
+		py := y.pixel(t)
+		s.line(x.pixLo, py, x.pixHi, py, "#e0e0e0", 1)
+		s.text(x.pixLo-6, py+4, "end", formatTick(t))
+	}
+	for _, t := range x.ticks {
+		px := x.pixel(t)
+		s.line(px, y.pixLo, px, y.pixLo+4, "#000000", 1)
+		s.text(px, y.pixLo+18, "middle", formatTick(t))
+	}
+	s.line(x.pixLo, y.pixLo, x.pixHi, y.pixLo, "#000000", 1)
+	s.line(x.pixLo, y.pixLo, x.pixLo, y.pixHi, "#000000", 1)
+
+	s.text((x.pixLo+x.pixHi)/2, y.pixLo+38, "middle", xLabel)
+	fmt.Fprintf(&s.b, `<text transform="translate(16,%.1f) rotate(-90)" text-anchor="middle">%s</text>`+"\n",
+		(y.pixLo+y.pixHi)/2, html.EscapeString(yLabel))
+}
+
+// renderSeries renders the time series of the variants as a line chart.
+func renderSeries(series Series, variantNames []string) string {
+	unit, unitName := durationUnit(series.Elapsed[len(series.Elapsed)-1])
+	xs := make([]float64, len(series.Elapsed))
+	for j, t := range series.Elapsed {
+		xs[j] = float64(t) / float64(unit)
+	}
+
+	ys := []float64{}
+	for _, values := range series.Values {
+		ys = append(ys, values...)
+	}
+
+	s := newSVGBuilder(chartWidth, chartHeight)
+	s.title(chartWidth, series.Name)
+
+	x := newAxis(xs, chartMarginLeft, chartWidth-chartMarginRight)
+	y := newAxis(ys, chartHeight-chartMarginBottom, chartMarginTop)
+	s.drawAxes(x, y, "Elapsed ("+unitName+")", series.Unit)
+
+	for i, values := range series.Values {
+		pxs := make([]float64, len(xs))
+		pys := make([]float64, len(xs))
+		for j := range xs {
+			pxs[j], pys[j] = x.pixel(xs[j]), math.NaN()
+			if !math.IsNaN(values[j]) {
+				pys[j] = y.pixel(values[j])
+			}
+		}
+		s.polyline(pxs, pys, plotColor(i))
+	}
+	s.legend(chartWidth-chartMarginRight, chartMarginTop-8, variantNames, 0)
+
+	return s.String()
+}
+
+// interval is a value with its confidence interval, labeled in a chart of intervals.
+type interval struct {
+	label     string
+	value     float64
+	halfWidth float64
+	// color is the index of the color in plotColors.
+	color int
+}
+
+// renderIntervals renders the values with their confidence intervals (if not NaN) as a chart with a
+// row per value, with the legend of the names of the colors from the firstColor-th.
+func renderIntervals(title, xLabel string, intervals []interval, legendNames []string, firstColor int) string {
+	const rowHeight = 24
+	const labelWidth = 240
+
+	height := chartMarginTop + rowHeight*len(intervals) + chartMarginBottom
+	s := newSVGBuilder(chartWidth, height)
+	s.title(chartWidth, title)
+
+	values := []float64{0}
+	for _, iv := range intervals {
+		values = append(values, iv.value)
+		if !math.IsNaN(iv.halfWidth) {
+			values = append(values, iv.value-iv.halfWidth, iv.value+iv.halfWidth)
+		}
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+	ticks := niceTicks(lo, hi, 6)
+	x := axis{lo: ticks[0], hi: ticks[len(ticks)-1], pixLo: labelWidth, pixHi: chartWidth - chartMarginRight,
+		ticks: ticks}
+	bottom := float64(height - chartMarginBottom)
+
+	for _, t := range x.ticks {
+		px := x.pixel(t)
+		s.line(px, chartMarginTop, px, bottom, "#e0e0e0", 1)
+		s.text(px, bottom+18, "middle", formatTick(t))
+	}
+	s.line(x.pixel(0), chartMarginTop, x.pixel(0), bottom, "#000000", 1)
+	s.line(x.pixLo, bottom, x.pixHi, bottom, "#000000", 1)
+	s.text((x.pixLo+x.pixHi)/2, bottom+38, "middle", xLabel)
+
+	for r, iv := range intervals {
+		py := float64(chartMarginTop + rowHeight*r + rowHeight/2)
+		s.text(labelWidth-8, py+4, "end", iv.label)
+		color := plotColor(iv.color)
+		if !math.IsNaN(iv.halfWidth) {
+			lo, hi := x.pixel(iv.value-iv.halfWidth), x.pixel(iv.value+iv.halfWidth)
+			s.line(lo, py, hi, py, color, 2)
+			s.line(lo, py-5, lo, py+5, color, 2)
+			s.line(hi, py-5, hi, py+5, color, 2)
+		}
+		s.circle(x.pixel(iv.value), py, 4, color)
+	}
+	if len(legendNames) > 0 {
+		s.legend(chartWidth-chartMarginRight, chartMarginTop-8, legendNames, firstColor)
+	}
+
+	return s.String()
+}
+
+// plotName converts the title of a plot to a name usable in file names (e.g., "Utilization
+// nvidia.com/gpu" to "utilization-nvidia-com-gpu").
+func plotName(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Layout of timelines in pixels.
+const (
+	timelineWidth       = 1200
+	timelineLabelWidth  = 160
+	timelineBarHeight   = 10
+	timelineLanePadding = 3
+	timelinePanelHeight = 120
+)
+
+// renderTimeline renders the timeline as a Gantt chart with a lane per node or GPU, over the panels
+// of the queue length and the utilization on the same time axis.
+func renderTimeline(t *Timeline) string {
+	unit, unitName := durationUnit(t.End.Sub(t.Start))
+	elapsed := func(clk time.Time) float64 { return float64(clk.Sub(t.Start)) / float64(unit) }
+	x := newAxis([]float64{0, elapsed(t.End)}, timelineLabelWidth, timelineWidth-chartMarginRight)
+
+	ganttTop := float64(chartMarginTop + 16)
+	ganttBottom := ganttTop
+	laneTops := make([]float64, len(t.Lanes))
+	for l, lane := range t.Lanes {
+		laneTops[l] = ganttBottom
+		ganttBottom += float64(lane.Rows*timelineBarHeight + 2*timelineLanePadding)
+	}
+	queueTop := ganttBottom + 48
+	utilTop := queueTop + timelinePanelHeight + 56
+	height := int(utilTop) + timelinePanelHeight + chartMarginBottom
+
+	s := newSVGBuilder(timelineWidth, height)
+	s.title(timelineWidth, "Pod timeline ("+t.Options.Lanes+" lanes)")
+
+	for l, lane := range t.Lanes {
+		h := float64(lane.Rows*timelineBarHeight + 2*timelineLanePadding)
+		if l%2 == 0 {
+			s.rect(x.pixLo, laneTops[l], x.pixHi-x.pixLo, h, "#f4f4f4", "")
+		}
+		s.text(x.pixLo-8, laneTops[l]+h/2+4, "end", lane.Name())
+	}
+	for _, tick := range x.ticks {
+		px := x.pixel(tick)
+		s.line(px, ganttTop, px, ganttBottom, "#e0e0e0", 1)
+		s.text(px, ganttBottom+18, "middle", formatTick(tick))
+	}
+	s.line(x.pixLo, ganttBottom, x.pixHi, ganttBottom, "#000000", 1)
+
+	colorNames, colors := t.colorKeys()
+	for i, bar := range t.Bars {
+		x0, x1 := x.pixel(elapsed(bar.Bound)), x.pixel(elapsed(bar.End))
+		y := laneTops[bar.Lane] + float64(timelineLanePadding+bar.Row*timelineBarHeight) + 1
+		h := float64(timelineBarHeight - 2)
+		color := plotColor(colors[i])
+		title := barTitle(t, bar)
+
+		// The startup of the pod is translucent.
+		xs := x1
+		if !bar.Started.IsZero() {
+			xs = x.pixel(elapsed(bar.Started))
+		}
+		if xs > x0 {
+			s.translucentRect(x0, y, xs-x0, h, color, 0.35, title)
+		}
+		if xs < x1 || x1-x0 < 1 {
+			s.rect(xs, y, math.Max(x1-xs, 1), h, color, title)
+		}
+		if bar.EndType == metrics.PodKilled || bar.EndType == metrics.PodFailed || bar.Preemptor != "" {
+			s.line(x1, y-1, x1, y+h+1, "#000000", 2)
+		}
+	}
+	s.legend(timelineWidth-chartMarginRight, chartMarginTop, colorNames, 0)
+
+	queueY := newAxis(stepValues(t.Queue), queueTop+timelinePanelHeight, queueTop)
+	s.drawAxes(x, queueY, "", "pods")
+	s.stepLine(x, queueY, t.Queue, elapsed, t.End, plotColor(0))
+	s.legend(timelineWidth-chartMarginRight, queueTop-8, []string{"Pending pods"}, 0)
+
+	resources, ratios, denominator := t.utilization()
+	values := []float64{0}
+	names := make([]string, len(resources))
+	for i, rsrc := range resources {
+		values = append(values, stepValues(ratios[i])...)
+		names[i] = string(rsrc)
+	}
+	utilY := newAxis(values, utilTop+timelinePanelHeight, utilTop)
+	s.drawAxes(x, utilY, "Elapsed ("+unitName+")", "ratio to "+denominator)
+	for i := range resources {
+		s.stepLine(x, utilY, ratios[i], elapsed, t.End, plotColor(i))
+	}
+	s.legend(timelineWidth-chartMarginRight, utilTop-8, names, 0)
+
+	return s.String()
+}
+
+// translucentRect draws a rect as rect does, with the opacity of the fill.
+func (s *svgBuilder) translucentRect(x, y, w, h float64, fill string, opacity float64, title string) {
+	fmt.Fprintf(&s.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="%.2f"><title>%s</title></rect>`+"\n",
+		x, y, w, h, fill, opacity, html.EscapeString(title))
+}
+
+// stepLine draws the steps from 0 at the start of the axis until the end.
+func (s *svgBuilder) stepLine(x, y axis, steps []Step, elapsed func(time.Time) float64, end time.Time, stroke string) {
+	xs, ys := []float64{x.pixel(0)}, []float64{y.pixel(0)}
+	for _, step := range steps {
+		px := x.pixel(elapsed(step.Clock))
+		xs = append(xs, px, px)
+		ys = append(ys, ys[len(ys)-1], y.pixel(step.Value))
+	}
+	xs = append(xs, x.pixel(elapsed(end)))
+	ys = append(ys, ys[len(ys)-1])
+
+	s.polyline(xs, ys, stroke)
+}
+
+func stepValues(steps []Step) []float64 {
+	values := make([]float64, len(steps))
+	for i, step := range steps {
+		values[i] = step.Value
+	}
+	return values
+}
+
+// barTitle returns the tooltip of the bar, with the clocks elapsed since the start of the timeline.
+func barTitle(t *Timeline, bar Bar) string {
+	str := fmt.Sprintf("%s/%s (priority %d)\n%s", bar.Namespace, bar.Name, bar.Priority, bar.Node)
+	if len(bar.GPUs) > 0 {
+		gpus := make([]string, len(bar.GPUs))
+		for i, gpu := range bar.GPUs {
+			gpus[i] = strconv.Itoa(gpu)
+		}
+		str += ", GPUs " + strings.Join(gpus, ",")
+	}
+
+	str += "\nBound +" + bar.Bound.Sub(t.Start).String()
+	if !bar.Started.IsZero() {
+		str += ", Started +" + bar.Started.Sub(t.Start).String()
+	}
+	if bar.EndType != "" {
+		str += ", " + string(bar.EndType) + " +" + bar.End.Sub(t.Start).String()
+	} else {
+		str += ", running at the end"
+	}
+
+	if len(bar.ResourceRequest) > 0 {
+		requests := make([]string, 0, len(bar.ResourceRequest))
+		for rsrc, q := range bar.ResourceRequest {
+			requests = append(requests, string(rsrc)+"="+q.String())
+		}
+		sort.Strings(requests)
+		str += "\nRequests " + strings.Join(requests, ", ")
+	}
+	if bar.Preemptor != "" {
+		str += "\nPreempted by " + bar.Preemptor
+	}
+	if bar.Reason != "" {
+		str += "\nReason " + bar.Reason
+	}
+
+	return str
+}
@@ -0,0 +1,433 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+)
+
+// Lanes of timelines.
+const (
+	// LanesNode is a lane per node.
+	LanesNode = "node"
+	// LanesGPU is a lane per GPU device, and a lane per node for the pods without GPUs.
+	LanesGPU = "gpu"
+)
+
+// Colors of the bars of timelines.
+const (
+	ColorByNamespace = "namespace"
+	ColorByPriority  = "priority"
+)
+
+// TimelineOptions is the options of a Timeline.
+type TimelineOptions struct {
+	// Lanes is LanesNode or LanesGPU (default LanesNode).
+	Lanes string
+	// ColorBy is ColorByNamespace or ColorByPriority (default ColorByNamespace).
+	ColorBy string
+	// Capacity is the total allocatable resources of the nodes, to which the allocated resources are
+	// plotted as ratios.
+	// If nil, they are plotted as ratios to their peaks, since the event log has no capacities.
+	Capacity v1.ResourceList
+}
+
+// Timeline is the placement of pods on nodes over time, built from the lifecycle events of the pods.
+type Timeline struct {
+	Options TimelineOptions
+	// Start and End are the clocks of the first and the last events.
+	Start time.Time
+	End   time.Time
+	Lanes []Lane
+	// Bars is the bars of the pods, one per lane that each pod occupies.
+	Bars []Bar
+	// Queue is the number of the pending pods, which are submitted (or returned to the queue) and not
+	// scheduled yet.
+	Queue []Step
+	// Allocated is the total requests of the pods bound to the nodes for each resource, in milli-units.
+	Allocated map[v1.ResourceName][]Step
+}
+
+// Lane is a row of the timeline, in which overlapping bars are stacked.
+type Lane struct {
+	Node string
+	// GPU is the index of the GPU device of the lane, or -1 for the lane of a node.
+	GPU int
+	// Rows is the number of the rows of the stacked bars.
+	Rows int
+}
+
+// Name returns the name of this Lane (e.g., "node-0" or "node-0/gpu-1").
+func (l Lane) Name() string {
+	if l.GPU < 0 {
+		return l.Node
+	}
+	return fmt.Sprintf("%s/gpu-%d", l.Node, l.GPU)
+}
+
+// Bar is the period from the binding of a pod to a node until its end.
+type Bar struct {
+	// Lane is the index of the lane of this Bar, and Row is its row in the lane.
+	Lane int
+	Row  int
+
+	Namespace string
+	Name      string
+	Priority  int32
+	Node      string
+	GPUs      []int
+	// ResourceRequest is the resource requests of the pod.
+	ResourceRequest v1.ResourceList
+
+	Bound time.Time
+	// Started is the clock at which the containers of the pod started, or zero if they did not.
+	Started time.Time
+	// End is the clock of EndType event, or the end of the timeline if the pod did not end.
+	End     time.Time
+	EndType metrics.PodEventType
+	// Preemptor is the key of the pod that preempted this pod, if any.
+	Preemptor string
+	// Reason is the reason of the end (e.g., OOMKilled) or the preemption.
+	Reason string
+}
+
+// Step is a value of a step function, which holds from the clock until the next Step.
+type Step struct {
+	Clock time.Time
+	Value float64
+}
+
+// LoadPodEvents loads the lifecycle events of pods from the file at the path.
+// Returns error if failed to read the file or to decode any of its lines.
+func LoadPodEvents(path string) ([]metrics.PodEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events, err := ReadPodEvents(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return events, nil
+}
+
+// ReadPodEvents reads the lifecycle events of pods from the lines of JSON written by
+// metrics.PodEventFileWriter.
+// Returns error if failed to decode any of the lines.
+func ReadPodEvents(r io.Reader) ([]metrics.PodEvent, error) {
+	events := []metrics.PodEvent{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event metrics.PodEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("Line %d is not a pod event: %s", lineNum, err.Error())
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// BuildTimeline builds the Timeline of the events, in the order of their clocks.
+// A bar of a pod starts when the pod is bound, and ends when it is finished, deleted, killed, failed
+// to start, or returned to the queue.
+// Returns error if the options are invalid, there are no events, or any event has an invalid clock.
+func BuildTimeline(events []metrics.PodEvent, opts TimelineOptions) (*Timeline, error) {
+	if opts.Lanes == "" {
+		opts.Lanes = LanesNode
+	}
+	if opts.Lanes != LanesNode && opts.Lanes != LanesGPU {
+		return nil, fmt.Errorf("Unknown lanes %q", opts.Lanes)
+	}
+	if opts.ColorBy == "" {
+		opts.ColorBy = ColorByNamespace
+	}
+	if opts.ColorBy != ColorByNamespace && opts.ColorBy != ColorByPriority {
+		return nil, fmt.Errorf("Unknown color %q", opts.ColorBy)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("No pod events found")
+	}
+
+	t := &Timeline{Options: opts, Allocated: map[v1.ResourceName][]Step{}}
+	pending := map[string]struct{}{}
+	running := map[string]*Bar{}
+	bars := []*Bar{}
+	allocated := map[v1.ResourceName]int64{}
+
+	addRequests := func(clk time.Time, req v1.ResourceList, sign int64) {
+		for rsrc, q := range req {
+			allocated[rsrc] += sign * q.MilliValue()
+			t.Allocated[rsrc] = appendStep(t.Allocated[rsrc], clk, float64(allocated[rsrc]))
+		}
+	}
+
+	for i, event := range events {
+		clk, err := time.Parse(time.RFC3339, event.Clock)
+		if err != nil {
+			return nil, fmt.Errorf("Event %d has invalid clock: %s", i+1, err.Error())
+		}
+		if i == 0 {
+			t.Start = clk
+		}
+		t.End = clk
+
+		key := event.Namespace + "/" + event.Name
+		bar := running[key]
+
+		switch event.Type {
+		case metrics.PodSubmitted:
+			pending[key] = struct{}{}
+		case metrics.PodScheduled:
+			delete(pending, key)
+		case metrics.PodBound:
+			delete(pending, key)
+			bar = &Bar{
+				Namespace:       event.Namespace,
+				Name:            event.Name,
+				Priority:        event.Priority,
+				Node:            event.Node,
+				GPUs:            event.GPUs,
+				ResourceRequest: event.ResourceRequest,
+				Bound:           clk,
+			}
+			running[key] = bar
+			bars = append(bars, bar)
+			addRequests(clk, bar.ResourceRequest, 1)
+		case metrics.PodStarted:
+			if bar != nil {
+				bar.Started = clk
+			}
+		case metrics.PodPreempted:
+			if bar != nil {
+				bar.Preemptor = event.Preemptor
+				bar.Reason = event.Reason
+			}
+		case metrics.PodBindRetried, metrics.PodFailed, metrics.PodFinished, metrics.PodDeleted,
+			metrics.PodKilled:
+			if event.Type == metrics.PodBindRetried {
+				pending[key] = struct{}{}
+			} else {
+				delete(pending, key)
+			}
+			if bar != nil {
+				bar.End = clk
+				bar.EndType = event.Type
+				if event.Reason != "" {
+					bar.Reason = event.Reason
+				}
+				delete(running, key)
+				addRequests(clk, bar.ResourceRequest, -1)
+			}
+		}
+
+		t.Queue = appendStep(t.Queue, clk, float64(len(pending)))
+	}
+
+	for _, bar := range running {
+		bar.End = t.End
+	}
+	t.layout(bars)
+
+	return t, nil
+}
+
+// appendStep appends the value at the clock to the steps, replacing the last step at the same clock
+// and skipping unchanged values.
+func appendStep(steps []Step, clk time.Time, value float64) []Step {
+	if n := len(steps); n > 0 {
+		if steps[n-1].Clock.Equal(clk) {
+			steps[n-1].Value = value
+			if n > 1 && steps[n-2].Value == value {
+				return steps[:n-1]
+			}
+			return steps
+		}
+		if steps[n-1].Value == value {
+			return steps
+		}
+	}
+	return append(steps, Step{Clock: clk, Value: value})
+}
+
+// layout assigns the bars to the lanes sorted by the names of the nodes and the indices of the GPUs,
+// and stacks overlapping bars in each lane on the lowest free rows.
+func (t *Timeline) layout(bars []*Bar) {
+	type laneKey struct {
+		node string
+		gpu  int
+	}
+	laneBars := map[laneKey][]Bar{}
+	for _, bar := range bars {
+		if t.Options.Lanes == LanesGPU && len(bar.GPUs) > 0 {
+			for _, gpu := range bar.GPUs {
+				k := laneKey{bar.Node, gpu}
+				laneBars[k] = append(laneBars[k], *bar)
+			}
+			continue
+		}
+		k := laneKey{bar.Node, -1}
+		laneBars[k] = append(laneBars[k], *bar)
+	}
+
+	keys := make([]laneKey, 0, len(laneBars))
+	for k := range laneBars {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].node != keys[j].node {
+			return keys[i].node < keys[j].node
+		}
+		return keys[i].gpu < keys[j].gpu
+	})
+
+	for l, k := range keys {
+		// Bars are in the order of their binding, so each is placed on the first row free by then.
+		rowEnds := []time.Time{}
+		for _, bar := range laneBars[k] {
+			bar.Lane, bar.Row = l, len(rowEnds)
+			for r, end := range rowEnds {
+				if !end.After(bar.Bound) {
+					bar.Row = r
+					break
+				}
+			}
+			if bar.Row == len(rowEnds) {
+				rowEnds = append(rowEnds, bar.End)
+			} else {
+				rowEnds[bar.Row] = bar.End
+			}
+			t.Bars = append(t.Bars, bar)
+		}
+		t.Lanes = append(t.Lanes, Lane{Node: k.node, GPU: k.gpu, Rows: len(rowEnds)})
+	}
+}
+
+// colorKeys returns the sorted names of the colors of the bars (namespaces or priorities), and the
+// index of the color of each bar.
+func (t *Timeline) colorKeys() ([]string, []int) {
+	if t.Options.ColorBy == ColorByPriority {
+		priorities := []int32{}
+		seen := map[int32]struct{}{}
+		for _, bar := range t.Bars {
+			if _, ok := seen[bar.Priority]; !ok {
+				seen[bar.Priority] = struct{}{}
+				priorities = append(priorities, bar.Priority)
+			}
+		}
+		sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+
+		names := make([]string, len(priorities))
+		indices := map[int32]int{}
+		for i, p := range priorities {
+			names[i] = fmt.Sprintf("priority %d", p)
+			indices[p] = i
+		}
+		colors := make([]int, len(t.Bars))
+		for i, bar := range t.Bars {
+			colors[i] = indices[bar.Priority]
+		}
+		return names, colors
+	}
+
+	names := []string{}
+	seen := map[string]struct{}{}
+	for _, bar := range t.Bars {
+		if _, ok := seen[bar.Namespace]; !ok {
+			seen[bar.Namespace] = struct{}{}
+			names = append(names, bar.Namespace)
+		}
+	}
+	sort.Strings(names)
+
+	indices := map[string]int{}
+	for i, ns := range names {
+		indices[ns] = i
+	}
+	colors := make([]int, len(t.Bars))
+	for i, bar := range t.Bars {
+		colors[i] = indices[bar.Namespace]
+	}
+	return names, colors
+}
+
+// utilization returns the sorted resources and the steps of their allocated ratios to the capacity,
+// or to their peaks if the capacity is not given, and the name of the denominator.
+func (t *Timeline) utilization() ([]v1.ResourceName, [][]Step, string) {
+	resources := []v1.ResourceName{}
+	for rsrc := range t.Allocated {
+		if t.Options.Capacity == nil {
+			resources = append(resources, rsrc)
+		} else if c, ok := t.Options.Capacity[rsrc]; ok && c.MilliValue() > 0 {
+			resources = append(resources, rsrc)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i] < resources[j] })
+
+	denominator := "capacity"
+	if t.Options.Capacity == nil {
+		denominator = "peak"
+	}
+
+	ratios := make([][]Step, len(resources))
+	for i, rsrc := range resources {
+		steps := t.Allocated[rsrc]
+		total := 0.0
+		if t.Options.Capacity != nil {
+			c := t.Options.Capacity[rsrc]
+			total = float64(c.MilliValue())
+		} else {
+			for _, s := range steps {
+				if s.Value > total {
+					total = s.Value
+				}
+			}
+		}
+
+		ratios[i] = make([]Step, len(steps))
+		for j, s := range steps {
+			ratios[i][j] = Step{Clock: s.Clock, Value: s.Value / total}
+			if total == 0 {
+				ratios[i][j].Value = 0
+			}
+		}
+	}
+
+	return resources, ratios, denominator
+}
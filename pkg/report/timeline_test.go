@@ -0,0 +1,105 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+)
+
+const testPodEvents = `
+{"Clock":"2019-01-01T00:00:00Z","Type":"Submitted","Namespace":"a","Name":"pod-0","Priority":1,"ResourceRequest":{"cpu":"2","nvidia.com/gpu":"2"}}
+{"Clock":"2019-01-01T00:00:00Z","Type":"Submitted","Namespace":"b","Name":"pod-1","ResourceRequest":{"cpu":"1"}}
+{"Clock":"2019-01-01T00:00:00Z","Type":"Bound","Namespace":"a","Name":"pod-0","Node":"node-0","Priority":1,"ResourceRequest":{"cpu":"2","nvidia.com/gpu":"2"},"GPUs":[0,1]}
+{"Clock":"2019-01-01T00:00:10Z","Type":"Bound","Namespace":"b","Name":"pod-1","Node":"node-0","ResourceRequest":{"cpu":"1"}}
+{"Clock":"2019-01-01T00:00:10Z","Type":"Started","Namespace":"a","Name":"pod-0","Node":"node-0","Priority":1}
+{"Clock":"2019-01-01T00:00:20Z","Type":"Killed","Namespace":"b","Name":"pod-1","Node":"node-0","Reason":"OOMKilled"}
+{"Clock":"2019-01-01T00:00:20Z","Type":"Submitted","Namespace":"b","Name":"pod-2","ResourceRequest":{"cpu":"1"}}
+{"Clock":"2019-01-01T00:00:30Z","Type":"Finished","Namespace":"a","Name":"pod-0","Node":"node-0","Priority":1}
+`
+
+func TestBuildTimeline(t *testing.T) {
+	events, err := ReadPodEvents(strings.NewReader(testPodEvents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 8 || events[5].Type != metrics.PodKilled {
+		t.Fatalf("got: %+v\nwant: 8 events", events)
+	}
+
+	timeline, err := BuildTimeline(events, TimelineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(timeline.Lanes) != 1 || timeline.Lanes[0].Name() != "node-0" || timeline.Lanes[0].Rows != 2 {
+		t.Errorf("got: %+v\nwant: [node-0 with 2 rows]", timeline.Lanes)
+	}
+	if len(timeline.Bars) != 2 {
+		t.Fatalf("got: %d bars\nwant: 2", len(timeline.Bars))
+	}
+	if bar := timeline.Bars[1]; bar.Row != 1 || bar.EndType != metrics.PodKilled || bar.Reason != "OOMKilled" ||
+		!bar.End.Equal(timeline.Start.Add(20*time.Second)) {
+		t.Errorf("got: %+v\nwant: pod-1 on row 1 killed at 20s by OOMKilled", bar)
+	}
+
+	queue := []float64{1, 0, 1}
+	if len(timeline.Queue) != len(queue) {
+		t.Fatalf("got: %+v\nwant: %v", timeline.Queue, queue)
+	}
+	for i, v := range queue {
+		if timeline.Queue[i].Value != v {
+			t.Errorf("got: %+v\nwant: %v", timeline.Queue, queue)
+		}
+	}
+
+	cpu := []float64{2000, 3000, 2000, 0}
+	if len(timeline.Allocated["cpu"]) != len(cpu) {
+		t.Fatalf("got: %+v\nwant: %v", timeline.Allocated["cpu"], cpu)
+	}
+	for i, v := range cpu {
+		if timeline.Allocated["cpu"][i].Value != v {
+			t.Errorf("got: %+v\nwant: %v", timeline.Allocated["cpu"], cpu)
+		}
+	}
+
+	timeline, err = BuildTimeline(events, TimelineOptions{Lanes: LanesGPU, ColorBy: ColorByPriority})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, lane := range timeline.Lanes {
+		names = append(names, lane.Name())
+	}
+	if actual := strings.Join(names, " "); actual != "node-0 node-0/gpu-0 node-0/gpu-1" {
+		t.Errorf("got: %s\nwant: node-0 node-0/gpu-0 node-0/gpu-1", actual)
+	}
+
+	var buf bytes.Buffer
+	if err := timeline.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<svg") || !strings.Contains(buf.String(), "priority 1") {
+		t.Errorf("got: %s\nwant: HTML with the timeline", buf.String())
+	}
+
+	if _, err := BuildTimeline(events, TimelineOptions{Lanes: "rack"}); err == nil {
+		t.Errorf("got: nil\nwant: error")
+	}
+}
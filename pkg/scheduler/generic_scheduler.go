@@ -17,6 +17,7 @@ package scheduler
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/containerd/containerd/log"
 	v1 "k8s.io/api/core/v1"
@@ -43,14 +44,31 @@ type GenericScheduler struct {
 
 	lastNodeIndex     uint64
 	preemptionEnabled bool
+
+	latencyModel LatencyModel
+	busyUntil    clock.Clock
+
+	snapshot              *clusterSnapshot
+	topologySpread        *topologySpread
+	predicateMetaProducer predicates.PredicateMetadataProducer
+	priorityMetaProducer  priorities.PriorityMetadataProducer
 }
 
 // NewGenericScheduler creates a new GenericScheduler.
 func NewGenericScheduler(preeptionEnabled bool) GenericScheduler {
-	return GenericScheduler{
+	snapshot := newClusterSnapshot()
+
+	sched := GenericScheduler{
 		predicates:        map[string]predicates.FitPredicate{},
 		preemptionEnabled: preeptionEnabled,
+
+		snapshot:              snapshot,
+		topologySpread:        newTopologySpread(snapshot),
+		predicateMetaProducer: predicates.NewPredicateMetadataFactory(podLister{snapshot}),
 	}
+	sched.SetListers(Listers{})
+
+	return sched
 }
 
 // AddExtender adds an extender to this GenericScheduler.
@@ -68,6 +86,38 @@ func (sched *GenericScheduler) AddPrioritizer(prioritizer priorities.PriorityCon
 	sched.prioritizers = append(sched.prioritizers, prioritizer)
 }
 
+// SetListers sets the listers of API objects used to compute the metadata of priority plugins.
+func (sched *GenericScheduler) SetListers(listers Listers) {
+	listers = listers.withDefaults()
+	sched.priorityMetaProducer = priorities.NewPriorityMetadataFactory(
+		listers.Service, listers.Controller, listers.ReplicaSet, listers.StatefulSet)
+}
+
+// PodLister returns a lister of the pods running on the cluster at the clock of the ongoing (or
+// latest) scheduling, including pods bound by this GenericScheduler at the clock.
+// It is intended to be passed to plugins (e.g., predicates.NewPodAffinityPredicate).
+func (sched *GenericScheduler) PodLister() algorithm.PodLister {
+	return podLister{sched.snapshot}
+}
+
+// NodeLister returns a lister of the nodes at the clock of the ongoing (or latest) scheduling.
+func (sched *GenericScheduler) NodeLister() algorithm.NodeLister {
+	return nodeLister{sched.snapshot}
+}
+
+// NodeInfo returns a getter of the nodes at the clock of the ongoing (or latest) scheduling.
+func (sched *GenericScheduler) NodeInfo() predicates.NodeInfo {
+	return sched.snapshot
+}
+
+// SetLatencyModel sets the model of the simulated time spent on each scheduling attempt.
+// If it is set, each pod is bound after the total latency of the attempts made so far at the clock,
+// and this GenericScheduler makes no more decisions until all of them have been bound.
+// By default, scheduling decisions take no simulated time.
+func (sched *GenericScheduler) SetLatencyModel(model LatencyModel) {
+	sched.latencyModel = model
+}
+
 // Schedule implements Scheduler interface.
 // Schedules pods in one-by-one manner by using registered extenders and plugins.
 func (sched *GenericScheduler) Schedule(
@@ -78,6 +128,17 @@ func (sched *GenericScheduler) Schedule(
 
 	results := []Event{}
 
+	// Still busy with the decisions made at a previous clock.
+	if clock.Before(sched.busyUntil) {
+		return results, nil
+	}
+
+	elapsed := time.Duration(0)
+	defer func() { sched.busyUntil = clock.Add(elapsed) }()
+
+	sched.snapshot.update(nodeInfoMap)
+	sched.topologySpread.reset()
+
 	for {
 		// For each pod popped from the front of the queue, ...
 		pod, err := pendingPods.Front() // not pop a pod here; it may fail to any node
@@ -98,10 +159,13 @@ func (sched *GenericScheduler) Schedule(
 		log.L.Debugf("Trying to schedule pod %s", podKey)
 
 		// ... try to bind the pod to a node.
+		attemptStart := time.Now()
 		result, err := sched.scheduleOne(pod, nodeLister, nodeInfoMap, pendingPods)
+		pluginTime := time.Since(attemptStart)
 
 		if err != nil {
 			updatePodStatusSchedulingFailure(clock, pod, err)
+			results = append(results, newFailedSchedulingEvent(pod, err, pluginTime))
 
 			// If failed to select a node that can accommodate the pod, ...
 			if fitError, ok := err.(*core.FitError); ok {
@@ -122,15 +186,45 @@ func (sched *GenericScheduler) Schedule(
 					results = append(results, delEvents...)
 				}
 
+				elapsed += sched.attemptLatency(fitError.NumAllNodes, time.Since(attemptStart))
+
+				// If the queue can keep unschedulable pods aside, move the pod there and try the
+				// pods behind it.
+				if unschedulableQueue, ok := pendingPods.(queue.UnschedulablePodQueue); ok {
+					pod, _ = pendingPods.Pop()
+					if err := unschedulableQueue.AddUnschedulable(clock, pod); err != nil {
+						return []Event{}, err
+					}
+					continue
+				}
+
 				// Else, stop the scheduling process at this clock.
 				break
 			} else {
-				return []Event{}, nil
+				// If a predicate or prioritizer failed (e.g., a volume predicate found a missing
+				// claim), keep the pod aside as kube-scheduler does, or stop the scheduling process
+				// at this clock, keeping the decisions made so far.
+				log.L.Debugf("Error scheduling pod %s: %s", podKey, err.Error())
+
+				// The error may be raised after evaluating any number of nodes, so the attempt is
+				// charged as evaluating all of them.
+				elapsed += sched.attemptLatency(len(nodeInfoMap), time.Since(attemptStart))
+
+				if unschedulableQueue, ok := pendingPods.(queue.UnschedulablePodQueue); ok {
+					pod, _ = pendingPods.Pop()
+					if err := unschedulableQueue.AddUnschedulable(clock, pod); err != nil {
+						return []Event{}, err
+					}
+					continue
+				}
+
+				break
 			}
 		}
 
 		// If found a node that can accommodate the pod, ...
 		log.L.Debugf("Selected node %s", result.SuggestedHost)
+		elapsed += sched.attemptLatency(result.EvaluatedNodes, time.Since(attemptStart))
 
 		pod, _ = pendingPods.Pop()
 		updatePodStatusSchedulingSucceess(clock, pod)
@@ -145,14 +239,46 @@ func (sched *GenericScheduler) Schedule(
 		nodeInfo.AddPod(pod)
 
 		// ... then bind it to the node.
-		results = append(results, &BindEvent{Pod: pod, ScheduleResult: result})
+		results = append(results, &BindEvent{
+			Pod:            pod,
+			ScheduleResult: result,
+			Latency:        elapsed,
+			PluginTime:     pluginTime,
+		})
 	}
 
 	return results, nil
 }
 
+// attemptLatency returns the simulated duration of a scheduling attempt, or zero if no
+// LatencyModel is set.
+func (sched *GenericScheduler) attemptLatency(evaluatedNodes int, wallClockTime time.Duration) time.Duration {
+	if sched.latencyModel == nil {
+		return 0
+	}
+	return sched.latencyModel.Latency(evaluatedNodes, wallClockTime)
+}
+
 var _ = Scheduler(&GenericScheduler{})
 
+// newFailedSchedulingEvent creates a FailedSchedulingEvent of the pod failed to be scheduled with
+// the error, after spending the wall-clock time in the plugins.
+func newFailedSchedulingEvent(pod *v1.Pod, err error, pluginTime time.Duration) *FailedSchedulingEvent {
+	event := FailedSchedulingEvent{Pod: pod, Message: err.Error(), PluginTime: pluginTime}
+
+	if fitError, ok := err.(*core.FitError); ok {
+		event.EvaluatedNodes = fitError.NumAllNodes
+		event.Reasons = map[string]int{}
+		for _, reasons := range fitError.FailedPredicates {
+			for _, reason := range reasons {
+				event.Reasons[reason.GetReason()]++
+			}
+		}
+	}
+
+	return &event
+}
+
 // scheduleOne makes scheduling decision for the given pod and nodes.
 // Returns core.ErrNoNodesAvailable if nodeLister lists zero nodes, or core.FitError if the given
 // pod does not fit in any nodes.
@@ -172,7 +298,8 @@ func (sched *GenericScheduler) scheduleOne(
 	}
 
 	// Filter out nodes that cannot accommodate the pod.
-	nodesFiltered, failedPredicateMap, err := sched.filter(pod, nodes, nodeInfoMap, podQueue)
+	predicateMeta := sched.predicateMetaProducer(pod, nodeInfoMap)
+	nodesFiltered, failedPredicateMap, err := sched.filter(pod, predicateMeta, nodes, nodeInfoMap, podQueue)
 	if err != nil {
 		return result, err
 	}
@@ -193,7 +320,8 @@ func (sched *GenericScheduler) scheduleOne(
 	}
 
 	// Prioritize nodes that have passed the filtering phase.
-	prios, err := sched.prioritize(pod, nodesFiltered, nodeInfoMap, podQueue)
+	priorityMeta := sched.priorityMetaProducer(pod, nodeInfoMap)
+	prios, err := sched.prioritize(pod, priorityMeta, nodesFiltered, nodeInfoMap, podQueue)
 	if err != nil {
 		return result, err
 	}
@@ -210,6 +338,7 @@ func (sched *GenericScheduler) scheduleOne(
 
 func (sched *GenericScheduler) filter(
 	pod *v1.Pod,
+	meta predicates.PredicateMetadata,
 	nodes []*v1.Node,
 	nodeInfoMap map[string]*nodeinfo.NodeInfo,
 	podQueue queue.PodQueue,
@@ -224,7 +353,7 @@ func (sched *GenericScheduler) filter(
 	}
 
 	// In-process plugins
-	filtered, failedPredicateMap, err := filterWithPlugins(pod, sched.predicates, nodes, nodeInfoMap, podQueue)
+	filtered, failedPredicateMap, err := filterWithPlugins(pod, meta, sched.predicates, nodes, nodeInfoMap, podQueue)
 	if err != nil {
 		return []*v1.Node{}, core.FailedPredicateMap{}, err
 	}
@@ -265,6 +394,7 @@ func (sched *GenericScheduler) filter(
 
 func (sched *GenericScheduler) prioritize(
 	pod *v1.Pod,
+	meta interface{},
 	filteredNodes []*v1.Node,
 	nodeInfoMap map[string]*nodeinfo.NodeInfo,
 	podQueue queue.PodQueue) (api.HostPriorityList, error) {
@@ -288,7 +418,7 @@ func (sched *GenericScheduler) prioritize(
 				return api.HostPriorityList{}, fmt.Errorf("No node named %s", node.Name)
 			}
 
-			prio, err := core.EqualPriorityMap(pod, &dummyPriorityMetadata{}, nodeInfo)
+			prio, err := core.EqualPriorityMap(pod, meta, nodeInfo)
 			if err != nil {
 				return api.HostPriorityList{}, err
 			}
@@ -299,7 +429,7 @@ func (sched *GenericScheduler) prioritize(
 	}
 
 	// In-process plugins
-	prioList, err := prioritizeWithPlugins(pod, sched.prioritizers, filteredNodes, nodeInfoMap, podQueue)
+	prioList, err := prioritizeWithPlugins(pod, meta, sched.prioritizers, filteredNodes, nodeInfoMap, podQueue)
 	if err != nil {
 		return api.HostPriorityList{}, err
 	}
@@ -362,7 +492,7 @@ func (sched *GenericScheduler) preempt(
 		return []Event{}, err
 	}
 
-	delEvents := make([]Event, 0, len(victims))
+	delEvents := make([]Event, 0, len(victims)+1)
 	if node != nil {
 		log.L.Tracef("Node %v selected for victim", node)
 		log.L.Debugf("Node %s selected for victim", node.Name)
@@ -372,17 +502,19 @@ func (sched *GenericScheduler) preempt(
 			return []Event{}, err
 		}
 
+		nomination := NominateEvent{Pod: preemptor, NodeName: node.Name, Victims: make([]string, 0, len(victims))}
+		delEvents = append(delEvents, &nomination)
+
 		// Delete the victim pods.
 		for _, victim := range victims {
 			log.L.Tracef("Pod %v selected for victim", victim)
 
-			if l.IsDebugEnabled() {
-				key, err := util.PodKey(victim)
-				if err != nil {
-					return []Event{}, err
-				}
-				log.L.Debugf("Pod %s selected for victim", key)
+			key, err := util.PodKey(victim)
+			if err != nil {
+				return []Event{}, err
 			}
+			log.L.Debugf("Pod %s selected for victim", key)
+			nomination.Victims = append(nomination.Victims, key)
 
 			event := DeleteEvent{PodNamespace: victim.Namespace, PodName: victim.Name, NodeName: node.Name}
 			delEvents = append(delEvents, &event)
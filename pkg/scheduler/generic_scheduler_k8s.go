@@ -148,8 +148,15 @@ func (sched *GenericScheduler) selectNodesForPreemption(
 ) (map[*v1.Node]*api.Victims, error) {
 	nodeToVictims := map[*v1.Node]*api.Victims{}
 
+	// We can use the same metadata producer for all nodes.
+	meta := sched.predicateMetaProducer(preemptor, nodeInfoMap)
+
 	for _, node := range potentialNodes {
-		pods, numPDBViolations, fits := sched.selectVictimsOnNode(preemptor, nodeInfoMap[node.Name], podQueue /* , pdbs */)
+		var metaCopy predicates.PredicateMetadata
+		if meta != nil {
+			metaCopy = meta.ShallowCopy()
+		}
+		pods, numPDBViolations, fits := sched.selectVictimsOnNode(preemptor, metaCopy, nodeInfoMap[node.Name], podQueue /* , pdbs */)
 		if fits {
 			nodeToVictims[node] = &api.Victims{
 				Pods:             pods,
@@ -163,6 +170,7 @@ func (sched *GenericScheduler) selectNodesForPreemption(
 
 func (sched *GenericScheduler) selectVictimsOnNode(
 	preemptor *v1.Pod,
+	meta predicates.PredicateMetadata,
 	nodeInfo *nodeinfo.NodeInfo,
 	podQueue queue.PodQueue,
 	// pdbs []*policy.PodDisruptionBudget,
@@ -176,10 +184,20 @@ func (sched *GenericScheduler) selectVictimsOnNode(
 
 	removePod := func(p *v1.Pod) {
 		nodeInfoCopy.RemovePod(p)
+		if meta != nil {
+			if err := meta.RemovePod(p); err != nil {
+				log.L.Warnf("Encountered error while removing pod from metadata: %v", err)
+			}
+		}
 	}
 
 	addPod := func(p *v1.Pod) {
 		nodeInfoCopy.AddPod(p)
+		if meta != nil {
+			if err := meta.AddPod(p, nodeInfoCopy); err != nil {
+				log.L.Warnf("Encountered error while adding pod to metadata: %v", err)
+			}
+		}
 	}
 
 	podPriority := util.PodPriority(preemptor)
@@ -191,7 +209,7 @@ func (sched *GenericScheduler) selectVictimsOnNode(
 	}
 	potentialVictims.Sort()
 
-	if fits, _, err := podFitsOnNode(preemptor, sched.predicates, nodeInfoCopy, podQueue); !fits {
+	if fits, _, err := podFitsOnNode(preemptor, meta, sched.predicates, nodeInfoCopy, podQueue); !fits {
 		if err != nil {
 			log.L.Warnf("Encountered error while selecting victims on node %s: %v", nodeInfoCopy.Node().Name, err)
 		}
@@ -212,7 +230,7 @@ func (sched *GenericScheduler) selectVictimsOnNode(
 
 	reprievePod := func(p *v1.Pod) bool {
 		addPod(p)
-		fits, _, _ := podFitsOnNode(preemptor, sched.predicates, nodeInfoCopy, podQueue)
+		fits, _, _ := podFitsOnNode(preemptor, meta, sched.predicates, nodeInfoCopy, podQueue)
 		if !fits {
 			removePod(p)
 			victims = append(victims, p)
@@ -246,6 +264,7 @@ func (sched *GenericScheduler) selectVictimsOnNode(
 
 func podFitsOnNode(
 	pod *v1.Pod,
+	meta predicates.PredicateMetadata,
 	preds map[string]predicates.FitPredicate,
 	nodeInfo *nodeinfo.NodeInfo,
 	podQueue queue.PodQueue,
@@ -254,15 +273,16 @@ func podFitsOnNode(
 
 	podsAdded := false
 	for i := 0; i < 2; i++ {
+		metaToUse := meta
 		nodeInfoToUse := nodeInfo
 		if i == 0 {
-			podsAdded, nodeInfoToUse = addNominatedPods(pod, nodeInfo, podQueue)
+			podsAdded, metaToUse, nodeInfoToUse = addNominatedPods(pod, meta, nodeInfo, podQueue)
 		} else if !podsAdded || len(failedPredicates) != 0 {
 			break
 		}
 
 		for _, pred := range preds {
-			fit, reasons, err := pred(pod, &dummyPredicateMetadata{}, nodeInfoToUse)
+			fit, reasons, err := pred(pod, metaToUse, nodeInfoToUse)
 
 			if err != nil {
 				return false, []predicates.PredicateFailureReason{}, err
@@ -279,21 +299,30 @@ func podFitsOnNode(
 }
 
 func addNominatedPods(
-	pod *v1.Pod, nodeInfo *nodeinfo.NodeInfo, podQueue queue.PodQueue,
-) (bool, *nodeinfo.NodeInfo) {
+	pod *v1.Pod, meta predicates.PredicateMetadata, nodeInfo *nodeinfo.NodeInfo, podQueue queue.PodQueue,
+) (bool, predicates.PredicateMetadata, *nodeinfo.NodeInfo) {
 	nominatedPods := podQueue.NominatedPods(nodeInfo.Node().Name)
 	if len(nominatedPods) == 0 {
-		return false, nodeInfo
+		return false, meta, nodeInfo
 	}
 
+	var metaOut predicates.PredicateMetadata
+	if meta != nil {
+		metaOut = meta.ShallowCopy()
+	}
 	nodeInfoOut := nodeInfo.Clone()
 	for _, p := range nominatedPods {
 		if util.PodPriority(p) >= util.PodPriority(pod) && p.UID != pod.UID {
 			nodeInfoOut.AddPod(p)
+			if metaOut != nil {
+				if err := metaOut.AddPod(p, nodeInfoOut); err != nil {
+					log.L.Warnf("Encountered error while adding nominated pod to metadata: %v", err)
+				}
+			}
 		}
 	}
 
-	return true, nodeInfoOut
+	return true, metaOut, nodeInfoOut
 }
 
 func pickOneNodeForPreemption(nodesToVictims map[*v1.Node]*api.Victims) *v1.Node {
@@ -0,0 +1,62 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"time"
+)
+
+// LatencyModel models the simulated time that a scheduler spends on each scheduling attempt.
+type LatencyModel interface {
+	// Latency returns the simulated duration of a scheduling attempt, given the number of nodes
+	// evaluated in the attempt and the wall-clock time actually spent on the plugin calls.
+	Latency(evaluatedNodes int, wallClockTime time.Duration) time.Duration
+}
+
+// FixedLatency is a LatencyModel that charges the same duration for every scheduling attempt.
+type FixedLatency struct {
+	Duration time.Duration
+}
+
+// PerNodeLatency is a LatencyModel that charges Base plus PerNode for each evaluated node.
+type PerNodeLatency struct {
+	Base    time.Duration
+	PerNode time.Duration
+}
+
+// WallClockLatency is a LatencyModel that charges the wall-clock time spent on the plugin calls,
+// multiplied by Scale.
+type WallClockLatency struct {
+	Scale float64
+}
+
+// Latency implements LatencyModel interface.
+func (f *FixedLatency) Latency(_ int, _ time.Duration) time.Duration {
+	return f.Duration
+}
+
+// Latency implements LatencyModel interface.
+func (p *PerNodeLatency) Latency(evaluatedNodes int, _ time.Duration) time.Duration {
+	return p.Base + time.Duration(evaluatedNodes)*p.PerNode
+}
+
+// Latency implements LatencyModel interface.
+func (w *WallClockLatency) Latency(_ int, wallClockTime time.Duration) time.Duration {
+	return time.Duration(float64(wallClockTime) * w.Scale)
+}
+
+var _ = LatencyModel(&FixedLatency{})
+var _ = LatencyModel(&PerNodeLatency{})
+var _ = LatencyModel(&WallClockLatency{})
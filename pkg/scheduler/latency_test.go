@@ -0,0 +1,201 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
+)
+
+var testStartClock = clock.NewClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+func TestLatencyModels(t *testing.T) {
+	tests := []struct {
+		name           string
+		model          LatencyModel
+		evaluatedNodes int
+		wallClockTime  time.Duration
+		expected       time.Duration
+	}{
+		{"fixed", &FixedLatency{Duration: time.Second}, 100, time.Millisecond, time.Second},
+		{"per node", &PerNodeLatency{Base: time.Second, PerNode: 10 * time.Millisecond}, 5, time.Millisecond,
+			1050 * time.Millisecond},
+		{"per node without nodes", &PerNodeLatency{Base: time.Second, PerNode: 10 * time.Millisecond}, 0, 0,
+			time.Second},
+		{"wall clock", &WallClockLatency{Scale: 2}, 100, 3 * time.Millisecond, 6 * time.Millisecond},
+		{"wall clock scaled down", &WallClockLatency{Scale: 0.5}, 100, 3 * time.Millisecond, 1500 * time.Microsecond},
+	}
+
+	for _, test := range tests {
+		if actual := test.model.Latency(test.evaluatedNodes, test.wallClockTime); actual != test.expected {
+			t.Errorf("%s: got: %v\nwant: %v", test.name, actual, test.expected)
+		}
+	}
+}
+
+// newTestLatencyScheduler creates a GenericScheduler charging a second per evaluated node, with a
+// predicate that fits pods only to node-0, and fails with error for the pods named "error".
+func newTestLatencyScheduler() *GenericScheduler {
+	sched := NewGenericScheduler(false)
+	sched.SetLatencyModel(&PerNodeLatency{PerNode: time.Second})
+	sched.AddPredicate("node-0", func(
+		pod *v1.Pod, _ predicates.PredicateMetadata, nodeInfo *nodeinfo.NodeInfo,
+	) (bool, []predicates.PredicateFailureReason, error) {
+		if pod.Name == "error" {
+			return false, nil, errors.New("Predicate error")
+		}
+		if nodeInfo.Node().Name != "node-0" {
+			return false, []predicates.PredicateFailureReason{predicates.ErrNodeSelectorNotMatch}, nil
+		}
+		return true, nil, nil
+	})
+
+	return &sched
+}
+
+func TestGenericSchedulerLatency(t *testing.T) {
+	// Each attempt evaluates the 3 nodes, and takes 3 seconds.
+	sched := newTestLatencyScheduler()
+	q := queue.NewFIFOQueue()
+	for _, name := range []string{"pod-0", "pod-1"} {
+		if err := q.Push(newTestPod("default", name, nil, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := sched.Schedule(testStartClock, q, sched.NodeLister(), newTestCluster(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The latencies of the attempts made at the same clock accumulate.
+	expected := []time.Duration{3 * time.Second, 6 * time.Second}
+	if len(events) != len(expected) {
+		t.Fatalf("got: %d events\nwant: %d", len(events), len(expected))
+	}
+	for i, e := range events {
+		bind, ok := e.(*BindEvent)
+		if !ok {
+			t.Fatalf("got: %T\nwant: *BindEvent", e)
+		}
+		if bind.ScheduleResult.EvaluatedNodes != 3 || bind.Latency != expected[i] {
+			t.Errorf("got: %d nodes in %v\nwant: 3 nodes in %v",
+				bind.ScheduleResult.EvaluatedNodes, bind.Latency, expected[i])
+		}
+	}
+
+	// The scheduler skips the clocks until all its decisions have been bound.
+	if err := q.Push(newTestPod("default", "pod-2", nil, "")); err != nil {
+		t.Fatal(err)
+	}
+	for _, sec := range []int{1, 5} {
+		events, err := sched.Schedule(testStartClock.Add(time.Duration(sec)*time.Second), q, sched.NodeLister(),
+			newTestCluster(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 0 {
+			t.Errorf("at %ds: got: %d events\nwant: busy", sec, len(events))
+		}
+	}
+
+	events, err = sched.Schedule(testStartClock.Add(6*time.Second), q, sched.NodeLister(), newTestCluster(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got: %d events\nwant: 1", len(events))
+	}
+	if bind, ok := events[0].(*BindEvent); !ok || bind.Latency != 3*time.Second {
+		t.Errorf("got: %v\nwant: bound in 3s", events[0])
+	}
+}
+
+func TestGenericSchedulerLatencyScalesWithNodes(t *testing.T) {
+	tests := []struct {
+		withNode3 bool
+		expected  time.Duration
+	}{
+		{false, 3 * time.Second},
+		{true, 4 * time.Second},
+	}
+
+	for _, test := range tests {
+		sched := newTestLatencyScheduler()
+		q := queue.NewFIFOQueue()
+		if err := q.Push(newTestPod("default", "pod-0", nil, "")); err != nil {
+			t.Fatal(err)
+		}
+
+		events, err := sched.Schedule(testStartClock, q, sched.NodeLister(), newTestCluster(test.withNode3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("got: %d events\nwant: 1", len(events))
+		}
+		if bind, ok := events[0].(*BindEvent); !ok || bind.Latency != test.expected {
+			t.Errorf("withNode3 %v: got: %v\nwant: bound in %v", test.withNode3, events[0], test.expected)
+		}
+	}
+}
+
+func TestGenericSchedulerLatencyOnError(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      string
+		queue    queue.PodQueue
+		expected time.Duration
+	}{
+		// node-0 is excluded from the cluster, so that the pod fits in no node.
+		{"unschedulable", "pod-0", queue.NewFIFOQueue(), 2 * time.Second},
+		{"error", "error", queue.NewFIFOQueue(), 2 * time.Second},
+		{"error with unschedulable queue", "error", queue.NewSchedulingQueue(), 2 * time.Second},
+	}
+
+	for _, test := range tests {
+		sched := newTestLatencyScheduler()
+		if err := test.queue.Push(newTestPod("default", test.pod, nil, "")); err != nil {
+			t.Fatal(err)
+		}
+
+		cluster := newTestCluster(false)
+		delete(cluster, "node-0")
+
+		events, err := sched.Schedule(testStartClock, test.queue, sched.NodeLister(), cluster)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("%s: got: %d events\nwant: 1", test.name, len(events))
+		}
+		if _, ok := events[0].(*FailedSchedulingEvent); !ok {
+			t.Fatalf("%s: got: %T\nwant: *FailedSchedulingEvent", test.name, events[0])
+		}
+
+		// The failed attempt is charged, so that the scheduler is busy after it.
+		if busy := sched.busyUntil.Sub(testStartClock); busy != test.expected {
+			t.Errorf("%s: got: busy for %v\nwant: %v", test.name, busy, test.expected)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+)
+
+// Listers is a set of listers of API objects other than nodes and pods, which are used by plugins
+// and metadata producers (e.g., SelectorSpreadPriority).
+// The simulator does not have these objects, so users can give them statically.
+// Nil fields are regarded as listers that list nothing.
+type Listers struct {
+	Service     algorithm.ServiceLister
+	Controller  algorithm.ControllerLister
+	ReplicaSet  algorithm.ReplicaSetLister
+	StatefulSet algorithm.StatefulSetLister
+}
+
+// StaticServiceLister is an algorithm.ServiceLister that lists the given services.
+type StaticServiceLister []*v1.Service
+
+// StaticControllerLister is an algorithm.ControllerLister that lists the given replication
+// controllers.
+type StaticControllerLister []*v1.ReplicationController
+
+// StaticReplicaSetLister is an algorithm.ReplicaSetLister that lists the given replica sets.
+type StaticReplicaSetLister []*apps.ReplicaSet
+
+// StaticStatefulSetLister is an algorithm.StatefulSetLister that lists the given stateful sets.
+type StaticStatefulSetLister []*apps.StatefulSet
+
+// List implements algorithm.ServiceLister interface.
+func (s StaticServiceLister) List(selector labels.Selector) ([]*v1.Service, error) {
+	services := []*v1.Service{}
+	for _, service := range s {
+		if selector.Matches(labels.Set(service.Labels)) {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+// GetPodServices implements algorithm.ServiceLister interface.
+// Returns services in the pod's namespace whose selector matches the pod.
+func (s StaticServiceLister) GetPodServices(pod *v1.Pod) ([]*v1.Service, error) {
+	services := []*v1.Service{}
+	for _, service := range s {
+		if service.Namespace != pod.Namespace || service.Spec.Selector == nil {
+			continue
+		}
+		if labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+// List implements algorithm.ControllerLister interface.
+func (s StaticControllerLister) List(selector labels.Selector) ([]*v1.ReplicationController, error) {
+	controllers := []*v1.ReplicationController{}
+	for _, controller := range s {
+		if selector.Matches(labels.Set(controller.Labels)) {
+			controllers = append(controllers, controller)
+		}
+	}
+	return controllers, nil
+}
+
+// GetPodControllers implements algorithm.ControllerLister interface.
+// Returns replication controllers in the pod's namespace whose selector matches the pod.
+func (s StaticControllerLister) GetPodControllers(pod *v1.Pod) ([]*v1.ReplicationController, error) {
+	controllers := []*v1.ReplicationController{}
+	for _, controller := range s {
+		if controller.Namespace != pod.Namespace || controller.Spec.Selector == nil {
+			continue
+		}
+		if labels.SelectorFromSet(controller.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			controllers = append(controllers, controller)
+		}
+	}
+	return controllers, nil
+}
+
+// GetPodReplicaSets implements algorithm.ReplicaSetLister interface.
+// Returns replica sets in the pod's namespace whose selector matches the pod.
+func (s StaticReplicaSetLister) GetPodReplicaSets(pod *v1.Pod) ([]*apps.ReplicaSet, error) {
+	replicaSets := []*apps.ReplicaSet{}
+	for _, rs := range s {
+		if rs.Namespace != pod.Namespace {
+			continue
+		}
+		if matches, err := labelSelectorMatches(rs.Spec.Selector, pod); err != nil {
+			return nil, err
+		} else if matches {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	return replicaSets, nil
+}
+
+// GetPodStatefulSets implements algorithm.StatefulSetLister interface.
+// Returns stateful sets in the pod's namespace whose selector matches the pod.
+func (s StaticStatefulSetLister) GetPodStatefulSets(pod *v1.Pod) ([]*apps.StatefulSet, error) {
+	statefulSets := []*apps.StatefulSet{}
+	for _, ss := range s {
+		if ss.Namespace != pod.Namespace {
+			continue
+		}
+		if matches, err := labelSelectorMatches(ss.Spec.Selector, pod); err != nil {
+			return nil, err
+		} else if matches {
+			statefulSets = append(statefulSets, ss)
+		}
+	}
+	return statefulSets, nil
+}
+
+var _ = algorithm.ServiceLister(StaticServiceLister{})
+var _ = algorithm.ControllerLister(StaticControllerLister{})
+var _ = algorithm.ReplicaSetLister(StaticReplicaSetLister{})
+var _ = algorithm.StatefulSetLister(StaticStatefulSetLister{})
+
+// labelSelectorMatches returns whether the non-empty selector matches the pod's labels.
+func labelSelectorMatches(selector *metav1.LabelSelector, pod *v1.Pod) (bool, error) {
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return !sel.Empty() && sel.Matches(labels.Set(pod.Labels)), nil
+}
+
+// withDefaults returns a copy of the listers whose nil fields are replaced with empty listers.
+func (l Listers) withDefaults() Listers {
+	if l.Service == nil {
+		l.Service = StaticServiceLister{}
+	}
+	if l.Controller == nil {
+		l.Controller = algorithm.EmptyControllerLister{}
+	}
+	if l.ReplicaSet == nil {
+		l.ReplicaSet = algorithm.EmptyReplicaSetLister{}
+	}
+	if l.StatefulSet == nil {
+		l.StatefulSet = algorithm.EmptyStatefulSetLister{}
+	}
+	return l
+}
+
+// clusterSnapshot holds the NodeInfo of all nodes given to the latest Schedule call, so that
+// plugins created before the scheduling can list nodes and pods on the cluster.
+// The pods include ones running or terminating on the nodes, and ones bound in the ongoing
+// scheduling.
+type clusterSnapshot struct {
+	mu          sync.RWMutex
+	nodeInfoMap map[string]*nodeinfo.NodeInfo
+}
+
+// podLister is an algorithm.PodLister over a clusterSnapshot.
+type podLister struct{ snapshot *clusterSnapshot }
+
+// nodeLister is an algorithm.NodeLister over a clusterSnapshot.
+type nodeLister struct{ snapshot *clusterSnapshot }
+
+func newClusterSnapshot() *clusterSnapshot {
+	return &clusterSnapshot{nodeInfoMap: map[string]*nodeinfo.NodeInfo{}}
+}
+
+func (s *clusterSnapshot) update(nodeInfoMap map[string]*nodeinfo.NodeInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodeInfoMap = nodeInfoMap
+}
+
+// GetNodeInfo implements predicates.NodeInfo interface.
+func (s *clusterSnapshot) GetNodeInfo(nodeName string) (*v1.Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, ok := s.nodeInfoMap[nodeName]
+	if !ok || info.Node() == nil {
+		return nil, fmt.Errorf("No node named %q", nodeName)
+	}
+	return info.Node(), nil
+}
+
+// List implements algorithm.PodLister interface.
+func (l podLister) List(selector labels.Selector) ([]*v1.Pod, error) {
+	return l.FilteredList(func(*v1.Pod) bool { return true }, selector)
+}
+
+// FilteredList implements algorithm.PodLister interface.
+func (l podLister) FilteredList(podFilter algorithm.PodFilter, selector labels.Selector) ([]*v1.Pod, error) {
+	l.snapshot.mu.RLock()
+	defer l.snapshot.mu.RUnlock()
+
+	pods := []*v1.Pod{}
+	for _, info := range l.snapshot.nodeInfoMap {
+		for _, pod := range info.Pods() {
+			if podFilter(pod) && selector.Matches(labels.Set(pod.Labels)) {
+				pods = append(pods, pod)
+			}
+		}
+	}
+	return pods, nil
+}
+
+// List implements algorithm.NodeLister interface.
+func (l nodeLister) List() ([]*v1.Node, error) {
+	l.snapshot.mu.RLock()
+	defer l.snapshot.mu.RUnlock()
+
+	nodes := make([]*v1.Node, 0, len(l.snapshot.nodeInfoMap))
+	for _, info := range l.snapshot.nodeInfoMap {
+		if info.Node() != nil {
+			nodes = append(nodes, info.Node())
+		}
+	}
+	return nodes, nil
+}
+
+var _ = predicates.NodeInfo(&clusterSnapshot{})
+var _ = algorithm.PodLister(podLister{})
+var _ = algorithm.NodeLister(nodeLister{})
@@ -0,0 +1,97 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"testing"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestSnapshotListers(t *testing.T) {
+	sched := newTestScheduler(newTestCluster(true))
+
+	nodes, err := sched.NodeLister().List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 4 {
+		t.Errorf("got: %d nodes\nwant: 4", len(nodes))
+	}
+
+	pods, err := sched.PodLister().List(labels.SelectorFromSet(labels.Set{"app": "web"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 3 {
+		t.Errorf("got: %d pods\nwant: 3", len(pods))
+	}
+
+	pods, err = sched.PodLister().FilteredList(
+		func(pod *v1.Pod) bool { return pod.Namespace == "other" }, labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Name != "web-2" {
+		t.Errorf("got: %v\nwant: [web-2]", pods)
+	}
+
+	if node, err := sched.NodeInfo().GetNodeInfo("node-2"); err != nil || node.Name != "node-2" {
+		t.Errorf("got: %v, %v\nwant: node-2", node, err)
+	}
+	if _, err := sched.NodeInfo().GetNodeInfo("node-4"); err == nil {
+		t.Errorf("got: nil\nwant: error")
+	}
+}
+
+func TestStaticListers(t *testing.T) {
+	pod := newTestPod("default", "pod", map[string]string{"app": "web", "tier": "front"}, "")
+
+	services := StaticServiceLister{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec: v1.ServiceSpec{Selector: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "web"},
+			Spec: v1.ServiceSpec{Selector: map[string]string{"app": "web"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"},
+			Spec: v1.ServiceSpec{Selector: map[string]string{"app": "db"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "headless"}},
+	}
+	if actual, _ := services.GetPodServices(pod); len(actual) != 1 || actual[0].Namespace != "default" ||
+		actual[0].Name != "web" {
+		t.Errorf("got: %v\nwant: [default/web]", actual)
+	}
+
+	replicaSets := StaticReplicaSetLister{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}, Spec: apps.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"front", "back"}},
+			}},
+		}},
+		// An empty selector matches no pods.
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "empty"}, Spec: apps.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{},
+		}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "web"}, Spec: apps.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		}},
+	}
+	if actual, err := replicaSets.GetPodReplicaSets(pod); err != nil || len(actual) != 1 ||
+		actual[0].Namespace != "default" || actual[0].Name != "web" {
+		t.Errorf("got: %v, %v\nwant: [default/web]", actual, err)
+	}
+}
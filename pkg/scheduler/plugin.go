@@ -32,18 +32,11 @@ import (
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/queue"
 )
 
-// dummyPredicateMetadata implements predicates.PredicateMetadata interface.
-type dummyPredicateMetadata struct{}
-type dummyPriorityMetadata struct{}
-
-func (d *dummyPredicateMetadata) ShallowCopy() predicates.PredicateMetadata             { return d }
-func (d *dummyPredicateMetadata) AddPod(pod *v1.Pod, nodeInfo *nodeinfo.NodeInfo) error { return nil }
-func (d *dummyPredicateMetadata) RemovePod(pod *v1.Pod) error                           { return nil }
-
 const workerNum = 16
 
 func filterWithPlugins(
 	pod *v1.Pod,
+	meta predicates.PredicateMetadata,
 	preds map[string]predicates.FitPredicate,
 	nodes []*v1.Node,
 	nodeInfoMap map[string]*nodeinfo.NodeInfo,
@@ -79,6 +72,7 @@ func filterWithPlugins(
 
 		fits, failedPredicates, err := podFitsOnNode(
 			pod,
+			meta,
 			preds,
 			nodeInfo,
 			podQueue,
@@ -114,6 +108,7 @@ func filterWithPlugins(
 
 func prioritizeWithPlugins(
 	pod *v1.Pod,
+	meta interface{},
 	prioritizers []priorities.PriorityConfig,
 	nodes []*v1.Node,
 	nodeInfoMap map[string]*nodeinfo.NodeInfo,
@@ -149,7 +144,7 @@ func prioritizeWithPlugins(
 			}
 
 			var err error
-			resultList[prioIdx][nodeIdx], err = prioritizers[prioIdx].Map(pod, &dummyPriorityMetadata{}, nodeInfo)
+			resultList[prioIdx][nodeIdx], err = prioritizers[prioIdx].Map(pod, meta, nodeInfo)
 			if err != nil {
 				appendError(err)
 				resultList[prioIdx][nodeIdx].Host = nodeName
@@ -157,9 +152,23 @@ func prioritizeWithPlugins(
 		}
 	})
 
-	// Run reduce phases of prioritizer plugins in parallel along plugins.
+	// Run reduce phases of prioritizer plugins, and prioritizer plugins not in the map-reduce style,
+	// in parallel along plugins.
 	wg := sync.WaitGroup{}
 	for prioIdx := range prioritizers {
+		if prioritizers[prioIdx].Function != nil {
+			wg.Add(1)
+			go func(prioIdx int) {
+				defer wg.Done()
+				var err error
+				resultList[prioIdx], err = prioritizers[prioIdx].Function(pod, nodeInfoMap, nodes)
+				if err != nil {
+					appendError(err)
+				}
+			}(prioIdx)
+			continue
+		}
+
 		if prioritizers[prioIdx].Reduce == nil {
 			continue
 		}
@@ -167,7 +176,7 @@ func prioritizeWithPlugins(
 		wg.Add(1)
 		go func(prioIdx int) {
 			defer wg.Done()
-			if err := prioritizers[prioIdx].Reduce(pod, &dummyPriorityMetadata{}, nodeInfoMap, resultList[prioIdx]); err != nil {
+			if err := prioritizers[prioIdx].Reduce(pod, meta, nodeInfoMap, resultList[prioIdx]); err != nil {
 				appendError(err)
 			}
 		}(prioIdx)
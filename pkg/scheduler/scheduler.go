@@ -15,6 +15,8 @@
 package scheduler
 
 import (
+	"time"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/scheduler/algorithm"
 	"k8s.io/kubernetes/pkg/scheduler/core"
@@ -46,6 +48,12 @@ type Event interface {
 type BindEvent struct {
 	Pod            *v1.Pod
 	ScheduleResult core.ScheduleResult
+	// Latency is the simulated duration from the clock at which the decision is made to the clock
+	// at which the pod is bound to the node.
+	Latency time.Duration
+	// PluginTime is the wall-clock time spent in filtering and prioritizing the nodes for the pod,
+	// i.e., in running the predicates, prioritizers, and extenders.
+	PluginTime time.Duration
 }
 
 // DeleteEvent represents an event of the deleting a bound pod on a node.
@@ -55,5 +63,43 @@ type DeleteEvent struct {
 	NodeName     string
 }
 
-func (b *BindEvent) IsSchedulerEvent() bool   { return true }
-func (d *DeleteEvent) IsSchedulerEvent() bool { return true }
+// RepartitionGPUEvent represents an event of changing the MIG partition layout of a GPU of a node
+// (see node.Node.RepartitionGPU).
+// Pods using the GPU are evicted, and the GPU is unavailable while it is repartitioned.
+type RepartitionGPUEvent struct {
+	NodeName string
+	// GPU is the index of the GPU in the device model of the node.
+	GPU int
+	// MIGProfiles is the new partition layout, which disables MIG if empty.
+	MIGProfiles []string
+}
+
+// FailedSchedulingEvent represents an event of failing to schedule a pod.
+// It is informational; the pod is kept in the queue.
+type FailedSchedulingEvent struct {
+	Pod     *v1.Pod
+	Message string
+	// Reasons is the number of the nodes that failed the pod for each reason (e.g., "Insufficient
+	// cpu"), which is nil if the scheduling failed with an error other than core.FitError.
+	Reasons map[string]int
+	// EvaluatedNodes is the number of the nodes on which the predicates were evaluated.
+	EvaluatedNodes int
+	// PluginTime is the wall-clock time spent in filtering the nodes for the pod.
+	PluginTime time.Duration
+}
+
+// NominateEvent represents an event of nominating a node for a pod that preempts the victim pods
+// on the node.
+// It is informational; the victims are deleted by the DeleteEvents following it.
+type NominateEvent struct {
+	Pod      *v1.Pod
+	NodeName string
+	// Victims is the keys (namespace/name) of the pods preempted.
+	Victims []string
+}
+
+func (b *BindEvent) IsSchedulerEvent() bool             { return true }
+func (d *DeleteEvent) IsSchedulerEvent() bool           { return true }
+func (r *RepartitionGPUEvent) IsSchedulerEvent() bool   { return true }
+func (f *FailedSchedulingEvent) IsSchedulerEvent() bool { return true }
+func (n *NominateEvent) IsSchedulerEvent() bool         { return true }
@@ -0,0 +1,334 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"sync"
+
+	"github.com/cpuguy83/strongerrors"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/priorities"
+	"k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// TopologySpreadConstraintsAnnotation is the annotation of a pod that specifies its topology spread
+// constraints in YAML (or JSON), in the same format as spec.topologySpreadConstraints of newer
+// kubernetes, which the vendored v1.PodSpec does not have.
+const TopologySpreadConstraintsAnnotation = "simTopologySpreadConstraints"
+
+// UnsatisfiableConstraintAction is the action to take when a pod does not satisfy a topology spread
+// constraint.
+type UnsatisfiableConstraintAction string
+
+const (
+	// DoNotSchedule makes the constraint a predicate.
+	DoNotSchedule UnsatisfiableConstraintAction = "DoNotSchedule"
+	// ScheduleAnyway makes the constraint a priority.
+	ScheduleAnyway UnsatisfiableConstraintAction = "ScheduleAnyway"
+)
+
+// TopologySpreadConstraint specifies how to spread the pods matching LabelSelector (in the same
+// namespace) among the topology domains given by the node label TopologyKey.
+type TopologySpreadConstraint struct {
+	MaxSkew           int32                         `json:"maxSkew"`
+	TopologyKey       string                        `json:"topologyKey"`
+	WhenUnsatisfiable UnsatisfiableConstraintAction `json:"whenUnsatisfiable"`
+	LabelSelector     *metav1.LabelSelector         `json:"labelSelector,omitempty"`
+}
+
+// ErrTopologySpreadConstraintsNotMatch is returned from PodTopologySpreadPredicate if the node
+// violates a DoNotSchedule topology spread constraint of the pod.
+var ErrTopologySpreadConstraintsNotMatch = &predicates.PredicateFailureError{
+	PredicateName: "PodTopologySpread",
+	PredicateDesc: "node(s) didn't match pod topology spread constraints",
+}
+
+// PodTopologySpreadPredicate returns a predicate plugin that evaluates the DoNotSchedule topology
+// spread constraints of pods, over the pods on the cluster given to the latest Schedule call.
+func (sched *GenericScheduler) PodTopologySpreadPredicate() predicates.FitPredicate {
+	return sched.topologySpread.predicate
+}
+
+// PodTopologySpreadPriority returns the map and reduce functions of a priority plugin that prefers
+// nodes in topology domains with fewer pods matching the ScheduleAnyway topology spread constraints
+// of pods.
+func (sched *GenericScheduler) PodTopologySpreadPriority() (
+	priorities.PriorityMapFunction, priorities.PriorityReduceFunction) {
+
+	return sched.topologySpread.priorityMap, sched.topologySpread.priorityReduce
+}
+
+// topologySpread evaluates topology spread constraints over a clusterSnapshot.
+type topologySpread struct {
+	snapshot *clusterSnapshot
+
+	mu     sync.Mutex
+	cached map[*v1.Pod]*spreadCounts
+}
+
+// spreadCounts is the number of pods matching each constraint of a pod, in each topology domain
+// and on each node.
+type spreadCounts struct {
+	constraints  []TopologySpreadConstraint
+	domainCounts []map[string]int
+	nodeCounts   []map[string]int
+}
+
+func newTopologySpread(snapshot *clusterSnapshot) *topologySpread {
+	return &topologySpread{snapshot: snapshot, cached: map[*v1.Pod]*spreadCounts{}}
+}
+
+// reset clears the counts computed on the previous snapshot.
+func (t *topologySpread) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cached = map[*v1.Pod]*spreadCounts{}
+}
+
+func (t *topologySpread) predicate(
+	pod *v1.Pod, _ predicates.PredicateMetadata, nodeInfo *nodeinfo.NodeInfo,
+) (bool, []predicates.PredicateFailureReason, error) {
+
+	counts, err := t.counts(pod)
+	if err != nil || counts == nil {
+		return err == nil, nil, err
+	}
+
+	node := nodeInfo.Node()
+	for i, c := range counts.constraints {
+		if c.WhenUnsatisfiable != DoNotSchedule {
+			continue
+		}
+
+		if _, ok := node.Labels[c.TopologyKey]; !ok {
+			return false, []predicates.PredicateFailureReason{ErrTopologySpreadConstraintsNotMatch}, nil
+		}
+
+		minCount := -1
+		for _, count := range counts.domainCounts[i] {
+			if minCount < 0 || count < minCount {
+				minCount = count
+			}
+		}
+		if minCount < 0 {
+			minCount = 0
+		}
+
+		selfMatch := 0
+		if matches, _ := constraintMatches(c, pod.Namespace, pod); matches {
+			selfMatch = 1
+		}
+
+		skew := counts.countOn(i, nodeInfo, pod.Namespace) + selfMatch - minCount
+		if skew > int(c.MaxSkew) {
+			return false, []predicates.PredicateFailureReason{ErrTopologySpreadConstraintsNotMatch}, nil
+		}
+	}
+
+	return true, nil, nil
+}
+
+func (t *topologySpread) priorityMap(
+	pod *v1.Pod, _ interface{}, nodeInfo *nodeinfo.NodeInfo,
+) (api.HostPriority, error) {
+
+	node := nodeInfo.Node()
+	result := api.HostPriority{Host: node.Name, Score: 0}
+
+	counts, err := t.counts(pod)
+	if err != nil || counts == nil {
+		return result, err
+	}
+
+	for i, c := range counts.constraints {
+		if c.WhenUnsatisfiable != ScheduleAnyway {
+			continue
+		}
+		if _, ok := node.Labels[c.TopologyKey]; !ok {
+			result.Score = -1 // the least preferred
+			return result, nil
+		}
+		result.Score += counts.countOn(i, nodeInfo, pod.Namespace)
+	}
+
+	return result, nil
+}
+
+// priorityReduce normalizes the scores so that the node with the fewest matching pods gets
+// api.MaxPriority and the one with the most gets zero.
+func (t *topologySpread) priorityReduce(
+	_ *v1.Pod, _ interface{}, _ map[string]*nodeinfo.NodeInfo, result api.HostPriorityList,
+) error {
+
+	maxCount, minCount := -1, -1
+	for _, prio := range result {
+		if prio.Score < 0 {
+			continue
+		}
+		if maxCount < 0 || prio.Score > maxCount {
+			maxCount = prio.Score
+		}
+		if minCount < 0 || prio.Score < minCount {
+			minCount = prio.Score
+		}
+	}
+
+	for i, prio := range result {
+		switch {
+		case prio.Score < 0:
+			result[i].Score = 0
+		case maxCount == minCount:
+			result[i].Score = api.MaxPriority
+		default:
+			result[i].Score = api.MaxPriority * (maxCount - prio.Score) / (maxCount - minCount)
+		}
+	}
+
+	return nil
+}
+
+// counts returns the numbers of pods matching the constraints of the pod, computed on the snapshot.
+// Returns nil if the pod has no constraints.
+func (t *topologySpread) counts(pod *v1.Pod) (*spreadCounts, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if counts, ok := t.cached[pod]; ok {
+		return counts, nil
+	}
+
+	constraints, err := parseTopologySpreadConstraints(pod)
+	if err != nil || len(constraints) == 0 {
+		return nil, err
+	}
+
+	counts := &spreadCounts{
+		constraints:  constraints,
+		domainCounts: make([]map[string]int, len(constraints)),
+		nodeCounts:   make([]map[string]int, len(constraints)),
+	}
+	for i := range constraints {
+		counts.domainCounts[i] = map[string]int{}
+		counts.nodeCounts[i] = map[string]int{}
+	}
+
+	t.snapshot.mu.RLock()
+	defer t.snapshot.mu.RUnlock()
+
+	for name, info := range t.snapshot.nodeInfoMap {
+		node := info.Node()
+		if node == nil || !hasAllTopologyKeys(node, constraints) {
+			continue
+		}
+		// Only nodes that the pod can be placed on form the topology domains.
+		if fits, _, _ := predicates.PodMatchNodeSelector(pod, nil, info); !fits {
+			continue
+		}
+
+		for i, c := range constraints {
+			count := 0
+			for _, p := range info.Pods() {
+				if matches, err := constraintMatches(c, pod.Namespace, p); err != nil {
+					return nil, err
+				} else if matches {
+					count++
+				}
+			}
+
+			counts.nodeCounts[i][name] = count
+			counts.domainCounts[i][node.Labels[c.TopologyKey]] += count
+		}
+	}
+
+	t.cached[pod] = counts
+	return counts, nil
+}
+
+// countOn returns the number of pods matching the i-th constraint in the topology domain of the
+// node, where the pods on the node are given by nodeInfo (e.g., with preemption victims removed)
+// instead of the snapshot.
+func (s *spreadCounts) countOn(i int, nodeInfo *nodeinfo.NodeInfo, namespace string) int {
+	c := s.constraints[i]
+	node := nodeInfo.Node()
+
+	onNode := 0
+	for _, p := range nodeInfo.Pods() {
+		if matches, _ := constraintMatches(c, namespace, p); matches {
+			onNode++
+		}
+	}
+
+	return s.domainCounts[i][node.Labels[c.TopologyKey]] - s.nodeCounts[i][node.Name] + onNode
+}
+
+// parseTopologySpreadConstraints parses TopologySpreadConstraintsAnnotation of the pod.
+// Returns an empty slice if the pod does not have the annotation.
+func parseTopologySpreadConstraints(pod *v1.Pod) ([]TopologySpreadConstraint, error) {
+	annot, ok := pod.Annotations[TopologySpreadConstraintsAnnotation]
+	if !ok {
+		return []TopologySpreadConstraint{}, nil
+	}
+
+	constraints := []TopologySpreadConstraint{}
+	if err := yaml.Unmarshal([]byte(annot), &constraints); err != nil {
+		return nil, strongerrors.InvalidArgument(
+			errors.Errorf("Invalid %s annotation of pod %q: %s",
+				TopologySpreadConstraintsAnnotation, util.PodKeyFromNames(pod.Namespace, pod.Name), err.Error()))
+	}
+
+	for _, c := range constraints {
+		if c.MaxSkew <= 0 || c.TopologyKey == "" ||
+			(c.WhenUnsatisfiable != DoNotSchedule && c.WhenUnsatisfiable != ScheduleAnyway) {
+			return nil, strongerrors.InvalidArgument(
+				errors.Errorf("Invalid topology spread constraint %+v of pod %q",
+					c, util.PodKeyFromNames(pod.Namespace, pod.Name)))
+		}
+	}
+
+	return constraints, nil
+}
+
+// constraintMatches returns whether the pod is in the namespace and matches the label selector of
+// the constraint.
+func constraintMatches(c TopologySpreadConstraint, namespace string, pod *v1.Pod) (bool, error) {
+	if pod.Namespace != namespace || c.LabelSelector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(c.LabelSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(pod.Labels)), nil
+}
+
+// hasAllTopologyKeys returns whether the node has the labels of all topology keys of the
+// constraints.
+func hasAllTopologyKeys(node *v1.Node, constraints []TopologySpreadConstraint) bool {
+	for _, c := range constraints {
+		if _, ok := node.Labels[c.TopologyKey]; !ok {
+			return false
+		}
+	}
+	return true
+}
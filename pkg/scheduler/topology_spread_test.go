@@ -0,0 +1,314 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/api"
+	"k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+)
+
+const (
+	testZoneKey     = "topology.kubernetes.io/zone"
+	testHostnameKey = "kubernetes.io/hostname"
+)
+
+func newTestPod(namespace, name string, labels map[string]string, constraints string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+	if constraints != "" {
+		pod.Annotations = map[string]string{TopologySpreadConstraintsAnnotation: constraints}
+	}
+	return pod
+}
+
+// newTestCluster creates a cluster of node-0 and node-1 in zone-a and node-2 in zone-b, where
+// node-0 runs 2 pods labeled app=web in the default namespace, and node-2 runs a pod labeled app=web
+// in the other namespace.
+// node-3 has no topology labels unless withNode3 is false.
+func newTestCluster(withNode3 bool) map[string]*nodeinfo.NodeInfo {
+	zones := map[string]string{"node-0": "zone-a", "node-1": "zone-a", "node-2": "zone-b"}
+	pods := map[string][]*v1.Pod{
+		"node-0": {
+			newTestPod("default", "web-0", map[string]string{"app": "web"}, ""),
+			newTestPod("default", "web-1", map[string]string{"app": "web"}, ""),
+		},
+		"node-2": {newTestPod("other", "web-2", map[string]string{"app": "web"}, "")},
+	}
+
+	infos := map[string]*nodeinfo.NodeInfo{}
+	for name, zone := range zones {
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{testZoneKey: zone, testHostnameKey: name},
+		}}
+		for _, pod := range pods[name] {
+			pod.Spec.NodeName = name
+		}
+		info := nodeinfo.NewNodeInfo(pods[name]...)
+		_ = info.SetNode(node)
+		infos[name] = info
+	}
+	if withNode3 {
+		info := nodeinfo.NewNodeInfo()
+		_ = info.SetNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}})
+		infos["node-3"] = info
+	}
+
+	return infos
+}
+
+func newTestScheduler(infos map[string]*nodeinfo.NodeInfo) *GenericScheduler {
+	sched := NewGenericScheduler(false)
+	sched.snapshot.update(infos)
+	sched.topologySpread.reset()
+	return &sched
+}
+
+func TestParseTopologySpreadConstraints(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotation  string
+		expected    []TopologySpreadConstraint
+		expectedErr bool
+	}{
+		{name: "no annotation", expected: []TopologySpreadConstraint{}},
+		{
+			name: "DoNotSchedule in YAML",
+			annotation: `
+- maxSkew: 1
+  topologyKey: topology.kubernetes.io/zone
+  whenUnsatisfiable: DoNotSchedule
+  labelSelector:
+    matchLabels:
+      app: web`,
+			expected: []TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       testZoneKey,
+				WhenUnsatisfiable: DoNotSchedule,
+				LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			}},
+		},
+		{
+			name:       "ScheduleAnyway in JSON",
+			annotation: `[{"maxSkew": 2, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "ScheduleAnyway"}]`,
+			expected: []TopologySpreadConstraint{{
+				MaxSkew:           2,
+				TopologyKey:       testHostnameKey,
+				WhenUnsatisfiable: ScheduleAnyway,
+			}},
+		},
+		{
+			name:        "unknown action",
+			annotation:  `[{"maxSkew": 1, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "Sometimes"}]`,
+			expectedErr: true,
+		},
+		{
+			name:        "zero maxSkew",
+			annotation:  `[{"maxSkew": 0, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "DoNotSchedule"}]`,
+			expectedErr: true,
+		},
+		{
+			name:        "no topologyKey",
+			annotation:  `[{"maxSkew": 1, "whenUnsatisfiable": "DoNotSchedule"}]`,
+			expectedErr: true,
+		},
+		{name: "not a list", annotation: `maxSkew: 1`, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		pod := newTestPod("default", "pod", nil, test.annotation)
+		actual, err := parseTopologySpreadConstraints(pod)
+		if test.expectedErr {
+			if err == nil {
+				t.Errorf("%s: got: nil\nwant: error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+			continue
+		}
+
+		if len(actual) != len(test.expected) {
+			t.Errorf("%s: got: %+v\nwant: %+v", test.name, actual, test.expected)
+			continue
+		}
+		for i, c := range actual {
+			e := test.expected[i]
+			if c.MaxSkew != e.MaxSkew || c.TopologyKey != e.TopologyKey || c.WhenUnsatisfiable != e.WhenUnsatisfiable ||
+				(c.LabelSelector == nil) != (e.LabelSelector == nil) ||
+				(c.LabelSelector != nil && c.LabelSelector.MatchLabels["app"] != e.LabelSelector.MatchLabels["app"]) {
+				t.Errorf("%s: got: %+v\nwant: %+v", test.name, c, e)
+			}
+		}
+	}
+}
+
+func TestPodTopologySpreadPredicate(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		constraints string
+		// expected is whether the pod fits on node-0, node-1, node-2, and node-3.
+		expected [4]bool
+	}{
+		{
+			// zone-a has 2 matching pods and zone-b has none.
+			name:        "zone maxSkew 1",
+			labels:      map[string]string{"app": "web"},
+			constraints: `[{"maxSkew": 1, "topologyKey": "topology.kubernetes.io/zone", "whenUnsatisfiable": "DoNotSchedule", "labelSelector": {"matchLabels": {"app": "web"}}}]`,
+			expected:    [4]bool{false, false, true, false},
+		},
+		{
+			name:        "zone maxSkew 3",
+			labels:      map[string]string{"app": "web"},
+			constraints: `[{"maxSkew": 3, "topologyKey": "topology.kubernetes.io/zone", "whenUnsatisfiable": "DoNotSchedule", "labelSelector": {"matchLabels": {"app": "web"}}}]`,
+			expected:    [4]bool{true, true, true, false},
+		},
+		{
+			// The pod in the other namespace on node-2 does not count.
+			name:        "hostname maxSkew 1",
+			labels:      map[string]string{"app": "web"},
+			constraints: `[{"maxSkew": 1, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "DoNotSchedule", "labelSelector": {"matchLabels": {"app": "web"}}}]`,
+			expected:    [4]bool{false, true, true, false},
+		},
+		{
+			// The pod itself does not match the selector, so node-0 has the skew of 2.
+			name:        "hostname maxSkew 2 without self match",
+			labels:      map[string]string{"app": "db"},
+			constraints: `[{"maxSkew": 2, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "DoNotSchedule", "labelSelector": {"matchLabels": {"app": "web"}}}]`,
+			expected:    [4]bool{true, true, true, false},
+		},
+		{
+			name:        "ScheduleAnyway",
+			labels:      map[string]string{"app": "web"},
+			constraints: `[{"maxSkew": 1, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "ScheduleAnyway", "labelSelector": {"matchLabels": {"app": "web"}}}]`,
+			expected:    [4]bool{true, true, true, true},
+		},
+		{name: "no constraints", expected: [4]bool{true, true, true, true}},
+	}
+
+	for _, test := range tests {
+		infos := newTestCluster(true)
+		sched := newTestScheduler(infos)
+		predicate := sched.PodTopologySpreadPredicate()
+		pod := newTestPod("default", "pod", test.labels, test.constraints)
+
+		for i, name := range []string{"node-0", "node-1", "node-2", "node-3"} {
+			fits, reasons, err := predicate(pod, nil, infos[name])
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err.Error())
+			}
+			if fits != test.expected[i] {
+				t.Errorf("%s on %s: got: %v\nwant: %v", test.name, name, fits, test.expected[i])
+			}
+			if !fits && (len(reasons) != 1 || reasons[0] != ErrTopologySpreadConstraintsNotMatch) {
+				t.Errorf("%s on %s: got: %v\nwant: [%v]", test.name, name, reasons, ErrTopologySpreadConstraintsNotMatch)
+			}
+		}
+	}
+}
+
+func TestPodTopologySpreadPriority(t *testing.T) {
+	infos := newTestCluster(true)
+	sched := newTestScheduler(infos)
+	priorityMap, priorityReduce := sched.PodTopologySpreadPriority()
+	pod := newTestPod("default", "pod", map[string]string{"app": "web"},
+		`[{"maxSkew": 1, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "ScheduleAnyway", "labelSelector": {"matchLabels": {"app": "web"}}}]`)
+
+	names := []string{"node-0", "node-1", "node-2", "node-3"}
+	result := api.HostPriorityList{}
+	for _, name := range names {
+		prio, err := priorityMap(pod, nil, infos[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, prio)
+	}
+	if err := priorityReduce(pod, nil, infos, result); err != nil {
+		t.Fatal(err)
+	}
+
+	// node-0 has the most matching pods, and node-3 has no hostname label.
+	expected := []int{0, api.MaxPriority, api.MaxPriority, 0}
+	for i, prio := range result {
+		if prio.Host != names[i] || prio.Score != expected[i] {
+			t.Errorf("got: %+v\nwant: %s with score %d", prio, names[i], expected[i])
+		}
+	}
+}
+
+func TestInterPodAffinityWithMetadata(t *testing.T) {
+	webSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	tests := []struct {
+		name     string
+		affinity *v1.Affinity
+		// expected is whether the pod fits on node-0, node-1, and node-2.
+		expected [3]bool
+	}{
+		{
+			name: "anti-affinity on zone",
+			affinity: &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{LabelSelector: webSelector, TopologyKey: testZoneKey},
+				},
+			}},
+			expected: [3]bool{false, false, true},
+		},
+		{
+			// The selector matches the pod in the other namespace only if the namespace is given.
+			name: "affinity on hostname across namespaces",
+			affinity: &v1.Affinity{PodAffinity: &v1.PodAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{LabelSelector: webSelector, TopologyKey: testHostnameKey, Namespaces: []string{"other"}},
+				},
+			}},
+			expected: [3]bool{false, false, true},
+		},
+		{
+			name: "affinity on zone in the same namespace",
+			affinity: &v1.Affinity{PodAffinity: &v1.PodAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{LabelSelector: webSelector, TopologyKey: testZoneKey},
+				},
+			}},
+			expected: [3]bool{true, true, false},
+		},
+	}
+
+	for _, test := range tests {
+		infos := newTestCluster(false)
+		sched := newTestScheduler(infos)
+		predicate := predicates.NewPodAffinityPredicate(sched.NodeInfo(), sched.PodLister())
+
+		pod := newTestPod("default", "pod", map[string]string{"app": "db"}, "")
+		pod.Spec.Affinity = test.affinity
+		meta := sched.predicateMetaProducer(pod, infos)
+
+		for i, name := range []string{"node-0", "node-1", "node-2"} {
+			fits, _, err := predicate(pod, meta, infos[name])
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err.Error())
+			}
+			if fits != test.expected[i] {
+				t.Errorf("%s on %s: got: %v\nwant: %v", test.name, name, fits, test.expected[i])
+			}
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/clock"
 	"github.com/pfnet-research/k8s-cluster-simulator/pkg/metrics"
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/node"
 )
 
 // Submitter defines the submitter interface.
@@ -59,6 +60,13 @@ type UpdateEvent struct {
 	NewPod       *v1.Pod
 }
 
+// NodeOperationEvent represents an event of operating a node at runtime (e.g., cordoning or
+// draining it).
+type NodeOperationEvent struct {
+	NodeName  string
+	Operation node.Operation
+}
+
 // TerminateSubmitterEvent represents an event of terminating the submission process.
 type TerminateSubmitterEvent struct {
 }
@@ -66,4 +74,5 @@ type TerminateSubmitterEvent struct {
 func (s *SubmitEvent) IsSubmitterEvent() bool             { return true }
 func (d *DeleteEvent) IsSubmitterEvent() bool             { return true }
 func (u *UpdateEvent) IsSubmitterEvent() bool             { return true }
+func (n *NodeOperationEvent) IsSubmitterEvent() bool      { return true }
 func (t *TerminateSubmitterEvent) IsSubmitterEvent() bool { return true }
@@ -16,6 +16,7 @@ package util
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cpuguy83/strongerrors"
 	"github.com/pkg/errors"
@@ -52,7 +53,10 @@ func PodTotalResourceRequests(pod *v1.Pod) v1.ResourceList {
 
 // ResourceListSum returns the sum of two resource lists.
 func ResourceListSum(r1, r2 v1.ResourceList) v1.ResourceList {
-	sum := r1.DeepCopy()
+	sum := v1.ResourceList{}
+	if r1 != nil {
+		sum = r1.DeepCopy()
+	}
 	for r2Key, r2Val := range r2 {
 		if r1Val, ok := sum[r2Key]; ok {
 			r1Val.Add(r2Val)
@@ -103,3 +107,12 @@ func PodKey(pod *v1.Pod) (string, error) {
 func PodKeyFromNames(namespace string, name string) string {
 	return fmt.Sprintf("%s/%s", namespace, name)
 }
+
+// SplitPodKey splits the key built by PodKeyFromNames into the namespace and pod name.
+// Returns an empty namespace if the key has no namespace.
+func SplitPodKey(key string) (namespace string, name string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
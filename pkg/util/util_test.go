@@ -244,3 +244,13 @@ func TestPodKey(t *testing.T) {
 	})
 	assert.EqualError(t, err, "Empty pod name")
 }
+
+func TestSplitPodKey(t *testing.T) {
+	namespace, name := util.SplitPodKey(util.PodKeyFromNames("namespace-0", "name-0"))
+	assert.Equal(t, "namespace-0", namespace)
+	assert.Equal(t, "name-0", name)
+
+	namespace, name = util.SplitPodKey("name-0")
+	assert.Equal(t, "", namespace)
+	assert.Equal(t, "name-0", name)
+}
@@ -0,0 +1,407 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package volume
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/kubernetes/pkg/controller/volume/persistentvolume"
+	"k8s.io/kubernetes/pkg/scheduler/algorithm/predicates"
+	"k8s.io/kubernetes/pkg/scheduler/volumebinder"
+	volumeutil "k8s.io/kubernetes/pkg/volume/util"
+
+	"github.com/pfnet-research/k8s-cluster-simulator/pkg/util"
+)
+
+// Store is the simulated persistent volumes, persistent volume claims, and storage classes of a
+// cluster, with the volume binding semantics of kubernetes.
+// Claims of storage classes with the Immediate binding mode are bound by BindImmediateClaims as the
+// persistent volume controller does, and the ones with the WaitForFirstConsumer binding mode are
+// bound when the pods using them are bound to nodes, as the volume binder of kube-scheduler does.
+// Volumes are not provisioned dynamically.
+//
+// Store implements predicates.PersistentVolumeInfo, predicates.PersistentVolumeClaimInfo,
+// predicates.StorageClassInfo, and persistentvolume.SchedulerVolumeBinder, so that it can be passed
+// to the volume predicates of kubernetes (e.g., predicates.NewVolumeBindingPredicate with
+// VolumeBinder).
+type Store struct {
+	nodes predicates.NodeInfo
+
+	classes map[string]*storagev1.StorageClass
+	// attachDurations is the time to attach a volume of each storage class to a node.
+	attachDurations map[string]time.Duration
+	pvs             map[string]*v1.PersistentVolume
+	pvcs            map[string]*v1.PersistentVolumeClaim
+
+	// assumed is the bindings of the claims of each pod to volumes, which have been chosen for the
+	// node of the pod but not bound yet.
+	assumed map[string][]binding
+	// assumedPVs is the set of the volumes in assumed.
+	assumedPVs map[string]struct{}
+}
+
+// binding is a binding of a claim to a volume.
+type binding struct {
+	pvc *v1.PersistentVolumeClaim
+	pv  *v1.PersistentVolume
+}
+
+// NewStore creates a new empty Store, which gets nodes from the NodeInfo.
+func NewStore(nodes predicates.NodeInfo) *Store {
+	return &Store{
+		nodes:           nodes,
+		classes:         map[string]*storagev1.StorageClass{},
+		attachDurations: map[string]time.Duration{},
+		pvs:             map[string]*v1.PersistentVolume{},
+		pvcs:            map[string]*v1.PersistentVolumeClaim{},
+		assumed:         map[string][]binding{},
+		assumedPVs:      map[string]struct{}{},
+	}
+}
+
+// AddStorageClass adds the storage class to this Store, with the time to attach its volumes to a
+// node.
+// Its volume binding mode defaults to Immediate.
+func (s *Store) AddStorageClass(class *storagev1.StorageClass, attachDuration time.Duration) {
+	if class.VolumeBindingMode == nil {
+		mode := storagev1.VolumeBindingImmediate
+		class.VolumeBindingMode = &mode
+	}
+
+	s.classes[class.Name] = class
+	s.attachDurations[class.Name] = attachDuration
+}
+
+// AddPersistentVolume adds the persistent volume to this Store.
+// The volume is Bound if it has a claim reference, or Available otherwise.
+func (s *Store) AddPersistentVolume(pv *v1.PersistentVolume) {
+	if pv.Spec.ClaimRef != nil {
+		pv.Status.Phase = v1.VolumeBound
+	} else {
+		pv.Status.Phase = v1.VolumeAvailable
+	}
+
+	s.pvs[pv.Name] = pv
+}
+
+// AddPersistentVolumeClaim adds the persistent volume claim to this Store.
+// If the claim specifies a volume that exists, it is bound to the volume.
+func (s *Store) AddPersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) {
+	pvc.Status.Phase = v1.ClaimPending
+	s.pvcs[util.PodKeyFromNames(pvc.Namespace, pvc.Name)] = pvc
+
+	if pv, ok := s.pvs[pvc.Spec.VolumeName]; ok && pvc.Spec.VolumeName != "" {
+		s.bind(pvc, pv)
+	}
+}
+
+// BindImmediateClaims binds the pending claims of storage classes with the Immediate binding mode
+// to matching available volumes, as the persistent volume controller of kubernetes does.
+// Returns true if any claim has been bound.
+func (s *Store) BindImmediateClaims() bool {
+	keys := make([]string, 0, len(s.pvcs))
+	for key := range s.pvcs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bound := false
+	for _, key := range keys {
+		pvc := s.pvcs[key]
+		if pvc.Spec.VolumeName != "" || s.delayBinding(pvc) {
+			continue
+		}
+
+		if pv := s.findMatchingVolume(pvc, nil, nil); pv != nil {
+			s.bind(pvc, pv)
+			bound = true
+		}
+	}
+
+	return bound
+}
+
+// AttachDuration returns the time to attach the volumes of the pod to its node, i.e., the longest
+// attach time of the storage classes of its claims, as volumes are attached in parallel.
+func (s *Store) AttachDuration(pod *v1.Pod) time.Duration {
+	duration := time.Duration(0)
+	for _, pvc := range s.podClaims(pod) {
+		if pvc == nil {
+			continue
+		}
+		if d := s.attachDurations[storageClassName(pvc)]; d > duration {
+			duration = d
+		}
+	}
+
+	return duration
+}
+
+// VolumeBinder returns the volume binder of kube-scheduler backed by this Store.
+func (s *Store) VolumeBinder() *volumebinder.VolumeBinder {
+	return &volumebinder.VolumeBinder{Binder: s}
+}
+
+// GetPersistentVolumeInfo implements predicates.PersistentVolumeInfo interface.
+func (s *Store) GetPersistentVolumeInfo(pvID string) (*v1.PersistentVolume, error) {
+	pv, ok := s.pvs[pvID]
+	if !ok {
+		return nil, fmt.Errorf("PersistentVolume %q not found", pvID)
+	}
+	return pv, nil
+}
+
+// GetPersistentVolumeClaimInfo implements predicates.PersistentVolumeClaimInfo interface.
+func (s *Store) GetPersistentVolumeClaimInfo(namespace string, name string) (*v1.PersistentVolumeClaim, error) {
+	pvc, ok := s.pvcs[util.PodKeyFromNames(namespace, name)]
+	if !ok {
+		return nil, fmt.Errorf("PersistentVolumeClaim %q not found", util.PodKeyFromNames(namespace, name))
+	}
+	return pvc, nil
+}
+
+// GetStorageClassInfo implements predicates.StorageClassInfo interface.
+func (s *Store) GetStorageClassInfo(className string) (*storagev1.StorageClass, error) {
+	class, ok := s.classes[className]
+	if !ok {
+		return nil, fmt.Errorf("StorageClass %q not found", className)
+	}
+	return class, nil
+}
+
+// FindPodVolumes implements persistentvolume.SchedulerVolumeBinder interface.
+// Claims that do not exist, and unbound claims of storage classes with the Immediate binding mode
+// are not satisfied.
+func (s *Store) FindPodVolumes(pod *v1.Pod, node *v1.Node) (bool, bool, error) {
+	_, unboundSatisfied, boundSatisfied := s.findPodBindings(pod, node)
+	return unboundSatisfied, boundSatisfied, nil
+}
+
+// AssumePodVolumes implements persistentvolume.SchedulerVolumeBinder interface.
+// Returns error if the node does not exist, or the volumes of the pod are not satisfied on it.
+func (s *Store) AssumePodVolumes(assumedPod *v1.Pod, nodeName string) (bool, error) {
+	node, err := s.nodes.GetNodeInfo(nodeName)
+	if err != nil {
+		return false, err
+	}
+
+	bindings, unboundSatisfied, boundSatisfied := s.findPodBindings(assumedPod, node)
+	if !unboundSatisfied || !boundSatisfied {
+		return false, fmt.Errorf("Volumes of pod %s are not satisfied on node %s",
+			util.PodKeyFromNames(assumedPod.Namespace, assumedPod.Name), nodeName)
+	}
+	if len(bindings) == 0 {
+		return true, nil
+	}
+
+	s.assumed[util.PodKeyFromNames(assumedPod.Namespace, assumedPod.Name)] = bindings
+	for _, b := range bindings {
+		s.assumedPVs[b.pv.Name] = struct{}{}
+	}
+
+	return false, nil
+}
+
+// BindPodVolumes implements persistentvolume.SchedulerVolumeBinder interface.
+// Binds the claims of the pod to the volumes assumed by AssumePodVolumes.
+func (s *Store) BindPodVolumes(assumedPod *v1.Pod) error {
+	key := util.PodKeyFromNames(assumedPod.Namespace, assumedPod.Name)
+	for _, b := range s.assumed[key] {
+		delete(s.assumedPVs, b.pv.Name)
+		if b.pvc.Spec.VolumeName != "" || b.pv.Spec.ClaimRef != nil {
+			return fmt.Errorf("PersistentVolumeClaim %s or PersistentVolume %s has been bound",
+				util.PodKeyFromNames(b.pvc.Namespace, b.pvc.Name), b.pv.Name)
+		}
+		s.bind(b.pvc, b.pv)
+	}
+	delete(s.assumed, key)
+
+	return nil
+}
+
+// GetBindingsCache implements persistentvolume.SchedulerVolumeBinder interface.
+// Returns nil, as this Store does not cache bindings across scheduling cycles.
+func (s *Store) GetBindingsCache() persistentvolume.PodBindingCache {
+	return nil
+}
+
+var _ = persistentvolume.SchedulerVolumeBinder(&Store{})
+var _ = predicates.PersistentVolumeInfo(&Store{})
+var _ = predicates.PersistentVolumeClaimInfo(&Store{})
+var _ = predicates.StorageClassInfo(&Store{})
+
+// findPodBindings finds the volumes to which the unbound claims of the pod are bound on the node.
+// Returns the bindings, whether the unbound claims are satisfied, and whether the bound claims are
+// satisfied.
+func (s *Store) findPodBindings(pod *v1.Pod, node *v1.Node) ([]binding, bool, bool) {
+	bindings := []binding{}
+	unboundSatisfied, boundSatisfied := true, true
+
+	chosen := map[string]struct{}{}
+	for _, pvc := range s.podClaims(pod) {
+		if pvc == nil {
+			unboundSatisfied = false
+			continue
+		}
+
+		if pvc.Spec.VolumeName != "" {
+			pv, ok := s.pvs[pvc.Spec.VolumeName]
+			if !ok || volumeutil.CheckNodeAffinity(pv, nodeLabels(node)) != nil {
+				boundSatisfied = false
+			}
+			continue
+		}
+
+		if !s.delayBinding(pvc) {
+			unboundSatisfied = false
+			continue
+		}
+
+		pv := s.findMatchingVolume(pvc, node, chosen)
+		if pv == nil {
+			unboundSatisfied = false
+			continue
+		}
+		chosen[pv.Name] = struct{}{}
+		bindings = append(bindings, binding{pvc: pvc, pv: pv})
+	}
+
+	return bindings, unboundSatisfied, boundSatisfied
+}
+
+// findMatchingVolume finds the smallest available volume that satisfies the claim, is accessible
+// from the node (unless node is nil), and is not excluded.
+// Returns nil if no volume matches.
+func (s *Store) findMatchingVolume(
+	pvc *v1.PersistentVolumeClaim, node *v1.Node, excluded map[string]struct{},
+) *v1.PersistentVolume {
+
+	request := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+
+	var smallest *v1.PersistentVolume
+	for _, pv := range s.pvs {
+		if _, ok := excluded[pv.Name]; ok {
+			continue
+		}
+		if _, ok := s.assumedPVs[pv.Name]; ok || pv.Spec.ClaimRef != nil {
+			continue
+		}
+		if pv.Spec.StorageClassName != storageClassName(pvc) {
+			continue
+		}
+		if !containsAccessModes(pv.Spec.AccessModes, pvc.Spec.AccessModes) {
+			continue
+		}
+		if node != nil && volumeutil.CheckNodeAffinity(pv, nodeLabels(node)) != nil {
+			continue
+		}
+
+		capacity := pv.Spec.Capacity[v1.ResourceStorage]
+		if capacity.Cmp(request) < 0 {
+			continue
+		}
+
+		if smallest == nil {
+			smallest = pv
+			continue
+		}
+		smallestCapacity := smallest.Spec.Capacity[v1.ResourceStorage]
+		if c := capacity.Cmp(smallestCapacity); c < 0 || (c == 0 && pv.Name < smallest.Name) {
+			smallest = pv
+		}
+	}
+
+	return smallest
+}
+
+// bind binds the claim to the volume.
+func (s *Store) bind(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) {
+	pv.Spec.ClaimRef = &v1.ObjectReference{
+		Kind:      "PersistentVolumeClaim",
+		Namespace: pvc.Namespace,
+		Name:      pvc.Name,
+		UID:       pvc.UID,
+	}
+	pv.Status.Phase = v1.VolumeBound
+
+	pvc.Spec.VolumeName = pv.Name
+	pvc.Status.Phase = v1.ClaimBound
+	pvc.Status.AccessModes = pv.Spec.AccessModes
+	pvc.Status.Capacity = pv.Spec.Capacity
+}
+
+// delayBinding returns whether the claim is bound when its pod is scheduled, i.e., its storage
+// class has the WaitForFirstConsumer binding mode.
+func (s *Store) delayBinding(pvc *v1.PersistentVolumeClaim) bool {
+	class, ok := s.classes[storageClassName(pvc)]
+	return ok && *class.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer
+}
+
+// podClaims returns the claims used by the pod, with nil for the ones that do not exist.
+func (s *Store) podClaims(pod *v1.Pod) []*v1.PersistentVolumeClaim {
+	pvcs := []*v1.PersistentVolumeClaim{}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvcs = append(pvcs, s.pvcs[util.PodKeyFromNames(pod.Namespace, vol.PersistentVolumeClaim.ClaimName)])
+	}
+
+	return pvcs
+}
+
+// storageClassName returns the name of the storage class of the claim.
+func storageClassName(pvc *v1.PersistentVolumeClaim) string {
+	if pvc.Spec.StorageClassName == nil {
+		return ""
+	}
+	return *pvc.Spec.StorageClassName
+}
+
+// containsAccessModes returns whether the modes contain all of the requested modes.
+func containsAccessModes(modes, requested []v1.PersistentVolumeAccessMode) bool {
+	for _, r := range requested {
+		found := false
+		for _, m := range modes {
+			if m == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodeLabels returns the labels of the node, with the hostname label defaulting to its name as the
+// kubelet sets.
+func nodeLabels(node *v1.Node) map[string]string {
+	labels := make(map[string]string, len(node.Labels)+1)
+	for k, v := range node.Labels {
+		labels[k] = v
+	}
+	if _, ok := labels[v1.LabelHostname]; !ok {
+		labels[v1.LabelHostname] = node.Name
+	}
+
+	return labels
+}
@@ -0,0 +1,250 @@
+// Copyright 2019 Preferred Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package volume
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testNodes is a predicates.NodeInfo of nodes in zones, keyed by their names.
+type testNodes map[string]string
+
+// GetNodeInfo implements predicates.NodeInfo interface.
+func (nodes testNodes) GetNodeInfo(name string) (*v1.Node, error) {
+	zone, ok := nodes[name]
+	if !ok {
+		return nil, fmt.Errorf("No node named %q", name)
+	}
+
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{v1.LabelZoneFailureDomain: zone},
+	}}, nil
+}
+
+// newTestStore creates a new Store of the nodes node-a in zone-a and node-b in zone-b, with the
+// storage class "standard" of the binding mode, whose volumes are attached in 10 seconds.
+func newTestStore(mode storagev1.VolumeBindingMode) *Store {
+	store := NewStore(testNodes{"node-a": "zone-a", "node-b": "zone-b"})
+	store.AddStorageClass(&storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: "standard"},
+		VolumeBindingMode: &mode,
+	}, 10*time.Second)
+
+	return store
+}
+
+// newTestPV creates a volume of the storage class "standard" with the capacity, which is accessible
+// only from the zone.
+func newTestPV(name, capacity, zone string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity:         v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)},
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: "standard",
+			NodeAffinity: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{{
+						MatchExpressions: []v1.NodeSelectorRequirement{{
+							Key:      v1.LabelZoneFailureDomain,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{zone},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// newTestPVC creates a claim of the storage class "standard" with the request.
+func newTestPVC(name, request string) *v1.PersistentVolumeClaim {
+	className := "standard"
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources:        v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(request)}},
+			StorageClassName: &className,
+		},
+	}
+}
+
+// newTestPod creates a pod using the claims.
+func newTestPod(name string, claimNames ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	for _, claimName := range claimNames {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name: claimName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			},
+		})
+	}
+
+	return pod
+}
+
+func TestWaitForFirstConsumer(t *testing.T) {
+	store := newTestStore(storagev1.VolumeBindingWaitForFirstConsumer)
+	store.AddPersistentVolume(newTestPV("pv-a", "10Gi", "zone-a"))
+	store.AddPersistentVolume(newTestPV("pv-b-small", "10Gi", "zone-b"))
+	store.AddPersistentVolume(newTestPV("pv-b-large", "20Gi", "zone-b"))
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-0", "5Gi"))
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-1", "5Gi"))
+
+	// The claims are not bound until their pods are bound to nodes.
+	if store.BindImmediateClaims() {
+		t.Errorf("got: claims bound immediately\nwant: not bound")
+	}
+
+	pod0 := newTestPod("pod-0", "pvc-0")
+	pod1 := newTestPod("pod-1", "pvc-1")
+	nodeB, _ := store.nodes.GetNodeInfo("node-b")
+	if unboundSatisfied, boundSatisfied, _ := store.FindPodVolumes(pod0, nodeB); !unboundSatisfied || !boundSatisfied {
+		t.Errorf("got: %v, %v\nwant: satisfied on node-b", unboundSatisfied, boundSatisfied)
+	}
+
+	// The smallest volume in zone-b is assumed for pod-0, and excluded from the ones for pod-1.
+	for _, pod := range []*v1.Pod{pod0, pod1} {
+		if allBound, err := store.AssumePodVolumes(pod, "node-b"); err != nil || allBound {
+			t.Fatalf("got: %v, %v\nwant: assumed", allBound, err)
+		}
+	}
+	for _, pod := range []*v1.Pod{pod0, pod1} {
+		if err := store.BindPodVolumes(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for claim, want := range map[string]string{"pvc-0": "pv-b-small", "pvc-1": "pv-b-large"} {
+		pvc, _ := store.GetPersistentVolumeClaimInfo("default", claim)
+		if pvc.Spec.VolumeName != want || pvc.Status.Phase != v1.ClaimBound {
+			t.Errorf("%s: got: %q, %s\nwant: %q, Bound", claim, pvc.Spec.VolumeName, pvc.Status.Phase, want)
+		}
+		pv, _ := store.GetPersistentVolumeInfo(want)
+		if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.Name != claim || pv.Status.Phase != v1.VolumeBound {
+			t.Errorf("%s: got: %v, %s\nwant: bound to %s", want, pv.Spec.ClaimRef, pv.Status.Phase, claim)
+		}
+	}
+
+	// The bound claim is satisfied only in the zone of its volume.
+	nodeA, _ := store.nodes.GetNodeInfo("node-a")
+	if _, boundSatisfied, _ := store.FindPodVolumes(pod0, nodeA); boundSatisfied {
+		t.Errorf("got: satisfied on node-a\nwant: not satisfied")
+	}
+}
+
+func TestAssumePodVolumesInWrongZone(t *testing.T) {
+	store := newTestStore(storagev1.VolumeBindingWaitForFirstConsumer)
+	store.AddPersistentVolume(newTestPV("pv-a", "10Gi", "zone-a"))
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-0", "5Gi"))
+	pod := newTestPod("pod-0", "pvc-0")
+
+	// No volume is accessible from node-b, so the binding is rejected and nothing is assumed.
+	if _, err := store.AssumePodVolumes(pod, "node-b"); err == nil {
+		t.Errorf("got: no error\nwant: volumes not satisfied on node-b")
+	}
+	if err := store.BindPodVolumes(pod); err != nil {
+		t.Fatal(err)
+	}
+	if pvc, _ := store.GetPersistentVolumeClaimInfo("default", "pvc-0"); pvc.Spec.VolumeName != "" {
+		t.Errorf("got: %q\nwant: unbound", pvc.Spec.VolumeName)
+	}
+
+	// The retry on node-a succeeds.
+	if _, err := store.AssumePodVolumes(pod, "node-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.BindPodVolumes(pod); err != nil {
+		t.Fatal(err)
+	}
+	if pvc, _ := store.GetPersistentVolumeClaimInfo("default", "pvc-0"); pvc.Spec.VolumeName != "pv-a" {
+		t.Errorf("got: %q\nwant: %q", pvc.Spec.VolumeName, "pv-a")
+	}
+}
+
+func TestBindImmediateClaims(t *testing.T) {
+	store := newTestStore(storagev1.VolumeBindingImmediate)
+	store.AddPersistentVolume(newTestPV("pv-small", "10Gi", "zone-a"))
+	store.AddPersistentVolume(newTestPV("pv-large", "20Gi", "zone-b"))
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-large", "15Gi"))
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-small", "5Gi"))
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-too-large", "50Gi"))
+
+	// A pod using an unbound claim of the Immediate binding mode is not satisfied until the claim is
+	// bound.
+	pod := newTestPod("pod-0", "pvc-small")
+	nodeA, _ := store.nodes.GetNodeInfo("node-a")
+	if unboundSatisfied, _, _ := store.FindPodVolumes(pod, nodeA); unboundSatisfied {
+		t.Errorf("got: satisfied before binding\nwant: not satisfied")
+	}
+
+	if !store.BindImmediateClaims() {
+		t.Errorf("got: no claims bound\nwant: bound")
+	}
+	for claim, want := range map[string]string{"pvc-large": "pv-large", "pvc-small": "pv-small", "pvc-too-large": ""} {
+		if pvc, _ := store.GetPersistentVolumeClaimInfo("default", claim); pvc.Spec.VolumeName != want {
+			t.Errorf("%s: got: %q\nwant: %q", claim, pvc.Spec.VolumeName, want)
+		}
+	}
+	if store.BindImmediateClaims() {
+		t.Errorf("got: claims bound again\nwant: not bound")
+	}
+
+	if unboundSatisfied, boundSatisfied, _ := store.FindPodVolumes(pod, nodeA); !unboundSatisfied || !boundSatisfied {
+		t.Errorf("got: %v, %v\nwant: satisfied after binding", unboundSatisfied, boundSatisfied)
+	}
+}
+
+func TestAttachDuration(t *testing.T) {
+	store := newTestStore(storagev1.VolumeBindingImmediate)
+	fast := storagev1.VolumeBindingImmediate
+	store.AddStorageClass(&storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: "fast"},
+		VolumeBindingMode: &fast,
+	}, time.Second)
+
+	fastPVC := newTestPVC("pvc-fast", "5Gi")
+	className := "fast"
+	fastPVC.Spec.StorageClassName = &className
+	store.AddPersistentVolumeClaim(fastPVC)
+	store.AddPersistentVolumeClaim(newTestPVC("pvc-standard", "5Gi"))
+
+	tests := []struct {
+		pod  *v1.Pod
+		want time.Duration
+	}{
+		{newTestPod("no-volumes"), 0},
+		{newTestPod("fast", "pvc-fast"), time.Second},
+		// Volumes are attached in parallel.
+		{newTestPod("fast-and-standard", "pvc-fast", "pvc-standard"), 10 * time.Second},
+		{newTestPod("unknown-claim", "pvc-unknown"), 0},
+	}
+
+	for _, test := range tests {
+		if d := store.AttachDuration(test.pod); d != test.want {
+			t.Errorf("%s: got: %v\nwant: %v", test.pod.Name, d, test.want)
+		}
+	}
+}